@@ -2,17 +2,23 @@ package private
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
 
 	"github.com/zacksfF/FullStack-Blockchain/blockchain/database"
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/dht"
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/gossip"
 	"github.com/zacksfF/FullStack-Blockchain/blockchain/peer"
 	"github.com/zacksfF/FullStack-Blockchain/blockchain/state"
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/transport"
 	"github.com/zacksfF/FullStack-Blockchain/core/web/errs"
+	"github.com/zacksfF/FullStack-Blockchain/core/web/miidd"
 	"github.com/zacksfF/FullStack-Blockchain/nameservices"
 	"github.com/zacksfF/FullStack-Blockchain/web2"
+	"github.com/zacksfF/FullStack-Blockchain/webhook"
 	"go.uber.org/zap"
 )
 
@@ -21,6 +27,7 @@ type Handlers struct {
 	Log   *zap.SugaredLogger
 	State *state.State
 	NS    *nameservices.NameService
+	Hooks *webhook.Webhooks
 }
 
 // SubmitNodeTransaction adds new node transactions to the mempool.
@@ -39,9 +46,14 @@ func (h Handlers) SubmitNodeTransaction(ctx context.Context, w http.ResponseWrit
 	// Ask the state package to add this transaction to the mempool and perform
 	// any other business logic.
 	h.Log.Infow("add tran", "traceid", v.TraceID, "sig:nonce", tx, "fron", tx.FromID, "to", tx.ToID, "value", tx.Value, "tip", tx.Tip)
+	sender, _ := h.State.LookupNode(miidd.GetNodeID(ctx))
 	if err := h.State.UpsertNodeTransaction(tx); err != nil {
+		if state.IsMisbehavior(err) {
+			h.State.ScorePeer(sender, peer.ScoreInvalidTx)
+		}
 		return errs.NewTrusted(err, http.StatusBadRequest)
 	}
+	h.State.ScorePeer(sender, peer.ScoreUsefulTx)
 
 	resp := struct {
 		Status string `json:"status"`
@@ -69,15 +81,63 @@ func (h Handlers) ProposeBlock(ctx context.Context, w http.ResponseWriter, r *ht
 		return fmt.Errorf("unable to decode block: %w", err)
 	}
 
+	return h.acceptBlock(ctx, w, block)
+}
+
+// ProposeCompactBlock takes a block in compact form received from a peer and
+// rebuilds it from the mempool. When transactions are missing, the peer is
+// asked for them instead of the block being accepted.
+func (h Handlers) ProposeCompactBlock(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	var cb database.CompactBlock
+	if err := web2.Decode(r, &cb); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+
+	block, missing, err := h.State.ReconstructBlock(cb)
+	if err != nil {
+		sender, _ := h.State.LookupNode(miidd.GetNodeID(ctx))
+		h.State.ScorePeer(sender, peer.ScoreInvalidBlock)
+
+		return errs.NewTrusted(err, http.StatusBadRequest)
+	}
+
+	if len(missing) > 0 {
+		resp := struct {
+			Status  string `json:"status"`
+			Missing []int  `json:"missing"`
+		}{
+			Status:  "incomplete",
+			Missing: missing,
+		}
+
+		return web2.Respond(ctx, w, resp, http.StatusOK)
+	}
+
+	return h.acceptBlock(ctx, w, block)
+}
+
+// acceptBlock validates the block proposed by a peer and adds it to the
+// blockchain database.
+func (h Handlers) acceptBlock(ctx context.Context, w http.ResponseWriter, block database.Block) error {
+
 	// Ask the state package to validate the proposed block. If the block
 	// passes validation, it will be added to the blockchain database.
+	sender, _ := h.State.LookupNode(miidd.GetNodeID(ctx))
 	if err := h.State.ProcessProposedBlock(block); err != nil {
 		if errors.Is(err, database.ErrChainForked) {
 			h.State.Reorganize()
 		}
 
+		if state.IsMisbehavior(err) {
+			h.State.ScorePeer(sender, peer.ScoreInvalidBlock)
+		}
+
 		return errs.NewTrusted(errors.New("block not accepted"), http.StatusNotAcceptable)
 	}
+	h.State.ScorePeer(sender, peer.ScoreUsefulBlock)
+
+	// The block is new to this node so it is passed on to the network.
+	h.State.RelayBlock(block)
 
 	resp := struct {
 		Status string `json:"status"`
@@ -88,23 +148,122 @@ func (h Handlers) ProposeBlock(ctx context.Context, w http.ResponseWriter, r *ht
 	return web2.Respond(ctx, w, resp, http.StatusOK)
 }
 
-// SubmitPeer is called by a node so they can be added to the known peer list.
-func (h Handlers) SubmitPeer(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+// Inventory receives the hashes of transactions and blocks a peer has and
+// responds with the ones this node wants the peer to send.
+func (h Handlers) Inventory(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	var inv []gossip.Inventory
+	if err := web2.Decode(r, &inv); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+
+	return web2.Respond(ctx, w, h.State.WantedInventory(inv), http.StatusOK)
+}
+
+// Stream upgrades the connection from a peer into a persistent connection
+// that multiplexes the requests between the nodes.
+func (h Handlers) Stream(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web2.GetValues(ctx)
+	if err != nil {
+		return web2.NewShutdownError("web value missing from context")
+	}
+
+	// Only a known peer can open a connection, so the node has completed a
+	// handshake and its host is known.
+	pr, _ := h.State.LookupNode(miidd.GetNodeID(ctx))
+
+	if err := h.State.AcceptStream(w, r, pr); err != nil {
+		switch {
+		case errors.Is(err, state.ErrNoTransport):
+			return errs.NewTrusted(err, http.StatusNotFound)
+		case errors.Is(err, transport.ErrTooManyConns):
+			return errs.NewTrusted(err, http.StatusServiceUnavailable)
+		}
+		h.Log.Infow("stream", "traceid", v.TraceID, "host", pr.Host, "ERROR", err)
+	}
+
+	return nil
+}
+
+// Handshake is called by a node so they can be added to the known peer list.
+// Nodes running a different blockchain or protocol are refused.
+func (h Handlers) Handshake(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
 	v, err := web2.GetValues(ctx)
 	if err != nil {
 		return web2.NewShutdownError("web value missing from context")
 	}
 
-	var peer peer.Peer
-	if err := web2.Decode(r, &peer); err != nil {
+	var hs peer.Handshake
+	if err := web2.Decode(r, &hs); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+
+	// The node sending the handshake must be the node it identifies.
+	if nodeID := miidd.GetNodeID(ctx); nodeID != hs.NodeID {
+		return errs.NewTrusted(fmt.Errorf("%w: node id[%s] sent by[%s]", peer.ErrHandshake, hs.NodeID, nodeID), http.StatusConflict)
+	}
+
+	if err := h.State.AcceptHandshake(hs); err != nil {
+		h.Log.Infow("refusing peer", "traceid", v.TraceID, "host", hs.Host, "node", hs.NodeID, "ERROR", err)
+		if errors.Is(err, peer.ErrPeerLimit) {
+			return errs.NewTrusted(err, http.StatusServiceUnavailable)
+		}
+		return errs.NewTrusted(err, http.StatusConflict)
+	}
+
+	return web2.Respond(ctx, w, h.State.Handshake(), http.StatusOK)
+}
+
+// PeerScores returns the score and ban status of the peers.
+func (h Handlers) PeerScores(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	return web2.Respond(ctx, w, h.State.PeerScores(), http.StatusOK)
+}
+
+// Webhooks returns the delivery status of every webhook.
+func (h Handlers) Webhooks(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	statuses := []webhook.HookStatus{}
+	if h.Hooks != nil {
+		statuses = h.Hooks.Status()
+	}
+
+	return web2.Respond(ctx, w, statuses, http.StatusOK)
+}
+
+// WebhookDeliveries returns the pending and recent deliveries of a webhook.
+func (h Handlers) WebhookDeliveries(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	if h.Hooks == nil {
+		return errs.NewTrusted(webhook.ErrHookNotFound, http.StatusNotFound)
+	}
+
+	deliveries, err := h.Hooks.Deliveries(web2.Param(r, "id"))
+	if err != nil {
+		if errors.Is(err, webhook.ErrHookNotFound) {
+			return errs.NewTrusted(err, http.StatusNotFound)
+		}
+		return err
+	}
+
+	return web2.Respond(ctx, w, deliveries, http.StatusOK)
+}
+
+// FindNode returns the contacts this node knows closest to the target, which
+// a peer uses to discover other nodes.
+func (h Handlers) FindNode(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	var req dht.FindRequest
+	if err := web2.Decode(r, &req); err != nil {
 		return fmt.Errorf("unable to decode payload: %w", err)
 	}
 
-	if !h.State.AddKnownPeer(peer) {
-		h.Log.Infow("adding peer", "traceid", v.TraceID, "host", peer.Host)
+	contacts, err := h.State.FindNode(miidd.GetNodeID(ctx), req.Target)
+	if err != nil {
+		return errs.NewTrusted(err, http.StatusBadRequest)
 	}
 
-	return web2.Respond(ctx, w, nil, http.StatusOK)
+	return web2.Respond(ctx, w, contacts, http.StatusOK)
+}
+
+// SyncProgress returns the progress of the current or last sync of the chain.
+func (h Handlers) SyncProgress(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	return web2.Respond(ctx, w, h.State.SyncProgress(), http.StatusOK)
 }
 
 // Status returns the current status of the node.
@@ -114,14 +273,80 @@ func (h Handlers) Status(ctx context.Context, w http.ResponseWriter, r *http.Req
 	status := peer.PeerStatus{
 		LatestBlockHash:   latestBlock.Hash(),
 		LatestBlockNumber: latestBlock.Header.Number,
-		KnownPeers:        h.State.KnownExternalPeers(),
 	}
 
 	return web2.Respond(ctx, w, status, http.StatusOK)
 }
 
-// BlocksByNumber returns all the blocks based on the specified to/from values.
+// BlocksByNumber returns the blocks based on the specified to/from values.
+// Only the leading blocks that fit into a message to a peer are returned, so
+// the peer asks again for the rest.
 func (h Handlers) BlocksByNumber(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	from, to, err := blockRange(r)
+	if err != nil {
+		return err
+	}
+
+	blocks := h.State.QueryBlocksByNumber(from, to)
+	if len(blocks) == 0 {
+		return web2.Respond(ctx, w, nil, http.StatusNoContent)
+	}
+
+	blockData := make([]database.BlockData, len(blocks))
+	for i, block := range blocks {
+		blockData[i] = database.NewBlockData(block)
+	}
+
+	return web2.Respond(ctx, w, fitMessage(blockData), http.StatusOK)
+}
+
+// BlockHeadersByNumber returns the headers for the range of blocks, so a
+// peer can validate the chain before downloading the blocks.
+func (h Handlers) BlockHeadersByNumber(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	from, to, err := blockRange(r)
+	if err != nil {
+		return err
+	}
+
+	headers := h.State.QueryHeadersByNumber(from, to)
+	if len(headers) == 0 {
+		return web2.Respond(ctx, w, nil, http.StatusNoContent)
+	}
+
+	return web2.Respond(ctx, w, headers, http.StatusOK)
+}
+
+// Mempool returns the set of uncommitted transactions. Only the best
+// transactions that fit into a message to a peer are returned.
+func (h Handlers) Mempool(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	txs := h.State.Mempool()
+	return web2.Respond(ctx, w, fitMessage(txs), http.StatusOK)
+}
+
+// =============================================================================
+
+// fitMessage returns the leading values that fit into a message to a peer,
+// which is no larger than the largest valid block. The first value is always
+// returned.
+func fitMessage[T any](values []T) []T {
+	size := 0
+	for i, v := range values {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return values[:i]
+		}
+
+		size += len(data) + 1
+		if size > database.MaxBlockSize && i > 0 {
+			return values[:i]
+		}
+	}
+
+	return values
+}
+
+// blockRange returns the range of block numbers from the request.
+func blockRange(r *http.Request) (uint64, uint64, error) {
 	fromStr := web2.Param(r, "from")
 	if fromStr == "latest" || fromStr == "" {
 		fromStr = fmt.Sprintf("%d", state.QueryLastest)
@@ -134,32 +359,16 @@ func (h Handlers) BlocksByNumber(ctx context.Context, w http.ResponseWriter, r *
 
 	from, err := strconv.ParseUint(fromStr, 10, 64)
 	if err != nil {
-		return errs.NewTrusted(err, http.StatusBadRequest)
+		return 0, 0, errs.NewTrusted(err, http.StatusBadRequest)
 	}
 	to, err := strconv.ParseUint(toStr, 10, 64)
 	if err != nil {
-		return errs.NewTrusted(err, http.StatusBadRequest)
+		return 0, 0, errs.NewTrusted(err, http.StatusBadRequest)
 	}
 
 	if from > to {
-		return errs.NewTrusted(errors.New("from greater than to"), http.StatusBadRequest)
+		return 0, 0, errs.NewTrusted(errors.New("from greater than to"), http.StatusBadRequest)
 	}
 
-	blocks := h.State.QueryBlocksByNumber(from, to)
-	if len(blocks) == 0 {
-		return web2.Respond(ctx, w, nil, http.StatusNoContent)
-	}
-
-	blockData := make([]database.BlockData, len(blocks))
-	for i, block := range blocks {
-		blockData[i] = database.NewBlockData(block)
-	}
-
-	return web2.Respond(ctx, w, blockData, http.StatusOK)
-}
-
-// Mempool returns the set of uncommitted transactions.
-func (h Handlers) Mempool(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
-	txs := h.State.Mempool()
-	return web2.Respond(ctx, w, txs, http.StatusOK)
+	return from, to, nil
 }
@@ -4,9 +4,11 @@ import (
 	"net/http"
 
 	"github.com/zacksfF/FullStack-Blockchain/blockchain/state"
+	"github.com/zacksfF/FullStack-Blockchain/core/web/miidd"
 	"github.com/zacksfF/FullStack-Blockchain/events"
 	"github.com/zacksfF/FullStack-Blockchain/nameservices"
 	"github.com/zacksfF/FullStack-Blockchain/web2"
+	"github.com/zacksfF/FullStack-Blockchain/webhook"
 	"go.uber.org/zap"
 )
 
@@ -16,6 +18,7 @@ type Config struct {
 	State *state.State
 	NS    *nameservices.NameService
 	Evts  *events.Events
+	Hooks *webhook.Webhooks
 }
 
 // Routes binds all the private routes.
@@ -24,14 +27,34 @@ func Routes(app *web2.App, cfg Config) {
 		Log:   cfg.Log,
 		State: cfg.State,
 		NS:    cfg.NS,
+		Hooks: cfg.Hooks,
 	}
 
 	const version = "v1"
 
-	app.Handle(http.MethodPost, version, "/node/peers", prv.SubmitPeer)
-	app.Handle(http.MethodGet, version, "/node/status", prv.Status)
-	app.Handle(http.MethodGet, version, "/node/block/list/:from/:to", prv.BlocksByNumber)
-	app.Handle(http.MethodPost, version, "/node/block/propose", prv.ProposeBlock)
-	app.Handle(http.MethodPost, version, "/node/tx/submit", prv.SubmitNodeTransaction)
-	app.Handle(http.MethodGet, version, "/node/tx/list", prv.Mempool)
+	// Requests from peers must be signed with their node key. Only the
+	// handshake is accepted from a node that is not a known peer yet. The
+	// sync progress and webhook status can also be read by the operator by
+	// signing with the key of this node.
+	signed := miidd.Node(nil)
+	known := miidd.Node(cfg.State.IsKnownNode)
+	operator := miidd.Node(func(nodeID string) bool {
+		return nodeID == cfg.State.NodeID() || cfg.State.IsKnownNode(nodeID)
+	})
+
+	app.Handle(http.MethodPost, version, "/node/handshake", prv.Handshake, signed)
+	app.Handle(http.MethodGet, version, "/node/stream", prv.Stream, known)
+	app.Handle(http.MethodGet, version, "/node/peers/scores", prv.PeerScores, known)
+	app.Handle(http.MethodGet, version, "/node/sync/progress", prv.SyncProgress, operator)
+	app.Handle(http.MethodGet, version, "/node/webhooks", prv.Webhooks, operator)
+	app.Handle(http.MethodGet, version, "/node/webhooks/:id/deliveries", prv.WebhookDeliveries, operator)
+	app.Handle(http.MethodGet, version, "/node/status", prv.Status, known)
+	app.Handle(http.MethodPost, version, "/node/inv", prv.Inventory, known)
+	app.Handle(http.MethodPost, version, "/node/dht/find", prv.FindNode, known)
+	app.Handle(http.MethodGet, version, "/node/block/list/:from/:to", prv.BlocksByNumber, known)
+	app.Handle(http.MethodGet, version, "/node/block/headers/:from/:to", prv.BlockHeadersByNumber, known)
+	app.Handle(http.MethodPost, version, "/node/block/propose", prv.ProposeBlock, known)
+	app.Handle(http.MethodPost, version, "/node/block/compact", prv.ProposeCompactBlock, known)
+	app.Handle(http.MethodPost, version, "/node/tx/submit", prv.SubmitNodeTransaction, known)
+	app.Handle(http.MethodGet, version, "/node/tx/list", prv.Mempool, known)
 }
@@ -0,0 +1,205 @@
+package public
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/database"
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/state"
+	"github.com/zacksfF/FullStack-Blockchain/core/web/errs"
+	"github.com/zacksfF/FullStack-Blockchain/web2"
+)
+
+// Limits for the number of blocks returned in a page.
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+// Blocks returns a page of blocks in order starting with the block number
+// in the from query parameter. The next field holds the value of from for
+// the following page and is left out on the last page. Pages don't change
+// as the chain grows, so the pages already read stay valid.
+func (h Handlers) Blocks(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	from, err := queryUint(r, "from", 1)
+	if err != nil {
+		return errs.NewTrusted(err, http.StatusBadRequest)
+	}
+	if from == 0 {
+		from = 1
+	}
+
+	limit, err := queryUint(r, "limit", defaultPageLimit)
+	if err != nil {
+		return errs.NewTrusted(err, http.StatusBadRequest)
+	}
+	if limit == 0 || limit > maxPageLimit {
+		return errs.NewTrusted(fmt.Errorf("limit must be between 1 and %d", maxPageLimit), http.StatusBadRequest)
+	}
+
+	page := blockPage{
+		Blocks: []blockInfo{},
+	}
+
+	latest := h.State.LatestBlock().Header.Number
+	if from > latest {
+		return web2.Respond(ctx, w, page, http.StatusOK)
+	}
+
+	to := min(from+limit-1, latest)
+	for _, block := range h.State.QueryBlocksByNumber(from, to) {
+		page.Blocks = append(page.Blocks, toBlockInfo(block))
+	}
+
+	if to < latest {
+		page.Next = strconv.FormatUint(to+1, 10)
+	}
+
+	return web2.Respond(ctx, w, page, http.StatusOK)
+}
+
+// BlockByNumber returns the block with the specified number.
+func (h Handlers) BlockByNumber(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	number, err := strconv.ParseUint(web2.Param(r, "number"), 10, 64)
+	if err != nil {
+		return errs.NewTrusted(errors.New("invalid block number"), http.StatusBadRequest)
+	}
+
+	block, err := h.State.QueryBlockByNumber(number)
+	if err != nil {
+		if errors.Is(err, database.ErrBlockNotFound) {
+			return errs.NewTrusted(err, http.StatusNotFound)
+		}
+		return err
+	}
+
+	return web2.Respond(ctx, w, toBlockInfo(block), http.StatusOK)
+}
+
+// BlockByHash returns the block with the specified hash.
+func (h Handlers) BlockByHash(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	block, err := h.State.QueryBlockByHash(web2.Param(r, "hash"))
+	if err != nil {
+		if errors.Is(err, database.ErrBlockNotFound) {
+			return errs.NewTrusted(err, http.StatusNotFound)
+		}
+		return err
+	}
+
+	return web2.Respond(ctx, w, toBlockInfo(block), http.StatusOK)
+}
+
+// Tx returns the mined transaction with the specified hash along with the
+// proof it's part of the block.
+func (h Handlers) Tx(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	mtx, err := h.State.QueryMinedTx(web2.Param(r, "hash"))
+	if err != nil {
+		if errors.Is(err, state.ErrTxNotFound) {
+			return errs.NewTrusted(err, http.StatusNotFound)
+		}
+		return err
+	}
+
+	rawProof, order, err := mtx.Block.MerkleTree.Proof(mtx.Tx)
+	if err != nil {
+		return err
+	}
+	proof := make([]string, len(rawProof))
+	for i, rp := range rawProof {
+		proof[i] = hexutil.Encode(rp)
+	}
+
+	info := txInfo{
+		Hash:        mtx.Receipt.TxHash,
+		Status:      state.TxMined,
+		BlockNumber: mtx.Receipt.BlockNumber,
+		BlockHash:   mtx.Receipt.BlockHash,
+		Index:       mtx.Index,
+		tx: tx{
+			FromAccount: mtx.Tx.FromID,
+			FromName:    h.NS.Lookup(mtx.Tx.FromID),
+			To:          mtx.Tx.ToID,
+			ToName:      h.NS.Lookup(mtx.Tx.ToID),
+			ChainID:     mtx.Tx.ChainID,
+			Nonce:       mtx.Tx.Nonce,
+			Value:       mtx.Tx.Value,
+			Tip:         mtx.Tx.Tip,
+			Data:        mtx.Tx.Data,
+			ExpiryBlock: mtx.Tx.ExpiryBlock,
+			ExpiryTime:  mtx.Tx.ExpiryTime,
+			TimeStamp:   mtx.Tx.TimeStamp,
+			GasPrice:    mtx.Tx.GasPrice,
+			GasUnits:    mtx.Tx.GasUnits,
+			Sig:         mtx.Tx.SignatureString(),
+			Proof:       proof,
+			ProofOrder:  order,
+		},
+	}
+
+	if mtx.Receipt.Failed {
+		info.Status = state.TxFailed
+		info.Reason = mtx.Receipt.Error
+	}
+
+	return web2.Respond(ctx, w, info, http.StatusOK)
+}
+
+// =============================================================================
+
+// toBlockInfo converts the block into the form returned by the explorer.
+func toBlockInfo(block database.Block) blockInfo {
+	values := block.MerkleTree.Values()
+
+	hashes := make([]string, len(values))
+	for i, tx := range values {
+		hashes[i] = tx.TxHash()
+	}
+
+	return blockInfo{
+		Number:        block.Header.Number,
+		Hash:          block.Hash(),
+		PrevBlockHash: block.Header.PrevBlockHash,
+		TimeStamp:     block.Header.TimeStamp,
+		BeneficiaryID: block.Header.BeneficiaryID,
+		Difficulty:    block.Header.Difficulty,
+		MiningReward:  block.Header.MiningReward,
+		StateRoot:     block.Header.StateRoot,
+		TransRoot:     block.Header.TransRoot,
+		Nonce:         block.Header.Nonce,
+		TxCount:       len(hashes),
+		TxHashes:      hashes,
+	}
+}
+
+// queryUint returns the query parameter as a number or the default when the
+// parameter isn't provided.
+func queryUint(r *http.Request, key string, def uint64) (uint64, error) {
+	v := r.URL.Query().Get(key)
+	if v == "" {
+		return def, nil
+	}
+
+	n, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %q", key, v)
+	}
+
+	return n, nil
+}
+
+// queryList returns the comma separated values of the query parameter.
+func queryList(r *http.Request, key string) []string {
+	var values []string
+	for _, v := range strings.Split(r.URL.Query().Get(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+
+	return values
+}
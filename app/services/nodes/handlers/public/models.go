@@ -9,7 +9,10 @@ type act struct {
 	Nonce   uint64             `json:"nonce"`
 }
 
+// The misspelled lastest_block key is still sent for the clients that
+// were written against it.
 type actInfo struct {
+	LatestBlock  string `json:"latest_block"`
 	LastestBlock string `json:"lastest_block"`
 	Uncommitted  int    `json:"uncommitted"`
 	Accounts     []act  `json:"accounts"`
@@ -25,6 +28,8 @@ type tx struct {
 	Value       uint64             `json:"value"`
 	Tip         uint64             `json:"tip"`
 	Data        []byte             `json:"data"`
+	ExpiryBlock uint64             `json:"expiry_block,omitempty"`
+	ExpiryTime  uint64             `json:"expiry_time,omitempty"`
 	TimeStamp   uint64             `json:"timestamp"`
 	GasPrice    uint64             `json:"gas_price"`
 	GasUnits    uint64             `json:"gas_units"`
@@ -45,3 +50,44 @@ type block struct {
 	Nonce         uint64             `json:"nonce"`
 	Transactions  []tx               `json:"txs"`
 }
+
+type txStatus struct {
+	Hash          string `json:"hash"`
+	Status        string `json:"status"`
+	Position      *int   `json:"position,omitempty"`
+	BlockNumber   uint64 `json:"block_number,omitempty"`
+	BlockHash     string `json:"block_hash,omitempty"`
+	Confirmations uint64 `json:"confirmations,omitempty"`
+	ReplacedBy    string `json:"replaced_by,omitempty"`
+	Reason        string `json:"reason,omitempty"`
+}
+
+type blockInfo struct {
+	Number        uint64             `json:"number"`
+	Hash          string             `json:"hash"`
+	PrevBlockHash string             `json:"prev_block_hash"`
+	TimeStamp     uint64             `json:"timestamp"`
+	BeneficiaryID database.AccountID `json:"beneficiary"`
+	Difficulty    uint16             `json:"difficulty"`
+	MiningReward  uint64             `json:"mining_reward"`
+	StateRoot     string             `json:"state_root"`
+	TransRoot     string             `json:"trans_root"`
+	Nonce         uint64             `json:"nonce"`
+	TxCount       int                `json:"tx_count"`
+	TxHashes      []string           `json:"tx_hashes"`
+}
+
+type blockPage struct {
+	Blocks []blockInfo `json:"blocks"`
+	Next   string      `json:"next,omitempty"`
+}
+
+type txInfo struct {
+	Hash        string `json:"hash"`
+	Status      string `json:"status"`
+	Reason      string `json:"reason,omitempty"`
+	BlockNumber uint64 `json:"block_number"`
+	BlockHash   string `json:"block_hash"`
+	Index       int    `json:"index"`
+	tx
+}
@@ -2,6 +2,7 @@ package public
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
@@ -72,6 +73,98 @@ func (h Handlers) Events(ctx context.Context, w http.ResponseWriter, r *http.Req
 	}
 }
 
+// EventStream handles a web socket to provide typed events to a client as
+// JSON. The topics and accounts query parameters take a comma separated
+// list to limit the events to those topics and accounts. A client that
+// reconnects passes the stream and sequence number of the last event it saw
+// in the stream and since query parameters to first get the events it
+// missed. Events that are no longer kept, or were dropped because the client
+// was too slow, are replaced by a gap marker. A stream from before the node
+// restarted gets a reset marker.
+func (h Handlers) EventStream(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web2.GetValues(ctx)
+	if err != nil {
+		return web2.NewShutdownError("web value missing from context")
+	}
+
+	filter := events.Filter{
+		Topics:   queryList(r, "topics"),
+		Accounts: queryList(r, "accounts"),
+	}
+	if err := filter.Validate(); err != nil {
+		return errs.NewTrusted(err, http.StatusBadRequest)
+	}
+
+	// Register the subscriber before the upgrade so a bad sequence number
+	// can still be reported with a status code.
+	var ch chan events.Event
+	var backlog []events.Event
+	if r.URL.Query().Has("since") {
+		since, err := queryUint(r, "since", 0)
+		if err != nil {
+			return errs.NewTrusted(err, http.StatusBadRequest)
+		}
+
+		stream := r.URL.Query().Get("stream")
+		if stream == "" {
+			return errs.NewTrusted(errors.New("stream is required with since"), http.StatusBadRequest)
+		}
+
+		ch, backlog, err = h.Evts.Resume(v.TraceID, filter, stream, since)
+		if err != nil {
+			return errs.NewTrusted(err, http.StatusBadRequest)
+		}
+	} else {
+		ch = h.Evts.Subscribe(v.TraceID, filter)
+	}
+	defer func() {
+		h.Log.Infow("event stream", "traceid", v.TraceID, "dropped", h.Evts.Dropped(v.TraceID))
+		h.Evts.Release(v.TraceID)
+	}()
+
+	// Need this to handle CORS on the websocket.
+	h.WS.CheckOrigin = func(r *http.Request) bool { return true }
+
+	// This upgrades the HTTP connection to a websocket connection.
+	c, err := h.WS.Upgrade(w, r, nil)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	// Send the events the client missed before the ones that follow.
+	for _, ev := range backlog {
+		if err := c.WriteJSON(ev); err != nil {
+			return nil
+		}
+	}
+
+	// Starting a ticker to send a ping message over the websocket.
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	// Block waiting for events from the blockchain or ticker.
+	for {
+		select {
+		case ev, wd := <-ch:
+
+			// If the channel is closed, release the websocket.
+			if !wd {
+				return nil
+			}
+
+			if err := c.WriteJSON(ev); err != nil {
+				return nil
+			}
+
+		case <-ticker.C:
+			if err := c.WriteMessage(websocket.PingMessage, []byte("ping")); err != nil {
+				return nil
+			}
+		}
+	}
+}
+
 // SubmitWalletTransaction adds new transactions to the mempool.
 func (h Handlers) SubmitWalletTransaction(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
 	v, err := web2.GetValues(ctx)
@@ -104,6 +197,34 @@ func (h Handlers) SubmitWalletTransaction(ctx context.Context, w http.ResponseWr
 	return web2.Respond(ctx, w, resp, http.StatusOK)
 }
 
+// TxStatus returns what the node knows about the specified transaction.
+func (h Handlers) TxStatus(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	hash := web2.Param(r, "hash")
+
+	status, err := h.State.QueryTxStatus(hash)
+	if err != nil {
+		if errors.Is(err, state.ErrTxNotFound) {
+			return errs.NewTrusted(err, http.StatusNotFound)
+		}
+		return err
+	}
+
+	resp := txStatus{
+		Hash:          status.Hash,
+		Status:        status.Status,
+		BlockNumber:   status.BlockNumber,
+		BlockHash:     status.BlockHash,
+		Confirmations: status.Confirmations,
+		ReplacedBy:    status.ReplacedBy,
+		Reason:        status.Reason,
+	}
+	if status.Status == state.TxPending {
+		resp.Position = &status.Position
+	}
+
+	return web2.Respond(ctx, w, resp, http.StatusOK)
+}
+
 // Genesis returns the genesis information.
 func (h Handlers) Genesis(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
 	gen := h.State.Genesis()
@@ -132,6 +253,8 @@ func (h Handlers) Mempool(ctx context.Context, w http.ResponseWriter, r *http.Re
 			Value:       tran.Value,
 			Tip:         tran.Tip,
 			Data:        tran.Data,
+			ExpiryBlock: tran.ExpiryBlock,
+			ExpiryTime:  tran.ExpiryTime,
 			TimeStamp:   tran.TimeStamp,
 			GasPrice:    tran.GasPrice,
 			GasUnits:    tran.GasUnits,
@@ -174,8 +297,11 @@ func (h Handlers) Accounts(ctx context.Context, w http.ResponseWriter, r *http.R
 		resp = append(resp, act)
 	}
 
+	latest := h.State.LatestBlock().Hash()
+
 	ai := actInfo{
-		LastestBlock: h.State.LatestBlock().Hash(),
+		LatestBlock:  latest,
+		LastestBlock: latest,
 		Uncommitted:  len(h.State.Mempool()),
 		Accounts:     resp,
 	}
@@ -229,6 +355,8 @@ func (h Handlers) BlocksByAccount(ctx context.Context, w http.ResponseWriter, r
 				Value:       tran.Value,
 				Tip:         tran.Tip,
 				Data:        tran.Data,
+				ExpiryBlock: tran.ExpiryBlock,
+				ExpiryTime:  tran.ExpiryTime,
 				TimeStamp:   tran.TimeStamp,
 				GasPrice:    tran.GasPrice,
 				GasUnits:    tran.GasUnits,
@@ -32,13 +32,19 @@ func Routes(app *web2.App, cfg Config) {
 	const version = "v1"
 
 	app.Handle(http.MethodGet, version, "/events", pbl.Events)
+	app.Handle(http.MethodGet, version, "/events/stream", pbl.EventStream)
 	app.Handle(http.MethodGet, version, "/genesis/list", pbl.Genesis)
 	app.Handle(http.MethodGet, version, "/accounts/list", pbl.Accounts)
 	app.Handle(http.MethodGet, version, "/accounts/list/:account", pbl.Accounts)
 	app.Handle(http.MethodGet, version, "/blocks/list", pbl.BlocksByAccount)
 	app.Handle(http.MethodGet, version, "/blocks/list/:account", pbl.BlocksByAccount)
+	app.Handle(http.MethodGet, version, "/blocks", pbl.Blocks)
+	app.Handle(http.MethodGet, version, "/blocks/:number", pbl.BlockByNumber)
+	app.Handle(http.MethodGet, version, "/blocks/hash/:hash", pbl.BlockByHash)
 	app.Handle(http.MethodGet, version, "/tx/uncommitted/list", pbl.Mempool)
 	app.Handle(http.MethodGet, version, "/tx/uncommitted/list/:account", pbl.Mempool)
 	app.Handle(http.MethodPost, version, "/tx/submit", pbl.SubmitWalletTransaction)
+	app.Handle(http.MethodGet, version, "/tx/status/:hash", pbl.TxStatus)
+	app.Handle(http.MethodGet, version, "/tx/:hash", pbl.Tx)
 	app.Handle(http.MethodPost, version, "/tx/proof/:block/", pbl.SubmitWalletTransaction)
 }
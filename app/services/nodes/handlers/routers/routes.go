@@ -10,11 +10,13 @@ import (
 	"github.com/zacksfF/FullStack-Blockchain/app/services/nodes/handlers/debug/checkgrp"
 	"github.com/zacksfF/FullStack-Blockchain/app/services/nodes/handlers/private"
 	"github.com/zacksfF/FullStack-Blockchain/app/services/nodes/handlers/public"
+	"github.com/zacksfF/FullStack-Blockchain/app/services/nodes/handlers/rpc"
 	"github.com/zacksfF/FullStack-Blockchain/blockchain/state"
 	"github.com/zacksfF/FullStack-Blockchain/core/web/miidd"
 	"github.com/zacksfF/FullStack-Blockchain/events"
 	"github.com/zacksfF/FullStack-Blockchain/nameservices"
 	"github.com/zacksfF/FullStack-Blockchain/web2"
+	"github.com/zacksfF/FullStack-Blockchain/webhook"
 	"go.uber.org/zap"
 )
 
@@ -25,6 +27,7 @@ type MuxConfig struct {
 	State    *state.State
 	NS       *nameservices.NameService
 	Evts     *events.Events
+	Hooks    *webhook.Webhooks
 }
 
 // PublicMux constructs a http.Handler with all application routes defined.
@@ -56,6 +59,12 @@ func PublicMux(cfg MuxConfig) http.Handler {
 		Evts:  cfg.Evts,
 	})
 
+	rpc.Routes(app, rpc.Config{
+		Log:   cfg.Log,
+		State: cfg.State,
+		Evts:  cfg.Evts,
+	})
+
 	return app
 }
 
@@ -85,6 +94,7 @@ func PrivateMux(cfg MuxConfig) http.Handler {
 		Log:   cfg.Log,
 		State: cfg.State,
 		NS:    cfg.NS,
+		Hooks: cfg.Hooks,
 	})
 
 	return app
@@ -0,0 +1,302 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/database"
+	"github.com/zacksfF/FullStack-Blockchain/events"
+	"github.com/zacksfF/FullStack-Blockchain/web2"
+)
+
+// call executes the method of the request. The websocket connection is nil
+// for requests that arrive over HTTP.
+func (h Handlers) call(ctx context.Context, c *conn, req request) (any, error) {
+	params, err := positional(req.Params)
+	if err != nil {
+		return nil, err
+	}
+
+	switch req.Method {
+	case "eth_chainId":
+		return hexutil.Uint64(h.State.Genesis().ChainID), nil
+
+	case "eth_blockNumber":
+		return hexutil.Uint64(h.State.LatestBlock().Header.Number), nil
+
+	case "eth_getBalance":
+		account, err := h.account(params)
+		if err != nil {
+			return nil, err
+		}
+		return hexutil.Uint64(account.Balance), nil
+
+	case "eth_getTransactionCount":
+		account, err := h.account(params)
+		if err != nil {
+			return nil, err
+		}
+
+		// Tools use the count as the nonce of the next transaction. Nonces
+		// on this chain start at one, so the count is one past the nonce of
+		// the last transaction.
+		return hexutil.Uint64(account.Nonce + 1), nil
+
+	case "eth_getBlockByNumber":
+		return h.getBlockByNumber(params)
+
+	case "eth_sendRawTransaction":
+		return h.sendRawTransaction(ctx, params)
+
+	case "eth_subscribe":
+		return h.subscribe(c, params)
+
+	case "eth_unsubscribe":
+		return h.unsubscribe(c, params)
+	}
+
+	return nil, newError(codeMethodNotFound, "the method %s does not exist/is not available", req.Method)
+}
+
+// positional decodes the params of a request, which must be an array when
+// they are provided.
+func positional(raw json.RawMessage) ([]json.RawMessage, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+
+	var params []json.RawMessage
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, newError(codeInvalidParams, "invalid params: expected an array")
+	}
+
+	return params, nil
+}
+
+// param decodes the param at the index into the value. It reports false when
+// the param wasn't provided.
+func param(params []json.RawMessage, i int, v any) (bool, error) {
+	if i >= len(params) || string(params[i]) == "null" {
+		return false, nil
+	}
+
+	if err := json.Unmarshal(params[i], v); err != nil {
+		return false, newError(codeInvalidParams, "invalid argument %d: %s", i, err)
+	}
+
+	return true, nil
+}
+
+// latestOnly validates the block tag at the index. The node only keeps the
+// latest state of the accounts, so a state at an older block can't be read.
+func latestOnly(params []json.RawMessage, i int) error {
+	var tag string
+	ok, err := param(params, i, &tag)
+	if err != nil || !ok {
+		return err
+	}
+
+	switch tag {
+	case "latest", "pending":
+		return nil
+	}
+
+	return newError(codeInvalidParams, "invalid argument %d: only the latest state is available", i)
+}
+
+// =============================================================================
+
+// account returns the account named by the params [address, block]. An
+// account that isn't on the chain yet has a zero balance and nonce.
+func (h Handlers) account(params []json.RawMessage) (database.Account, error) {
+	var address string
+	ok, err := param(params, 0, &address)
+	if err != nil {
+		return database.Account{}, err
+	}
+	if !ok {
+		return database.Account{}, newError(codeInvalidParams, "missing value for required argument 0")
+	}
+
+	if _, err := database.ToAccountID(address); err != nil {
+		return database.Account{}, newError(codeInvalidParams, "invalid argument 0: %s", err)
+	}
+
+	if err := latestOnly(params, 1); err != nil {
+		return database.Account{}, err
+	}
+
+	// Accounts are stored using the checksum form of the address.
+	accountID := database.AccountID(common.HexToAddress(address).Hex())
+
+	account, err := h.State.QueryAccount(accountID)
+	if err != nil {
+		return database.Account{AccountID: accountID}, nil
+	}
+
+	return account, nil
+}
+
+// getBlockByNumber returns the block named by the params [block, fullTx]. A
+// block that doesn't exist returns null.
+func (h Handlers) getBlockByNumber(params []json.RawMessage) (any, error) {
+	var tag string
+	ok, err := param(params, 0, &tag)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, newError(codeInvalidParams, "missing value for required argument 0")
+	}
+
+	var fullTx bool
+	if _, err := param(params, 1, &fullTx); err != nil {
+		return nil, err
+	}
+
+	latest := h.State.LatestBlock().Header.Number
+
+	var number uint64
+	switch tag {
+	case "latest", "pending", "safe", "finalized":
+		number = latest
+	case "earliest":
+		number = 0
+	default:
+		number, err = hexutil.DecodeUint64(tag)
+		if err != nil {
+			return nil, newError(codeInvalidParams, "invalid argument 0: %s", err)
+		}
+	}
+
+	// The genesis block isn't stored as a block.
+	if number == 0 || number > latest {
+		return nil, nil
+	}
+
+	blocks := h.State.QueryBlocksByNumber(number, number)
+	if len(blocks) == 0 {
+		return nil, nil
+	}
+
+	return toBlock(database.NewBlockData(blocks[0]), fullTx), nil
+}
+
+// sendRawTransaction submits the transaction in the params [data]. The data
+// is the hex encoded JSON of a signed transaction, since transactions on this
+// chain are not RLP encoded. An RLP encoded transaction is refused with an
+// error that says so.
+func (h Handlers) sendRawTransaction(ctx context.Context, params []json.RawMessage) (any, error) {
+	var data hexutil.Bytes
+	ok, err := param(params, 0, &data)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, newError(codeInvalidParams, "missing value for required argument 0")
+	}
+
+	if trimmed := bytes.TrimSpace(data); len(trimmed) == 0 || trimmed[0] != '{' {
+		return nil, newError(codeInvalidParams, "invalid argument 0: RLP encoded transactions are not supported, send the hex encoded JSON of a signed transaction")
+	}
+
+	var signedTx database.SignedTx
+	if err := json.Unmarshal(data, &signedTx); err != nil {
+		return nil, newError(codeInvalidParams, "invalid argument 0: unable to decode transaction: %s", err)
+	}
+
+	var traceID string
+	if v, err := web2.GetValues(ctx); err == nil {
+		traceID = v.TraceID
+	}
+
+	h.Log.Infow("rpc: add tran", "traceid", traceID, "sig:nonce", signedTx, "from", signedTx.FromID, "to", signedTx.ToID, "value", signedTx.Value, "tip", signedTx.Tip)
+	if err := h.State.UpsertWalletTransaction(signedTx); err != nil {
+		return nil, newError(codeServer, "%s", err)
+	}
+
+	return signedTx.TxHash(), nil
+}
+
+// =============================================================================
+
+// subscribe starts a subscription for the params [kind]. Subscriptions are
+// only available over a websocket.
+func (h Handlers) subscribe(c *conn, params []json.RawMessage) (any, error) {
+	if c == nil || h.Evts == nil {
+		return nil, newError(codeMethodNotFound, "notifications not supported")
+	}
+
+	var kind string
+	if _, err := param(params, 0, &kind); err != nil {
+		return nil, err
+	}
+	if kind != "newHeads" {
+		return nil, newError(codeInvalidParams, "unsupported subscription type %q", kind)
+	}
+
+	id, err := newSubscriptionID()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if c.subs == nil {
+		c.mu.Unlock()
+		return nil, newError(codeInternal, "connection closed")
+	}
+	c.subs[id] = struct{}{}
+	ch := h.Evts.Subscribe(id, events.Filter{Topics: []string{events.TopicNewBlock}})
+	c.mu.Unlock()
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+
+		for ev := range ch {
+			blockData, ok := ev.Data.(database.BlockData)
+			if !ok {
+				continue
+			}
+
+			var n notification
+			n.JSONRPC = "2.0"
+			n.Method = "eth_subscription"
+			n.Params.Subscription = id
+			n.Params.Result = toHeader(blockData)
+
+			if err := c.write(n); err != nil {
+				return
+			}
+		}
+	}()
+
+	return id, nil
+}
+
+// unsubscribe ends the subscription for the params [id].
+func (h Handlers) unsubscribe(c *conn, params []json.RawMessage) (any, error) {
+	if c == nil || h.Evts == nil {
+		return nil, newError(codeMethodNotFound, "notifications not supported")
+	}
+
+	var id string
+	if _, err := param(params, 0, &id); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, exists := c.subs[id]; !exists {
+		return false, nil
+	}
+
+	delete(c.subs, id)
+	h.Evts.Release(id)
+
+	return true, nil
+}
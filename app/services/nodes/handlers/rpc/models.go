@@ -0,0 +1,96 @@
+package rpc
+
+import (
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/database"
+)
+
+// header represents a block header in the form used by Ethereum. Fields that
+// have no meaning on this chain, like the gas limits, are left out.
+type header struct {
+	Number           hexutil.Uint64     `json:"number"`
+	Hash             string             `json:"hash"`
+	ParentHash       string             `json:"parentHash"`
+	Nonce            hexutil.Uint64     `json:"nonce"`
+	StateRoot        string             `json:"stateRoot"`
+	TransactionsRoot string             `json:"transactionsRoot"`
+	Miner            database.AccountID `json:"miner"`
+	Difficulty       hexutil.Uint64     `json:"difficulty"`
+	Timestamp        hexutil.Uint64     `json:"timestamp"`
+}
+
+// block represents a block in the form used by Ethereum. The transactions
+// are either the transaction hashes or the full transactions.
+type block struct {
+	header
+	Transactions any `json:"transactions"`
+}
+
+// transaction represents a transaction in the form used by Ethereum.
+type transaction struct {
+	Hash             string             `json:"hash"`
+	Nonce            hexutil.Uint64     `json:"nonce"`
+	BlockHash        string             `json:"blockHash"`
+	BlockNumber      hexutil.Uint64     `json:"blockNumber"`
+	TransactionIndex hexutil.Uint64     `json:"transactionIndex"`
+	From             database.AccountID `json:"from"`
+	To               database.AccountID `json:"to"`
+	Value            hexutil.Uint64     `json:"value"`
+	Tip              hexutil.Uint64     `json:"tip"`
+	GasPrice         hexutil.Uint64     `json:"gasPrice"`
+	Gas              hexutil.Uint64     `json:"gas"`
+	Input            hexutil.Bytes      `json:"input"`
+}
+
+// toHeader converts the block data into a header. Block timestamps are kept
+// in milliseconds and Ethereum uses seconds.
+func toHeader(bd database.BlockData) header {
+	return header{
+		Number:           hexutil.Uint64(bd.Header.Number),
+		Hash:             bd.Hash,
+		ParentHash:       bd.Header.PrevBlockHash,
+		Nonce:            hexutil.Uint64(bd.Header.Nonce),
+		StateRoot:        bd.Header.StateRoot,
+		TransactionsRoot: bd.Header.TransRoot,
+		Miner:            bd.Header.BeneficiaryID,
+		Difficulty:       hexutil.Uint64(bd.Header.Difficulty),
+		Timestamp:        hexutil.Uint64(bd.Header.TimeStamp / 1000),
+	}
+}
+
+// toBlock converts the block data into a block.
+func toBlock(bd database.BlockData, fullTx bool) block {
+	b := block{
+		header: toHeader(bd),
+	}
+
+	if !fullTx {
+		hashes := make([]string, len(bd.Trans))
+		for i, tx := range bd.Trans {
+			hashes[i] = tx.TxHash()
+		}
+		b.Transactions = hashes
+		return b
+	}
+
+	txs := make([]transaction, len(bd.Trans))
+	for i, tx := range bd.Trans {
+		txs[i] = transaction{
+			Hash:             tx.TxHash(),
+			Nonce:            hexutil.Uint64(tx.Nonce),
+			BlockHash:        bd.Hash,
+			BlockNumber:      hexutil.Uint64(bd.Header.Number),
+			TransactionIndex: hexutil.Uint64(i),
+			From:             tx.FromID,
+			To:               tx.ToID,
+			Value:            hexutil.Uint64(tx.Value),
+			Tip:              hexutil.Uint64(tx.Tip),
+			GasPrice:         hexutil.Uint64(tx.GasPrice),
+			Gas:              hexutil.Uint64(tx.GasUnits),
+			Input:            tx.Data,
+		}
+	}
+	b.Transactions = txs
+
+	return b
+}
@@ -0,0 +1,309 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/gorilla/websocket"
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/state"
+	"github.com/zacksfF/FullStack-Blockchain/core/web/errs"
+	"github.com/zacksfF/FullStack-Blockchain/events"
+	"github.com/zacksfF/FullStack-Blockchain/web2"
+	"go.uber.org/zap"
+)
+
+// Package rpc provides an Ethereum compatible JSON-RPC 2.0 API so tools
+// that speak to Ethereum nodes can read the chain and submit transactions.
+// Requests are accepted as a HTTP POST or over a websocket, which is also
+// used to deliver subscriptions. Transactions on this chain are not RLP
+// encoded, so eth_sendRawTransaction takes the hex encoded JSON of a signed
+// transaction instead of what Ethereum wallets produce.
+
+// Set of error codes defined by JSON-RPC 2.0 and used by Ethereum nodes.
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeInternal       = -32603
+	codeServer         = -32000
+)
+
+// Limits on what a client can send, so a single message can't make the node
+// hold or process an unbounded amount of data.
+const (
+	maxMessageSize = 1 << 20
+	maxBatchSize   = 100
+)
+
+// Config contains all the mandatory systems required by handlers.
+type Config struct {
+	Log   *zap.SugaredLogger
+	State *state.State
+	Evts  *events.Events
+}
+
+// Handlers manages the set of JSON-RPC endpoints.
+type Handlers struct {
+	Log   *zap.SugaredLogger
+	State *state.State
+	Evts  *events.Events
+	WS    websocket.Upgrader
+}
+
+// Routes binds the JSON-RPC routes.
+func Routes(app *web2.App, cfg Config) {
+	rpc := Handlers{
+		Log:   cfg.Log,
+		State: cfg.State,
+		Evts:  cfg.Evts,
+		WS:    websocket.Upgrader{},
+	}
+
+	app.Handle(http.MethodPost, "", "/rpc", rpc.HTTP)
+	app.Handle(http.MethodGet, "", "/rpc", rpc.WebSocket)
+}
+
+// HTTP handles a request or batch of requests sent as a HTTP POST.
+func (h Handlers) HTTP(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxMessageSize))
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return errs.NewTrusted(fmt.Errorf("payload over %d bytes", maxErr.Limit), http.StatusRequestEntityTooLarge)
+		}
+		return fmt.Errorf("unable to read payload: %w", err)
+	}
+
+	resp := h.handle(ctx, nil, body)
+	if resp == nil {
+		return web2.Respond(ctx, w, nil, http.StatusNoContent)
+	}
+
+	return web2.Respond(ctx, w, resp, http.StatusOK)
+}
+
+// WebSocket handles requests sent over a websocket. The websocket also
+// receives the notifications for the subscriptions it makes.
+func (h Handlers) WebSocket(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+
+	// Need this to handle CORS on the websocket.
+	h.WS.CheckOrigin = func(r *http.Request) bool { return true }
+
+	ws, err := h.WS.Upgrade(w, r, nil)
+	if err != nil {
+		return err
+	}
+	ws.SetReadLimit(maxMessageSize)
+
+	c := conn{
+		ws:   ws,
+		subs: make(map[string]struct{}),
+	}
+	defer h.closeConn(&c)
+
+	for {
+		_, msg, err := ws.ReadMessage()
+		if err != nil {
+			return nil
+		}
+
+		if resp := h.handle(ctx, &c, msg); resp != nil {
+			if err := c.write(resp); err != nil {
+				return nil
+			}
+		}
+	}
+}
+
+// =============================================================================
+
+// request represents a JSON-RPC request. A request without an id is a
+// notification and gets no response.
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+}
+
+// response represents a JSON-RPC response, which carries either a result or
+// an error.
+type response struct {
+	ID     json.RawMessage
+	Result any
+	Error  *Error
+}
+
+// MarshalJSON implements the json.Marshaler interface so a response never
+// has both a result and an error.
+func (r response) MarshalJSON() ([]byte, error) {
+	id := r.ID
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+
+	if r.Error != nil {
+		return json.Marshal(struct {
+			JSONRPC string          `json:"jsonrpc"`
+			ID      json.RawMessage `json:"id"`
+			Error   *Error          `json:"error"`
+		}{"2.0", id, r.Error})
+	}
+
+	return json.Marshal(struct {
+		JSONRPC string          `json:"jsonrpc"`
+		ID      json.RawMessage `json:"id"`
+		Result  any             `json:"result"`
+	}{"2.0", id, r.Result})
+}
+
+// notification represents a message sent to a websocket for a subscription.
+type notification struct {
+	JSONRPC string `json:"jsonrpc"`
+	Method  string `json:"method"`
+	Params  struct {
+		Subscription string `json:"subscription"`
+		Result       any    `json:"result"`
+	} `json:"params"`
+}
+
+// Error represents a JSON-RPC error.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// newError constructs an error with the specified code.
+func newError(code int, format string, args ...any) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// handle processes a message holding a request or a batch of requests and
+// returns what should be sent back, which is nil when there is nothing to
+// send.
+func (h Handlers) handle(ctx context.Context, c *conn, msg []byte) any {
+	msg = bytes.TrimSpace(msg)
+
+	if len(msg) == 0 || msg[0] != '[' {
+		resp, ok := h.handleOne(ctx, c, msg)
+		if !ok {
+			return nil
+		}
+		return resp
+	}
+
+	var batch []json.RawMessage
+	if err := json.Unmarshal(msg, &batch); err != nil {
+		return response{Error: newError(codeParseError, "parse error: %s", err)}
+	}
+
+	if len(batch) == 0 {
+		return response{Error: newError(codeInvalidRequest, "empty batch")}
+	}
+
+	if len(batch) > maxBatchSize {
+		return response{Error: newError(codeInvalidRequest, "batch of %d requests is over the max of %d", len(batch), maxBatchSize)}
+	}
+
+	var resps []response
+	for _, raw := range batch {
+		if resp, ok := h.handleOne(ctx, c, raw); ok {
+			resps = append(resps, resp)
+		}
+	}
+
+	if len(resps) == 0 {
+		return nil
+	}
+
+	return resps
+}
+
+// handleOne processes a single request. It reports false when the request
+// is a notification, which gets no response.
+func (h Handlers) handleOne(ctx context.Context, c *conn, msg []byte) (response, bool) {
+	var req request
+	if err := json.Unmarshal(msg, &req); err != nil {
+		if !json.Valid(msg) {
+			return response{Error: newError(codeParseError, "parse error: %s", err)}, true
+		}
+		return response{Error: newError(codeInvalidRequest, "invalid request: %s", err)}, true
+	}
+
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		return response{ID: req.ID, Error: newError(codeInvalidRequest, "invalid request")}, true
+	}
+
+	result, err := h.call(ctx, c, req)
+	if req.ID == nil {
+		return response{}, false
+	}
+
+	if err != nil {
+		rpcErr, ok := err.(*Error)
+		if !ok {
+			rpcErr = newError(codeInternal, "%s", err)
+		}
+		return response{ID: req.ID, Error: rpcErr}, true
+	}
+
+	return response{ID: req.ID, Result: result}, true
+}
+
+// =============================================================================
+
+// conn represents a websocket connection and the subscriptions made on it.
+type conn struct {
+	ws  *websocket.Conn
+	wmu sync.Mutex
+	wg  sync.WaitGroup
+
+	mu   sync.Mutex
+	subs map[string]struct{}
+}
+
+// write sends the value to the websocket. Writes are serialized since the
+// subscriptions write from their own goroutines.
+func (c *conn) write(v any) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	return c.ws.WriteJSON(v)
+}
+
+// closeConn releases the subscriptions of the connection and closes it.
+func (h Handlers) closeConn(c *conn) {
+	c.mu.Lock()
+	for id := range c.subs {
+		h.Evts.Release(id)
+	}
+	c.subs = nil
+	c.mu.Unlock()
+
+	c.wg.Wait()
+	c.ws.Close()
+}
+
+// newSubscriptionID returns a random id for a subscription.
+func newSubscriptionID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hexutil.Encode(b), nil
+}
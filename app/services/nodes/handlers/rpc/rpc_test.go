@@ -0,0 +1,361 @@
+package rpc_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gorilla/websocket"
+	"github.com/zacksfF/FullStack-Blockchain/app/services/nodes/handlers/routers"
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/database"
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/genesis"
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/peer"
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/state"
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/storage/memory"
+	"github.com/zacksfF/FullStack-Blockchain/events"
+	"go.uber.org/zap"
+)
+
+const (
+	minerPrivateKey   = "8dc79feefd3b86e2f9991def0e5ccd9a5128e104682407b308594bc1032ac7f0"
+	kennedyPrivateKey = "9f332e3700d8fc2446eaf6d15034cf96e0c2745e40353deef032a5dbf1dfed93"
+
+	kennedyAccountID = database.AccountID("0xF01813E4B85e178A83e29B8E7bF26BD830a25f32")
+	edAccountID      = database.AccountID("0xa988b1866EaBF72B4c53b592c97aAD8e4b9bDCC0")
+
+	chainID = 1
+)
+
+func Test_HTTP(t *testing.T) {
+	st, srv := newServer(t)
+
+	var chain hexutil.Uint64
+	call(t, srv.URL, "eth_chainId", nil, &chain)
+	if chain != chainID {
+		t.Fatalf("Should get the chain id, got %d, exp %d", chain, chainID)
+	}
+
+	// The account id is accepted in lower case like Ethereum tools send it.
+	var balance hexutil.Uint64
+	call(t, srv.URL, "eth_getBalance", []any{strings.ToLower(string(kennedyAccountID)), "latest"}, &balance)
+	if balance != 1_000_000 {
+		t.Fatalf("Should get the balance, got %d, exp %d", balance, 1_000_000)
+	}
+
+	signedTx := newSignedTx(t, 1)
+	data, err := json.Marshal(signedTx)
+	if err != nil {
+		t.Fatalf("Should be able to marshal the transaction: %s", err)
+	}
+
+	var txHash string
+	call(t, srv.URL, "eth_sendRawTransaction", []any{hexutil.Encode(data)}, &txHash)
+	if txHash != signedTx.TxHash() {
+		t.Fatalf("Should get the transaction hash, got %s, exp %s", txHash, signedTx.TxHash())
+	}
+
+	if _, err := st.MineNewBlock(context.Background()); err != nil {
+		t.Fatalf("Should be able to mine a block: %s", err)
+	}
+
+	var number hexutil.Uint64
+	call(t, srv.URL, "eth_blockNumber", nil, &number)
+	if number != 1 {
+		t.Fatalf("Should get the block number, got %d, exp 1", number)
+	}
+
+	// The count is the nonce to use for the next transaction.
+	var count hexutil.Uint64
+	call(t, srv.URL, "eth_getTransactionCount", []any{kennedyAccountID, "pending"}, &count)
+	if count != 2 {
+		t.Fatalf("Should get the transaction count, got %d, exp 2", count)
+	}
+
+	var block struct {
+		Hash         string   `json:"hash"`
+		Number       string   `json:"number"`
+		Transactions []string `json:"transactions"`
+	}
+	call(t, srv.URL, "eth_getBlockByNumber", []any{"0x1", false}, &block)
+	if block.Number != "0x1" || block.Hash != st.LatestBlock().Hash() {
+		t.Fatalf("Should get the block, got %s %s", block.Number, block.Hash)
+	}
+	if len(block.Transactions) != 1 || block.Transactions[0] != txHash {
+		t.Fatalf("Should get the transaction hashes in the block, got %v", block.Transactions)
+	}
+
+	var missing *json.RawMessage
+	call(t, srv.URL, "eth_getBlockByNumber", []any{"0x2", false}, &missing)
+	if missing != nil {
+		t.Fatalf("Should get null for a block that doesn't exist, got %s", *missing)
+	}
+}
+
+func Test_Errors(t *testing.T) {
+	_, srv := newServer(t)
+
+	tt := []struct {
+		name string
+		body string
+		code int
+	}{
+		{"parse", `{"jsonrpc":`, -32700},
+		{"request", `{"jsonrpc":"1.0","id":1,"method":"eth_chainId"}`, -32600},
+		{"method", `{"jsonrpc":"2.0","id":1,"method":"eth_mining"}`, -32601},
+		{"params", `{"jsonrpc":"2.0","id":1,"method":"eth_getBalance","params":["0x12"]}`, -32602},
+		{"history", `{"jsonrpc":"2.0","id":1,"method":"eth_getBalance","params":["0xF01813E4B85e178A83e29B8E7bF26BD830a25f32","0x0"]}`, -32602},
+		{"subscribe", `{"jsonrpc":"2.0","id":1,"method":"eth_subscribe","params":["newHeads"]}`, -32601},
+		{"rlp", `{"jsonrpc":"2.0","id":1,"method":"eth_sendRawTransaction","params":["0xf86c0185"]}`, -32602},
+		{"batch", "[" + strings.Repeat(`{"jsonrpc":"2.0","id":1,"method":"eth_chainId"},`, 100) + `{"jsonrpc":"2.0","id":1,"method":"eth_chainId"}]`, -32600},
+	}
+
+	for _, tst := range tt {
+		var resp struct {
+			Error *struct {
+				Code int `json:"code"`
+			} `json:"error"`
+		}
+		post(t, srv.URL, tst.body, &resp)
+
+		if resp.Error == nil || resp.Error.Code != tst.code {
+			t.Fatalf("%s: Should get error code %d, got %+v", tst.name, tst.code, resp.Error)
+		}
+	}
+}
+
+func Test_Batch(t *testing.T) {
+	_, srv := newServer(t)
+
+	body := `[
+		{"jsonrpc":"2.0","id":1,"method":"eth_chainId"},
+		{"jsonrpc":"2.0","method":"eth_chainId"},
+		{"jsonrpc":"2.0","id":2,"method":"eth_blockNumber"}
+	]`
+
+	var resp []struct {
+		ID     int    `json:"id"`
+		Result string `json:"result"`
+	}
+	post(t, srv.URL, body, &resp)
+
+	if len(resp) != 2 {
+		t.Fatalf("Should get a response for each request that isn't a notification, got %d, exp 2", len(resp))
+	}
+	if resp[0].ID != 1 || resp[0].Result != "0x1" || resp[1].ID != 2 || resp[1].Result != "0x0" {
+		t.Fatalf("Should get the responses in order, got %+v", resp)
+	}
+}
+
+func Test_MessageSize(t *testing.T) {
+	_, srv := newServer(t)
+
+	body := `{"jsonrpc":"2.0","id":1,"method":"eth_chainId","params":["` + strings.Repeat("a", 1<<20) + `"]}`
+
+	resp, err := http.Post(srv.URL+"/rpc", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Should be able to post the request: %s", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Fatalf("Should refuse a message over the limit, got %d", resp.StatusCode)
+	}
+}
+
+func Test_NewHeads(t *testing.T) {
+	st, srv := newServer(t)
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/rpc"
+	ws, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Should be able to dial the websocket: %s", err)
+	}
+	defer ws.Close()
+
+	ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	if err := ws.WriteJSON(map[string]any{"jsonrpc": "2.0", "id": 1, "method": "eth_subscribe", "params": []string{"newHeads"}}); err != nil {
+		t.Fatalf("Should be able to send the request: %s", err)
+	}
+
+	var sub struct {
+		Result string `json:"result"`
+	}
+	if err := ws.ReadJSON(&sub); err != nil || sub.Result == "" {
+		t.Fatalf("Should get a subscription id, got %q: %v", sub.Result, err)
+	}
+
+	if err := st.UpsertWalletTransaction(newSignedTx(t, 1)); err != nil {
+		t.Fatalf("Should be able to submit the transaction: %s", err)
+	}
+	if _, err := st.MineNewBlock(context.Background()); err != nil {
+		t.Fatalf("Should be able to mine a block: %s", err)
+	}
+
+	var n struct {
+		Method string `json:"method"`
+		Params struct {
+			Subscription string `json:"subscription"`
+			Result       struct {
+				Number string `json:"number"`
+				Hash   string `json:"hash"`
+			} `json:"result"`
+		} `json:"params"`
+	}
+	if err := ws.ReadJSON(&n); err != nil {
+		t.Fatalf("Should get a notification for the new block: %s", err)
+	}
+
+	if n.Method != "eth_subscription" || n.Params.Subscription != sub.Result {
+		t.Fatalf("Should get a notification for the subscription, got %s %s", n.Method, n.Params.Subscription)
+	}
+	if n.Params.Result.Number != "0x1" || n.Params.Result.Hash != st.LatestBlock().Hash() {
+		t.Fatalf("Should get the header of the new block, got %s %s", n.Params.Result.Number, n.Params.Result.Hash)
+	}
+}
+
+// =============================================================================
+
+// newServer starts the public routes of a node with funded accounts. Block
+// events are sent to the subscribers like main.go does.
+func newServer(t *testing.T) (*state.State, *httptest.Server) {
+	privateKey, err := crypto.HexToECDSA(minerPrivateKey)
+	if err != nil {
+		t.Fatalf("Should be able to construct the private key: %s", err)
+	}
+
+	storage, err := memory.New()
+	if err != nil {
+		t.Fatalf("Should be able to construct the storage: %s", err)
+	}
+
+	evts := events.New()
+	t.Cleanup(evts.Shutdown)
+
+	st, err := state.New(state.Config{
+		BeneficiaryID: database.PublicKeyToAccountID(privateKey.PublicKey),
+		NodeKey:       privateKey,
+		Host:          "localhost:9080",
+		Storage:       storage,
+		Genesis: genesis.Genesis{
+			Date:          time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			ChainID:       chainID,
+			TransPerBlock: 10,
+			Difficulty:    1,
+			MiningReward:  700,
+			GasPrice:      15,
+			Balances: map[string]uint64{
+				string(kennedyAccountID): 1_000_000,
+			},
+		},
+		SelectStrategy: "Tip",
+		KnownPeers:     peer.NewPeerSet(),
+		EvHandler: func(v string, args ...any) {
+			if strings.HasPrefix(v, "viewer:") {
+				evts.Send(fmt.Sprintf(v, args...))
+			}
+		},
+		Publisher: evts.Publish,
+	})
+	if err != nil {
+		t.Fatalf("Should be able to construct the state: %s", err)
+	}
+	st.Worker = noopWorker{}
+
+	srv := httptest.NewServer(routers.PublicMux(routers.MuxConfig{
+		Shutdown: make(chan os.Signal, 1),
+		Log:      zap.NewNop().Sugar(),
+		State:    st,
+		Evts:     evts,
+	}))
+	t.Cleanup(srv.Close)
+
+	return st, srv
+}
+
+// call sends the request to the node and decodes the result.
+func call(t *testing.T, url string, method string, params []any, result any) {
+	t.Helper()
+
+	body, err := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": 1, "method": method, "params": params})
+	if err != nil {
+		t.Fatalf("Should be able to marshal the request: %s", err)
+	}
+
+	var resp struct {
+		Result json.RawMessage `json:"result"`
+		Error  *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	post(t, url, string(body), &resp)
+
+	if resp.Error != nil {
+		t.Fatalf("Should be able to call %s: %s", method, resp.Error.Message)
+	}
+
+	if err := json.Unmarshal(resp.Result, result); err != nil {
+		t.Fatalf("Should be able to decode the result of %s: %s", method, err)
+	}
+}
+
+// post sends the body to the rpc route and decodes the response.
+func post(t *testing.T, url string, body string, v any) {
+	t.Helper()
+
+	resp, err := http.Post(url+"/rpc", "application/json", bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatalf("Should be able to post the request: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Should get status OK, got %d", resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		t.Fatalf("Should be able to decode the response: %s", err)
+	}
+}
+
+// newSignedTx signs a transaction from kennedy with the nonce.
+func newSignedTx(t *testing.T, nonce uint64) database.SignedTx {
+	privateKey, err := crypto.HexToECDSA(kennedyPrivateKey)
+	if err != nil {
+		t.Fatalf("Should be able to construct the private key: %s", err)
+	}
+
+	tx := database.Tx{
+		ChainID: chainID,
+		Nonce:   nonce,
+		FromID:  kennedyAccountID,
+		ToID:    edAccountID,
+		Value:   1,
+	}
+
+	signedTx, err := tx.Sign(privateKey)
+	if err != nil {
+		t.Fatalf("Should be able to sign the transaction: %s", err)
+	}
+
+	return signedTx
+}
+
+// noopWorker implements the Worker interface which does nothing.
+type noopWorker struct{}
+
+func (n noopWorker) Shutdown()                              {}
+func (n noopWorker) Sync()                                  {}
+func (n noopWorker) SignalStartMining()                     {}
+func (n noopWorker) SignalCancelMining()                    {}
+func (n noopWorker) SignalShareTx(blockTx database.BlockTx) {}
+func (n noopWorker) SignalShareBlock(block database.Block)  {}
@@ -19,10 +19,12 @@ import (
 	"github.com/zacksfF/FullStack-Blockchain/blockchain/peer"
 	"github.com/zacksfF/FullStack-Blockchain/blockchain/state"
 	"github.com/zacksfF/FullStack-Blockchain/blockchain/storage/disk"
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/transport"
 	"github.com/zacksfF/FullStack-Blockchain/blockchain/worker"
 	"github.com/zacksfF/FullStack-Blockchain/events"
 	"github.com/zacksfF/FullStack-Blockchain/logger"
 	"github.com/zacksfF/FullStack-Blockchain/nameservices"
+	"github.com/zacksfF/FullStack-Blockchain/webhook"
 	"go.uber.org/zap"
 )
 
@@ -66,17 +68,46 @@ func run(log *zap.SugaredLogger) error {
 			DebugHost       string        `conf:"default:0.0.0.0:7080"`
 			PublicHost      string        `conf:"default:0.0.0.0:8080"`
 			PrivateHost     string        `conf:"default:0.0.0.0:9080"`
+			EventReplaySize int           `conf:"default:1024"` // Typed events kept for subscribers that resume
 		}
 		State struct {
-			Beneficiary    string   `conf:"default:miner1"`
-			DBPath         string   `conf:"default:zblock/miner1/"`
-			SelectStrategy string   `conf:"default:Tip"`
-			OriginPeers    []string `conf:"default:0.0.0.0:9080"` //
-			Consensus      string   `conf:"default:POW"`          // Change to POA to run Proof of Authority
+			Beneficiary          string        `conf:"default:miner1"`
+			NodeKey              string        `conf:"default:zblock/nodes/miner1.ecdsa"` // Generated on first start
+			DBPath               string        `conf:"default:zblock/miner1/"`
+			SelectStrategy       string        `conf:"default:Tip"` // Tip, Tip_Advanced, Tip_Optimal, FIFO, Fee_Per_Gas, Tip_Age
+			MempoolMaxSize       int           `conf:"default:10000"`
+			MempoolMaxPerAccount int           `conf:"default:100"`
+			MempoolMinTip        uint64        `conf:"default:0"`
+			MempoolTTL           time.Duration `conf:"default:24h"`
+			MempoolJournal       string        `conf:"default:zblock/mempool/miner1.journal"`
+			MempoolReplaceBump   uint64        `conf:"default:10"`           // Percentage tip bump to replace a pending transaction
+			MempoolNoReplace     bool          `conf:"default:false"`        // Reject all replacements, including cancellations
+			OriginPeers          []string      `conf:"default:0.0.0.0:9080"` //
+			PeerBanThreshold     int           `conf:"default:-100"`         // Score at which a misbehaving peer is banned
+			PeerBanDuration      time.Duration `conf:"default:1h"`
+			MaxInboundPeers      int           `conf:"default:32"` // Peers that connected to this node, 0 for no limit
+			MaxOutboundPeers     int           `conf:"default:8"`  // Peers this node connected to, 0 for no limit
+			TrustedPeers         []string      // Peers that always have a slot and are never banned
+			PeerStore            string        `conf:"default:zblock/peers/miner1.json"`
+			PeerTimeout          time.Duration `conf:"default:10s"`   // How long a single call to a peer can take
+			PeerRetries          int           `conf:"default:2"`     // Times a call that timed out or failed on the peer is retried
+			PeerBackoff          time.Duration `conf:"default:250ms"` // Wait before the first retry, doubled after each retry
+			GossipFanout         int           `conf:"default:8"`     // Number of peers new data is announced to, 0 for all
+			StreamQueueSize      int           `conf:"default:64"`    // Messages waiting to be sent to a peer before senders block
+			StreamMaxInFlight    int           `conf:"default:16"`    // Requests from a peer served at the same time
+			Consensus            string        `conf:"default:POW"`   // Change to POA to run Proof of Authority
 		}
 		NameService struct {
 			Folder string `conf:"default:zblock/accounts/"`
 		}
+		Webhook struct {
+			Hooks       string        `conf:"default:zblock/webhooks/miner1.json"` // Hooks to deliver events to, none when missing
+			Journal     string        `conf:"default:zblock/webhooks/miner1.journal"`
+			Timeout     time.Duration `conf:"default:10s"` // How long a single delivery attempt can take
+			MaxAttempts int           `conf:"default:10"`  // Attempts before a delivery is marked as failed
+			Backoff     time.Duration `conf:"default:1s"`  // Wait before the first retry, doubled after each retry
+			MaxBackoff  time.Duration `conf:"default:10m"`
+		}
 	}{
 		Version: conf.Version{
 			Build: build,
@@ -138,18 +169,63 @@ func run(log *zap.SugaredLogger) error {
 		return fmt.Errorf("unable to load private key for node: %w", err)
 	}
 
+	// The node key identifies this node to its peers and is used to sign
+	// the requests sent to them.
+	nodeKey, err := peer.LoadKey(cfg.State.NodeKey)
+	if err != nil {
+		return fmt.Errorf("unable to load node key: %w", err)
+	}
+	log.Infow("startup", "status", "node identity", "node", peer.NodeID(nodeKey.PublicKey))
+
 	// A peer set is a collection of known nodes in the network so transactions
 	// and blocks can be shared.
-	peerSet := peer.NewPeerSet()
+	peerSet := peer.NewPeerSetWithConfig(peer.Config{
+		BanThreshold: cfg.State.PeerBanThreshold,
+		BanDuration:  cfg.State.PeerBanDuration,
+		MaxInbound:   cfg.State.MaxInboundPeers,
+		MaxOutbound:  cfg.State.MaxOutboundPeers,
+	})
 	for _, host := range cfg.State.OriginPeers {
 		peerSet.Add(peer.New(host))
 	}
-	peerSet.Add(peer.New(cfg.Web.PrivateHost))
+	for _, host := range cfg.State.TrustedPeers {
+		peerSet.Reserve(peer.New(host))
+	}
+	peerSet.Reserve(peer.New(cfg.Web.PrivateHost))
+
+	// The webhooks are sent the typed events they want as posts signed with
+	// the node key. Deliveries still pending from the last run are resumed.
+	hookList, err := webhook.Load(cfg.Webhook.Hooks)
+	if err != nil {
+		return fmt.Errorf("unable to load webhooks: %w", err)
+	}
+	hooks, err := webhook.New(webhook.Config{
+		Hooks:       hookList,
+		JournalPath: cfg.Webhook.Journal,
+		Timeout:     cfg.Webhook.Timeout,
+		MaxAttempts: cfg.Webhook.MaxAttempts,
+		Backoff:     cfg.Webhook.Backoff,
+		MaxBackoff:  cfg.Webhook.MaxBackoff,
+		Sign: func(r *http.Request, body []byte) error {
+			return peer.SignRequest(r, body, nodeKey)
+		},
+		EvHandler: func(v string, args ...any) {
+			log.Infow(fmt.Sprintf(v, args...), "traceid", "00000000-0000-0000-0000-000000000000")
+		},
+	})
+	if err != nil {
+		return err
+	}
+	defer hooks.Shutdown()
+	log.Infow("startup", "status", "webhooks", "hooks", len(hookList))
 
 	// The blockchain packages accept a function of this signature to allow the
 	// application to log. For now, these raw messages are sent to any websocket
 	// client that is connected into the system through the events package.
-	evts := events.New()
+	evts := events.NewWithConfig(events.Config{
+		ReplaySize: cfg.Web.EventReplaySize,
+		Sink:       hooks.Publish,
+	})
 	ev := func(v string, args ...any) {
 		const websocketPrefix = "viewer:"
 
@@ -172,17 +248,44 @@ func run(log *zap.SugaredLogger) error {
 		return err
 	}
 
+	// The transport keeps a persistent connection with each peer so the
+	// requests between nodes don't open a new HTTP request each time.
+	tr := transport.New(transport.Config{
+		QueueSize:   cfg.State.StreamQueueSize,
+		MaxInFlight: cfg.State.StreamMaxInFlight,
+		Sign: func(r *http.Request) error {
+			return peer.SignRequest(r, nil, nodeKey)
+		},
+		EvHandler: ev,
+	})
+	defer tr.Close()
+
 	// The state value represents the blockchain node and manages the blockchain
 	// database and provides an API for application support.
 	state, err := state.New(state.Config{
-		BeneficiaryID:  database.PublicKeyToAccountID(privateKey.PublicKey),
-		Host:           cfg.Web.PrivateHost,
-		Storage:        storage,
-		Genesis:        genesis,
-		SelectStrategy: cfg.State.SelectStrategy,
-		KnownPeers:     *peerSet,
-		Consensus:      cfg.State.Consensus,
-		EvHandler:      ev,
+		BeneficiaryID:        database.PublicKeyToAccountID(privateKey.PublicKey),
+		NodeKey:              nodeKey,
+		Host:                 cfg.Web.PrivateHost,
+		Storage:              storage,
+		Genesis:              genesis,
+		SelectStrategy:       cfg.State.SelectStrategy,
+		MempoolMaxSize:       cfg.State.MempoolMaxSize,
+		MempoolMaxPerAccount: cfg.State.MempoolMaxPerAccount,
+		MempoolMinTip:        cfg.State.MempoolMinTip,
+		MempoolTTL:           cfg.State.MempoolTTL,
+		MempoolJournal:       cfg.State.MempoolJournal,
+		MempoolReplaceBump:   cfg.State.MempoolReplaceBump,
+		MempoolNoReplace:     cfg.State.MempoolNoReplace,
+		KnownPeers:           peerSet,
+		PeerStore:            cfg.State.PeerStore,
+		GossipFanout:         cfg.State.GossipFanout,
+		Transport:            tr,
+		PeerTimeout:          cfg.State.PeerTimeout,
+		PeerRetries:          cfg.State.PeerRetries,
+		PeerBackoff:          cfg.State.PeerBackoff,
+		Consensus:            cfg.State.Consensus,
+		EvHandler:            ev,
+		Publisher:            evts.Publish,
 	})
 	if err != nil {
 		return err
@@ -260,8 +363,13 @@ func run(log *zap.SugaredLogger) error {
 		Shutdown: shutdown,
 		Log:      log,
 		State:    state,
+		Hooks:    hooks,
 	})
 
+	// Requests received over the persistent peer connections are served by
+	// the same routes.
+	tr.SetHandler(privateMux)
+
 	// Construct a server to service the requests against the mux.
 	private := http.Server{
 		Addr:         cfg.Web.PrivateHost,
@@ -0,0 +1,161 @@
+package simnet
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+
+// Link represents the conditions of the connection from one node to
+// another. The latency is added to a request on its way to the node and to
+// the response on its way back. Loss is the fraction of requests, between 0
+// and 1, that never reach the node.
+type Link struct {
+	Latency time.Duration
+	Loss    float64
+}
+
+// SetDefaultLink sets the conditions for every link that doesn't have its
+// own conditions set.
+func (net *Network) SetDefaultLink(l Link) {
+	net.mu.Lock()
+	defer net.mu.Unlock()
+
+	net.defaultLink = l
+}
+
+// SetLink sets the conditions for the requests sent from one node to
+// another. The link back has its own conditions.
+func (net *Network) SetLink(from int, to int, l Link) {
+	net.mu.Lock()
+	defer net.mu.Unlock()
+
+	net.links[[2]int{from, to}] = l
+}
+
+// Partition splits the network into the specified groups of nodes. Nodes
+// can only reach the nodes in their own group and a node that isn't in any
+// group can't reach any other node.
+func (net *Network) Partition(groups ...[]int) {
+	net.mu.Lock()
+	defer net.mu.Unlock()
+
+	net.groups = make(map[int]int)
+	for g, group := range groups {
+		for _, i := range group {
+			net.groups[i] = g
+		}
+	}
+}
+
+// Heal removes the partition so every node can reach every other node.
+func (net *Network) Heal() {
+	net.mu.Lock()
+	defer net.mu.Unlock()
+
+	net.groups = nil
+}
+
+// =============================================================================
+
+// link returns the conditions from one node to another and reports if the
+// nodes can reach each other.
+func (net *Network) link(from int, to int) (Link, bool) {
+	net.mu.RLock()
+	defer net.mu.RUnlock()
+
+	if net.groups != nil {
+		gf, okf := net.groups[from]
+		gt, okt := net.groups[to]
+		if !okf || !okt || gf != gt {
+			return Link{}, false
+		}
+	}
+
+	if l, exists := net.links[[2]int{from, to}]; exists {
+		return l, true
+	}
+
+	return net.defaultLink, true
+}
+
+// roundTripper delivers the requests sent by a node to the private routes of
+// the node being called.
+type roundTripper struct {
+	net  *Network
+	from *Node
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (rt roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		defer req.Body.Close()
+	}
+
+	to, exists := rt.net.byHost[req.URL.Host]
+	if !exists {
+		return nil, fmt.Errorf("%w: unknown host %s", ErrUnreachable, req.URL.Host)
+	}
+
+	l, ok := rt.net.link(rt.from.Index, to.Index)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s is partitioned from %s", ErrUnreachable, to.Host, rt.from.Host)
+	}
+
+	if l.Loss > 0 && rand.Float64() < l.Loss {
+		return nil, fmt.Errorf("%w: %s to %s", ErrMessageLost, rt.from.Host, to.Host)
+	}
+
+	if err := wait(req, l.Latency); err != nil {
+		return nil, err
+	}
+
+	// The node being called is checked when the request arrives, so a node
+	// that crashes while the request is on its way doesn't answer it.
+	to.mu.RLock()
+	handler, running := to.handler, to.running
+	to.mu.RUnlock()
+
+	if !running {
+		return nil, fmt.Errorf("%w: %s is down", ErrUnreachable, to.Host)
+	}
+
+	r := req.Clone(req.Context())
+	r.RemoteAddr = rt.from.Host
+	r.RequestURI = req.URL.RequestURI()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, r)
+
+	// The response travels back over the link from the node being called.
+	back, ok := rt.net.link(to.Index, rt.from.Index)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s is partitioned from %s", ErrUnreachable, rt.from.Host, to.Host)
+	}
+
+	if err := wait(req, back.Latency); err != nil {
+		return nil, err
+	}
+
+	return rec.Result(), nil
+}
+
+// wait holds the request for the latency of the link unless the request is
+// cancelled first.
+func wait(req *http.Request, latency time.Duration) error {
+	if latency <= 0 {
+		return nil
+	}
+
+	t := time.NewTimer(latency)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-req.Context().Done():
+		return req.Context().Err()
+	}
+}
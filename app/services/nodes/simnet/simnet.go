@@ -0,0 +1,258 @@
+package simnet
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/zacksfF/FullStack-Blockchain/app/services/nodes/handlers/routers"
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/database"
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/genesis"
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/peer"
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/state"
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/storage/memory"
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/worker"
+	"go.uber.org/zap"
+)
+
+// Package simnet runs a network of full nodes in a single process so the
+// behavior of the network can be tested. Every node has its own state and
+// worker, and the requests between nodes are routed in memory to the private
+// routes of the node being called. The links between nodes can be given
+// latency and message loss, the network can be partitioned, and nodes can
+// be crashed and restarted with the blocks they had stored.
+
+// Set of errors returned when a request can't be delivered.
+var (
+	ErrUnreachable = errors.New("host unreachable")
+	ErrMessageLost = errors.New("message lost")
+)
+
+// Config represents the settings for the simulated network. The Genesis is
+// shared by every node and must have the Date set, since nodes refuse peers
+// with a different genesis.
+type Config struct {
+	Nodes              int
+	Consensus          string
+	Genesis            genesis.Genesis
+	PeerUpdateInterval time.Duration
+	CycleDuration      time.Duration
+	Log                *zap.SugaredLogger
+	EvHandler          func(host string, v string, args ...any)
+}
+
+// Node represents a full node running in the network.
+type Node struct {
+	Index   int
+	Host    string
+	Key     *ecdsa.PrivateKey
+	storage *memory.Memory
+
+	mu      sync.RWMutex
+	state   *state.State
+	handler http.Handler
+	running bool
+}
+
+// State returns the state of the node. After a restart, the node has a new
+// state value.
+func (n *Node) State() *state.State {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	return n.state
+}
+
+// Running reports if the node is up.
+func (n *Node) Running() bool {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	return n.running
+}
+
+// AccountID returns the account that receives the rewards for the blocks
+// the node mines.
+func (n *Node) AccountID() database.AccountID {
+	return database.PublicKeyToAccountID(n.Key.PublicKey)
+}
+
+// Network represents a set of nodes that can only reach each other through
+// the simulated links.
+type Network struct {
+	cfg    Config
+	nodes  []*Node
+	byHost map[string]*Node
+
+	mu          sync.RWMutex
+	defaultLink Link
+	links       map[[2]int]Link
+	groups      map[int]int
+}
+
+// New constructs a network with the specified number of nodes. The nodes
+// aren't running until Start is called.
+func New(cfg Config) (*Network, error) {
+	if cfg.Nodes < 1 {
+		return nil, errors.New("at least one node is required")
+	}
+	if cfg.Genesis.Date.IsZero() {
+		return nil, errors.New("genesis date is required")
+	}
+	if cfg.Consensus == "" {
+		cfg.Consensus = state.ConsensusPOW
+	}
+	if cfg.Log == nil {
+		cfg.Log = zap.NewNop().Sugar()
+	}
+
+	net := Network{
+		cfg:    cfg,
+		nodes:  make([]*Node, cfg.Nodes),
+		byHost: make(map[string]*Node, cfg.Nodes),
+		links:  make(map[[2]int]Link),
+	}
+
+	for i := range net.nodes {
+		key, err := crypto.GenerateKey()
+		if err != nil {
+			return nil, fmt.Errorf("generating key for node %d: %w", i, err)
+		}
+
+		storage, err := memory.New()
+		if err != nil {
+			return nil, err
+		}
+
+		n := Node{
+			Index:   i,
+			Host:    fmt.Sprintf("node%d:9080", i),
+			Key:     key,
+			storage: storage,
+		}
+
+		net.nodes[i] = &n
+		net.byHost[n.Host] = &n
+	}
+
+	return &net, nil
+}
+
+// Start starts every node in order. The first node is the origin node the
+// other nodes connect to, like the defaults in main.go.
+func (net *Network) Start() error {
+	for _, n := range net.nodes {
+		if err := net.start(n); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Shutdown brings down every node that is running.
+func (net *Network) Shutdown() {
+	for _, n := range net.nodes {
+		if n.Running() {
+			net.Crash(n.Index)
+		}
+	}
+}
+
+// Node returns the node at the specified index.
+func (net *Network) Node(i int) *Node {
+	return net.nodes[i]
+}
+
+// Nodes returns every node in the network.
+func (net *Network) Nodes() []*Node {
+	return net.nodes
+}
+
+// Crash brings the node down. The node stops answering requests before its
+// worker is shut down, so the rest of the network sees it go away at once.
+func (net *Network) Crash(i int) error {
+	n := net.nodes[i]
+
+	n.mu.Lock()
+	if !n.running {
+		n.mu.Unlock()
+		return fmt.Errorf("node %d is not running", i)
+	}
+	n.running = false
+	st := n.state
+	n.mu.Unlock()
+
+	return st.Shutdown()
+}
+
+// Restart brings a crashed node back up with the blocks it had stored.
+func (net *Network) Restart(i int) error {
+	n := net.nodes[i]
+	if n.Running() {
+		return fmt.Errorf("node %d is running", i)
+	}
+
+	return net.start(n)
+}
+
+// =============================================================================
+
+// start constructs the state for the node and runs its worker, which syncs
+// the node with the network before returning.
+func (net *Network) start(n *Node) error {
+	ev := func(v string, args ...any) {
+		if net.cfg.EvHandler != nil {
+			net.cfg.EvHandler(n.Host, v, args...)
+		}
+	}
+
+	peerSet := peer.NewPeerSet()
+	if origin := net.nodes[0]; origin != n {
+		peerSet.Add(peer.New(origin.Host))
+	}
+	peerSet.Reserve(peer.New(n.Host))
+
+	st, err := state.New(state.Config{
+		BeneficiaryID:  n.AccountID(),
+		NodeKey:        n.Key,
+		Host:           n.Host,
+		Storage:        n.storage,
+		Genesis:        net.cfg.Genesis,
+		SelectStrategy: "Tip",
+		KnownPeers:     peerSet,
+		Client:         &http.Client{Transport: roundTripper{net: net, from: n}},
+		Consensus:      net.cfg.Consensus,
+		EvHandler:      ev,
+	})
+	if err != nil {
+		return fmt.Errorf("constructing node %d: %w", n.Index, err)
+	}
+
+	mux := routers.PrivateMux(routers.MuxConfig{
+		Shutdown: make(chan os.Signal, 1),
+		Log:      net.cfg.Log,
+		State:    st,
+	})
+
+	// Like main.go, the worker syncs the node with the network before the
+	// node starts answering requests.
+	worker.RunWithConfig(st, worker.Config{
+		PeerUpdateInterval: net.cfg.PeerUpdateInterval,
+		CycleDuration:      net.cfg.CycleDuration,
+		EvHandler:          ev,
+	})
+
+	n.mu.Lock()
+	n.state = st
+	n.handler = mux
+	n.running = true
+	n.mu.Unlock()
+
+	return nil
+}
@@ -0,0 +1,310 @@
+package simnet_test
+
+import (
+	"errors"
+	"hash/fnv"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/zacksfF/FullStack-Blockchain/app/services/nodes/simnet"
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/database"
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/genesis"
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/peer"
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/state"
+)
+
+const (
+	kennedyPrivateKey = "9f332e3700d8fc2446eaf6d15034cf96e0c2745e40353deef032a5dbf1dfed93"
+	ceasarPrivateKey  = "601d7574860c135e9d3c1d52b0ee997404130edc2a1177c78fda92dd6a3dc2f7"
+
+	kennedyAccountID = database.AccountID("0xF01813E4B85e178A83e29B8E7bF26BD830a25f32")
+	ceasarAccountID  = database.AccountID("0xbEE6ACE826eC3DE1B6349888B9151B92522F7F76")
+	edAccountID      = database.AccountID("0xa988b1866EaBF72B4c53b592c97aAD8e4b9bDCC0")
+
+	chainID = 1
+)
+
+// waitMargin is the time left before the test deadline for a test that
+// gave up waiting to report it and shut down the network. Without a
+// deadline, a test waits for waitTime.
+const (
+	waitMargin = 10 * time.Second
+	waitTime   = time.Minute
+)
+
+func Test_ForkReorg(t *testing.T) {
+	net := newNetwork(t, simnet.Config{Nodes: 2, Consensus: state.ConsensusPOW})
+
+	// Each side of the partition mines its own blocks, which forks the chain.
+	net.Partition([]int{0}, []int{1})
+
+	for nonce := uint64(1); nonce <= 4; nonce++ {
+		submitTx(t, net.Node(0), kennedyPrivateKey, nonce)
+		waitHeight(t, net.Node(0), nonce)
+	}
+
+	submitTx(t, net.Node(1), ceasarPrivateKey, 1)
+	waitHeight(t, net.Node(1), 1)
+
+	fork := blockHash(net.Node(1), 1)
+	if fork == blockHash(net.Node(0), 1) {
+		t.Fatal("Should mine a different block on each side of the partition")
+	}
+
+	// Once the partition heals, the next block from the longer chain makes
+	// the node on the shorter chain reorganize onto it.
+	net.Heal()
+
+	submitTx(t, net.Node(0), kennedyPrivateKey, 5)
+	waitHeight(t, net.Node(0), 5)
+	waitConverged(t, net)
+
+	if blockHash(net.Node(1), 1) == fork {
+		t.Fatal("Should drop the block mined on the shorter chain")
+	}
+
+	for _, n := range net.Nodes() {
+		account, err := n.State().QueryAccount(kennedyAccountID)
+		if err != nil {
+			t.Fatalf("Should be able to query the account on %s: %s", n.Host, err)
+		}
+		if account.Nonce != 5 {
+			t.Fatalf("Should apply every transaction on %s, got nonce %d, exp 5", n.Host, account.Nonce)
+		}
+	}
+}
+
+func Test_CrashRestart(t *testing.T) {
+	net := newNetwork(t, simnet.Config{Nodes: 2, Consensus: state.ConsensusPOW})
+
+	if err := net.Crash(1); err != nil {
+		t.Fatalf("Should be able to crash the node: %s", err)
+	}
+
+	for nonce := uint64(1); nonce <= 3; nonce++ {
+		submitTx(t, net.Node(0), kennedyPrivateKey, nonce)
+		waitHeight(t, net.Node(0), nonce)
+	}
+
+	// The node keeps the blocks it had stored and syncs the blocks it missed
+	// when it comes back.
+	if err := net.Restart(1); err != nil {
+		t.Fatalf("Should be able to restart the node: %s", err)
+	}
+
+	waitConverged(t, net)
+
+	if got := net.Node(1).State().LatestBlock().Header.Number; got != 3 {
+		t.Fatalf("Should sync the missed blocks, got height %d, exp 3", got)
+	}
+}
+
+func Test_LeaderRotation(t *testing.T) {
+	const blocks = 6
+
+	net := newNetwork(t, simnet.Config{
+		Nodes:         3,
+		Consensus:     state.ConsensusPOA,
+		CycleDuration: 250 * time.Millisecond,
+	})
+	net.SetDefaultLink(simnet.Link{Latency: 5 * time.Millisecond})
+
+	// Every node has to know the full set of nodes to select the same leader.
+	// A node only shares the peers it confirmed with a handshake, which can
+	// happen after the other nodes looked for peers, so the nodes keep
+	// looking instead of waiting for the next peer update.
+	waitFor(t, "every node knows every node", func() bool {
+		for _, n := range net.Nodes() {
+			if len(n.State().KnownPeers()) != len(net.Nodes()) {
+				n.State().Discover()
+				return false
+			}
+		}
+		return true
+	})
+
+	for nonce := uint64(1); nonce <= blocks; nonce++ {
+		submitTx(t, net.Node(0), kennedyPrivateKey, nonce)
+		for _, n := range net.Nodes() {
+			waitHeight(t, n, nonce)
+		}
+	}
+	waitConverged(t, net)
+
+	hosts := make([]string, len(net.Nodes()))
+	beneficiaries := make(map[string]database.AccountID)
+	for i, n := range net.Nodes() {
+		hosts[i] = n.Host
+		beneficiaries[n.Host] = n.AccountID()
+	}
+	sort.Strings(hosts)
+
+	// The leader for each block is picked from the hash of the block before.
+	chain := net.Node(0).State().QueryBlocksByNumber(1, blocks)
+	prevHash := database.Block{}.Hash()
+	for _, block := range chain {
+		h := fnv.New32a()
+		h.Write([]byte(prevHash))
+		leader := hosts[h.Sum32()%uint32(len(hosts))]
+
+		if block.Header.BeneficiaryID != beneficiaries[leader] {
+			t.Fatalf("Should have block %d mined by the leader %s, got %s", block.Header.Number, beneficiaries[leader], block.Header.BeneficiaryID)
+		}
+
+		prevHash = block.Hash()
+	}
+}
+
+func Test_Link(t *testing.T) {
+	const latency = 50 * time.Millisecond
+
+	net := newNetwork(t, simnet.Config{Nodes: 2, Consensus: state.ConsensusPOW})
+	node0 := net.Node(0).State()
+	node1 := peer.New(net.Node(1).Host)
+
+	net.SetLink(0, 1, simnet.Link{Latency: latency})
+	net.SetLink(1, 0, simnet.Link{Latency: latency})
+
+	start := time.Now()
+	if _, err := node0.NetRequestPeerStatus(node1); err != nil {
+		t.Fatalf("Should be able to reach the node: %s", err)
+	}
+	if d := time.Since(start); d < 2*latency {
+		t.Fatalf("Should delay the request and the response, got %v, exp at least %v", d, 2*latency)
+	}
+
+	net.SetLink(0, 1, simnet.Link{Loss: 1})
+	if _, err := node0.NetRequestPeerStatus(node1); !errors.Is(err, simnet.ErrMessageLost) {
+		t.Fatalf("Should lose the request, got %v", err)
+	}
+
+	net.SetLink(0, 1, simnet.Link{})
+	net.Partition([]int{0}, []int{1})
+	if _, err := node0.NetRequestPeerStatus(node1); !errors.Is(err, simnet.ErrUnreachable) {
+		t.Fatalf("Should not reach a node across the partition, got %v", err)
+	}
+
+	net.Heal()
+	if err := net.Crash(1); err != nil {
+		t.Fatalf("Should be able to crash the node: %s", err)
+	}
+	if _, err := node0.NetRequestPeerStatus(node1); !errors.Is(err, simnet.ErrUnreachable) {
+		t.Fatalf("Should not reach a crashed node, got %v", err)
+	}
+}
+
+// =============================================================================
+
+// newNetwork starts a network with funded accounts for the tests. The peer
+// operations are held off so a partition doesn't remove the peers.
+func newNetwork(t *testing.T, cfg simnet.Config) *simnet.Network {
+	cfg.Genesis = genesis.Genesis{
+		Date:          time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		ChainID:       chainID,
+		TransPerBlock: 10,
+		Difficulty:    1,
+		MiningReward:  700,
+		GasPrice:      15,
+		Balances: map[string]uint64{
+			string(kennedyAccountID): 1_000_000,
+			string(ceasarAccountID):  1_000_000,
+		},
+	}
+	cfg.PeerUpdateInterval = time.Hour
+
+	net, err := simnet.New(cfg)
+	if err != nil {
+		t.Fatalf("Should be able to construct the network: %s", err)
+	}
+
+	if err := net.Start(); err != nil {
+		t.Fatalf("Should be able to start the network: %s", err)
+	}
+	t.Cleanup(net.Shutdown)
+
+	return net
+}
+
+// submitTx sends a transaction from the account of the key to the node.
+func submitTx(t *testing.T, n *simnet.Node, hexKey string, nonce uint64) {
+	privateKey, err := crypto.HexToECDSA(hexKey)
+	if err != nil {
+		t.Fatalf("Should be able to construct the private key: %s", err)
+	}
+
+	tx := database.Tx{
+		ChainID: chainID,
+		Nonce:   nonce,
+		FromID:  database.PublicKeyToAccountID(privateKey.PublicKey),
+		ToID:    edAccountID,
+		Value:   1,
+	}
+
+	signedTx, err := tx.Sign(privateKey)
+	if err != nil {
+		t.Fatalf("Should be able to sign the transaction: %s", err)
+	}
+
+	if err := n.State().UpsertWalletTransaction(signedTx); err != nil {
+		t.Fatalf("Should be able to submit the transaction to %s: %s", n.Host, err)
+	}
+}
+
+// blockHash returns the hash of the block with the number on the node.
+func blockHash(n *simnet.Node, number uint64) string {
+	blocks := n.State().QueryBlocksByNumber(number, number)
+	if len(blocks) == 0 {
+		return ""
+	}
+
+	return blocks[0].Hash()
+}
+
+// waitHeight waits for the node to reach the block number.
+func waitHeight(t *testing.T, n *simnet.Node, number uint64) {
+	waitFor(t, n.Host+" reaches the height", func() bool {
+		return n.State().LatestBlock().Header.Number >= number
+	})
+}
+
+// waitConverged waits for every running node to have the same latest block.
+func waitConverged(t *testing.T, net *simnet.Network) {
+	waitFor(t, "the nodes converge", func() bool {
+		var hash string
+		for _, n := range net.Nodes() {
+			if !n.Running() {
+				continue
+			}
+			latest := n.State().LatestBlock().Hash()
+			if hash != "" && latest != hash {
+				return false
+			}
+			hash = latest
+		}
+		return true
+	})
+}
+
+// waitFor waits for the condition to be true. It waits for as long as the
+// test deadline allows, since a run under the race detector or on a loaded
+// machine can be many times slower than usual.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline, ok := t.Deadline()
+	if ok {
+		deadline = deadline.Add(-waitMargin)
+	} else {
+		deadline = time.Now().Add(waitTime)
+	}
+
+	start := time.Now()
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Should see %s within %v", what, time.Since(start).Round(time.Millisecond))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
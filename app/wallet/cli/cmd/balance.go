@@ -1,27 +1,16 @@
 package cmd
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"log"
-	"net/http"
 
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/spf13/cobra"
 	"github.com/zacksfF/FullStack-Blockchain/blockchain/database"
+	"github.com/zacksfF/FullStack-Blockchain/client"
 )
 
-type balance struct {
-	Account string `json:"account"`
-	Balance uint   `json:"balance"`
-}
-
-type balances struct {
-	LastestBlock string    `json:"lastest_block"`
-	Uncommitted  int       `json:"uncommitted"`
-	Balances     []balance `json:"balances"`
-}
-
 var balanceCmd = &cobra.Command{
 	Use:   "balance",
 	Short: "Print your balance .",
@@ -42,19 +31,14 @@ func balanceRun(cmd *cobra.Command, args []string) {
 	accountID := database.PublicKeyToAccountID(privateKey.PublicKey)
 	fmt.Println("For Account:", accountID)
 
-	resp, err := http.Get(fmt.Sprintf("%s/v1/accounts/list/%s", url, accountID))
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer resp.Body.Close()
+	node := client.New(client.Config{URL: url})
 
-	decoder := json.NewDecoder(resp.Body)
-	var balances balances
-	if err := decoder.Decode(&balances); err != nil {
+	accounts, err := node.Account(context.Background(), accountID)
+	if err != nil {
 		log.Fatal(err)
 	}
 
-	if len(balances.Balances) > 0 {
-		fmt.Println(balances.Balances[0].Balance)
+	if len(accounts.Accounts) > 0 {
+		fmt.Println(accounts.Accounts[0].Balance)
 	}
 }
@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"log"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/spf13/cobra"
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/database"
+)
+
+var cancelCmd = &cobra.Command{
+	Use:   "cancel",
+	Short: "Cancel a pending transaction",
+	Long: `Cancel replaces a pending transaction with a zero value transaction sent
+to yourself using the same nonce. The tip must be high enough for the node's
+replacement policy, which is a 10% bump by default.`,
+	Run: cancelRun,
+}
+
+func init() {
+	rootCmd.AddCommand(cancelCmd)
+	cancelCmd.Flags().StringVarP(&url, "url", "u", "http://localhost:8080", "Url of the node.")
+	cancelCmd.Flags().Uint64VarP(&nonce, "nonce", "n", 0, "Nonce of the transaction to cancel.")
+	cancelCmd.Flags().Uint64VarP(&tip, "tip", "c", 0, "Tip for the cancellation.")
+	cancelCmd.MarkFlagRequired("nonce")
+}
+
+func cancelRun(cmd *cobra.Command, args []string) {
+	privateKey, err := crypto.LoadECDSA(getPrivateKeyPath())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	account := database.PublicKeyToAccountID(privateKey.PublicKey)
+
+	from = string(account)
+	to = string(account)
+	value = 0
+	data = nil
+
+	sendWithDetails(privateKey)
+}
@@ -1,26 +1,28 @@
 package cmd
 
 import (
-	"bytes"
+	"context"
 	"crypto/ecdsa"
-	"encoding/json"
 	"fmt"
 	"log"
-	"net/http"
+	"time"
 
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/spf13/cobra"
 	"github.com/zacksfF/FullStack-Blockchain/blockchain/database"
+	"github.com/zacksfF/FullStack-Blockchain/client"
 )
 
 var (
-	url   string
-	nonce uint64
-	from  string
-	to    string
-	value uint64
-	tip   uint64
-	data  []byte
+	url         string
+	nonce       uint64
+	from        string
+	to          string
+	value       uint64
+	tip         uint64
+	data        []byte
+	expiryBlock uint64
+	expiresIn   time.Duration
 )
 
 var sendCmd = &cobra.Command{
@@ -38,6 +40,8 @@ func init() {
 	sendCmd.Flags().Uint64VarP(&value, "value", "v", 0, "Value to send.")
 	sendCmd.Flags().Uint64VarP(&tip, "tip", "c", 0, "Tip to send.")
 	sendCmd.Flags().BytesHexVarP(&data, "data", "d", nil, "Data to send.")
+	sendCmd.Flags().Uint64Var(&expiryBlock, "expiry-block", 0, "Last block number the transaction can be mined into.")
+	sendCmd.Flags().DurationVar(&expiresIn, "expires-in", 0, "How long the transaction can wait to be mined.")
 }
 
 func sendRun(cmd *cobra.Command, args []string) {
@@ -66,19 +70,22 @@ func sendWithDetails(privateKey *ecdsa.PrivateKey) {
 		log.Fatal(err)
 	}
 
-	signedTx, err := tx.Sign(privateKey)
-	if err != nil {
-		log.Fatal(err)
+	tx.ExpiryBlock = expiryBlock
+	if expiresIn > 0 {
+		tx.ExpiryTime = uint64(time.Now().Add(expiresIn).UTC().UnixMilli())
 	}
 
-	data, err := json.Marshal(signedTx)
+	signedTx, err := tx.Sign(privateKey)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	resp, err := http.Post(fmt.Sprintf("%s/v1/tx/submit", url), "application/json", bytes.NewBuffer(data))
-	if err != nil {
-		log.Fatal(err)
+	node := client.New(client.Config{URL: url})
+
+	if err := node.SubmitTransaction(context.Background(), signedTx); err != nil {
+		log.Fatalf("submitting transaction: %s", err)
 	}
-	defer resp.Body.Close()
+
+	// The hash can be used to follow the transaction with the wait command.
+	fmt.Println(signedTx.TxHash())
 }
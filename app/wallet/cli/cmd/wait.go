@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/zacksfF/FullStack-Blockchain/client"
+)
+
+var (
+	confirmations uint64
+	pollInterval  time.Duration
+	waitTimeout   time.Duration
+)
+
+var waitCmd = &cobra.Command{
+	Use:   "wait <hash>",
+	Short: "Wait for a transaction to be mined",
+	Long: `Wait polls the node for the status of the transaction until it has been
+mined with the requested number of confirmations. It fails if the transaction
+failed, was replaced, expired or was evicted from the mempool.`,
+	Args: cobra.ExactArgs(1),
+	Run:  waitRun,
+}
+
+func init() {
+	rootCmd.AddCommand(waitCmd)
+	waitCmd.Flags().StringVarP(&url, "url", "u", "http://localhost:8080", "Url of the node.")
+	waitCmd.Flags().Uint64VarP(&confirmations, "confirmations", "n", 1, "Number of confirmations to wait for.")
+	waitCmd.Flags().DurationVarP(&pollInterval, "interval", "i", 2*time.Second, "How often to ask the node for the status.")
+	waitCmd.Flags().DurationVar(&waitTimeout, "timeout", 0, "How long to wait before giving up. Zero waits forever.")
+}
+
+func waitRun(cmd *cobra.Command, args []string) {
+	hash := args[0]
+
+	var deadline time.Time
+	if waitTimeout > 0 {
+		deadline = time.Now().Add(waitTimeout)
+	}
+
+	node := client.New(client.Config{URL: url})
+
+	var last string
+	for {
+		status, found, err := queryTxStatus(node, hash)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		var line string
+		switch {
+		case !found:
+			line = "unknown"
+		case status.Status == "pending" && status.Position != nil:
+			line = fmt.Sprintf("pending: position %d", *status.Position)
+		case status.Status == "mined":
+			line = fmt.Sprintf("mined: block %d: confirmations %d", status.BlockNumber, status.Confirmations)
+		case status.Status == "replaced":
+			line = fmt.Sprintf("replaced: by %s", status.ReplacedBy)
+		case status.Reason != "":
+			line = fmt.Sprintf("%s: %s", status.Status, status.Reason)
+		default:
+			line = status.Status
+		}
+
+		if line != last {
+			fmt.Println(line)
+			last = line
+		}
+
+		switch status.Status {
+		case "mined":
+			if status.Confirmations >= confirmations {
+				return
+			}
+		case "failed", "replaced", "expired", "evicted":
+			log.Fatalf("transaction %s will not be mined", hash)
+		}
+
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			log.Fatalf("timed out waiting for transaction %s", hash)
+		}
+
+		time.Sleep(pollInterval)
+	}
+}
+
+// queryTxStatus asks the node for the status of the transaction. A node that
+// doesn't know about the transaction yet is not an error.
+func queryTxStatus(node *client.Client, hash string) (client.TxStatus, bool, error) {
+	status, err := node.TxStatus(context.Background(), hash)
+	if err != nil {
+		if client.StatusCode(err) == http.StatusNotFound {
+			return client.TxStatus{}, false, nil
+		}
+		return client.TxStatus{}, false, fmt.Errorf("querying transaction status: %w", err)
+	}
+
+	return status, true, nil
+}
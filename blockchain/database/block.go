@@ -3,6 +3,7 @@ package database
 import (
 	"context"
 	"crypto/rand"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math"
@@ -17,6 +18,25 @@ import (
 // is two or more blocks ahead of ours.
 var ErrChainForked = errors.New("blockchain forked, start resync")
 
+// ErrBlockOutOfOrder is returned when a block doesn't follow the latest block.
+// This happens when peers race to mine the same block and is not misbehavior.
+var ErrBlockOutOfOrder = errors.New("block is out of order")
+
+// ErrInvalidHeader is returned when a chain of block headers received from
+// a peer is not valid.
+var ErrInvalidHeader = errors.New("invalid block header")
+
+// ErrBlockNotFound is returned by storage when a block doesn't exist.
+var ErrBlockNotFound = errors.New("block not found")
+
+// MaxBlockSize is the largest a block can be once it's encoded to be sent
+// over the network. A larger block is not valid.
+const MaxBlockSize = 2 << 20
+
+// blockOverhead is the room kept for the hash and header of a block around
+// its transactions.
+const blockOverhead = 1 << 10
+
 // =============================================================================
 
 // BlockData represents what can be serialized to disk and over the network.
@@ -125,6 +145,25 @@ func POW(ctx context.Context, args POWArgs) (Block, error) {
 	return block, nil
 }
 
+// FitTrans returns the leading transactions that fit into a block that is
+// no larger than MaxBlockSize.
+func FitTrans(trans []BlockTx) []BlockTx {
+	size := blockOverhead
+	for i, tx := range trans {
+		data, err := json.Marshal(tx)
+		if err != nil {
+			return trans[:i]
+		}
+
+		size += len(data) + 1
+		if size > MaxBlockSize {
+			return trans[:i]
+		}
+	}
+
+	return trans
+}
+
 // performPOW does the work of mining to find a valid hash for a specified
 // block. Pointer semantics are being used since a nonce is being discovered.
 func (b *Block) performPOW(ctx context.Context, ev func(v string, args ...any)) error {
@@ -194,6 +233,17 @@ func (b Block) Hash() string {
 	return signature.Hash(b.Header)
 }
 
+// Size returns the number of bytes the block takes once it's encoded to be
+// sent over the network.
+func (b Block) Size() int {
+	data, err := json.Marshal(NewBlockData(b))
+	if err != nil {
+		return 0
+	}
+
+	return len(data)
+}
+
 // ValidateBlock takes a block and validates it to be included into the blockchain.
 func (b Block) ValidateBlock(previousBlock Block, stateRoot string, evHandler func(v string, args ...any)) error {
 	evHandler("database: ValidateBlock: validate: blk[%d]: check: chain is not forked", b.Header.Number)
@@ -221,13 +271,13 @@ func (b Block) ValidateBlock(previousBlock Block, stateRoot string, evHandler fu
 	evHandler("database: ValidateBlock: validate: blk[%d]: check: block number is the next number", b.Header.Number)
 
 	if b.Header.Number != nextNumber {
-		return fmt.Errorf("this block is not the next number, got %d, exp %d", b.Header.Number, nextNumber)
+		return fmt.Errorf("%w: this block is not the next number, got %d, exp %d", ErrBlockOutOfOrder, b.Header.Number, nextNumber)
 	}
 
 	evHandler("database: ValidateBlock: validate: blk[%d]: check: parent hash does match parent block", b.Header.Number)
 
 	if b.Header.PrevBlockHash != previousBlock.Hash() {
-		return fmt.Errorf("parent block hash doesn't match our known parent, got %s, exp %s", b.Header.PrevBlockHash, previousBlock.Hash())
+		return fmt.Errorf("%w: parent block hash doesn't match our known parent, got %s, exp %s", ErrBlockOutOfOrder, b.Header.PrevBlockHash, previousBlock.Hash())
 	}
 
 	if previousBlock.Header.TimeStamp > 0 {
@@ -255,12 +305,67 @@ func (b Block) ValidateBlock(previousBlock Block, stateRoot string, evHandler fu
 		return fmt.Errorf("state of the accounts are wrong, current %s, expected %s", stateRoot, b.Header.StateRoot)
 	}
 
+	evHandler("database: ValidateBlock: validate: blk[%d]: check: block is not over the max size", b.Header.Number)
+
+	if size := b.Size(); size > MaxBlockSize {
+		return fmt.Errorf("block is %d bytes, over the max of %d", size, MaxBlockSize)
+	}
+
 	evHandler("database: ValidateBlock: validate: blk[%d]: check: merkle root does match transactions", b.Header.Number)
 
 	if b.Header.TransRoot != b.MerkleTree.RootHex() {
 		return fmt.Errorf("merkle root does not match transactions, got %s, exp %s", b.MerkleTree.RootHex(), b.Header.TransRoot)
 	}
 
+	evHandler("database: ValidateBlock: validate: blk[%d]: check: transactions have not expired", b.Header.Number)
+
+	for _, tx := range b.MerkleTree.Values() {
+		if tx.IsExpired(b.Header.Number, b.Header.TimeStamp) {
+			return fmt.Errorf("%w: tx[%s]", ErrTxExpired, tx)
+		}
+	}
+
+	return nil
+}
+
+// ValidateHeaders checks the headers form a chain that extends the previous
+// block. Only what can be checked from the headers alone is validated, so
+// the full blocks still need to be validated as they are added to the chain.
+// When the first header doesn't extend the previous block the peer is on a
+// different fork and ErrBlockOutOfOrder is returned.
+func ValidateHeaders(previousBlock Block, headers []BlockHeader) error {
+	prev := previousBlock
+
+	for i, header := range headers {
+		block := Block{Header: header}
+
+		nextNumber := prev.Header.Number + 1
+		if header.Number != nextNumber {
+			return fmt.Errorf("%w: this header is not the next number, got %d, exp %d", ErrInvalidHeader, header.Number, nextNumber)
+		}
+
+		if header.PrevBlockHash != prev.Hash() {
+			if i == 0 {
+				return fmt.Errorf("%w: parent block hash doesn't match our known parent, got %s, exp %s", ErrBlockOutOfOrder, header.PrevBlockHash, prev.Hash())
+			}
+			return fmt.Errorf("%w: blk[%d]: parent block hash doesn't match parent header", ErrInvalidHeader, header.Number)
+		}
+
+		if header.Difficulty < prev.Header.Difficulty {
+			return fmt.Errorf("%w: blk[%d]: block difficulty is less than previous block difficulty, parent %d, block %d", ErrInvalidHeader, header.Number, prev.Header.Difficulty, header.Difficulty)
+		}
+
+		if hash := block.Hash(); !isHashSolved(header.Difficulty, hash) {
+			return fmt.Errorf("%w: blk[%d]: %s invalid block hash", ErrInvalidHeader, header.Number, hash)
+		}
+
+		if prev.Header.TimeStamp > 0 && header.TimeStamp < prev.Header.TimeStamp {
+			return fmt.Errorf("%w: blk[%d]: block timestamp is before parent block", ErrInvalidHeader, header.Number)
+		}
+
+		prev = block
+	}
+
 	return nil
 }
 
@@ -0,0 +1,166 @@
+package database
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// CORE NOTE: Bitcoin relays new blocks in a compact form (BIP 152) since the
+// peers receiving a block almost always have its transactions in their
+// mempool already. Only the header and a short id for each transaction is
+// sent. The peer rebuilds the block from its mempool and asks for the
+// transactions it's missing. The short id is taken from the same hash used
+// for the merkle tree, so a transaction only matches when it's exactly the
+// one recorded in the block.
+
+// ShortIDSize is the number of bytes of the transaction hash used to
+// identify a transaction in a compact block.
+const ShortIDSize = 8
+
+// ErrInvalidCompactBlock is returned when a compact block can't describe a
+// valid block.
+var ErrInvalidCompactBlock = errors.New("invalid compact block")
+
+// PrefilledTx represents a transaction sent with a compact block because the
+// peer doesn't have it.
+type PrefilledTx struct {
+	Index int     `json:"index"`
+	Tx    BlockTx `json:"tx"`
+}
+
+// CompactBlock represents a block with its transactions replaced by short
+// ids, which is what is sent to peers when a block is proposed.
+type CompactBlock struct {
+	Hash      string        `json:"hash"`
+	Header    BlockHeader   `json:"block"`
+	ShortIDs  []string      `json:"short_ids"`
+	Prefilled []PrefilledTx `json:"prefilled,omitempty"`
+}
+
+// NewCompactBlock constructs a compact block from a block.
+func NewCompactBlock(block Block) (CompactBlock, error) {
+	trans := block.MerkleTree.Values()
+
+	cb := CompactBlock{
+		Hash:     block.Hash(),
+		Header:   block.Header,
+		ShortIDs: make([]string, len(trans)),
+	}
+
+	for i, tx := range trans {
+		id, err := ShortTxID(tx)
+		if err != nil {
+			return CompactBlock{}, err
+		}
+		cb.ShortIDs[i] = id
+	}
+
+	return cb, nil
+}
+
+// ShortTxID returns the short id for the transaction.
+func ShortTxID(tx BlockTx) (string, error) {
+	hash, err := tx.Hash()
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash[:ShortIDSize]), nil
+}
+
+// Prefill returns a copy of the compact block that carries the block
+// transactions at the specified indexes.
+func (cb CompactBlock) Prefill(block Block, indexes []int) (CompactBlock, error) {
+	trans := block.MerkleTree.Values()
+
+	prefilled := make([]PrefilledTx, 0, len(indexes))
+	for _, i := range indexes {
+		if i < 0 || i >= len(trans) {
+			return CompactBlock{}, fmt.Errorf("%w: index %d out of range", ErrInvalidCompactBlock, i)
+		}
+		prefilled = append(prefilled, PrefilledTx{Index: i, Tx: trans[i]})
+	}
+
+	cb.Prefilled = prefilled
+	return cb, nil
+}
+
+// Reconstruct rebuilds the block from the prefilled transactions and the
+// transactions in the pool. When transactions are missing, the indexes of
+// the missing transactions are returned instead of the block.
+func (cb CompactBlock) Reconstruct(pool []BlockTx) (Block, []int, error) {
+	prefilled := make(map[int]BlockTx, len(cb.Prefilled))
+	for _, ptx := range cb.Prefilled {
+		if ptx.Index < 0 || ptx.Index >= len(cb.ShortIDs) {
+			return Block{}, nil, fmt.Errorf("%w: index %d out of range", ErrInvalidCompactBlock, ptx.Index)
+		}
+
+		id, err := ShortTxID(ptx.Tx)
+		if err != nil {
+			return Block{}, nil, err
+		}
+		if id != cb.ShortIDs[ptx.Index] {
+			return Block{}, nil, fmt.Errorf("%w: tx at index %d doesn't match short id", ErrInvalidCompactBlock, ptx.Index)
+		}
+
+		prefilled[ptx.Index] = ptx.Tx
+	}
+
+	// Two transactions in the pool with the same short id can't be told
+	// apart, so neither is used.
+	byID := make(map[string]BlockTx, len(pool))
+	ambiguous := make(map[string]bool)
+	for _, tx := range pool {
+		id, err := ShortTxID(tx)
+		if err != nil {
+			continue
+		}
+		if _, exists := byID[id]; exists {
+			ambiguous[id] = true
+		}
+		byID[id] = tx
+	}
+
+	trans := make([]BlockTx, len(cb.ShortIDs))
+	var missing []int
+	for i, id := range cb.ShortIDs {
+		if tx, exists := prefilled[i]; exists {
+			trans[i] = tx
+			continue
+		}
+
+		tx, exists := byID[id]
+		if !exists || ambiguous[id] {
+			missing = append(missing, i)
+			continue
+		}
+		trans[i] = tx
+	}
+
+	if len(missing) > 0 {
+		return Block{}, missing, nil
+	}
+
+	block, err := ToBlock(BlockData{Hash: cb.Hash, Header: cb.Header, Trans: trans})
+	if err != nil {
+		return Block{}, nil, fmt.Errorf("%w: %w", ErrInvalidCompactBlock, err)
+	}
+
+	// A transaction from the pool can share a short id with the one in the
+	// block without being the same transaction. Ask for every transaction
+	// that was taken from the pool so the block can be rebuilt exactly.
+	if block.MerkleTree.RootHex() != cb.Header.TransRoot {
+		for i := range cb.ShortIDs {
+			if _, exists := prefilled[i]; !exists {
+				missing = append(missing, i)
+			}
+		}
+		if len(missing) == 0 {
+			return Block{}, nil, fmt.Errorf("%w: transactions don't match header", ErrInvalidCompactBlock)
+		}
+		return Block{}, missing, nil
+	}
+
+	return block, nil, nil
+}
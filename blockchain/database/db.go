@@ -14,10 +14,17 @@ import (
 // blockchain in storage and maintaining an in-memory databse of account information.
 
 // Storage interface represents the behavior required to be implemented by any
-// package providing support for reading and writing the blockchain.
+// package providing support for reading and writing the blockchain. Blocks
+// are indexed by hash when they are written, and GetBlockByHash returns
+// ErrBlockNotFound for a hash that isn't in the index. Receipts are stored
+// by transaction hash and GetReceipt returns ErrReceiptNotFound for a
+// transaction without one.
 type Storage interface {
 	Write(blockData BlockData) error
 	GetBlock(num uint64) (BlockData, error)
+	GetBlockByHash(hash string) (BlockData, error)
+	WriteReceipt(receipt Receipt) error
+	GetReceipt(txHash string) (Receipt, error)
 	ForEach() Iterator
 	Close() error
 	Reset() error
@@ -39,15 +46,24 @@ type Database struct {
 	latestBlock Block
 	accounts    map[AccountID]Account
 	storage     Storage
+	evHandler   func(v string, args ...any)
 }
 
 // New constructs a new database and applies account genesis information and
 // reads/writes the blockchain database on disk if a dbPath is provided.
 func New(genesis genesis.Genesis, storage Storage, evHandler func(v string, args ...any)) (*Database, error) {
+	// Build a safe event handler function for use.
+	ev := func(v string, args ...any) {
+		if evHandler != nil {
+			evHandler(v, args...)
+		}
+	}
+
 	db := Database{
-		genesis:  genesis,
-		accounts: make(map[AccountID]Account),
-		storage:  storage,
+		genesis:   genesis,
+		accounts:  make(map[AccountID]Account),
+		storage:   storage,
+		evHandler: ev,
 	}
 
 	// Update the database with account balance information from genesis.
@@ -71,9 +87,17 @@ func New(genesis genesis.Genesis, storage Storage, evHandler func(v string, args
 			return nil, err
 		}
 
-		// Update the database with the transaction information.
+		// Update the database with the transaction information. A receipt
+		// missing from storage, like when the node stopped before it was
+		// written, is written again.
 		for _, tx := range block.MerkleTree.Values() {
-			db.ApplyTransaction(block, tx)
+			err := db.applyTransaction(block, tx)
+
+			if _, rerr := storage.GetReceipt(tx.TxHash()); errors.Is(rerr, ErrReceiptNotFound) {
+				if err := storage.WriteReceipt(newReceipt(block, tx, err)); err != nil {
+					return nil, fmt.Errorf("writing receipt: %w", err)
+				}
+			}
 		}
 		db.ApplyMiningReward(block)
 
@@ -171,12 +195,29 @@ func (db *Database) ApplyMiningReward(block Block) {
 	db.accounts[block.Header.BeneficiaryID] = account
 }
 
+// Receipt returns the receipt for the specified transaction from storage.
+// ErrReceiptNotFound is returned if it hasn't been mined into a block.
+func (db *Database) Receipt(txHash string) (Receipt, error) {
+	return db.storage.GetReceipt(txHash)
+}
+
 // ApplyTransaction performs the business logic for applying a transaction
-// to the database.
+// to the database. A receipt is written to storage with the outcome.
 func (db *Database) ApplyTransaction(block Block, tx BlockTx) error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
+	err := db.applyTransaction(block, tx)
+	if werr := db.storage.WriteReceipt(newReceipt(block, tx, err)); werr != nil {
+		db.evHandler("database: ApplyTransaction: receipt: WARNING: tx[%s]: %s", tx, werr)
+	}
+
+	return err
+}
+
+// applyTransaction updates the accounts for the transaction. The caller
+// must hold the write lock.
+func (db *Database) applyTransaction(block Block, tx BlockTx) error {
 	// Capture these accounts from the database.
 	from, exists := db.accounts[tx.FromID]
 	if !exists {
@@ -219,8 +260,10 @@ func (db *Database) ApplyTransaction(block Block, tx BlockTx) error {
 	}
 
 	// Update the balances between the two parties.
-	from.Balance -= tx.Value
-	to.Balance += tx.Value
+	if tx.ToID != tx.FromID {
+		from.Balance -= tx.Value
+		to.Balance += tx.Value
+	}
 
 	// Give the beneficiary the tip.
 	from.Balance -= tx.Tip
@@ -229,9 +272,12 @@ func (db *Database) ApplyTransaction(block Block, tx BlockTx) error {
 	// Update the nonce for the next transaction check.
 	from.Nonce = tx.Nonce
 
-	// Update the final changes to these accounts.
+	// Update the final changes to these accounts. When the transaction is
+	// sent to yourself, the from account already holds every change.
+	if tx.ToID != tx.FromID {
+		db.accounts[tx.ToID] = to
+	}
 	db.accounts[tx.FromID] = from
-	db.accounts[tx.ToID] = to
 	db.accounts[block.Header.BeneficiaryID] = bnfc
 
 	return nil
@@ -275,6 +321,17 @@ func (db *Database) GetBlock(num uint64) (Block, error) {
 	return ToBlock(blockData)
 }
 
+// GetBlockByHash locates and returns the block with the specified hash
+// using the hash index of the storage.
+func (db *Database) GetBlockByHash(hash string) (Block, error) {
+	blockData, err := db.storage.GetBlockByHash(hash)
+	if err != nil {
+		return Block{}, err
+	}
+
+	return ToBlock(blockData)
+}
+
 // =============================================================================
 
 // DatabaseIterator provides support for iterating over the blocks in the
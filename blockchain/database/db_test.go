@@ -1,8 +1,10 @@
 package database_test
 
 import (
+	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/zacksfF/FullStack-Blockchain/blockchain/database"
@@ -157,6 +159,266 @@ func TestNonceValidation(t *testing.T) {
 	}
 }
 
+func Test_SelfTransaction(t *testing.T) {
+	const (
+		from  = database.AccountID("0xdd6B972ffcc631a62CAE1BB9d80b7ff429c8ebA4")
+		miner = database.AccountID("0xFef311483Cc040e1A89fb9bb469eeB8A70935EF8")
+	)
+
+	db, err := database.New(genesis.Genesis{ChainID: 1, Balances: map[string]uint64{string(from): 1000}}, MockStorage{}, nil)
+	if err != nil {
+		t.Fatalf("Should be able to open database: %v", err)
+	}
+
+	// A transaction sent to yourself with a value is still refused.
+	blockTx, err := sign(database.Tx{ChainID: 1, Nonce: 1, FromID: from, ToID: from, Value: 10}, 0)
+	if err != nil {
+		t.Fatalf("Should be able to sign transaction: %v", err)
+	}
+	if err := blockTx.Validate(1); err == nil {
+		t.Fatalf("Should not be able to send value to yourself.")
+	}
+
+	// A cancellation sends nothing to yourself and only pays the fees.
+	blockTx, err = sign(database.Tx{ChainID: 1, Nonce: 1, FromID: from, ToID: from, Tip: 5}, 10)
+	if err != nil {
+		t.Fatalf("Should be able to sign transaction: %v", err)
+	}
+	if err := blockTx.Validate(1); err != nil {
+		t.Fatalf("Should be able to send nothing to yourself: %v", err)
+	}
+
+	if err := db.ApplyTransaction(database.Block{Header: database.BlockHeader{BeneficiaryID: miner}}, blockTx); err != nil {
+		t.Fatalf("Should be able to apply transaction: %v", err)
+	}
+
+	accounts := db.Copy()
+	if accounts[from].Balance != 985 || accounts[from].Nonce != 1 {
+		t.Fatalf("Should only pay the fees and use the nonce, got balance %d nonce %d", accounts[from].Balance, accounts[from].Nonce)
+	}
+	if accounts[miner].Balance != 15 {
+		t.Fatalf("Should pay the fees to the miner, got %d", accounts[miner].Balance)
+	}
+}
+
+func Test_ExpiredTransaction(t *testing.T) {
+	noop := func(v string, args ...any) {}
+
+	tt := []struct {
+		name    string
+		tx      database.Tx
+		expired bool
+	}{
+		{name: "no expiry", tx: database.Tx{ChainID: 1, Nonce: 1}},
+		{name: "block in range", tx: database.Tx{ChainID: 1, Nonce: 1, ExpiryBlock: 1}},
+		{name: "time in range", tx: database.Tx{ChainID: 1, Nonce: 1, ExpiryTime: uint64(time.Now().Add(time.Hour).UnixMilli())}},
+		{name: "time passed", tx: database.Tx{ChainID: 1, Nonce: 1, ExpiryTime: 1}, expired: true},
+	}
+
+	for _, tst := range tt {
+		f := func(t *testing.T) {
+			tst.tx.FromID = "0xdd6B972ffcc631a62CAE1BB9d80b7ff429c8ebA4"
+			tst.tx.ToID = "0xF01813E4B85e178A83e29B8E7bF26BD830a25f32"
+
+			blockTx, err := sign(tst.tx, 0)
+			if err != nil {
+				t.Fatalf("Test %s:\tShould be able to sign transaction: %v", tst.name, err)
+			}
+
+			block, err := database.POW(context.Background(), database.POWArgs{
+				Difficulty: 1,
+				StateRoot:  "state",
+				Trans:      []database.BlockTx{blockTx},
+				EvHandler:  noop,
+			})
+			if err != nil {
+				t.Fatalf("Test %s:\tShould be able to mine the block: %v", tst.name, err)
+			}
+
+			err = block.ValidateBlock(database.Block{}, "state", noop)
+			switch {
+			case tst.expired && !errors.Is(err, database.ErrTxExpired):
+				t.Fatalf("Test %s:\tShould reject the block with an expired transaction, got: %v", tst.name, err)
+			case !tst.expired && err != nil:
+				t.Fatalf("Test %s:\tShould accept the block: %v", tst.name, err)
+			}
+		}
+
+		t.Run(tst.name, f)
+	}
+}
+
+func Test_ValidateHeaders(t *testing.T) {
+	noop := func(v string, args ...any) {}
+
+	tx, err := sign(database.Tx{ChainID: 1, Nonce: 1, FromID: "0xdd6B972ffcc631a62CAE1BB9d80b7ff429c8ebA4", ToID: "0xF01813E4B85e178A83e29B8E7bF26BD830a25f32"}, 0)
+	if err != nil {
+		t.Fatalf("Should be able to sign transaction: %v", err)
+	}
+
+	var headers []database.BlockHeader
+	var prev database.Block
+	for i := 0; i < 3; i++ {
+		block, err := database.POW(context.Background(), database.POWArgs{
+			Difficulty: 1,
+			PrevBlock:  prev,
+			StateRoot:  "state",
+			Trans:      []database.BlockTx{tx},
+			EvHandler:  noop,
+		})
+		if err != nil {
+			t.Fatalf("Should be able to mine block %d: %v", i+1, err)
+		}
+
+		headers = append(headers, block.Header)
+		prev = block
+	}
+
+	tampered := append([]database.BlockHeader(nil), headers...)
+	tampered[1].StateRoot = "other"
+
+	tt := []struct {
+		name    string
+		prev    database.Block
+		headers []database.BlockHeader
+		err     error
+	}{
+		{name: "valid", headers: headers},
+		{name: "fork", prev: database.Block{Header: database.BlockHeader{Number: 1, StateRoot: "fork"}}, headers: headers[1:], err: database.ErrBlockOutOfOrder},
+		{name: "gap", headers: []database.BlockHeader{headers[0], headers[2]}, err: database.ErrInvalidHeader},
+		{name: "tampered", headers: tampered, err: database.ErrInvalidHeader},
+	}
+
+	for _, tst := range tt {
+		f := func(t *testing.T) {
+			err := database.ValidateHeaders(tst.prev, tst.headers)
+			switch {
+			case tst.err == nil && err != nil:
+				t.Fatalf("Test %s:\tShould accept the headers: %v", tst.name, err)
+			case tst.err != nil && !errors.Is(err, tst.err):
+				t.Fatalf("Test %s:\tShould reject the headers with %q, got: %v", tst.name, tst.err, err)
+			}
+		}
+
+		t.Run(tst.name, f)
+	}
+}
+
+func Test_CompactBlock(t *testing.T) {
+	var trans []database.BlockTx
+	for i := 1; i <= 3; i++ {
+		tx, err := sign(database.Tx{ChainID: 1, Nonce: uint64(i), FromID: "0xdd6B972ffcc631a62CAE1BB9d80b7ff429c8ebA4", ToID: "0xF01813E4B85e178A83e29B8E7bF26BD830a25f32"}, 0)
+		if err != nil {
+			t.Fatalf("Should be able to sign transaction: %v", err)
+		}
+		trans = append(trans, tx)
+	}
+
+	block, err := database.POW(context.Background(), database.POWArgs{
+		Difficulty: 1,
+		StateRoot:  "state",
+		Trans:      trans,
+		EvHandler:  func(v string, args ...any) {},
+	})
+	if err != nil {
+		t.Fatalf("Should be able to mine the block: %v", err)
+	}
+
+	cb, err := database.NewCompactBlock(block)
+	if err != nil {
+		t.Fatalf("Should be able to construct the compact block: %v", err)
+	}
+
+	// The pool is missing the second transaction of the block.
+	pool := block.MerkleTree.Values()
+	pool = append(pool[:1:1], pool[2])
+
+	_, missing, err := cb.Reconstruct(pool)
+	if err != nil {
+		t.Fatalf("Should be able to rebuild from the pool: %v", err)
+	}
+	if len(missing) != 1 || missing[0] != 1 {
+		t.Fatalf("Should ask for the missing transaction, got %v", missing)
+	}
+
+	cb, err = cb.Prefill(block, missing)
+	if err != nil {
+		t.Fatalf("Should be able to prefill the missing transaction: %v", err)
+	}
+
+	rebuilt, missing, err := cb.Reconstruct(pool)
+	if err != nil || len(missing) != 0 {
+		t.Fatalf("Should rebuild the block, got missing %v: %v", missing, err)
+	}
+	if rebuilt.Hash() != block.Hash() || rebuilt.MerkleTree.RootHex() != block.Header.TransRoot {
+		t.Fatal("Should rebuild the same block")
+	}
+
+	cb.Prefilled[0].Tx = pool[0]
+	if _, _, err := cb.Reconstruct(pool); !errors.Is(err, database.ErrInvalidCompactBlock) {
+		t.Fatalf("Should reject a prefilled transaction that doesn't match its short id, got: %v", err)
+	}
+}
+
+func Test_BlockSize(t *testing.T) {
+	const (
+		from = database.AccountID("0xdd6B972ffcc631a62CAE1BB9d80b7ff429c8ebA4")
+		to   = database.AccountID("0xF01813E4B85e178A83e29B8E7bF26BD830a25f32")
+	)
+
+	tx, err := sign(database.Tx{ChainID: 1, Nonce: 1, FromID: from, ToID: to, Data: make([]byte, database.MaxTxDataSize+1)}, 0)
+	if err != nil {
+		t.Fatalf("Should be able to sign transaction: %v", err)
+	}
+	if err := tx.Validate(1); err == nil {
+		t.Fatal("Should refuse a transaction with data over the max size.")
+	}
+
+	var trans []database.BlockTx
+	for i := 1; i <= 40; i++ {
+		tx, err := sign(database.Tx{ChainID: 1, Nonce: uint64(i), FromID: from, ToID: to, Data: make([]byte, database.MaxTxDataSize)}, 0)
+		if err != nil {
+			t.Fatalf("Should be able to sign transaction: %v", err)
+		}
+		if err := tx.Validate(1); err != nil {
+			t.Fatalf("Should accept a transaction with data at the max size: %v", err)
+		}
+		trans = append(trans, tx)
+	}
+
+	mine := func(trans []database.BlockTx) database.Block {
+		block, err := database.POW(context.Background(), database.POWArgs{
+			Difficulty: 1,
+			StateRoot:  "state",
+			Trans:      trans,
+			EvHandler:  func(v string, args ...any) {},
+		})
+		if err != nil {
+			t.Fatalf("Should be able to mine the block: %v", err)
+		}
+		return block
+	}
+
+	noop := func(v string, args ...any) {}
+
+	if err := mine(trans).ValidateBlock(database.Block{}, "state", noop); err == nil {
+		t.Fatal("Should refuse a block over the max size.")
+	}
+
+	fit := database.FitTrans(trans)
+	if len(fit) == 0 || len(fit) == len(trans) {
+		t.Fatalf("Should leave out the transactions that don't fit, got %d of %d", len(fit), len(trans))
+	}
+
+	block := mine(fit)
+	if size := block.Size(); size > database.MaxBlockSize {
+		t.Fatalf("Should fit into the max block size, got %d", size)
+	}
+	if err := block.ValidateBlock(database.Block{}, "state", noop); err != nil {
+		t.Fatalf("Should accept a block at the max size: %v", err)
+	}
+}
+
 // =============================================================================
 
 func sign(tx database.Tx, gas uint64) (database.BlockTx, error) {
@@ -195,6 +457,18 @@ func (ms MockStorage) GetBlock(num uint64) (database.BlockData, error) {
 	return database.BlockData{}, nil
 }
 
+func (ms MockStorage) GetBlockByHash(hash string) (database.BlockData, error) {
+	return database.BlockData{}, database.ErrBlockNotFound
+}
+
+func (ms MockStorage) WriteReceipt(receipt database.Receipt) error {
+	return nil
+}
+
+func (ms MockStorage) GetReceipt(txHash string) (database.Receipt, error) {
+	return database.Receipt{}, database.ErrReceiptNotFound
+}
+
 func (ms MockStorage) ForEach() database.Iterator {
 	return &MockIterator{}
 }
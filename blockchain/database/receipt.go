@@ -0,0 +1,39 @@
+package database
+
+import "errors"
+
+// CORE NOTE: Ethereum stores a receipt for every transaction that is mined
+// into a block so wallets can learn the outcome. A transaction in a block can
+// still fail, like when the nonce is wrong or the account can't cover the
+// value, and the gas is charged either way. The Ardan blockchain builds its
+// receipts as blocks are applied and keeps them in storage next to the
+// blocks, so they don't need to be held in memory for the whole chain.
+
+// ErrReceiptNotFound is returned by storage when a transaction has no receipt.
+var ErrReceiptNotFound = errors.New("receipt not found")
+
+// Receipt represents the outcome of applying a transaction mined in a block.
+type Receipt struct {
+	TxHash      string `json:"tx_hash"`
+	BlockNumber uint64 `json:"block_number"`
+	BlockHash   string `json:"block_hash"`
+	Failed      bool   `json:"failed"`
+	Error       string `json:"error,omitempty"`
+}
+
+// newReceipt constructs a receipt for the transaction applied in the
+// specified block with the error returned from applying it.
+func newReceipt(block Block, tx BlockTx, err error) Receipt {
+	r := Receipt{
+		TxHash:      tx.TxHash(),
+		BlockNumber: block.Header.Number,
+		BlockHash:   block.Hash(),
+	}
+
+	if err != nil {
+		r.Failed = true
+		r.Error = err.Error()
+	}
+
+	return r
+}
@@ -12,15 +12,25 @@ import (
 	"github.com/zacksfF/FullStack-Blockchain/blockchain/signature"
 )
 
+// MaxTxDataSize is the largest the extra data of a transaction can be, so
+// a transaction always fits into a block.
+const MaxTxDataSize = 64 << 10
+
+// ErrTxExpired is returned when a transaction can no longer be mined
+// into a block because its expiry has passed.
+var ErrTxExpired = errors.New("transaction has expired")
+
 // Tx is the transactional information between two parties.
 type Tx struct {
-	ChainID uint16    `json:"chain_id"` // Ethereum: The chain id that is listed in the genesis file.
-	Nonce   uint64    `json:"nonce"`    // Ethereum: Unique id for the transaction supplied by the user.
-	FromID  AccountID `json:"from"`     // Ethereum: Account sending the transaction. Will be checked against signature.
-	ToID    AccountID `json:"to"`       // Ethereum: Account receiving the benefit of the transaction.
-	Value   uint64    `json:"value"`    // Ethereum: Monetary value received from this transaction.
-	Tip     uint64    `json:"tip"`      // Ethereum: Tip offered by the sender as an incentive to mine this transaction.
-	Data    []byte    `json:"data"`     // Ethereum: Extra data related to the transaction.
+	ChainID     uint16    `json:"chain_id"`               // Ethereum: The chain id that is listed in the genesis file.
+	Nonce       uint64    `json:"nonce"`                  // Ethereum: Unique id for the transaction supplied by the user.
+	FromID      AccountID `json:"from"`                   // Ethereum: Account sending the transaction. Will be checked against signature.
+	ToID        AccountID `json:"to"`                     // Ethereum: Account receiving the benefit of the transaction.
+	Value       uint64    `json:"value"`                  // Ethereum: Monetary value received from this transaction.
+	Tip         uint64    `json:"tip"`                    // Ethereum: Tip offered by the sender as an incentive to mine this transaction.
+	Data        []byte    `json:"data"`                   // Ethereum: Extra data related to the transaction.
+	ExpiryBlock uint64    `json:"expiry_block,omitempty"` // Ardan: Optional last block number the transaction can be mined into.
+	ExpiryTime  uint64    `json:"expiry_time,omitempty"`  // Ardan: Optional time in milliseconds after which the transaction can't be mined.
 }
 
 // NewTx constructs a new transaction.
@@ -45,6 +55,21 @@ func NewTx(chainID uint16, nonce uint64, fromID AccountID, toID AccountID, value
 	return tx, nil
 }
 
+// IsExpired reports whether the transaction can no longer be mined into a
+// block with the specified number and timestamp in milliseconds. A zero
+// expiry field means the transaction doesn't expire on that measure.
+func (tx Tx) IsExpired(blockNumber uint64, timeStamp uint64) bool {
+	if tx.ExpiryBlock > 0 && blockNumber > tx.ExpiryBlock {
+		return true
+	}
+
+	if tx.ExpiryTime > 0 && timeStamp > tx.ExpiryTime {
+		return true
+	}
+
+	return false
+}
+
 // Sign uses the specified private key to sign the transaction.
 func (tx Tx) Sign(privateKey *ecdsa.PrivateKey) (SignedTx, error) {
 
@@ -91,7 +116,13 @@ func (tx SignedTx) Validate(chainID uint16) error {
 		return errors.New("to account is not properly formatted")
 	}
 
-	if tx.FromID == tx.ToID {
+	if len(tx.Data) > MaxTxDataSize {
+		return fmt.Errorf("transaction data is %d bytes, over the max of %d", len(tx.Data), MaxTxDataSize)
+	}
+
+	// Sending to yourself is allowed so a pending transaction can be cancelled
+	// by replacing it with a zero value transaction using the same nonce.
+	if tx.FromID == tx.ToID && tx.Value != 0 {
 		return fmt.Errorf("transaction invalid, sending money to yourself, from %s, to %s", tx.FromID, tx.ToID)
 	}
 
@@ -111,6 +142,12 @@ func (tx SignedTx) Validate(chainID uint16) error {
 	return nil
 }
 
+// TxHash returns a unique string that identifies the signed transaction. It
+// doesn't change as the transaction is shared between nodes.
+func (tx SignedTx) TxHash() string {
+	return signature.Hash(tx)
+}
+
 // SignatureString returns the signature as a string.
 func (tx SignedTx) SignatureString() string {
 	return signature.SignatureString(tx.V, tx.R, tx.S)
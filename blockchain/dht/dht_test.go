@@ -0,0 +1,252 @@
+package dht_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/dht"
+)
+
+// node represents a node in the test network, listening on localhost.
+type node struct {
+	contact dht.Contact
+	table   *dht.Table
+}
+
+func Test_Discovery(t *testing.T) {
+	const numNodes = 64
+
+	nodes := newNetwork(t, numNodes)
+
+	// Every node only knows about the first node to start with.
+	var wg sync.WaitGroup
+	for _, n := range nodes[1:] {
+		n.table.Add(nodes[0].contact)
+
+		wg.Add(1)
+		go func(n *node) {
+			defer wg.Done()
+			if err := dht.Refresh(n.table, query(n)); err != nil {
+				t.Errorf("Should be able to refresh the table: %s", err)
+			}
+		}(n)
+	}
+	wg.Wait()
+
+	for _, n := range nodes {
+		if n.table.Len() < dht.K {
+			t.Fatalf("Should know at least %d nodes, node %s knows %d", dht.K, n.contact.Host, n.table.Len())
+		}
+	}
+
+	// Any node should be able to find the nodes closest to any id.
+	for i := 0; i < 20; i++ {
+		from := nodes[(i*7)%numNodes]
+		target := dht.RandomID(from.table.Self(), i%4)
+
+		got, err := dht.Lookup(from.table, target, query(from))
+		if err != nil {
+			t.Fatalf("Should be able to look up the target: %s", err)
+		}
+
+		exp := closest(nodes, from, target)
+		if len(got) != len(exp) {
+			t.Fatalf("Should find %d contacts, got %d", len(exp), len(got))
+		}
+		for j := range exp {
+			if got[j] != exp[j] {
+				t.Fatalf("Should find the closest nodes to %s, got %v, exp %v", target, got[j], exp[j])
+			}
+		}
+	}
+}
+
+func Test_TableBuckets(t *testing.T) {
+	self := dht.RandomID(dht.ID{}, 0)
+
+	table, err := dht.NewTable(self.String())
+	if err != nil {
+		t.Fatalf("Should be able to construct the table: %s", err)
+	}
+
+	if table.Add(dht.Contact{ID: self.String(), Host: "self"}) {
+		t.Fatal("Should not add the table's own node")
+	}
+
+	// Every node that differs in the first bit shares the same bucket.
+	for i := 0; i < dht.K+5; i++ {
+		table.Add(dht.Contact{ID: dht.RandomID(self, 0).String(), Host: fmt.Sprintf("far%d", i)})
+	}
+	if table.Len() != dht.K {
+		t.Fatalf("Should limit the bucket to %d contacts, got %d", dht.K, table.Len())
+	}
+
+	near := dht.Contact{ID: dht.RandomID(self, 100).String(), Host: "near"}
+	if !table.Add(near) {
+		t.Fatal("Should add a contact to a different bucket")
+	}
+
+	if got := table.Closest(self, 1); len(got) != 1 || got[0] != near {
+		t.Fatalf("Should return the closest contact first, got %v", got)
+	}
+
+	table.Remove(near.ID)
+	if table.Len() != dht.K {
+		t.Fatalf("Should remove the contact, got %d contacts", table.Len())
+	}
+}
+
+func Test_LookupLimit(t *testing.T) {
+	self := dht.RandomID(dht.ID{}, 0)
+	target := dht.RandomID(self, 0)
+
+	table, err := dht.NewTable(self.String())
+	if err != nil {
+		t.Fatalf("Should be able to construct the table: %s", err)
+	}
+
+	seed := dht.Contact{ID: dht.RandomID(self, 1).String(), Host: "seed"}
+	table.Add(seed)
+
+	// The seed answers with more contacts than a node can know closest to
+	// the target, with the closest contact past the limit.
+	answer := make([]dht.Contact, 0, dht.K+1)
+	for i := 0; i < dht.K; i++ {
+		answer = append(answer, dht.Contact{ID: dht.RandomID(target, 1).String(), Host: fmt.Sprintf("far%d", i)})
+	}
+	flood := dht.Contact{ID: dht.RandomID(target, 100).String(), Host: "flood"}
+	answer = append(answer, flood)
+
+	var mu sync.Mutex
+	queried := make(map[string]bool)
+	query := func(c dht.Contact, target dht.ID) ([]dht.Contact, error) {
+		mu.Lock()
+		queried[c.Host] = true
+		mu.Unlock()
+
+		if c == seed {
+			return answer, nil
+		}
+		return nil, nil
+	}
+
+	got, err := dht.Lookup(table, target, query)
+	if err != nil {
+		t.Fatalf("Should be able to look up the target: %s", err)
+	}
+
+	if queried[flood.Host] {
+		t.Fatal("Should drop the contacts past the limit of an answer")
+	}
+	for _, c := range got {
+		if c == flood {
+			t.Fatal("Should not return a contact past the limit of an answer")
+		}
+	}
+}
+
+// =============================================================================
+
+// newNetwork starts the specified number of nodes on localhost.
+func newNetwork(t *testing.T, n int) []*node {
+	nodes := make([]*node, n)
+
+	for i := range nodes {
+		id := dht.RandomID(dht.ID{}, 0)
+
+		table, err := dht.NewTable(id.String())
+		if err != nil {
+			t.Fatalf("Should be able to construct the table: %s", err)
+		}
+
+		nd := node{table: table}
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var req dht.FindRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			target, err := dht.ParseID(req.Target)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			// Like the node, learn about the node asking.
+			nd.table.Add(dht.Contact{ID: r.Header.Get("X-Id"), Host: r.Header.Get("X-Host")})
+
+			json.NewEncoder(w).Encode(nd.table.Closest(target, dht.K))
+		}))
+		t.Cleanup(srv.Close)
+
+		nd.contact = dht.Contact{ID: id.String(), Host: strings.TrimPrefix(srv.URL, "http://")}
+		nodes[i] = &nd
+	}
+
+	return nodes
+}
+
+// query returns the function the node uses to ask other nodes for contacts.
+func query(n *node) dht.QueryFunc {
+	return func(c dht.Contact, target dht.ID) ([]dht.Contact, error) {
+		data, err := json.Marshal(dht.FindRequest{Target: target.String()})
+		if err != nil {
+			return nil, err
+		}
+
+		req, err := http.NewRequest(http.MethodPost, "http://"+c.Host, bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("X-Id", n.contact.ID)
+		req.Header.Set("X-Host", n.contact.Host)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+
+		var contacts []dht.Contact
+		if err := json.NewDecoder(resp.Body).Decode(&contacts); err != nil {
+			return nil, err
+		}
+
+		return contacts, nil
+	}
+}
+
+// closest returns the K nodes closest to the target other than the node
+// looking.
+func closest(nodes []*node, from *node, target dht.ID) []dht.Contact {
+	var contacts []dht.Contact
+	for _, n := range nodes {
+		if n != from {
+			contacts = append(contacts, n.contact)
+		}
+	}
+
+	distance := func(c dht.Contact) []byte {
+		id, _ := dht.ParseID(c.ID)
+		d := make([]byte, len(id))
+		for i := range id {
+			d[i] = id[i] ^ target[i]
+		}
+		return d
+	}
+
+	sort.Slice(contacts, func(i, j int) bool {
+		return bytes.Compare(distance(contacts[i]), distance(contacts[j])) < 0
+	})
+
+	return contacts[:dht.K]
+}
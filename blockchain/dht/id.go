@@ -0,0 +1,94 @@
+package dht
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"math/bits"
+	"strings"
+)
+
+// IDBits is the number of bits in a node id.
+const IDBits = 160
+
+// ID represents a node id, which is the address of the node identity key.
+type ID [IDBits / 8]byte
+
+// ParseID converts a node id in hex into an ID.
+func ParseID(nodeID string) (ID, error) {
+	b, err := hex.DecodeString(strings.TrimPrefix(nodeID, "0x"))
+	if err != nil {
+		return ID{}, fmt.Errorf("invalid node id %q: %w", nodeID, err)
+	}
+
+	var id ID
+	if len(b) != len(id) {
+		return ID{}, fmt.Errorf("invalid node id %q: got %d bytes, exp %d", nodeID, len(b), len(id))
+	}
+	copy(id[:], b)
+
+	return id, nil
+}
+
+// RandomID returns a random id that shares exactly the specified number of
+// leading bits with the id, which makes it fall into that bucket of the
+// id's routing table.
+func RandomID(id ID, prefix int) ID {
+	var out ID
+	rand.Read(out[:])
+
+	for i := 0; i < prefix; i++ {
+		out.setBit(i, id.bit(i))
+	}
+	if prefix < IDBits {
+		out.setBit(prefix, 1-id.bit(prefix))
+	}
+
+	return out
+}
+
+// String returns the id in hex.
+func (id ID) String() string {
+	return "0x" + hex.EncodeToString(id[:])
+}
+
+// =============================================================================
+
+// commonPrefix returns the number of leading bits the ids share.
+func commonPrefix(a ID, b ID) int {
+	for i := range a {
+		if x := a[i] ^ b[i]; x != 0 {
+			return i*8 + bits.LeadingZeros8(x)
+		}
+	}
+
+	return IDBits
+}
+
+// closer reports if a is closer to the target than b using the xor metric.
+func closer(target ID, a ID, b ID) bool {
+	var da, db ID
+	for i := range target {
+		da[i] = target[i] ^ a[i]
+		db[i] = target[i] ^ b[i]
+	}
+
+	return bytes.Compare(da[:], db[:]) < 0
+}
+
+// bit returns the value of the bit at the index, counting from the most
+// significant bit.
+func (id ID) bit(i int) byte {
+	return (id[i/8] >> (7 - i%8)) & 1
+}
+
+// setBit sets the bit at the index to the value.
+func (id *ID) setBit(i int, v byte) {
+	mask := byte(1) << (7 - i%8)
+	if v == 1 {
+		id[i/8] |= mask
+		return
+	}
+	id[i/8] &^= mask
+}
@@ -0,0 +1,135 @@
+package dht
+
+import (
+	"errors"
+	"sort"
+)
+
+// ErrNoContacts is returned when the routing table has no contacts to start
+// a lookup from.
+var ErrNoContacts = errors.New("no contacts in routing table")
+
+// QueryFunc asks the node at the contact for the contacts it knows closest
+// to the target.
+type QueryFunc func(c Contact, target ID) ([]Contact, error)
+
+// Lookup finds the K contacts closest to the target. The closest contacts
+// not yet asked are queried Alpha at a time and the contacts they return
+// are added to the search, until the K closest contacts found have all
+// responded. Contacts that respond are added to the table and contacts that
+// fail are removed from it.
+func Lookup(t *Table, target ID, query QueryFunc) ([]Contact, error) {
+	type candidate struct {
+		contact Contact
+		id      ID
+		queried bool
+		failed  bool
+	}
+
+	var candidates []*candidate
+	known := make(map[ID]bool)
+
+	add := func(c Contact) {
+		id, err := ParseID(c.ID)
+		if err != nil || id == t.self || known[id] {
+			return
+		}
+		known[id] = true
+		candidates = append(candidates, &candidate{contact: c, id: id})
+	}
+
+	for _, c := range t.Closest(target, K) {
+		add(c)
+	}
+
+	if len(candidates) == 0 {
+		return nil, ErrNoContacts
+	}
+
+	type result struct {
+		cand     *candidate
+		contacts []Contact
+		err      error
+	}
+
+	for {
+		sort.Slice(candidates, func(i, j int) bool {
+			return closer(target, candidates[i].id, candidates[j].id)
+		})
+
+		// Pick the closest contacts not asked yet from the K closest that
+		// haven't failed.
+		var next []*candidate
+		var closest int
+		for _, cand := range candidates {
+			if cand.failed {
+				continue
+			}
+			if closest++; closest > K {
+				break
+			}
+			if !cand.queried && len(next) < Alpha {
+				next = append(next, cand)
+			}
+		}
+
+		if len(next) == 0 {
+			break
+		}
+
+		results := make(chan result, len(next))
+		for _, cand := range next {
+			cand.queried = true
+			go func(cand *candidate) {
+				contacts, err := query(cand.contact, target)
+				results <- result{cand: cand, contacts: contacts, err: err}
+			}(cand)
+		}
+
+		for range next {
+			r := <-results
+
+			if r.err != nil {
+				r.cand.failed = true
+				t.Remove(r.cand.contact.ID)
+				continue
+			}
+
+			// A node can only answer with the K contacts it knows closest
+			// to the target, so the rest of a longer answer is dropped.
+			t.Add(r.cand.contact)
+			for _, c := range r.contacts[:min(len(r.contacts), K)] {
+				add(c)
+			}
+		}
+	}
+
+	var contacts []Contact
+	for _, cand := range candidates {
+		if cand.queried && !cand.failed {
+			contacts = append(contacts, cand.contact)
+			if len(contacts) == K {
+				break
+			}
+		}
+	}
+
+	return contacts, nil
+}
+
+// Refresh fills the table by looking up the node's own id, which finds the
+// nodes closest to it, followed by a random id in each bucket up to the
+// deepest bucket in use, which finds nodes from every part of the network.
+func Refresh(t *Table, query QueryFunc) error {
+	if _, err := Lookup(t, t.self, query); err != nil {
+		return err
+	}
+
+	for i := 0; i <= t.deepest(); i++ {
+		if _, err := Lookup(t, RandomID(t.self, i), query); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,184 @@
+package dht
+
+import (
+	"sort"
+	"sync"
+)
+
+// Package dht provides Kademlia style peer discovery. Nodes are placed in a
+// routing table by the xor distance between their node id and this node's
+// id. Each bucket of the table holds a limited number of nodes that share a
+// common prefix with this node, so the table knows many nodes close by and a
+// few nodes from every other part of the network. A lookup walks the network
+// towards a target id by asking the closest known nodes for nodes closer
+// still, which finds any node in a number of steps that grows with the log
+// of the network size.
+
+// K is the max number of contacts in a bucket and the number of contacts
+// returned by a lookup.
+const K = 16
+
+// Alpha is the number of nodes queried at the same time during a lookup.
+const Alpha = 3
+
+// Contact represents how to reach a node in the network.
+type Contact struct {
+	ID   string `json:"id"`
+	Host string `json:"host"`
+}
+
+// FindRequest represents a request for the contacts closest to a target.
+type FindRequest struct {
+	Target string `json:"target"`
+}
+
+// Table represents the routing table of a node.
+type Table struct {
+	self    ID
+	mu      sync.Mutex
+	buckets [IDBits][]entry
+}
+
+// entry represents a contact in a bucket.
+type entry struct {
+	id      ID
+	contact Contact
+}
+
+// NewTable constructs a routing table for the node with the specified id.
+func NewTable(nodeID string) (*Table, error) {
+	self, err := ParseID(nodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Table{self: self}, nil
+}
+
+// Self returns the id of the node that owns the table.
+func (t *Table) Self() ID {
+	return t.self
+}
+
+// Add records a contact that responded. Contacts that keep responding stay
+// in the table, so a new contact is only added when its bucket has room.
+// It reports if the contact was added.
+func (t *Table) Add(c Contact) bool {
+	id, err := ParseID(c.ID)
+	if err != nil || id == t.self {
+		return false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	b := commonPrefix(t.self, id)
+	bucket := t.buckets[b]
+
+	// Move a known contact to the end of the bucket, which keeps the
+	// contacts ordered from least to most recently seen.
+	for i, e := range bucket {
+		if e.id == id {
+			copy(bucket[i:], bucket[i+1:])
+			bucket[len(bucket)-1] = entry{id: id, contact: c}
+			return false
+		}
+	}
+
+	if len(bucket) >= K {
+		return false
+	}
+
+	t.buckets[b] = append(bucket, entry{id: id, contact: c})
+	return true
+}
+
+// Remove removes the contact with the node id, which makes room in its
+// bucket for a new contact.
+func (t *Table) Remove(nodeID string) {
+	id, err := ParseID(nodeID)
+	if err != nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	b := commonPrefix(t.self, id)
+	if b == IDBits {
+		return
+	}
+
+	bucket := t.buckets[b]
+	for i, e := range bucket {
+		if e.id == id {
+			t.buckets[b] = append(bucket[:i:i], bucket[i+1:]...)
+			return
+		}
+	}
+}
+
+// Closest returns up to n contacts closest to the target.
+func (t *Table) Closest(target ID, n int) []Contact {
+	t.mu.Lock()
+	entries := make([]entry, 0, K)
+	for _, bucket := range t.buckets {
+		entries = append(entries, bucket...)
+	}
+	t.mu.Unlock()
+
+	sort.Slice(entries, func(i, j int) bool {
+		return closer(target, entries[i].id, entries[j].id)
+	})
+
+	contacts := make([]Contact, 0, min(n, len(entries)))
+	for _, e := range entries[:min(n, len(entries))] {
+		contacts = append(contacts, e.contact)
+	}
+
+	return contacts
+}
+
+// Contacts returns every contact in the table. The contacts are spread out
+// over the buckets, so they come from every part of the network.
+func (t *Table) Contacts() []Contact {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var contacts []Contact
+	for _, bucket := range t.buckets {
+		for _, e := range bucket {
+			contacts = append(contacts, e.contact)
+		}
+	}
+
+	return contacts
+}
+
+// Len returns the number of contacts in the table.
+func (t *Table) Len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var n int
+	for _, bucket := range t.buckets {
+		n += len(bucket)
+	}
+
+	return n
+}
+
+// deepest returns the highest bucket index holding a contact or -1 when the
+// table is empty.
+func (t *Table) deepest() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for i := IDBits - 1; i >= 0; i-- {
+		if len(t.buckets[i]) > 0 {
+			return i
+		}
+	}
+
+	return -1
+}
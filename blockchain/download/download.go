@@ -0,0 +1,302 @@
+package download
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/database"
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/peer"
+)
+
+// Package download fetches the blocks for a chain of headers that has
+// already been validated. The blocks are split into batches that are
+// downloaded from several peers at the same time and added to the chain in
+// order as they arrive.
+
+// Set of default values used when the config leaves them unset.
+const (
+	DefaultBatchSize = 50
+	DefaultRetries   = 3
+	DefaultBackoff   = time.Second
+)
+
+// Set of errors returned by the download.
+var (
+	ErrInvalidBatch = errors.New("blocks don't match the headers")
+	ErrNoPeers      = errors.New("no peers left to download from")
+)
+
+// Source represents a peer blocks can be downloaded from and the latest
+// block number the peer has.
+type Source struct {
+	Peer   peer.Peer
+	Height uint64
+}
+
+// Progress represents how far along the download is.
+type Progress struct {
+	From         uint64 `json:"from"`
+	To           uint64 `json:"to"`
+	Applied      uint64 `json:"applied"`
+	Batches      int    `json:"batches"`
+	BatchesDone  int    `json:"batches_done"`
+	Retries      int    `json:"retries"`
+	PeersStarted int    `json:"peers_started"`
+	PeersFailed  int    `json:"peers_failed"`
+}
+
+// Config represents what is needed to download the blocks for the headers.
+// Fetch requests a range of blocks from a peer and Apply adds a block to
+// the chain. Failed is called when a peer couldn't provide a batch. A peer
+// that sent blocks that don't match the headers is removed from the
+// download, any other peer is used again after the backoff. Progress is
+// called after every batch added to the chain.
+type Config struct {
+	Sources   []Source
+	Headers   []database.BlockHeader
+	BatchSize int
+	Retries   int
+	Backoff   time.Duration
+	Fetch     func(pr peer.Peer, from uint64, to uint64) ([]database.Block, error)
+	Apply     func(pr peer.Peer, block database.Block) error
+	Failed    func(pr peer.Peer, err error)
+	Progress  func(p Progress)
+}
+
+// Run downloads and applies the blocks for the headers. A batch that fails
+// is given to the next free peer until it has been retried the configured
+// number of times. The progress made is returned even when the download
+// fails part way through.
+func Run(cfg Config) (Progress, error) {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = DefaultBatchSize
+	}
+	if cfg.Retries <= 0 {
+		cfg.Retries = DefaultRetries
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = DefaultBackoff
+	}
+	if cfg.Failed == nil {
+		cfg.Failed = func(pr peer.Peer, err error) {}
+	}
+	if cfg.Progress == nil {
+		cfg.Progress = func(p Progress) {}
+	}
+
+	if len(cfg.Headers) == 0 {
+		return Progress{}, nil
+	}
+
+	batches := split(cfg.Headers, cfg.BatchSize)
+
+	p := Progress{
+		From:         cfg.Headers[0].Number,
+		To:           cfg.Headers[len(cfg.Headers)-1].Number,
+		Batches:      len(batches),
+		PeersStarted: len(cfg.Sources),
+	}
+
+	queue := append([]*batch(nil), batches...)
+	idle := append([]Source(nil), cfg.Sources...)
+	results := make(chan result)
+	rested := make(chan Source, len(cfg.Sources))
+	fetched := make(map[int]result)
+
+	var running, resting int
+	var err error
+
+	for p.BatchesDone < len(batches) {
+
+		// Give the lowest batches to the peers that aren't busy, since the
+		// blocks can only be added to the chain in order.
+		for i := 0; i < len(queue); {
+			j := pick(idle, queue[i])
+			if j < 0 {
+				i++
+				continue
+			}
+
+			src, b := idle[j], queue[i]
+			idle = append(idle[:j], idle[j+1:]...)
+			queue = append(queue[:i], queue[i+1:]...)
+
+			running++
+			go func() {
+				results <- b.fetch(cfg, src)
+			}()
+		}
+
+		if running == 0 && resting == 0 {
+			err = ErrNoPeers
+			break
+		}
+
+		var r result
+		select {
+		case src := <-rested:
+			resting--
+			idle = append(idle, src)
+			continue
+		case r = <-results:
+			running--
+		}
+
+		if r.err != nil {
+			cfg.Failed(r.src.Peer, r.err)
+			p.PeersFailed++
+
+			// A peer that timed out may only be busy, so it's given the
+			// chance to serve another batch once it had time to recover.
+			if !errors.Is(r.err, ErrInvalidBatch) {
+				resting++
+				src := r.src
+				time.AfterFunc(cfg.Backoff, func() { rested <- src })
+			}
+
+			r.batch.attempts++
+			if r.batch.attempts > cfg.Retries {
+				err = fmt.Errorf("blocks %d-%d: %w", r.batch.from, r.batch.to, r.err)
+				break
+			}
+
+			p.Retries++
+			queue = requeue(queue, r.batch)
+			continue
+		}
+
+		idle = append(idle, r.src)
+		fetched[r.batch.index] = r
+
+		// Add the batches that are next in line to the chain.
+		for {
+			r, exists := fetched[p.BatchesDone]
+			if !exists {
+				break
+			}
+			delete(fetched, p.BatchesDone)
+
+			for _, block := range r.blocks {
+				if err = cfg.Apply(r.src.Peer, block); err != nil {
+					break
+				}
+				p.Applied++
+			}
+			if err != nil {
+				break
+			}
+
+			p.BatchesDone++
+			cfg.Progress(p)
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	// Wait for the requests still running when the download stopped early.
+	for ; running > 0; running-- {
+		<-results
+	}
+
+	return p, err
+}
+
+// =============================================================================
+
+// batch represents a range of blocks requested from a single peer.
+type batch struct {
+	index    int
+	from     uint64
+	to       uint64
+	hashes   []string
+	attempts int
+}
+
+// result represents the outcome of requesting a batch from a peer.
+type result struct {
+	batch  *batch
+	src    Source
+	blocks []database.Block
+	err    error
+}
+
+// split divides the headers into batches of the specified size.
+func split(headers []database.BlockHeader, size int) []*batch {
+	var batches []*batch
+
+	for i := 0; i < len(headers); i += size {
+		end := min(i+size, len(headers))
+
+		b := batch{
+			index:  len(batches),
+			from:   headers[i].Number,
+			to:     headers[end-1].Number,
+			hashes: make([]string, 0, end-i),
+		}
+		for _, header := range headers[i:end] {
+			b.hashes = append(b.hashes, database.Block{Header: header}.Hash())
+		}
+
+		batches = append(batches, &b)
+	}
+
+	return batches
+}
+
+// fetch requests the batch from the peer and checks the blocks match the
+// headers that were validated.
+func (b *batch) fetch(cfg Config, src Source) result {
+	blocks, err := cfg.Fetch(src.Peer, b.from, b.to)
+	if err == nil {
+		err = b.verify(blocks)
+	}
+
+	return result{batch: b, src: src, blocks: blocks, err: err}
+}
+
+// verify checks the blocks are the ones described by the headers.
+func (b *batch) verify(blocks []database.Block) error {
+	if len(blocks) != len(b.hashes) {
+		return fmt.Errorf("%w: got %d blocks, exp %d", ErrInvalidBatch, len(blocks), len(b.hashes))
+	}
+
+	for i, block := range blocks {
+		if block.Hash() != b.hashes[i] {
+			return fmt.Errorf("%w: blk[%d]: hash doesn't match header", ErrInvalidBatch, block.Header.Number)
+		}
+
+		if block.Header.TransRoot != block.MerkleTree.RootHex() {
+			return fmt.Errorf("%w: blk[%d]: transactions don't match header", ErrInvalidBatch, block.Header.Number)
+		}
+	}
+
+	return nil
+}
+
+// pick returns the index of an idle peer that has all the blocks in the
+// batch or -1 if there isn't one.
+func pick(idle []Source, b *batch) int {
+	for i, src := range idle {
+		if src.Height >= b.to {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// requeue puts the batch back in the queue, keeping the queue ordered.
+func requeue(queue []*batch, b *batch) []*batch {
+	i := 0
+	for i < len(queue) && queue[i].index < b.index {
+		i++
+	}
+
+	queue = append(queue, nil)
+	copy(queue[i+1:], queue[i:])
+	queue[i] = b
+
+	return queue
+}
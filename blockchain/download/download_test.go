@@ -0,0 +1,219 @@
+package download_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/database"
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/download"
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/peer"
+)
+
+func Test_Run(t *testing.T) {
+	chain := newChain(t, 100)
+
+	sources := []download.Source{
+		{Peer: peer.New("a"), Height: 100},
+		{Peer: peer.New("b"), Height: 100},
+		{Peer: peer.New("c"), Height: 100},
+	}
+
+	var mu sync.Mutex
+	served := make(map[string]int)
+
+	var applied []uint64
+	cfg := download.Config{
+		Sources:   sources,
+		Headers:   headers(chain),
+		BatchSize: 10,
+		Fetch: func(pr peer.Peer, from uint64, to uint64) ([]database.Block, error) {
+			mu.Lock()
+			served[pr.Host]++
+			mu.Unlock()
+			return chain[from-1 : to], nil
+		},
+		Apply: func(pr peer.Peer, block database.Block) error {
+			applied = append(applied, block.Header.Number)
+			return nil
+		},
+	}
+
+	p, err := download.Run(cfg)
+	if err != nil {
+		t.Fatalf("Should be able to download the blocks: %s", err)
+	}
+
+	if p.Applied != 100 || p.BatchesDone != 10 {
+		t.Fatalf("Should report all the blocks applied, got %+v", p)
+	}
+
+	for i, num := range applied {
+		if num != uint64(i+1) {
+			t.Fatalf("Should apply the blocks in order, got block %d at %d", num, i)
+		}
+	}
+
+	if len(served) != len(sources) {
+		t.Fatalf("Should download from every peer, got %v", served)
+	}
+}
+
+func Test_Retry(t *testing.T) {
+	chain := newChain(t, 30)
+
+	bad := peer.New("bad")
+	short := peer.New("short")
+
+	var failed []string
+	cfg := download.Config{
+		Sources: []download.Source{
+			{Peer: bad, Height: 30},
+			{Peer: short, Height: 10},
+			{Peer: peer.New("good"), Height: 30},
+		},
+		Headers:   headers(chain),
+		BatchSize: 10,
+		Fetch: func(pr peer.Peer, from uint64, to uint64) ([]database.Block, error) {
+			switch {
+			case pr == bad:
+				return chain[from:to], nil
+			case pr == short && to > 10:
+				t.Errorf("Should not ask a peer for blocks it doesn't have, got %d-%d", from, to)
+			}
+			return chain[from-1 : to], nil
+		},
+		Apply: func(pr peer.Peer, block database.Block) error {
+			return nil
+		},
+		Failed: func(pr peer.Peer, err error) {
+			if !errors.Is(err, download.ErrInvalidBatch) {
+				t.Errorf("Should fail the batch because the blocks don't match, got %s", err)
+			}
+			failed = append(failed, pr.Host)
+		},
+	}
+
+	p, err := download.Run(cfg)
+	if err != nil {
+		t.Fatalf("Should be able to download the blocks from the other peers: %s", err)
+	}
+
+	if p.Applied != 30 {
+		t.Fatalf("Should apply all the blocks, got %d", p.Applied)
+	}
+
+	if len(failed) != 1 || failed[0] != "bad" {
+		t.Fatalf("Should fail the bad peer once, got %v", failed)
+	}
+}
+
+func Test_NoPeers(t *testing.T) {
+	chain := newChain(t, 20)
+
+	cfg := download.Config{
+		Sources:   []download.Source{{Peer: peer.New("a"), Height: 20}},
+		Headers:   headers(chain),
+		BatchSize: 10,
+		Fetch: func(pr peer.Peer, from uint64, to uint64) ([]database.Block, error) {
+			if from > 1 {
+				return chain[from:to], nil
+			}
+			return chain[from-1 : to], nil
+		},
+		Apply: func(pr peer.Peer, block database.Block) error {
+			return nil
+		},
+	}
+
+	p, err := download.Run(cfg)
+	if !errors.Is(err, download.ErrNoPeers) {
+		t.Fatalf("Should fail once there are no peers left, got %v", err)
+	}
+
+	if p.Applied != 10 {
+		t.Fatalf("Should report the blocks applied before failing, got %d", p.Applied)
+	}
+}
+
+func Test_RetrySource(t *testing.T) {
+	chain := newChain(t, 20)
+
+	var calls int
+	cfg := download.Config{
+		Sources:   []download.Source{{Peer: peer.New("a"), Height: 20}},
+		Headers:   headers(chain),
+		BatchSize: 10,
+		Backoff:   10 * time.Millisecond,
+		Fetch: func(pr peer.Peer, from uint64, to uint64) ([]database.Block, error) {
+			calls++
+			if calls == 2 {
+				return nil, errors.New("timeout")
+			}
+			return chain[from-1 : to], nil
+		},
+		Apply: func(pr peer.Peer, block database.Block) error {
+			return nil
+		},
+	}
+
+	p, err := download.Run(cfg)
+	if err != nil {
+		t.Fatalf("Should be able to download the blocks once the peer is back: %s", err)
+	}
+
+	if p.Applied != 20 || p.Retries != 1 || p.PeersFailed != 1 {
+		t.Fatalf("Should apply all the blocks after one retry, got %+v", p)
+	}
+}
+
+// =============================================================================
+
+// newChain constructs the specified number of blocks. The blocks don't have
+// to be valid, since the download only checks they match the headers.
+func newChain(t *testing.T, n int) []database.Block {
+	privateKey, err := crypto.HexToECDSA("9f332e3700d8fc2446eaf6d15034cf96e0c2745e40353deef032a5dbf1dfed93")
+	if err != nil {
+		t.Fatalf("Should be able to construct the private key: %s", err)
+	}
+
+	var chain []database.Block
+	var prev database.Block
+	for i := 1; i <= n; i++ {
+		signedTx, err := database.Tx{ChainID: 1, Nonce: uint64(i)}.Sign(privateKey)
+		if err != nil {
+			t.Fatalf("Should be able to sign the transaction: %s", err)
+		}
+
+		blockData := database.BlockData{
+			Trans: []database.BlockTx{database.NewBlockTx(signedTx, 0, 0)},
+		}
+
+		block, err := database.ToBlock(blockData)
+		if err != nil {
+			t.Fatalf("Should be able to construct the block: %s", err)
+		}
+
+		block.Header = database.BlockHeader{
+			Number:        uint64(i),
+			PrevBlockHash: prev.Hash(),
+			TransRoot:     block.MerkleTree.RootHex(),
+		}
+
+		chain = append(chain, block)
+		prev = block
+	}
+
+	return chain
+}
+
+// headers returns the headers of the blocks.
+func headers(chain []database.Block) []database.BlockHeader {
+	out := make([]database.BlockHeader, len(chain))
+	for i, block := range chain {
+		out[i] = block.Header
+	}
+	return out
+}
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"os"
 	"time"
+
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/signature"
 )
 
 //Package genesis maintains access to the genesis file
@@ -36,3 +38,9 @@ func Load() (Genesis, error){
 	return genesis, nil
 }
 
+
+// Hash returns a hash of the genesis document so nodes can make sure they
+// are running the same blockchain.
+func (g Genesis) Hash() string {
+	return signature.Hash(g)
+}
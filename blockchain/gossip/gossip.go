@@ -0,0 +1,44 @@
+package gossip
+
+import (
+	"math/rand"
+
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/peer"
+)
+
+// Package gossip provides support for spreading transactions and blocks
+// across the network without every node sending everything to every peer.
+// Nodes announce the hashes of what they have to a random subset of their
+// peers and only send the data the peers ask for.
+
+// Set of kinds of data that can be announced.
+const (
+	KindTx    = "tx"
+	KindBlock = "block"
+)
+
+// DefaultFanout is the number of peers data is announced to.
+const DefaultFanout = 8
+
+// Inventory represents the announcement of a transaction or block by hash.
+type Inventory struct {
+	Kind string `json:"kind"`
+	Hash string `json:"hash"`
+}
+
+// Fanout returns a random selection of up to n of the specified peers. All
+// the peers are returned when n is zero or there are not more than n peers.
+func Fanout(peers []peer.Peer, n int) []peer.Peer {
+	if n <= 0 || len(peers) <= n {
+		return peers
+	}
+
+	selected := make([]peer.Peer, len(peers))
+	copy(selected, peers)
+
+	rand.Shuffle(len(selected), func(i, j int) {
+		selected[i], selected[j] = selected[j], selected[i]
+	})
+
+	return selected[:n]
+}
@@ -0,0 +1,58 @@
+package gossip_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/gossip"
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/peer"
+)
+
+func Test_Seen(t *testing.T) {
+	seen := gossip.NewSeen(2)
+
+	if !seen.Add("0x1") || seen.Add("0x1") {
+		t.Fatalf("Should only add a hash the first time it is seen.")
+	}
+
+	seen.Add("0x2")
+	seen.Add("0x3")
+
+	if seen.Has("0x1") {
+		t.Fatalf("Should forget the oldest hash once the cache is full.")
+	}
+	if !seen.Has("0x2") || !seen.Has("0x3") {
+		t.Fatalf("Should remember the most recent hashes.")
+	}
+}
+
+func Test_Fanout(t *testing.T) {
+	peers := make([]peer.Peer, 20)
+	for i := range peers {
+		peers[i] = peer.New(fmt.Sprintf("host%d", i))
+	}
+
+	if got := gossip.Fanout(peers, 0); len(got) != len(peers) {
+		t.Fatalf("Should select every peer for a zero fanout, got %d", len(got))
+	}
+	if got := gossip.Fanout(peers[:3], 8); len(got) != 3 {
+		t.Fatalf("Should select every peer when there are fewer than the fanout, got %d", len(got))
+	}
+
+	got := gossip.Fanout(peers, 8)
+	if len(got) != 8 {
+		t.Fatalf("Should select %d peers, got %d", 8, len(got))
+	}
+
+	unique := make(map[peer.Peer]struct{})
+	for _, pr := range got {
+		unique[pr] = struct{}{}
+	}
+	if len(unique) != len(got) {
+		t.Fatalf("Should select each peer only once: %v", got)
+	}
+
+	if peers[0] != peer.New("host0") || peers[19] != peer.New("host19") {
+		t.Fatalf("Should not change the order of the original peers.")
+	}
+}
@@ -0,0 +1,57 @@
+package gossip
+
+import "sync"
+
+// DefaultSeenSize is the number of hashes remembered by the seen cache.
+const DefaultSeenSize = 50_000
+
+// Seen maintains a bounded record of the transaction and block hashes a node
+// has already seen so they are not requested or relayed again. The oldest
+// hashes are forgotten first.
+type Seen struct {
+	mu     sync.Mutex
+	size   int
+	hashes map[string]struct{}
+	order  []string
+}
+
+// NewSeen constructs a seen cache that remembers up to size hashes.
+func NewSeen(size int) *Seen {
+	if size <= 0 {
+		size = DefaultSeenSize
+	}
+
+	return &Seen{
+		size:   size,
+		hashes: make(map[string]struct{}),
+	}
+}
+
+// Add records the hash as seen. It returns false if the hash was already seen.
+func (s *Seen) Add(hash string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.hashes[hash]; exists {
+		return false
+	}
+
+	s.hashes[hash] = struct{}{}
+	s.order = append(s.order, hash)
+
+	for len(s.order) > s.size {
+		delete(s.hashes, s.order[0])
+		s.order = s.order[1:]
+	}
+
+	return true
+}
+
+// Has reports if the hash has been seen.
+func (s *Seen) Has(hash string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, exists := s.hashes[hash]
+	return exists
+}
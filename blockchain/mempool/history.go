@@ -0,0 +1,56 @@
+package mempool
+
+// historySize is the number of dropped transactions remembered so their
+// status can still be reported after they leave the pool.
+const historySize = 10_000
+
+// history maintains a bounded record of the transactions dropped from the
+// pool, keyed by transaction hash. The oldest records are forgotten first.
+type history struct {
+	size    int
+	dropped map[string]Dropped
+	order   []string
+}
+
+// newHistory constructs a history that remembers up to size transactions.
+func newHistory(size int) *history {
+	return &history{
+		size:    size,
+		dropped: make(map[string]Dropped),
+	}
+}
+
+// add records the dropped transaction.
+func (h *history) add(hash string, d Dropped) {
+	if _, exists := h.dropped[hash]; !exists {
+		h.order = append(h.order, hash)
+	}
+	h.dropped[hash] = d
+
+	for len(h.order) > h.size {
+		delete(h.dropped, h.order[0])
+		h.order = h.order[1:]
+	}
+}
+
+// forget removes the record for a transaction that was added back
+// into the pool.
+func (h *history) forget(hash string) {
+	if _, exists := h.dropped[hash]; !exists {
+		return
+	}
+	delete(h.dropped, hash)
+
+	for i, oh := range h.order {
+		if oh == hash {
+			h.order = append(h.order[:i], h.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// lookup returns the record for the specified transaction.
+func (h *history) lookup(hash string) (Dropped, bool) {
+	d, exists := h.dropped[hash]
+	return d, exists
+}
@@ -0,0 +1,225 @@
+package mempool
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/database"
+)
+
+// CORE NOTE: Bitcoin Core writes the mempool to a mempool.dat file on shutdown
+// and loads it on startup. A file that is only written on shutdown is lost on
+// a crash, so the Ardan blockchain appends every change to a journal instead.
+// On startup the journal is replayed and then rewritten to hold only what is
+// still in the pool.
+
+// The set of operations that can be recorded in the journal.
+const (
+	opUpsert   = "upsert"
+	opDelete   = "delete"
+	opTruncate = "truncate"
+)
+
+// minCompactRecords is the minimum number of records that need to be written
+// before the journal is compacted back down to the contents of the pool.
+const minCompactRecords = 1000
+
+// record represents a single change made to the mempool.
+type record struct {
+	Op string           `json:"op"`
+	Tx database.BlockTx `json:"tx"`
+}
+
+// journal maintains an append only file of the changes made to the mempool.
+type journal struct {
+	path    string
+	file    *os.File
+	records int
+}
+
+// openJournal opens the journal at the specified path for appending,
+// creating the file and any missing folders.
+func openJournal(path string) (*journal, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	return &journal{path: path, file: f}, nil
+}
+
+// write appends the record to the end of the journal.
+func (j *journal) write(rec record) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	if _, err := j.file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	if err := j.file.Sync(); err != nil {
+		return err
+	}
+	j.records++
+
+	return nil
+}
+
+// load reads the journal and applies each record in order, returning the
+// transactions that were still in the pool when the journal was last written.
+// A record that can't be read, like a partial record at the end of the file
+// from a crash, is skipped so the pool starts with what could be read. The
+// reason each record was skipped is returned.
+func (j *journal) load() ([]database.BlockTx, []error, error) {
+	f, err := os.Open(j.path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	pool := make(map[string]database.BlockTx)
+	var order []string
+
+	var skipped []error
+	var line int
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line++
+
+		var rec record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			skipped = append(skipped, fmt.Errorf("line %d: %w", line, err))
+			continue
+		}
+
+		switch rec.Op {
+		case opUpsert:
+			key, err := mapKey(rec.Tx)
+			if err != nil {
+				skipped = append(skipped, fmt.Errorf("line %d: %w", line, err))
+				continue
+			}
+			if _, exists := pool[key]; !exists {
+				order = append(order, key)
+			}
+			pool[key] = rec.Tx
+
+		case opDelete:
+			key, err := mapKey(rec.Tx)
+			if err != nil {
+				skipped = append(skipped, fmt.Errorf("line %d: %w", line, err))
+				continue
+			}
+			delete(pool, key)
+
+		case opTruncate:
+			pool = make(map[string]database.BlockTx)
+			order = nil
+
+		default:
+			skipped = append(skipped, fmt.Errorf("line %d: unknown journal operation %q", line, rec.Op))
+		}
+	}
+
+	// The rest of a journal that can't be read is skipped as well.
+	if err := scanner.Err(); err != nil {
+		skipped = append(skipped, fmt.Errorf("after line %d: %w", line, err))
+	}
+
+	txs := make([]database.BlockTx, 0, len(pool))
+	for _, key := range order {
+		if tx, exists := pool[key]; exists {
+			txs = append(txs, tx)
+			delete(pool, key)
+		}
+	}
+
+	return txs, skipped, nil
+}
+
+// rotate replaces the journal with a new file that only holds the specified
+// transactions. The new file is written next to the old one and renamed so
+// a crash never leaves a partially written journal behind.
+func (j *journal) rotate(txs []database.BlockTx) error {
+	tmp := j.path + ".tmp"
+
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(f)
+	for _, tx := range txs {
+		data, err := json.Marshal(record{Op: opUpsert, Tx: tx})
+		if err != nil {
+			f.Close()
+			return err
+		}
+		if _, err := w.Write(append(data, '\n')); err != nil {
+			f.Close()
+			return err
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp, j.path); err != nil {
+		return err
+	}
+	if err := syncDir(filepath.Dir(j.path)); err != nil {
+		return err
+	}
+
+	// The old file handle points to the replaced file.
+	j.file.Close()
+
+	file, err := os.OpenFile(j.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	j.file = file
+	j.records = len(txs)
+
+	return nil
+}
+
+// close closes the journal file.
+func (j *journal) close() error {
+	return j.file.Close()
+}
+
+// syncDir flushes the folder so a file renamed into it survives a crash.
+func syncDir(path string) error {
+	d, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return d.Sync()
+}
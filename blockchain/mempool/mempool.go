@@ -1,21 +1,87 @@
 package mempool
 
 import (
+	"encoding/json"
 	"errors"
+	"expvar"
 	"fmt"
-	"math"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/zacksfF/FullStack-Blockchain/blockchain/database"
 	"github.com/zacksfF/FullStack-Blockchain/blockchain/mempool/selector"
 )
 
+// Set of errors returned when a transaction is not admitted into the pool.
+var (
+	ErrTipTooLow    = errors.New("transaction tip is below the mempool minimum")
+	ErrAccountLimit = errors.New("account has reached the mempool transaction limit")
+	ErrMempoolFull  = errors.New("mempool is full and the transaction tip is too low to evict another")
+	ErrReplaceTip   = errors.New("replacement transaction tip is too low")
+	ErrNoReplace    = errors.New("replacing a pending transaction is not allowed")
+)
+
+// DefaultReplaceBump is the percentage the tip must increase by to replace a
+// pending transaction when no policy is configured. This matches Ethereum.
+const DefaultReplaceBump = 10
+
+// The set of reasons a transaction is dropped from the pool without
+// being mined into a block.
+const (
+	DropExpired  = "expired"
+	DropStale    = "stale"
+	DropEvicted  = "evicted"
+	DropReplaced = "replaced"
+)
+
+// Dropped represents a transaction that was removed from the pool and
+// the reason why. ReplacedBy holds the hash of the replacement transaction
+// when the reason is DropReplaced.
+type Dropped struct {
+	Tx         database.BlockTx
+	Reason     string
+	ReplacedBy string
+}
+
+// These counters are published in /debug/vars so operators can see how much
+// pressure the mempool is under. They are process wide like everything else
+// registered with expvar.
+var (
+	evictions  = expvar.NewInt("mempool_evictions")
+	rejections = expvar.NewInt("mempool_rejections")
+)
+
+// Config represents the configuration required to construct a mempool.
+type Config struct {
+	SelectStrategy string
+	MaxSize        int           // Maximum number of transactions in the pool. Zero means no limit.
+	MaxPerAccount  int           // Maximum number of transactions per account. Zero means no limit.
+	MinTip         uint64        // Minimum tip required to be admitted into the pool.
+	TTL            time.Duration // How long a transaction can sit in the pool. Zero means forever.
+	JournalPath    string        // File used to persist the pool across restarts. Empty disables it.
+	ReplaceBump    uint64        // Percentage the tip must increase by to replace a pending transaction. Zero means DefaultReplaceBump.
+	NoReplace      bool          // Reject every replacement of a pending transaction.
+	EvHandler      func(v string, args ...any)
+}
+
 // Mempool represents a cache of transactions organized by account:nonce.
 type Mempool struct {
-	mu       sync.RWMutex
-	pool     map[string]database.BlockTx
-	selectFn selector.Func
+	mu            sync.RWMutex
+	pool          map[string]database.BlockTx
+	perAccount    map[database.AccountID]int
+	hashes        map[string]string
+	history       *history
+	selectFn      selector.Func
+	orderFn       selector.Func
+	maxSize       int
+	maxPerAccount int
+	minTip        uint64
+	ttl           time.Duration
+	replaceBump   uint64
+	noReplace     bool
+	journal       *journal
+	evHandler     func(v string, args ...any)
 }
 
 // New constructs a new mempool using the default sort strategy.
@@ -25,19 +91,125 @@ func New() (*Mempool, error) {
 
 // NewWithStrategy constructs a new mempool with specified sort strategy.
 func NewWithStrategy(strategy string) (*Mempool, error) {
-	selectFn, err := selector.Retrieve(strategy)
+	return NewWithConfig(Config{SelectStrategy: strategy})
+}
+
+// NewWithConfig constructs a new mempool with the specified sort strategy
+// and capacity limits.
+func NewWithConfig(cfg Config) (*Mempool, error) {
+	selectFn, err := selector.Retrieve(cfg.SelectStrategy)
 	if err != nil {
 		return nil, err
 	}
 
+	orderFn, err := selector.RetrieveOrder(cfg.SelectStrategy)
+	if err != nil {
+		return nil, err
+	}
+
+	// Build a safe event handler function for use.
+	ev := func(v string, args ...any) {
+		if cfg.EvHandler != nil {
+			cfg.EvHandler(v, args...)
+		}
+	}
+
+	// A replacement must always pay more so an unset bump gets the default.
+	// Operators that want no replacements at all set NoReplace.
+	replaceBump := cfg.ReplaceBump
+	if replaceBump == 0 {
+		replaceBump = DefaultReplaceBump
+	}
+
 	mp := Mempool{
-		pool:     make(map[string]database.BlockTx),
-		selectFn: selectFn,
+		pool:          make(map[string]database.BlockTx),
+		perAccount:    make(map[database.AccountID]int),
+		hashes:        make(map[string]string),
+		history:       newHistory(historySize),
+		selectFn:      selectFn,
+		orderFn:       orderFn,
+		maxSize:       cfg.MaxSize,
+		maxPerAccount: cfg.MaxPerAccount,
+		minTip:        cfg.MinTip,
+		ttl:           cfg.TTL,
+		replaceBump:   replaceBump,
+		noReplace:     cfg.NoReplace,
+		evHandler:     ev,
+	}
+
+	if cfg.JournalPath != "" {
+		jnl, err := openJournal(cfg.JournalPath)
+		if err != nil {
+			return nil, fmt.Errorf("opening mempool journal: %w", err)
+		}
+		mp.journal = jnl
 	}
 
 	return &mp, nil
 }
 
+// Replay loads the transactions recorded in the journal back into the pool.
+// Records that can't be read are skipped. Each transaction is checked with
+// the specified function first and any transaction that fails is dropped,
+// such as those already mined while the node was down. The journal is then
+// rewritten to match the pool.
+func (mp *Mempool) Replay(validate func(tx database.BlockTx) error) (int, error) {
+	if mp.journal == nil {
+		return 0, nil
+	}
+
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	txs, skipped, err := mp.journal.load()
+	if err != nil {
+		return 0, fmt.Errorf("loading mempool journal: %w", err)
+	}
+	for _, err := range skipped {
+		mp.evHandler("mempool: Replay: skipped record: %s", err)
+	}
+
+	// Stop journaling while the pool is being rebuilt. The journal is
+	// rewritten once the replay is complete.
+	jnl := mp.journal
+	mp.journal = nil
+	defer func() {
+		mp.journal = jnl
+	}()
+
+	var restored int
+	for _, tx := range txs {
+		if err := validate(tx); err != nil {
+			mp.evHandler("mempool: Replay: dropped: tx[%s]: %s", tx, err)
+			continue
+		}
+
+		if err := mp.upsert(tx); err != nil {
+			mp.evHandler("mempool: Replay: dropped: tx[%s]: %s", tx, err)
+			continue
+		}
+		restored++
+	}
+
+	if err := jnl.rotate(mp.values()); err != nil {
+		return restored, fmt.Errorf("rotating mempool journal: %w", err)
+	}
+
+	return restored, nil
+}
+
+// Close releases the journal if one is being maintained.
+func (mp *Mempool) Close() error {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	if mp.journal == nil {
+		return nil
+	}
+
+	return mp.journal.close()
+}
+
 // Count returns the current number of transaction in the pool.
 func (mp *Mempool) Count() int {
 	mp.mu.RLock()
@@ -51,32 +223,118 @@ func (mp *Mempool) Upsert(tx database.BlockTx) error {
 	mp.mu.Lock()
 	defer mp.mu.Unlock()
 
+	if err := mp.upsert(tx); err != nil {
+		return err
+	}
+
+	mp.record(opUpsert, tx)
+
+	return nil
+}
+
+// upsert performs the admission checks and adds or replaces the transaction.
+// The caller must hold the write lock.
+func (mp *Mempool) upsert(tx database.BlockTx) error {
+
 	// CORE NOTE: Different blockchains have different algorithms to limit the
 	// size of the mempool. Some limit based on the amount of memory being
 	// consumed and some may limit based on the number of transaction. If a limit
 	// is met, then either the transaction that has the least return on investment
 	// or the oldest will be dropped from the pool to make room for new the transaction.
 
-	// For now, the Ardan blockchain in not imposing any limits.
+	// The Ardan blockchain limits the number of transactions, both globally
+	// and per account, and drops the transaction with the lowest tip.
 	key, err := mapKey(tx)
 	if err != nil {
 		return err
 	}
 
+	if tx.Tip < mp.minTip {
+		rejections.Add(1)
+		return ErrTipTooLow
+	}
+
 	// Ethereum requires a 10% bump in the tip to replace an existing
-	// transaction in the mempool and so do we. We want to limit users
-	// from this sort of behavior.
+	// transaction in the mempool and by default so do we. We want to limit
+	// users from this sort of behavior.
 	if etx, exists := mp.pool[key]; exists {
-		if tx.Tip < uint64(math.Round(float64(etx.Tip)*1.10)) {
-			return errors.New("replacing a transaction requires a 10% bump in the tip")
+		if mp.noReplace {
+			rejections.Add(1)
+			return ErrNoReplace
+		}
+
+		minTip := replaceTip(etx.Tip, mp.replaceBump)
+		if tx.Tip < minTip {
+			rejections.Add(1)
+			return fmt.Errorf("%w: requires a %d%% bump, got[%d] exp[%d]", ErrReplaceTip, mp.replaceBump, tx.Tip, minTip)
+		}
+
+		// A replacement doesn't change the size of the pool.
+		ehash, hash := etx.TxHash(), tx.TxHash()
+		delete(mp.hashes, ehash)
+		mp.pool[key] = tx
+		mp.hashes[hash] = key
+		mp.history.forget(hash)
+		mp.history.add(ehash, Dropped{Tx: etx, Reason: DropReplaced, ReplacedBy: hash})
+		mp.replacedTxEvent(etx, tx)
+
+		return nil
+	}
+
+	if mp.maxPerAccount > 0 && mp.perAccount[tx.FromID] >= mp.maxPerAccount {
+		rejections.Add(1)
+		return ErrAccountLimit
+	}
+
+	if mp.maxSize > 0 && len(mp.pool) >= mp.maxSize {
+		ekey, etx, found := mp.evictionCandidate(tx.FromID)
+		if !found || etx.Tip >= tx.Tip {
+			rejections.Add(1)
+			return ErrMempoolFull
 		}
+
+		mp.remove(ekey, etx)
+		mp.record(opDelete, etx)
+		mp.history.add(etx.TxHash(), Dropped{Tx: etx, Reason: DropEvicted})
+		evictions.Add(1)
+
+		mp.evHandler("mempool: Upsert: evicted: tx[%s] tip[%d]: for tx[%s] tip[%d]", etx, etx.Tip, tx, tx.Tip)
 	}
 
+	hash := tx.TxHash()
 	mp.pool[key] = tx
+	mp.hashes[hash] = key
+	mp.perAccount[tx.FromID]++
+	mp.history.forget(hash)
 
 	return nil
 }
 
+// replacedTxEvent provides a specific event so the submitter of the original
+// transaction knows it will never be mined.
+func (mp *Mempool) replacedTxEvent(etx database.BlockTx, tx database.BlockTx) {
+	ev := struct {
+		From       database.AccountID `json:"from"`
+		Nonce      uint64             `json:"nonce"`
+		Tx         string             `json:"tx"`
+		ReplacedBy string             `json:"replaced_by"`
+		Tip        uint64             `json:"tip"`
+	}{
+		From:       etx.FromID,
+		Nonce:      etx.Nonce,
+		Tx:         etx.TxHash(),
+		ReplacedBy: tx.TxHash(),
+		Tip:        tx.Tip,
+	}
+
+	data, err := json.Marshal(ev)
+	if err != nil {
+		data = []byte(fmt.Sprintf("{error: %q}", err.Error()))
+	}
+
+	mp.evHandler("viewer: tx: replaced: %s", string(data))
+}
+
 // Delete removed a transaction from the mempool.
 func (mp *Mempool) Delete(tx database.BlockTx) error {
 	mp.mu.Lock()
@@ -87,17 +345,61 @@ func (mp *Mempool) Delete(tx database.BlockTx) error {
 		return err
 	}
 
-	delete(mp.pool, key)
+	if etx, exists := mp.pool[key]; exists {
+		mp.remove(key, etx)
+		mp.record(opDelete, etx)
+	}
 
 	return nil
 }
 
+// Prune removes the transactions that can't be mined into a block with the
+// specified number at the specified time, along with the transactions that
+// have been in the pool longer than the configured TTL.
+func (mp *Mempool) Prune(blockNumber uint64, now time.Time) []Dropped {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	nowMilli := uint64(now.UTC().UnixMilli())
+
+	var staleMilli uint64
+	if mp.ttl > 0 {
+		staleMilli = uint64(now.Add(-mp.ttl).UTC().UnixMilli())
+	}
+
+	var dropped []Dropped
+	for key, tx := range mp.pool {
+		var reason string
+		switch {
+		case tx.IsExpired(blockNumber, nowMilli):
+			reason = DropExpired
+		case staleMilli > 0 && tx.TimeStamp < staleMilli:
+			reason = DropStale
+		default:
+			continue
+		}
+
+		mp.remove(key, tx)
+		mp.record(opDelete, tx)
+
+		d := Dropped{Tx: tx, Reason: reason}
+		mp.history.add(tx.TxHash(), d)
+		dropped = append(dropped, d)
+	}
+
+	return dropped
+}
+
 // Truncate clears all the transactions from the pool.
 func (mp *Mempool) Truncate() {
 	mp.mu.Lock()
 	defer mp.mu.Unlock()
 
 	mp.pool = make(map[string]database.BlockTx)
+	mp.perAccount = make(map[database.AccountID]int)
+	mp.hashes = make(map[string]string)
+
+	mp.record(opTruncate, database.BlockTx{})
 }
 
 // PickBest uses the configured sort strategy to return a set of transactions.
@@ -140,8 +442,140 @@ func (mp *Mempool) PickBest(howMany ...uint16) []database.BlockTx {
 	return mp.selectFn(m, number)
 }
 
+// Values returns the transactions in the mempool in no particular order.
+func (mp *Mempool) Values() []database.BlockTx {
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+
+	return mp.values()
+}
+
+// Position returns where the specified transaction sits in the order the
+// configured sort strategy would pick transactions, starting at zero for the
+// next transaction to be picked. The order comes from selector.RetrieveOrder
+// so a status query never runs a full selection.
+func (mp *Mempool) Position(hash string) (int, bool) {
+	m := make(map[database.AccountID][]database.BlockTx)
+	mp.mu.RLock()
+	key, exists := mp.hashes[hash]
+	if exists {
+		for k, tx := range mp.pool {
+			account := accountFromMapKey(k)
+			m[account] = append(m[account], tx)
+		}
+	}
+	mp.mu.RUnlock()
+
+	if !exists {
+		return 0, false
+	}
+
+	for i, tx := range mp.orderFn(m, 0) {
+		if k, err := mapKey(tx); err == nil && k == key {
+			return i, true
+		}
+	}
+
+	return 0, false
+}
+
+// Dropped returns the record for a transaction that was dropped from the
+// pool without being mined. Only the most recent drops are remembered.
+func (mp *Mempool) Dropped(hash string) (Dropped, bool) {
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+
+	return mp.history.lookup(hash)
+}
+
 // =============================================================================
 
+// evictionCandidate finds the transaction with the lowest tip that can be
+// dropped without leaving a nonce gap behind, which means only the highest
+// nonce for each account is considered. Transactions for the specified
+// account are skipped so an account can't evict its own lower nonces.
+func (mp *Mempool) evictionCandidate(skip database.AccountID) (string, database.BlockTx, bool) {
+	last := make(map[database.AccountID]database.BlockTx)
+	for _, tx := range mp.pool {
+		if tx.FromID == skip {
+			continue
+		}
+		if ltx, exists := last[tx.FromID]; !exists || tx.Nonce > ltx.Nonce {
+			last[tx.FromID] = tx
+		}
+	}
+
+	var candidate database.BlockTx
+	var found bool
+	for _, tx := range last {
+		switch {
+		case !found:
+			candidate, found = tx, true
+		case tx.Tip < candidate.Tip:
+			candidate = tx
+		case tx.Tip == candidate.Tip && tx.TimeStamp > candidate.TimeStamp:
+			candidate = tx
+		}
+	}
+
+	if !found {
+		return "", database.BlockTx{}, false
+	}
+
+	key, _ := mapKey(candidate)
+	return key, candidate, true
+}
+
+// remove deletes the transaction from the pool and keeps the per account
+// counts in sync. The caller must hold the write lock.
+func (mp *Mempool) remove(key string, tx database.BlockTx) {
+	delete(mp.pool, key)
+	delete(mp.hashes, tx.TxHash())
+
+	mp.perAccount[tx.FromID]--
+	if mp.perAccount[tx.FromID] <= 0 {
+		delete(mp.perAccount, tx.FromID)
+	}
+}
+
+// record writes the change to the journal if one is being maintained. Once
+// enough records have been written, the journal is compacted down to the
+// current contents of the pool. The caller must hold the write lock.
+func (mp *Mempool) record(op string, tx database.BlockTx) {
+	if mp.journal == nil {
+		return
+	}
+
+	if err := mp.journal.write(record{Op: op, Tx: tx}); err != nil {
+		mp.evHandler("mempool: journal: WARNING: %s", err)
+		return
+	}
+
+	if mp.journal.records > max(minCompactRecords, 2*len(mp.pool)) {
+		if err := mp.journal.rotate(mp.values()); err != nil {
+			mp.evHandler("mempool: journal: compact: WARNING: %s", err)
+		}
+	}
+}
+
+// values returns the transactions in the pool. The caller must hold a lock.
+func (mp *Mempool) values() []database.BlockTx {
+	txs := make([]database.BlockTx, 0, len(mp.pool))
+	for _, tx := range mp.pool {
+		txs = append(txs, tx)
+	}
+
+	return txs
+}
+
+// replaceTip returns the lowest tip that can replace a transaction with the
+// specified tip. The tip must go up by the bump percentage rounded up, and
+// always by at least one.
+func replaceTip(tip uint64, bump uint64) uint64 {
+	inc := tip/100*bump + (tip%100*bump+99)/100
+	return tip + max(inc, 1)
+}
+
 // mapKey is used to generate the map key.
 func mapKey(tx database.BlockTx) (string, error) {
 	return fmt.Sprintf("%s:%d", tx.FromID, tx.Nonce), nil
@@ -1,7 +1,13 @@
 package mempool_test
 
 import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/zacksfF/FullStack-Blockchain/blockchain/database"
@@ -103,6 +109,408 @@ func Test_CRUD(t *testing.T) {
 	}
 }
 
+func Test_Limits(t *testing.T) {
+	const (
+		bill  = "9f332e3700d8fc2446eaf6d15034cf96e0c2745e40353deef032a5dbf1dfed93"
+		pavel = "fae85851bdf5c9f49923722ce38f3c1defcfd3619ef5453230a58ad805499959"
+		ed    = "aed31b6b5a341af8f27e66fb0b7633cf20fc27049e3eb7f6f623a4655b719ebb"
+	)
+
+	tran := func(hexKey string, from database.AccountID, nonce uint64, tip uint64) database.BlockTx {
+		tx, err := sign(hexKey, database.Tx{Nonce: nonce, FromID: from, ToID: "0xbEE6ACE826eC3DE1B6349888B9151B92522F7F76", Tip: tip})
+		if err != nil {
+			t.Fatalf("Should be able to sign transaction: %s", err)
+		}
+		return tx
+	}
+
+	const (
+		billID  = database.AccountID("0xF01813E4B85e178A83e29B8E7bF26BD830a25f32")
+		pavelID = database.AccountID("0xdd6B972ffcc631a62CAE1BB9d80b7ff429c8ebA4")
+		edID    = database.AccountID("0xa988b1866EaBF72B4c53b592c97aAD8e4b9bDCC0")
+	)
+
+	t.Run("min tip", func(t *testing.T) {
+		mp, err := mempool.NewWithConfig(mempool.Config{SelectStrategy: "tip", MinTip: 10})
+		if err != nil {
+			t.Fatalf("Should be able to construct a mempool: %s", err)
+		}
+
+		if err := mp.Upsert(tran(bill, billID, 1, 5)); !errors.Is(err, mempool.ErrTipTooLow) {
+			t.Fatalf("Should reject a transaction below the minimum tip, got: %v", err)
+		}
+		if err := mp.Upsert(tran(bill, billID, 1, 10)); err != nil {
+			t.Fatalf("Should accept a transaction at the minimum tip: %s", err)
+		}
+	})
+
+	t.Run("per account", func(t *testing.T) {
+		mp, err := mempool.NewWithConfig(mempool.Config{SelectStrategy: "tip", MaxPerAccount: 2})
+		if err != nil {
+			t.Fatalf("Should be able to construct a mempool: %s", err)
+		}
+
+		for nonce := uint64(1); nonce <= 2; nonce++ {
+			if err := mp.Upsert(tran(bill, billID, nonce, 10)); err != nil {
+				t.Fatalf("Should accept transaction %d: %s", nonce, err)
+			}
+		}
+		if err := mp.Upsert(tran(bill, billID, 3, 10)); !errors.Is(err, mempool.ErrAccountLimit) {
+			t.Fatalf("Should reject a transaction over the account limit, got: %v", err)
+		}
+		if err := mp.Upsert(tran(bill, billID, 2, 20)); err != nil {
+			t.Fatalf("Should be able to replace a transaction at the account limit: %s", err)
+		}
+		if err := mp.Upsert(tran(pavel, pavelID, 1, 10)); err != nil {
+			t.Fatalf("Should accept a transaction from another account: %s", err)
+		}
+	})
+
+	t.Run("eviction", func(t *testing.T) {
+		mp, err := mempool.NewWithConfig(mempool.Config{SelectStrategy: "tip", MaxSize: 3})
+		if err != nil {
+			t.Fatalf("Should be able to construct a mempool: %s", err)
+		}
+
+		mp.Upsert(tran(bill, billID, 1, 5))
+		mp.Upsert(tran(bill, billID, 2, 50))
+		mp.Upsert(tran(pavel, pavelID, 1, 20))
+
+		if err := mp.Upsert(tran(ed, edID, 1, 30)); err != nil {
+			t.Fatalf("Should evict a lower tip to make room: %s", err)
+		}
+		if mp.Count() != 3 {
+			t.Fatalf("Should keep the pool at its max size, got %d", mp.Count())
+		}
+
+		// Bill's nonce 1 has the lowest tip, but evicting it would leave a gap
+		// in front of nonce 2, so Pavel's transaction is the one dropped.
+		for _, tx := range mp.PickBest() {
+			if tx.FromID == pavelID {
+				t.Fatalf("Should have evicted the lowest tip without a nonce gap.")
+			}
+		}
+
+		if err := mp.Upsert(tran(pavel, pavelID, 1, 1)); !errors.Is(err, mempool.ErrMempoolFull) {
+			t.Fatalf("Should reject a transaction that can't evict another, got: %v", err)
+		}
+	})
+}
+
+func Test_Replace(t *testing.T) {
+	const (
+		bill   = "9f332e3700d8fc2446eaf6d15034cf96e0c2745e40353deef032a5dbf1dfed93"
+		billID = database.AccountID("0xF01813E4B85e178A83e29B8E7bF26BD830a25f32")
+	)
+
+	tran := func(to database.AccountID, tip uint64) database.BlockTx {
+		tx, err := sign(bill, database.Tx{ChainID: 1, Nonce: 1, FromID: billID, ToID: to, Tip: tip})
+		if err != nil {
+			t.Fatalf("Should be able to sign transaction: %s", err)
+		}
+		return tx
+	}
+
+	const ceasarID = database.AccountID("0xbEE6ACE826eC3DE1B6349888B9151B92522F7F76")
+
+	tt := []struct {
+		name   string
+		cfg    mempool.Config
+		tip    uint64
+		expErr error
+	}{
+		{name: "default bump", cfg: mempool.Config{ReplaceBump: mempool.DefaultReplaceBump}, tip: 109, expErr: mempool.ErrReplaceTip},
+		{name: "default bump met", cfg: mempool.Config{ReplaceBump: mempool.DefaultReplaceBump}, tip: 110},
+		{name: "unset bump", cfg: mempool.Config{}, tip: 109, expErr: mempool.ErrReplaceTip},
+		{name: "unset bump met", cfg: mempool.Config{}, tip: 110},
+		{name: "custom bump", cfg: mempool.Config{ReplaceBump: 50}, tip: 149, expErr: mempool.ErrReplaceTip},
+		{name: "custom bump met", cfg: mempool.Config{ReplaceBump: 50}, tip: 150},
+		{name: "no replace", cfg: mempool.Config{NoReplace: true}, tip: 1000, expErr: mempool.ErrNoReplace},
+	}
+
+	for _, tst := range tt {
+		f := func(t *testing.T) {
+			var events []string
+			tst.cfg.SelectStrategy = "tip"
+			tst.cfg.EvHandler = func(v string, args ...any) {
+				events = append(events, fmt.Sprintf(v, args...))
+			}
+
+			mp, err := mempool.NewWithConfig(tst.cfg)
+			if err != nil {
+				t.Fatalf("Test %s:\tShould be able to construct a mempool: %s", tst.name, err)
+			}
+
+			if err := mp.Upsert(tran(ceasarID, 100)); err != nil {
+				t.Fatalf("Test %s:\tShould be able to add the transaction: %s", tst.name, err)
+			}
+
+			// Cancel the transaction by sending nothing to yourself.
+			cancel := tran(billID, tst.tip)
+			err = mp.Upsert(cancel)
+
+			if tst.expErr != nil {
+				if !errors.Is(err, tst.expErr) {
+					t.Fatalf("Test %s:\tShould reject the replacement with %v, got: %v", tst.name, tst.expErr, err)
+				}
+				if len(events) != 0 {
+					t.Fatalf("Test %s:\tShould not send a replaced event, got: %v", tst.name, events)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Test %s:\tShould accept the replacement: %s", tst.name, err)
+			}
+
+			txs := mp.PickBest()
+			if len(txs) != 1 || txs[0].ToID != billID {
+				t.Fatalf("Test %s:\tShould only hold the replacement transaction: %v", tst.name, txs)
+			}
+
+			exp := fmt.Sprintf(`"replaced_by":%q`, cancel.TxHash())
+			if len(events) != 1 || !strings.HasPrefix(events[0], "viewer: tx: replaced: ") || !strings.Contains(events[0], exp) {
+				t.Fatalf("Test %s:\tShould send a replaced event, got: %v", tst.name, events)
+			}
+		}
+
+		t.Run(tst.name, f)
+	}
+}
+
+func Test_ReplaceSmallTip(t *testing.T) {
+	const (
+		bill   = "9f332e3700d8fc2446eaf6d15034cf96e0c2745e40353deef032a5dbf1dfed93"
+		billID = database.AccountID("0xF01813E4B85e178A83e29B8E7bF26BD830a25f32")
+	)
+
+	tran := func(value uint64, tip uint64) database.BlockTx {
+		tx, err := sign(bill, database.Tx{ChainID: 1, Nonce: 1, FromID: billID, ToID: "0xbEE6ACE826eC3DE1B6349888B9151B92522F7F76", Value: value, Tip: tip})
+		if err != nil {
+			t.Fatalf("Should be able to sign transaction: %s", err)
+		}
+		return tx
+	}
+
+	// The default bump rounds down to nothing for these tips, so the
+	// replacement must still pay one more. A bump of 1.5 is rounded up.
+	tt := []struct {
+		tip    uint64
+		minTip uint64
+	}{
+		{tip: 0, minTip: 1},
+		{tip: 1, minTip: 2},
+		{tip: 2, minTip: 3},
+		{tip: 3, minTip: 4},
+		{tip: 4, minTip: 5},
+		{tip: 15, minTip: 17},
+	}
+
+	for _, tst := range tt {
+		f := func(t *testing.T) {
+			mp, err := mempool.NewWithConfig(mempool.Config{SelectStrategy: "tip"})
+			if err != nil {
+				t.Fatalf("Should be able to construct a mempool: %s", err)
+			}
+
+			if err := mp.Upsert(tran(1, tst.tip)); err != nil {
+				t.Fatalf("Should be able to add the transaction: %s", err)
+			}
+
+			if err := mp.Upsert(tran(2, tst.minTip-1)); !errors.Is(err, mempool.ErrReplaceTip) {
+				t.Fatalf("Should reject a replacement with tip %d, got: %v", tst.minTip-1, err)
+			}
+
+			if err := mp.Upsert(tran(3, tst.minTip)); err != nil {
+				t.Fatalf("Should accept a replacement with tip %d: %s", tst.minTip, err)
+			}
+		}
+
+		t.Run(fmt.Sprintf("tip%d", tst.tip), f)
+	}
+}
+
+func Test_Journal(t *testing.T) {
+	const (
+		bill   = "9f332e3700d8fc2446eaf6d15034cf96e0c2745e40353deef032a5dbf1dfed93"
+		billID = database.AccountID("0xF01813E4B85e178A83e29B8E7bF26BD830a25f32")
+	)
+
+	tran := func(nonce uint64, tip uint64) database.BlockTx {
+		tx, err := sign(bill, database.Tx{ChainID: 1, Nonce: nonce, FromID: billID, ToID: "0xbEE6ACE826eC3DE1B6349888B9151B92522F7F76", Tip: tip})
+		if err != nil {
+			t.Fatalf("Should be able to sign transaction: %s", err)
+		}
+		return tx
+	}
+
+	cfg := mempool.Config{
+		SelectStrategy: "tip",
+		JournalPath:    filepath.Join(t.TempDir(), "mempool", "node.journal"),
+	}
+
+	mp, err := mempool.NewWithConfig(cfg)
+	if err != nil {
+		t.Fatalf("Should be able to construct a mempool: %s", err)
+	}
+
+	for nonce := uint64(1); nonce <= 4; nonce++ {
+		if err := mp.Upsert(tran(nonce, 10)); err != nil {
+			t.Fatalf("Should be able to upsert transaction %d: %s", nonce, err)
+		}
+	}
+	mp.Upsert(tran(4, 20))
+	mp.Delete(tran(3, 10))
+	mp.Close()
+
+	mp, err = mempool.NewWithConfig(cfg)
+	if err != nil {
+		t.Fatalf("Should be able to reopen the mempool: %s", err)
+	}
+	defer mp.Close()
+
+	// Pretend nonce 1 was mined while the node was down.
+	restored, err := mp.Replay(func(tx database.BlockTx) error {
+		if tx.Nonce <= 1 {
+			return errors.New("already mined")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Should be able to replay the journal: %s", err)
+	}
+
+	if restored != 2 {
+		t.Fatalf("Should restore 2 transactions, got %d", restored)
+	}
+
+	for _, tx := range mp.PickBest() {
+		switch tx.Nonce {
+		case 2:
+		case 4:
+			if tx.Tip != 20 {
+				t.Fatalf("Should restore the replaced transaction, got tip %d", tx.Tip)
+			}
+		default:
+			t.Fatalf("Should not restore nonce %d", tx.Nonce)
+		}
+	}
+}
+
+func Test_JournalCorrupt(t *testing.T) {
+	const (
+		bill   = "9f332e3700d8fc2446eaf6d15034cf96e0c2745e40353deef032a5dbf1dfed93"
+		billID = database.AccountID("0xF01813E4B85e178A83e29B8E7bF26BD830a25f32")
+	)
+
+	cfg := mempool.Config{
+		SelectStrategy: "tip",
+		JournalPath:    filepath.Join(t.TempDir(), "node.journal"),
+	}
+
+	write := func(tail string) {
+		mp, err := mempool.NewWithConfig(cfg)
+		if err != nil {
+			t.Fatalf("Should be able to construct a mempool: %s", err)
+		}
+		for nonce := uint64(1); nonce <= 2; nonce++ {
+			tx, err := sign(bill, database.Tx{ChainID: 1, Nonce: nonce, FromID: billID, ToID: "0xbEE6ACE826eC3DE1B6349888B9151B92522F7F76"})
+			if err != nil {
+				t.Fatalf("Should be able to sign transaction: %s", err)
+			}
+			mp.Upsert(tx)
+		}
+		mp.Close()
+
+		f, err := os.OpenFile(cfg.JournalPath, os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			t.Fatalf("Should be able to open the journal: %s", err)
+		}
+		f.WriteString(tail)
+		f.Close()
+	}
+
+	replay := func() (int, error) {
+		mp, err := mempool.NewWithConfig(cfg)
+		if err != nil {
+			t.Fatalf("Should be able to reopen the mempool: %s", err)
+		}
+		defer mp.Close()
+
+		return mp.Replay(func(tx database.BlockTx) error { return nil })
+	}
+
+	// A record cut short by a crash is the last thing in the journal.
+	write(`{"op":"delete","tx":{"chain_id":`)
+	if restored, err := replay(); err != nil || restored != 2 {
+		t.Fatalf("Should ignore a partial last record, got %d: %v", restored, err)
+	}
+
+	// A corrupt record is skipped and the records after it are still applied.
+	os.Remove(cfg.JournalPath)
+	write("not a record\n{\"op\":\"rewind\"}\n")
+	if restored, err := replay(); err != nil || restored != 2 {
+		t.Fatalf("Should skip the corrupt records, got %d: %v", restored, err)
+	}
+
+	os.Remove(cfg.JournalPath)
+	write("not a record\n{\"op\":\"truncate\"}\n")
+	if restored, err := replay(); err != nil || restored != 0 {
+		t.Fatalf("Should apply the records after a corrupt record, got %d: %v", restored, err)
+	}
+}
+
+func Test_Prune(t *testing.T) {
+	const (
+		bill   = "9f332e3700d8fc2446eaf6d15034cf96e0c2745e40353deef032a5dbf1dfed93"
+		billID = database.AccountID("0xF01813E4B85e178A83e29B8E7bF26BD830a25f32")
+	)
+
+	tran := func(tx database.Tx, received time.Time) database.BlockTx {
+		tx.FromID = billID
+		tx.ToID = "0xbEE6ACE826eC3DE1B6349888B9151B92522F7F76"
+
+		blockTx, err := sign(bill, tx)
+		if err != nil {
+			t.Fatalf("Should be able to sign transaction: %s", err)
+		}
+		blockTx.TimeStamp = uint64(received.UnixMilli())
+
+		return blockTx
+	}
+
+	now := time.Now()
+
+	mp, err := mempool.NewWithConfig(mempool.Config{SelectStrategy: "tip", TTL: time.Hour})
+	if err != nil {
+		t.Fatalf("Should be able to construct a mempool: %s", err)
+	}
+
+	mp.Upsert(tran(database.Tx{Nonce: 1}, now))
+	mp.Upsert(tran(database.Tx{Nonce: 2, ExpiryBlock: 4}, now))
+	mp.Upsert(tran(database.Tx{Nonce: 3, ExpiryTime: uint64(now.Add(-time.Minute).UnixMilli())}, now))
+	mp.Upsert(tran(database.Tx{Nonce: 4}, now.Add(-2*time.Hour)))
+
+	reasons := make(map[uint64]string)
+	for _, d := range mp.Prune(5, now) {
+		reasons[d.Tx.Nonce] = d.Reason
+	}
+
+	exp := map[uint64]string{2: mempool.DropExpired, 3: mempool.DropExpired, 4: mempool.DropStale}
+	if len(reasons) != len(exp) {
+		t.Fatalf("Should drop %d transactions, got %d", len(exp), len(reasons))
+	}
+	for nonce, reason := range exp {
+		if reasons[nonce] != reason {
+			t.Fatalf("Should drop nonce %d as %q, got %q", nonce, reason, reasons[nonce])
+		}
+	}
+
+	if mp.Count() != 1 {
+		t.Fatalf("Should keep the one live transaction, got %d", mp.Count())
+	}
+}
+
 // =============================================================================
 
 func sign(hexKey string, tx database.Tx) (database.BlockTx, error) {
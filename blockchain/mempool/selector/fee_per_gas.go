@@ -0,0 +1,38 @@
+package selector
+
+import (
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/database"
+)
+
+// CORE NOTE: Today every transaction is charged the same gas, so picking by
+// fee per gas gives the same result as picking by tip. Once the gas used by a
+// transaction varies, a miner earns more by filling a block with transactions
+// that pay the most for each unit of gas instead of the biggest tip.
+
+// feePerGasSelect returns transactions with the best total fee paid per unit
+// of gas while respecting the nonce for each account/transaction.
+var feePerGasSelect = func(m map[database.AccountID][]database.BlockTx, howMany int) []database.BlockTx {
+	before := func(a, b database.BlockTx) bool {
+		fa, fb := feePerGas(a), feePerGas(b)
+		if fa != fb {
+			return fa > fb
+		}
+		if a.TimeStamp != b.TimeStamp {
+			return a.TimeStamp < b.TimeStamp
+		}
+		return a.FromID < b.FromID
+	}
+
+	return headsSelect(m, howMany, before)
+}
+
+// feePerGas calculates the gas fee plus tip paid for each unit of gas. A
+// transaction that uses no gas is only worth its tip.
+func feePerGas(tx database.BlockTx) float64 {
+	if tx.GasUnits == 0 {
+		return float64(tx.Tip)
+	}
+
+	fee := tx.GasPrice*tx.GasUnits + tx.Tip
+	return float64(fee) / float64(tx.GasUnits)
+}
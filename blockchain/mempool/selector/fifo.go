@@ -0,0 +1,19 @@
+package selector
+
+import (
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/database"
+)
+
+// fifoSelect returns transactions in the order they were received by the
+// node while respecting the nonce for each account/transaction. Tips are
+// ignored so every account is treated fairly.
+var fifoSelect = func(m map[database.AccountID][]database.BlockTx, howMany int) []database.BlockTx {
+	before := func(a, b database.BlockTx) bool {
+		if a.TimeStamp != b.TimeStamp {
+			return a.TimeStamp < b.TimeStamp
+		}
+		return a.FromID < b.FromID
+	}
+
+	return headsSelect(m, howMany, before)
+}
@@ -0,0 +1,95 @@
+package selector
+
+import (
+	"sort"
+
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/database"
+)
+
+// CORE NOTE: Picking the transactions with the best total tip while respecting
+// the nonce ordering means choosing a prefix of each account's transactions.
+// This is a grouped knapsack problem where every transaction weighs the same,
+// so it can be solved with dynamic programming in polynomial time instead of
+// enumerating every combination of prefixes like advancedTipSelect does.
+
+// optimalTipSelect returns transactions with the best total tip while
+// respecting the nonce for each account/transaction. It finds the same
+// selection as advancedTipSelect in O(accounts * howMany * txsPerAccount).
+var optimalTipSelect = func(m map[database.AccountID][]database.BlockTx, howMany int) []database.BlockTx {
+
+	// Sort the transactions per account by nonce and the accounts by id so
+	// the result is the same for the same mempool.
+	accounts := make([]database.AccountID, 0, len(m))
+	var total int
+	for from := range m {
+		if len(m[from]) > 1 {
+			sort.Sort(byNonce(m[from]))
+		}
+		accounts = append(accounts, from)
+		total += len(m[from])
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i] < accounts[j] })
+
+	// If every transaction fits, there is nothing to optimize.
+	if howMany <= 0 || howMany >= total {
+		final := make([]database.BlockTx, 0, total)
+		for _, from := range accounts {
+			final = append(final, m[from]...)
+		}
+		return final
+	}
+
+	// best[c] holds the highest total tip that can be collected with at most c
+	// transactions from the accounts processed so far, and count[c] how many
+	// transactions that takes. On a tie, more transactions are preferred so
+	// the block is filled. take[i][c] records how many transactions account i
+	// contributes to the solution for c so the selection can be rebuilt.
+	best := make([]uint64, howMany+1)
+	count := make([]int, howMany+1)
+	take := make([][]int, len(accounts))
+
+	for i, from := range accounts {
+		txs := m[from]
+		limit := min(len(txs), howMany)
+
+		prefix := make([]uint64, limit+1)
+		for k := 1; k <= limit; k++ {
+			prefix[k] = prefix[k-1] + txs[k-1].Tip
+		}
+
+		nextBest := make([]uint64, howMany+1)
+		nextCount := make([]int, howMany+1)
+		choice := make([]int, howMany+1)
+
+		for c := 0; c <= howMany; c++ {
+			nextBest[c], nextCount[c] = best[c], count[c]
+
+			for k := 1; k <= limit && k <= c; k++ {
+				tip := best[c-k] + prefix[k]
+				num := count[c-k] + k
+
+				if tip > nextBest[c] || (tip == nextBest[c] && num > nextCount[c]) {
+					nextBest[c], nextCount[c], choice[c] = tip, num, k
+				}
+			}
+		}
+
+		best, count, take[i] = nextBest, nextCount, choice
+	}
+
+	// Walk back through the choices to find how many transactions to
+	// take from each account.
+	taken := make([]int, len(accounts))
+	c := howMany
+	for i := len(accounts) - 1; i >= 0; i-- {
+		taken[i] = take[i][c]
+		c -= taken[i]
+	}
+
+	final := make([]database.BlockTx, 0, howMany)
+	for i, from := range accounts {
+		final = append(final, m[from][:taken[i]]...)
+	}
+
+	return final
+}
@@ -0,0 +1,201 @@
+package selector_test
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/database"
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/mempool/selector"
+)
+
+func TestOptimalSort(t *testing.T) {
+	tran := func(nonce uint64, from string, hexKey string, tip uint64, ts time.Time) database.BlockTx {
+		const toID = "0xbEE6ACE826eC3DE1B6349888B9151B92522F7F76"
+
+		tx, err := sign(hexKey, database.Tx{Nonce: nonce, FromID: database.AccountID(from), ToID: toID, Tip: tip})
+		if err != nil {
+			t.Fatalf("Should be able to sign transaction: %s", tx)
+		}
+		return tx
+	}
+
+	type test struct {
+		name    string
+		txs     []database.BlockTx
+		howMany int
+		best    []database.BlockTx
+	}
+
+	now := time.Now()
+	tt := []test{
+		{
+			name: "unblock big fee",
+			txs: []database.BlockTx{
+				tran(0, fromPavel, signPavel, 1, now),
+				tran(1, fromPavel, signPavel, 1, now),
+				tran(2, fromPavel, signPavel, 50, now),
+
+				tran(0, fromBill, signBill, 1, now),
+				tran(1, fromBill, signBill, 15, now),
+				tran(2, fromBill, signBill, 16, now),
+
+				tran(0, fromEd, signEd, 5, now),
+				tran(1, fromEd, signEd, 6, now),
+				tran(2, fromEd, signEd, 7, now),
+			},
+			howMany: 4,
+			best: []database.BlockTx{
+				tran(0, fromPavel, signPavel, 1, now),
+				tran(1, fromPavel, signPavel, 1, now),
+				tran(2, fromPavel, signPavel, 50, now),
+
+				tran(0, fromEd, signEd, 5, now),
+			},
+		},
+		{
+			name: "fill with zero tips",
+			txs: []database.BlockTx{
+				tran(0, fromPavel, signPavel, 0, now),
+				tran(1, fromPavel, signPavel, 0, now),
+
+				tran(0, fromBill, signBill, 0, now),
+			},
+			howMany: 2,
+			best: []database.BlockTx{
+				tran(0, fromPavel, signPavel, 0, now),
+			},
+		},
+	}
+
+	for _, tst := range tt {
+		f := func(t *testing.T) {
+			m := make(map[database.AccountID][]database.BlockTx)
+			for _, tx := range tst.txs {
+				m[tx.FromID] = append(m[tx.FromID], tx)
+			}
+
+			sort, err := selector.Retrieve(selector.StrategyTipOptimal)
+			if err != nil {
+				t.Fatalf("Test %s:\tShould be able to get sort strategy function: %s", tst.name, err)
+			}
+
+			txs := sort(m, tst.howMany)
+			if len(txs) != tst.howMany {
+				t.Fatalf("Test %s:\tShould to get %d after sort, but got %d", tst.name, tst.howMany, len(txs))
+			}
+			for _, exp := range tst.best {
+				found := false
+				for _, tx := range txs {
+					if exp.Nonce == tx.Nonce && exp.FromID == tx.FromID {
+						found = true
+						break
+					}
+				}
+
+				if !found {
+					t.Fatalf("Test %s:\tShould get back the right from/nonce: %s/%d", tst.name, exp.FromID, exp.Nonce)
+				}
+			}
+		}
+
+		t.Run(tst.name, f)
+	}
+}
+
+// TestOptimalMatchesAdvanced validates the optimal strategy finds the same
+// total tip as the exhaustive search performed by the advanced strategy.
+func TestOptimalMatchesAdvanced(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+
+	optimal, err := selector.Retrieve(selector.StrategyTipOptimal)
+	if err != nil {
+		t.Fatalf("Should be able to get sort strategy function: %s", err)
+	}
+	advanced, err := selector.Retrieve(selector.StrategyTipAdvanced)
+	if err != nil {
+		t.Fatalf("Should be able to get sort strategy function: %s", err)
+	}
+
+	for i := 0; i < 200; i++ {
+		accounts := 1 + rnd.Intn(4)
+		perAccount := 1 + rnd.Intn(4)
+		howMany := 1 + rnd.Intn(accounts*perAccount)
+
+		m := newPool(rnd, accounts, perAccount)
+		got := totalTip(optimal(m, howMany))
+		exp := totalTip(advanced(m, howMany))
+
+		if got != exp {
+			t.Fatalf("Case %d:\tShould get the best total tip for %d accounts, %d per account, %d wanted: got %d, exp %d", i, accounts, perAccount, howMany, got, exp)
+		}
+	}
+}
+
+// =============================================================================
+
+// The advanced strategy enumerates every combination of prefixes, so it is
+// only benchmarked at sizes that finish in a reasonable amount of time.
+func BenchmarkTipAdvanced(b *testing.B) {
+	for _, accounts := range []int{4, 8, 12} {
+		b.Run(fmt.Sprintf("accounts-%d", accounts), func(b *testing.B) {
+			benchmarkStrategy(b, selector.StrategyTipAdvanced, accounts)
+		})
+	}
+}
+
+func BenchmarkTipOptimal(b *testing.B) {
+	for _, accounts := range []int{4, 8, 12, 1_000, 5_000} {
+		b.Run(fmt.Sprintf("accounts-%d", accounts), func(b *testing.B) {
+			benchmarkStrategy(b, selector.StrategyTipOptimal, accounts)
+		})
+	}
+}
+
+func benchmarkStrategy(b *testing.B, strategy string, accounts int) {
+	const (
+		perAccount = 5
+		howMany    = 10
+	)
+
+	fn, err := selector.Retrieve(strategy)
+	if err != nil {
+		b.Fatalf("Should be able to get sort strategy function: %s", err)
+	}
+
+	m := newPool(rand.New(rand.NewSource(1)), accounts, perAccount)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		fn(m, howMany)
+	}
+}
+
+// newPool constructs a mempool organized by account with random tips. The
+// transactions are not signed since the strategies don't look at signatures.
+func newPool(rnd *rand.Rand, accounts int, perAccount int) map[database.AccountID][]database.BlockTx {
+	m := make(map[database.AccountID][]database.BlockTx, accounts)
+	for a := 0; a < accounts; a++ {
+		from := database.AccountID(fmt.Sprintf("0x%040x", a))
+		for n := 0; n < perAccount; n++ {
+			tx := database.BlockTx{
+				SignedTx: database.SignedTx{
+					Tx: database.Tx{FromID: from, Nonce: uint64(n), Tip: uint64(rnd.Intn(100))},
+				},
+			}
+			m[from] = append(m[from], tx)
+		}
+	}
+
+	return m
+}
+
+// totalTip sums the tips for the specified transactions.
+func totalTip(txs []database.BlockTx) uint64 {
+	var total uint64
+	for _, tx := range txs {
+		total += tx.Tip
+	}
+	return total
+}
@@ -1,8 +1,12 @@
 package selector
 
 import (
+	"container/heap"
+	"errors"
 	"fmt"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/zacksfF/FullStack-Blockchain/blockchain/database"
 )
@@ -11,13 +15,34 @@ import (
 const (
 	StrategyTip         = "tip"
 	StrategyTipAdvanced = "tip_advanced"
+	StrategyTipOptimal  = "tip_optimal"
+	StrategyFIFO        = "fifo"
+	StrategyFeePerGas   = "fee_per_gas"
+	StrategyTipAge      = "tip_age"
 )
 
-// Map of different select strategies with functions.
-var strategies = map[string]Func{
-	StrategyTip:         tipSelect,
-	StrategyTipAdvanced: advancedTipSelect,
-}
+// Map of different select strategies with functions. Embedding applications
+// can add their own strategies with Register.
+var (
+	mu         sync.RWMutex
+	strategies = map[string]Func{
+		StrategyTip:         tipSelect,
+		StrategyTipAdvanced: advancedTipSelect,
+		StrategyTipOptimal:  optimalTipSelect,
+		StrategyFIFO:        fifoSelect,
+		StrategyFeePerGas:   feePerGasSelect,
+		StrategyTipAge:      tipAgeSelect,
+	}
+
+	// orders holds a cheaper function for the strategies that can't return
+	// every transaction in a meaningful order quickly. The tip strategies
+	// that search for the best selection use the plain tip ordering.
+	orders = map[string]Func{
+		StrategyTip:         tipOrder,
+		StrategyTipAdvanced: tipOrder,
+		StrategyTipOptimal:  tipOrder,
+	}
+)
 
 // Func defines a function that takes a mempool of transactions grouped by
 // account and selects howMany of them in an order based on the functions
@@ -25,8 +50,34 @@ var strategies = map[string]Func{
 // for howMany must return all the transactions in the strategies ordering.
 type Func func(transactions map[database.AccountID][]database.BlockTx, howMany int) []database.BlockTx
 
+// Register adds a new select strategy function under the specified name so it
+// can be retrieved like any of the built-in strategies. Names are not case
+// sensitive and a strategy can't be registered twice.
+func Register(strategy string, fn Func) error {
+	if strategy == "" {
+		return errors.New("strategy name is required")
+	}
+	if fn == nil {
+		return fmt.Errorf("strategy %q requires a function", strategy)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	name := strings.ToLower(strategy)
+	if _, exists := strategies[name]; exists {
+		return fmt.Errorf("strategy %q already exists", strategy)
+	}
+	strategies[name] = fn
+
+	return nil
+}
+
 // Retrieve returns the specified select strategy function.
 func Retrieve(strategy string) (Func, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+
 	fn, exists := strategies[strings.ToLower(strategy)]
 	if !exists {
 		return nil, fmt.Errorf("strategy %q does not exist", strategy)
@@ -34,6 +85,26 @@ func Retrieve(strategy string) (Func, error) {
 	return fn, nil
 }
 
+// RetrieveOrder returns the function that orders every transaction the way
+// the specified strategy would pick them. It's used to report where a
+// transaction sits without running a full selection, so strategies that
+// search for the best selection are ordered by tip instead.
+func RetrieveOrder(strategy string) (Func, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	name := strings.ToLower(strategy)
+	if fn, exists := orders[name]; exists {
+		return fn, nil
+	}
+
+	fn, exists := strategies[name]
+	if !exists {
+		return nil, fmt.Errorf("strategy %q does not exist", strategy)
+	}
+	return fn, nil
+}
+
 // byNonce provides sorting support by the transaction id value.
 type byNonce []database.BlockTx
 
@@ -71,3 +142,63 @@ func (bt byTip) Less(i, j int) bool {
 func (bt byTip) Swap(i, j int) {
 	bt[i], bt[j] = bt[j], bt[i]
 }
+
+// =============================================================================
+
+// headsSelect returns transactions by repeatedly taking the transaction with
+// the highest priority from the front of each account's list. Since only the
+// lowest nonce for an account can be picked, nonce ordering is respected.
+// The before function reports if transaction a has priority over b.
+func headsSelect(m map[database.AccountID][]database.BlockTx, howMany int, before func(a, b database.BlockTx) bool) []database.BlockTx {
+	h := heads{before: before}
+	var total int
+	for key := range m {
+		if len(m[key]) > 1 {
+			sort.Sort(byNonce(m[key]))
+		}
+		if len(m[key]) > 0 {
+			h.accounts = append(h.accounts, m[key])
+		}
+		total += len(m[key])
+	}
+	heap.Init(&h)
+
+	if howMany <= 0 || howMany > total {
+		howMany = total
+	}
+
+	final := make([]database.BlockTx, 0, howMany)
+	for len(final) < howMany {
+		txs := h.accounts[0]
+		final = append(final, txs[0])
+
+		if len(txs) == 1 {
+			heap.Pop(&h)
+			continue
+		}
+		h.accounts[0] = txs[1:]
+		heap.Fix(&h, 0)
+	}
+
+	return final
+}
+
+// heads implements heap.Interface over the remaining transactions for each
+// account, ordered by the first transaction in each list.
+type heads struct {
+	accounts [][]database.BlockTx
+	before   func(a, b database.BlockTx) bool
+}
+
+func (h heads) Len() int           { return len(h.accounts) }
+func (h heads) Less(i, j int) bool { return h.before(h.accounts[i][0], h.accounts[j][0]) }
+func (h heads) Swap(i, j int)      { h.accounts[i], h.accounts[j] = h.accounts[j], h.accounts[i] }
+func (h *heads) Push(x any)        { h.accounts = append(h.accounts, x.([]database.BlockTx)) }
+
+func (h *heads) Pop() any {
+	old := h.accounts
+	n := len(old)
+	x := old[n-1]
+	h.accounts = old[:n-1]
+	return x
+}
@@ -0,0 +1,200 @@
+package selector_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/database"
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/mempool/selector"
+)
+
+func TestRegister(t *testing.T) {
+	first := func(m map[database.AccountID][]database.BlockTx, howMany int) []database.BlockTx {
+		return nil
+	}
+
+	// Strategies can't be removed once registered so each run of the test
+	// needs its own name.
+	name := fmt.Sprintf("Test_Register_%d", time.Now().UnixNano())
+
+	if err := selector.Register(name, first); err != nil {
+		t.Fatalf("Should be able to register a strategy: %s", err)
+	}
+
+	if _, err := selector.Retrieve(strings.ToLower(name)); err != nil {
+		t.Fatalf("Should be able to retrieve the registered strategy: %s", err)
+	}
+
+	if err := selector.Register(strings.ToLower(name), first); err == nil {
+		t.Fatalf("Should not be able to register the same strategy twice")
+	}
+
+	if err := selector.Register(selector.StrategyTip, first); err == nil {
+		t.Fatalf("Should not be able to replace a built-in strategy")
+	}
+
+	if err := selector.Register("test_nil", nil); err == nil {
+		t.Fatalf("Should not be able to register a strategy without a function")
+	}
+}
+
+func TestPrioritySort(t *testing.T) {
+	now := time.Now()
+
+	tran := func(from string, nonce uint64, tip uint64, gasUnits uint64, received time.Time) database.BlockTx {
+		return database.BlockTx{
+			SignedTx:  database.SignedTx{Tx: database.Tx{FromID: database.AccountID(from), Nonce: nonce, Tip: tip}},
+			TimeStamp: uint64(received.UTC().UnixMilli()),
+			GasPrice:  10,
+			GasUnits:  gasUnits,
+		}
+	}
+
+	type test struct {
+		name     string
+		strategy string
+		txs      []database.BlockTx
+		howMany  int
+		best     []database.BlockTx
+	}
+
+	tt := []test{
+		{
+			name:     "fifo oldest first",
+			strategy: selector.StrategyFIFO,
+			txs: []database.BlockTx{
+				tran(fromPavel, 1, 100, 1, now.Add(-time.Minute)),
+				tran(fromPavel, 2, 100, 1, now),
+				tran(fromBill, 1, 0, 1, now.Add(-3*time.Minute)),
+				tran(fromBill, 2, 0, 1, now.Add(-2*time.Minute)),
+			},
+			howMany: 3,
+			best: []database.BlockTx{
+				tran(fromBill, 1, 0, 1, now.Add(-3*time.Minute)),
+				tran(fromBill, 2, 0, 1, now.Add(-2*time.Minute)),
+				tran(fromPavel, 1, 100, 1, now.Add(-time.Minute)),
+			},
+		},
+		{
+			name:     "fifo respects nonce",
+			strategy: selector.StrategyFIFO,
+			txs: []database.BlockTx{
+				tran(fromPavel, 2, 0, 1, now.Add(-time.Minute)),
+				tran(fromPavel, 1, 0, 1, now),
+				tran(fromBill, 1, 0, 1, now.Add(-30*time.Second)),
+			},
+			howMany: 3,
+			best: []database.BlockTx{
+				tran(fromBill, 1, 0, 1, now.Add(-30*time.Second)),
+				tran(fromPavel, 1, 0, 1, now),
+				tran(fromPavel, 2, 0, 1, now.Add(-time.Minute)),
+			},
+		},
+		{
+			name:     "fee per gas",
+			strategy: selector.StrategyFeePerGas,
+			txs: []database.BlockTx{
+				tran(fromPavel, 1, 100, 10, now),
+				tran(fromBill, 1, 50, 1, now),
+				tran(fromEd, 1, 20, 1, now),
+			},
+			howMany: 2,
+			best: []database.BlockTx{
+				tran(fromBill, 1, 50, 1, now),
+				tran(fromEd, 1, 20, 1, now),
+			},
+		},
+		{
+			name:     "tip age prevents starvation",
+			strategy: selector.StrategyTipAge,
+			txs: []database.BlockTx{
+				tran(fromPavel, 1, 5, 1, now.Add(-2*time.Hour)),
+				tran(fromBill, 1, 40, 1, now),
+				tran(fromEd, 1, 30, 1, now),
+			},
+			howMany: 2,
+			best: []database.BlockTx{
+				tran(fromPavel, 1, 5, 1, now.Add(-2*time.Hour)),
+				tran(fromBill, 1, 40, 1, now),
+			},
+		},
+		{
+			name:     "all when zero",
+			strategy: selector.StrategyTipAge,
+			txs: []database.BlockTx{
+				tran(fromPavel, 1, 5, 1, now),
+				tran(fromBill, 1, 40, 1, now),
+			},
+			howMany: 0,
+			best: []database.BlockTx{
+				tran(fromBill, 1, 40, 1, now),
+				tran(fromPavel, 1, 5, 1, now),
+			},
+		},
+	}
+
+	for _, tst := range tt {
+		f := func(t *testing.T) {
+			m := make(map[database.AccountID][]database.BlockTx)
+			for _, tx := range tst.txs {
+				m[tx.FromID] = append(m[tx.FromID], tx)
+			}
+
+			sort, err := selector.Retrieve(tst.strategy)
+			if err != nil {
+				t.Fatalf("Test %s:\tShould be able to get sort strategy function: %s", tst.name, err)
+			}
+
+			txs := sort(m, tst.howMany)
+			if len(txs) != len(tst.best) {
+				t.Fatalf("Test %s:\tShould to get %d after sort, but got %d", tst.name, len(tst.best), len(txs))
+			}
+
+			for i, exp := range tst.best {
+				if txs[i].FromID != exp.FromID || txs[i].Nonce != exp.Nonce {
+					t.Fatalf("Test %s:\tShould get back the right from/nonce at %d: got %s/%d, exp %s/%d", tst.name, i, txs[i].FromID, txs[i].Nonce, exp.FromID, exp.Nonce)
+				}
+			}
+		}
+
+		t.Run(tst.name, f)
+	}
+}
+
+func TestRetrieveOrder(t *testing.T) {
+	tran := func(from string, nonce uint64, tip uint64) database.BlockTx {
+		return database.BlockTx{
+			SignedTx: database.SignedTx{Tx: database.Tx{FromID: database.AccountID(from), Nonce: nonce, Tip: tip}},
+		}
+	}
+
+	// The searching tip strategies are ordered by tip one row of nonces at a
+	// time, even when every transaction is asked for.
+	for _, strategy := range []string{selector.StrategyTip, selector.StrategyTipAdvanced, selector.StrategyTipOptimal} {
+		order, err := selector.RetrieveOrder(strategy)
+		if err != nil {
+			t.Fatalf("Should be able to get the order for %s: %s", strategy, err)
+		}
+
+		m := make(map[database.AccountID][]database.BlockTx)
+		for _, tx := range []database.BlockTx{tran(fromBill, 2, 100), tran(fromBill, 1, 10), tran(fromPavel, 1, 30), tran(fromEd, 1, 20)} {
+			m[tx.FromID] = append(m[tx.FromID], tx)
+		}
+
+		var got []string
+		for _, tx := range order(m, 0) {
+			got = append(got, fmt.Sprintf("%s/%d", tx.FromID, tx.Nonce))
+		}
+
+		exp := []string{fromPavel + "/1", fromEd + "/1", fromBill + "/1", fromBill + "/2"}
+		if strings.Join(got, " ") != strings.Join(exp, " ") {
+			t.Fatalf("Should order %s by tip within each nonce, got %v, exp %v", strategy, got, exp)
+		}
+	}
+
+	if _, err := selector.RetrieveOrder("unknown"); err == nil {
+		t.Fatal("Should not be able to get the order for an unknown strategy")
+	}
+}
@@ -92,3 +92,37 @@ var tipSelect = func(m map[database.AccountID][]database.BlockTx, howMany int) [
 
 	return final
 }
+
+// tipOrder returns the transactions in the order tipSelect would pick them,
+// one row of nonces at a time with each row sorted by tip. Unlike tipSelect
+// the rows are always sorted, so it also gives a stable order when every
+// transaction is returned.
+var tipOrder = func(m map[database.AccountID][]database.BlockTx, howMany int) []database.BlockTx {
+	for key := range m {
+		if len(m[key]) > 1 {
+			sort.Sort(byNonce(m[key]))
+		}
+	}
+
+	var final []database.BlockTx
+	for i := 0; ; i++ {
+		var row []database.BlockTx
+		for _, txs := range m {
+			if len(txs) > i {
+				row = append(row, txs[i])
+			}
+		}
+		if row == nil {
+			break
+		}
+
+		sort.Stable(byTip(row))
+		final = append(final, row...)
+	}
+
+	if howMany > 0 && howMany < len(final) {
+		final = final[:howMany]
+	}
+
+	return final
+}
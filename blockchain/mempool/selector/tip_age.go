@@ -0,0 +1,43 @@
+package selector
+
+import (
+	"time"
+
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/database"
+)
+
+// agePeriod is how long a transaction waits in the mempool before the
+// weight of its tip has doubled.
+const agePeriod = 10 * time.Minute
+
+// tipAgeSelect returns transactions with the best tip weighted by how long
+// they have been waiting, while respecting the nonce for each account/
+// transaction. A transaction with a low tip keeps gaining weight until it is
+// picked, so it can't be starved by a steady stream of higher tips.
+var tipAgeSelect = func(m map[database.AccountID][]database.BlockTx, howMany int) []database.BlockTx {
+	now := uint64(time.Now().UTC().UnixMilli())
+
+	before := func(a, b database.BlockTx) bool {
+		wa, wb := ageWeight(a, now), ageWeight(b, now)
+		if wa != wb {
+			return wa > wb
+		}
+		if a.TimeStamp != b.TimeStamp {
+			return a.TimeStamp < b.TimeStamp
+		}
+		return a.FromID < b.FromID
+	}
+
+	return headsSelect(m, howMany, before)
+}
+
+// ageWeight calculates the priority of a transaction. One is added to the tip
+// so transactions without a tip still age.
+func ageWeight(tx database.BlockTx, now uint64) float64 {
+	var age float64
+	if now > tx.TimeStamp {
+		age = float64(now - tx.TimeStamp)
+	}
+
+	return float64(tx.Tip+1) * (1 + age/float64(agePeriod.Milliseconds()))
+}
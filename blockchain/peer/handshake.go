@@ -0,0 +1,80 @@
+package peer
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/signature"
+)
+
+// ProtocolVersion is the version of the node to node protocol. It needs to
+// change every time a change is made that older nodes can't understand.
+const ProtocolVersion = 2
+
+// ErrHandshake is returned when a peer is running a different blockchain
+// or protocol and must be refused.
+var ErrHandshake = errors.New("peer handshake refused")
+
+// Handshake represents the information nodes exchange before they accept
+// each other as peers. The handshake is signed with the node identity key
+// so the node id can't be claimed by another node.
+type Handshake struct {
+	ChainID         uint16 `json:"chain_id"`
+	GenesisHash     string `json:"genesis_hash"`
+	ProtocolVersion uint16 `json:"protocol_version"`
+	NodeID          string `json:"node_id"`
+	Host            string `json:"host"`
+	Signature       string `json:"signature"`
+}
+
+// Sign signs the handshake with the node identity key.
+func (hs Handshake) Sign(privateKey *ecdsa.PrivateKey) (Handshake, error) {
+	hs.Signature = ""
+
+	v, r, s, err := signature.Sign(hs, privateKey)
+	if err != nil {
+		return Handshake{}, err
+	}
+	hs.Signature = signature.SignatureString(v, r, s)
+
+	return hs, nil
+}
+
+// Validate checks the handshake received from a peer is for the same
+// blockchain and protocol as the specified local handshake and was signed
+// by the node it identifies.
+func (hs Handshake) Validate(local Handshake) error {
+	switch {
+	case hs.ProtocolVersion != local.ProtocolVersion:
+		return fmt.Errorf("%w: protocol version mismatch, got[%d] exp[%d]", ErrHandshake, hs.ProtocolVersion, local.ProtocolVersion)
+
+	case hs.ChainID != local.ChainID:
+		return fmt.Errorf("%w: chain id mismatch, got[%d] exp[%d]", ErrHandshake, hs.ChainID, local.ChainID)
+
+	case hs.GenesisHash != local.GenesisHash:
+		return fmt.Errorf("%w: genesis hash mismatch, got[%s] exp[%s]", ErrHandshake, hs.GenesisHash, local.GenesisHash)
+
+	case hs.NodeID == "":
+		return fmt.Errorf("%w: missing node id", ErrHandshake)
+
+	case hs.Host == "":
+		return fmt.Errorf("%w: missing host", ErrHandshake)
+	}
+
+	// The signature is checked without the signature field since that is
+	// how the handshake was signed.
+	sig := hs.Signature
+	hs.Signature = ""
+
+	signer, err := recoverSigner(hs, sig)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrHandshake, err)
+	}
+
+	if signer != hs.NodeID {
+		return fmt.Errorf("%w: node id[%s] signed by[%s]", ErrHandshake, hs.NodeID, signer)
+	}
+
+	return nil
+}
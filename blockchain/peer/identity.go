@@ -0,0 +1,155 @@
+package peer
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/signature"
+)
+
+// Set of HTTP headers a node uses to sign the requests it sends to a peer.
+const (
+	IDHeader        = "X-Node-ID"
+	TimeHeader      = "X-Node-Time"
+	SignatureHeader = "X-Node-Signature"
+)
+
+// MaxClockSkew is how far the time of a signed request can be from the time
+// of the node receiving it. This limits how long a request can be replayed.
+const MaxClockSkew = time.Minute
+
+// ErrUnauthenticated is returned when a request isn't properly signed by
+// the node it claims to come from.
+var ErrUnauthenticated = errors.New("peer request not authenticated")
+
+// NodeID returns the identity of the node that owns the specified key.
+func NodeID(publicKey ecdsa.PublicKey) string {
+	return crypto.PubkeyToAddress(publicKey).String()
+}
+
+// LoadKey reads the node identity key from the specified path. A new key is
+// generated and saved the first time a node starts.
+func LoadKey(path string) (*ecdsa.PrivateKey, error) {
+	privateKey, err := crypto.LoadECDSA(path)
+	if err == nil {
+		return privateKey, nil
+	}
+
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	privateKey, err = crypto.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	if err := crypto.SaveECDSA(path, privateKey); err != nil {
+		return nil, err
+	}
+
+	return privateKey, nil
+}
+
+// =============================================================================
+
+// stamp represents the parts of a request covered by its signature.
+type stamp struct {
+	Method   string
+	Path     string
+	Time     int64
+	BodyHash string
+}
+
+// newStamp constructs the stamp for the specified request and body.
+func newStamp(r *http.Request, t int64, body []byte) stamp {
+	hash := sha256.Sum256(body)
+
+	return stamp{
+		Method:   r.Method,
+		Path:     r.URL.RequestURI(),
+		Time:     t,
+		BodyHash: hexutil.Encode(hash[:]),
+	}
+}
+
+// SignRequest signs the request with the node identity key. The body must be
+// the same bytes that are sent with the request.
+func SignRequest(r *http.Request, body []byte, privateKey *ecdsa.PrivateKey) error {
+	t := time.Now().UTC().Unix()
+
+	v, rs, s, err := signature.Sign(newStamp(r, t, body), privateKey)
+	if err != nil {
+		return err
+	}
+
+	r.Header.Set(IDHeader, NodeID(privateKey.PublicKey))
+	r.Header.Set(TimeHeader, strconv.FormatInt(t, 10))
+	r.Header.Set(SignatureHeader, signature.SignatureString(v, rs, s))
+
+	return nil
+}
+
+// VerifyRequest checks the request was signed by the node it claims to come
+// from and returns the identity of that node.
+func VerifyRequest(r *http.Request, body []byte, now time.Time) (string, error) {
+	nodeID := r.Header.Get(IDHeader)
+	if nodeID == "" {
+		return "", fmt.Errorf("%w: missing node id", ErrUnauthenticated)
+	}
+
+	t, err := strconv.ParseInt(r.Header.Get(TimeHeader), 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("%w: invalid time", ErrUnauthenticated)
+	}
+
+	skew := now.Sub(time.Unix(t, 0))
+	if skew > MaxClockSkew || skew < -MaxClockSkew {
+		return "", fmt.Errorf("%w: time outside of allowed skew", ErrUnauthenticated)
+	}
+
+	signer, err := recoverSigner(newStamp(r, t, body), r.Header.Get(SignatureHeader))
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrUnauthenticated, err)
+	}
+
+	if signer != nodeID {
+		return "", fmt.Errorf("%w: signed by %s", ErrUnauthenticated, signer)
+	}
+
+	return nodeID, nil
+}
+
+// recoverSigner returns the identity of the node that signed the value.
+func recoverSigner(value any, sig string) (string, error) {
+
+	// The signature is 65 bytes encoded in hex with a 0x prefix.
+	if len(sig) != 132 {
+		return "", errors.New("invalid signature length")
+	}
+
+	v, r, s, err := signature.ToVRSFromHexSignature(sig)
+	if err != nil {
+		return "", err
+	}
+
+	if err := signature.VerifySignature(v, r, s); err != nil {
+		return "", err
+	}
+
+	return signature.FromAddress(value, v, r, s)
+}
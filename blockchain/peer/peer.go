@@ -1,6 +1,10 @@
 package peer
 
-import "sync"
+import (
+	"sort"
+	"sync"
+	"time"
+)
 
 // Package peer maintains the peer related information such as the set
 // of know peers and their status.
@@ -23,59 +27,530 @@ func (p Peer) Match(host string) bool {
 }
 
 // PeerStatus represents information about the status
-// of any given peer.
+// of any given peer. Peers are found through discovery, so the
+// status doesn't share the peer's list of known peers.
 type PeerStatus struct {
 	LatestBlockHash   string `json:"latest_block_hash"`
 	LatestBlockNumber uint64 `json:"latest_block_number"`
-	KnownPeers        []Peer `json:"known_peers"`
+}
+
+// info represents what is remembered about a node's reliability along with
+// the host it was last known at.
+type info struct {
+	host     string
+	score    int
+	lastSeen time.Time
+	height   uint64
+}
+
+// member represents a node in the set and the slot it holds.
+type member struct {
+	peer Peer
+	slot slot
+}
+
+// claim represents a host in the set before this node proved which node
+// answers there. A node that connected to this node claims a node id, which
+// is only trusted for the requests the node signs and is never bound to the
+// host.
+type claim struct {
+	slot   slot
+	nodeID string
 }
 
 // PeerSet represents the data representation to maintain a set of known peers.
+// Peers are keyed by the node id they proved in a handshake. A host is held
+// as pending until a handshake this node made to the host proves the node
+// id, and only then is the host bound to the node. Each
+// node carries a score that is adjusted based on its behavior, which is
+// remembered even if the node is removed. A node whose score drops to the ban
+// threshold is removed and can't be added back until the ban expires. Each
+// peer holds an inbound, outbound or reserved slot and the number of inbound
+// and outbound slots can be limited.
 type PeerSet struct {
-	mu  sync.RWMutex
-	set map[Peer]struct{}
+	mu           sync.RWMutex
+	set          map[string]member
+	pending      map[Peer]claim
+	hosts        map[string]string
+	info         map[string]info
+	banned       map[string]time.Time
+	banThreshold int
+	banDuration  time.Duration
+	maxInbound   int
+	maxOutbound  int
 }
 
 // NewPeerSet construct a new info set to manage node peer information.
 func NewPeerSet() *PeerSet {
+	return NewPeerSetWithConfig(Config{
+		BanThreshold: DefaultBanThreshold,
+		BanDuration:  DefaultBanDuration,
+	})
+}
+
+// NewPeerSetWithConfig constructs a new info set with the specified
+// ban policy and peer limits.
+func NewPeerSetWithConfig(cfg Config) *PeerSet {
 	return &PeerSet{
-		set: make(map[Peer]struct{}),
+		set:          make(map[string]member),
+		pending:      make(map[Peer]claim),
+		hosts:        make(map[string]string),
+		info:         make(map[string]info),
+		banned:       make(map[string]time.Time),
+		banThreshold: cfg.BanThreshold,
+		banDuration:  cfg.BanDuration,
+		maxInbound:   cfg.MaxInbound,
+		maxOutbound:  cfg.MaxOutbound,
 	}
 }
 
-// Add adds a new node to the set.
+// Add adds a new node this node connects to into an outbound slot. The node
+// is pending until a handshake proves its node id. When the outbound slots
+// are full, the node only gets a slot if an underperforming node can be
+// rotated out.
 func (ps *PeerSet) Add(peer Peer) bool {
 	ps.mu.Lock()
 	defer ps.mu.Unlock()
 
-	_, exists := ps.set[peer]
-	if !exists {
-		ps.set[peer] = struct{}{}
-		return true
+	if ps.exists(peer) {
+		return false
+	}
+
+	if !ps.free(slotOutbound) {
+		return false
 	}
 
-	return false
+	ps.pending[peer] = claim{slot: slotOutbound}
+	return true
+}
+
+// Exists reports if the node is in the set.
+func (ps *PeerSet) Exists(peer Peer) bool {
+	ps.mu.RLock()
+	defer ps.mu.RUnlock()
+
+	return ps.exists(peer)
 }
 
-// Remove removes a node from the set
+// Remove removes a node from the set. A node in a reserved slot keeps
+// its slot.
 func (ps *PeerSet) Remove(peer Peer) {
 	ps.mu.Lock()
 	defer ps.mu.Unlock()
 
-	delete(ps.set, peer)
+	if c, exists := ps.pending[peer]; exists {
+		if c.slot != slotReserved {
+			delete(ps.pending, peer)
+		}
+		return
+	}
+
+	nodeID, exists := ps.hosts[peer.Host]
+	if !exists || ps.set[nodeID].slot == slotReserved {
+		return
+	}
+
+	ps.unbind(nodeID)
 }
 
-// Copy returns a list of the known peers.
+// Copy returns a list of the known peers ordered by reliability.
 func (ps *PeerSet) Copy(host string) []Peer {
 	ps.mu.RLock()
 	defer ps.mu.RUnlock()
 
-	var peers []Peer
-	for peer := range ps.set {
+	peers := make([]Peer, 0, len(ps.set)+len(ps.pending))
+	for _, m := range ps.set {
+		if !m.peer.Match(host) {
+			peers = append(peers, m.peer)
+		}
+	}
+	for peer := range ps.pending {
 		if !peer.Match(host) {
 			peers = append(peers, peer)
 		}
 	}
 
+	sort.Slice(peers, func(i, j int) bool {
+		return ps.moreReliable(peers[i], peers[j])
+	})
+
 	return peers
 }
+
+// Identify binds the host of the node to the node id it proved in a
+// handshake. It must only be called once this node has made a handshake
+// to the host itself, since the binding replaces any node bound to the host
+// before and moves a node that changed its host. A pending node keeps its
+// slot. A banned node is removed and false is returned.
+func (ps *PeerSet) Identify(peer Peer, nodeID string) bool {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	if ps.isBanned(nodeID) {
+		ps.remove(peer)
+		return false
+	}
+
+	c, pending := ps.pending[peer]
+	delete(ps.pending, peer)
+	s := c.slot
+
+	// The node answering at the host is no longer the node bound to it, so
+	// the node id that just proved itself takes over the slot.
+	if old, exists := ps.hosts[peer.Host]; exists && old != nodeID {
+		s, pending = ps.set[old].slot, true
+		ps.unbind(old)
+	}
+
+	if m, exists := ps.set[nodeID]; exists {
+		delete(ps.hosts, m.peer.Host)
+		m.peer = peer
+		ps.set[nodeID] = m
+		ps.hosts[peer.Host] = nodeID
+		ps.remember(nodeID, peer)
+		return true
+	}
+
+	if pending {
+		ps.set[nodeID] = member{peer: peer, slot: s}
+		ps.hosts[peer.Host] = nodeID
+		ps.remember(nodeID, peer)
+	}
+
+	return true
+}
+
+// Lookup returns the node with the specified node id.
+func (ps *PeerSet) Lookup(nodeID string) (Peer, bool) {
+	ps.mu.RLock()
+	defer ps.mu.RUnlock()
+
+	m, exists := ps.set[nodeID]
+	return m.peer, exists
+}
+
+// Bound returns the node id bound to the host of the node.
+func (ps *PeerSet) Bound(peer Peer) (string, bool) {
+	ps.mu.RLock()
+	defer ps.mu.RUnlock()
+
+	nodeID, exists := ps.hosts[peer.Host]
+	return nodeID, exists
+}
+
+// Claimed returns the host held in the set that claims the node id without
+// having proved it yet.
+func (ps *PeerSet) Claimed(nodeID string) (Peer, bool) {
+	ps.mu.RLock()
+	defer ps.mu.RUnlock()
+
+	for peer, c := range ps.pending {
+		if c.nodeID == nodeID {
+			return peer, true
+		}
+	}
+
+	return Peer{}, false
+}
+
+// Seen records the node responded with the specified latest block height.
+// Nothing is recorded for a node that hasn't proved its node id.
+func (ps *PeerSet) Seen(peer Peer, height uint64) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	nodeID, exists := ps.hosts[peer.Host]
+	if !exists {
+		return
+	}
+
+	inf := ps.info[nodeID]
+	inf.lastSeen = time.Now().UTC()
+	inf.height = height
+	ps.info[nodeID] = inf
+}
+
+// Adjust changes the score for the node by the specified amount. If the
+// score drops to the ban threshold, the node is removed from the set and
+// banned. Once the ban expires, the node starts over with a zero score.
+// A node in a reserved slot is trusted and never banned. Only a node that
+// proved its node id can be scored, since the score and ban belong to the
+// node and not the host.
+func (ps *PeerSet) Adjust(peer Peer, delta int) (score int, banned bool) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	nodeID, exists := ps.hosts[peer.Host]
+	if !exists {
+		return 0, false
+	}
+
+	inf := ps.info[nodeID]
+	score = min(inf.score+delta, MaxScore)
+	if ps.set[nodeID].slot == slotReserved {
+		score = max(score, ps.banThreshold+1)
+	}
+	if score > ps.banThreshold {
+		inf.score = score
+		ps.info[nodeID] = inf
+		return score, false
+	}
+
+	ps.unbind(nodeID)
+	delete(ps.info, nodeID)
+	ps.banned[nodeID] = time.Now().Add(ps.banDuration)
+
+	return score, true
+}
+
+// Banned reports if the node at the host of the peer is currently banned.
+func (ps *PeerSet) Banned(peer Peer) bool {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	nodeID, exists := ps.hosts[peer.Host]
+	return exists && ps.isBanned(nodeID)
+}
+
+// BannedNode reports if the node with the specified node id is currently
+// banned.
+func (ps *PeerSet) BannedNode(nodeID string) bool {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	return ps.isBanned(nodeID)
+}
+
+// Scores returns the score for each node in the set, each node with a score
+// that has been removed and the nodes that are currently banned.
+func (ps *PeerSet) Scores() []Score {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	scores := make([]Score, 0, len(ps.set)+len(ps.pending)+len(ps.banned))
+	for nodeID, m := range ps.set {
+		scores = append(scores, Score{Host: m.peer.Host, NodeID: nodeID, Score: ps.info[nodeID].score, Known: true, Slot: m.slot.String()})
+	}
+	for peer, c := range ps.pending {
+		scores = append(scores, Score{Host: peer.Host, Known: true, Slot: c.slot.String()})
+	}
+	for nodeID, inf := range ps.info {
+		if _, exists := ps.set[nodeID]; !exists {
+			scores = append(scores, Score{Host: inf.host, NodeID: nodeID, Score: inf.score})
+		}
+	}
+	for nodeID, until := range ps.banned {
+		if ps.isBanned(nodeID) {
+			scores = append(scores, Score{NodeID: nodeID, Score: ps.banThreshold, BannedUntil: &until})
+		}
+	}
+
+	sort.Slice(scores, func(i, j int) bool {
+		if scores[i].Host != scores[j].Host {
+			return scores[i].Host < scores[j].Host
+		}
+		return scores[i].NodeID < scores[j].NodeID
+	})
+
+	return scores
+}
+
+// Records returns what is known about every node in the set, ordered by
+// reliability, followed by the nodes that are currently banned, so they can
+// be saved and restored later. What is remembered about nodes outside the
+// set isn't saved, which keeps the store from growing without limit.
+func (ps *PeerSet) Records() []Record {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	records := make([]Record, 0, len(ps.set)+len(ps.pending))
+	for nodeID, m := range ps.set {
+		inf := ps.info[nodeID]
+		records = append(records, Record{
+			Host:     m.peer.Host,
+			ID:       nodeID,
+			LastSeen: inf.lastSeen,
+			Height:   inf.height,
+			Score:    inf.score,
+		})
+	}
+	for peer := range ps.pending {
+		records = append(records, Record{Host: peer.Host})
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		return ps.moreReliableInfo(ps.info[records[i].ID], records[i].Host, ps.info[records[j].ID], records[j].Host)
+	})
+
+	bans := make([]Record, 0, len(ps.banned))
+	for nodeID, until := range ps.banned {
+		if ps.isBanned(nodeID) {
+			bans = append(bans, Record{ID: nodeID, BannedUntil: &until})
+		}
+	}
+
+	sort.Slice(bans, func(i, j int) bool {
+		return bans[i].ID < bans[j].ID
+	})
+
+	return append(records, bans...)
+}
+
+// Restore adds the nodes from the specified records to the set along with
+// what was known about them. The nodes are added to outbound slots in the
+// order of the records until the slots are full. What is known about the
+// rest is kept in case they are added later, up to MaxRemembered nodes. The
+// node id of a record was proved by a handshake when it was saved, so the
+// host is bound to it again. The bans that haven't expired are restored.
+func (ps *PeerSet) Restore(records []Record) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	for _, rec := range records {
+		if rec.BannedUntil != nil {
+			if rec.ID != "" && time.Now().Before(*rec.BannedUntil) {
+				ps.banned[rec.ID] = *rec.BannedUntil
+			}
+			continue
+		}
+
+		peer := New(rec.Host)
+		if rec.Host == "" || ps.exists(peer) {
+			continue
+		}
+
+		if rec.ID == "" {
+			if ps.free(slotOutbound) {
+				ps.pending[peer] = claim{slot: slotOutbound}
+			}
+			continue
+		}
+
+		if ps.isBanned(rec.ID) {
+			continue
+		}
+		if _, exists := ps.set[rec.ID]; exists {
+			continue
+		}
+
+		ps.info[rec.ID] = info{
+			host:     rec.Host,
+			score:    min(rec.Score, MaxScore),
+			lastSeen: rec.LastSeen,
+			height:   rec.Height,
+		}
+
+		if ps.free(slotOutbound) {
+			ps.set[rec.ID] = member{peer: peer, slot: slotOutbound}
+			ps.hosts[rec.Host] = rec.ID
+		}
+	}
+
+	ps.forget()
+}
+
+// moreReliable reports if node a is more reliable than node b. Nodes with a
+// higher score come first, then the nodes seen most recently and then the
+// nodes with the most blocks. The caller must hold a lock.
+func (ps *PeerSet) moreReliable(a Peer, b Peer) bool {
+	return ps.moreReliableInfo(ps.info[ps.hosts[a.Host]], a.Host, ps.info[ps.hosts[b.Host]], b.Host)
+}
+
+// moreReliableInfo compares what is known about two nodes for moreReliable.
+func (ps *PeerSet) moreReliableInfo(ia info, hostA string, ib info, hostB string) bool {
+	switch {
+	case ia.score != ib.score:
+		return ia.score > ib.score
+	case !ia.lastSeen.Equal(ib.lastSeen):
+		return ia.lastSeen.After(ib.lastSeen)
+	case ia.height != ib.height:
+		return ia.height > ib.height
+	}
+
+	return hostA < hostB
+}
+
+// exists reports if the host of the node is pending or bound to a node in
+// the set. The caller must hold a lock.
+func (ps *PeerSet) exists(peer Peer) bool {
+	if _, exists := ps.pending[peer]; exists {
+		return true
+	}
+
+	_, exists := ps.hosts[peer.Host]
+	return exists
+}
+
+// remove removes the node at the host whether it's pending or bound. The
+// caller must hold the write lock.
+func (ps *PeerSet) remove(peer Peer) {
+	delete(ps.pending, peer)
+	if nodeID, exists := ps.hosts[peer.Host]; exists {
+		ps.unbind(nodeID)
+	}
+}
+
+// unbind removes the node from the set and forgets the host bound to it.
+// What is known about the node is kept, up to MaxRemembered nodes. The
+// caller must hold the write lock.
+func (ps *PeerSet) unbind(nodeID string) {
+	if m, exists := ps.set[nodeID]; exists {
+		delete(ps.hosts, m.peer.Host)
+		delete(ps.set, nodeID)
+	}
+
+	ps.forget()
+}
+
+// forget drops what is known about the least reliable nodes outside the
+// set once more than MaxRemembered are remembered, along with the bans that
+// expired. The caller must hold the write lock.
+func (ps *PeerSet) forget() {
+	for nodeID := range ps.banned {
+		ps.isBanned(nodeID)
+	}
+
+	var nodeIDs []string
+	for nodeID := range ps.info {
+		if _, exists := ps.set[nodeID]; !exists {
+			nodeIDs = append(nodeIDs, nodeID)
+		}
+	}
+
+	if len(nodeIDs) <= MaxRemembered {
+		return
+	}
+
+	sort.Slice(nodeIDs, func(i, j int) bool {
+		a, b := ps.info[nodeIDs[i]], ps.info[nodeIDs[j]]
+		return ps.moreReliableInfo(a, a.host, b, b.host)
+	})
+
+	for _, nodeID := range nodeIDs[MaxRemembered:] {
+		delete(ps.info, nodeID)
+	}
+}
+
+// remember records the host the node was last known at. The caller must
+// hold the write lock.
+func (ps *PeerSet) remember(nodeID string, peer Peer) {
+	inf := ps.info[nodeID]
+	inf.host = peer.Host
+	ps.info[nodeID] = inf
+}
+
+// isBanned reports if the node is currently banned and forgets bans that
+// have expired. The caller must hold the write lock.
+func (ps *PeerSet) isBanned(nodeID string) bool {
+	until, exists := ps.banned[nodeID]
+	if !exists {
+		return false
+	}
+
+	if time.Now().After(until) {
+		delete(ps.banned, nodeID)
+		return false
+	}
+
+	return true
+}
@@ -1,8 +1,14 @@
 package peer_test
 
 import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/zacksfF/FullStack-Blockchain/blockchain/peer"
 )
 
@@ -45,3 +51,352 @@ func Test_CRUD(t *testing.T) {
 		t.Run(tst.name, f)
 	}
 }
+
+func Test_Handshake(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("Should be able to generate a node key: %s", err)
+	}
+
+	local := peer.Handshake{
+		ChainID:         1,
+		GenesisHash:     "0xgenesis",
+		ProtocolVersion: peer.ProtocolVersion,
+		NodeID:          "node1",
+		Host:            "host1",
+	}
+
+	type table struct {
+		name   string
+		change func(hs *peer.Handshake)
+		refuse bool
+	}
+
+	tt := []table{
+		{name: "match", change: func(hs *peer.Handshake) {}},
+		{name: "chain id", change: func(hs *peer.Handshake) { hs.ChainID = 2 }, refuse: true},
+		{name: "genesis", change: func(hs *peer.Handshake) { hs.GenesisHash = "0xother" }, refuse: true},
+		{name: "protocol", change: func(hs *peer.Handshake) { hs.ProtocolVersion++ }, refuse: true},
+		{name: "node id", change: func(hs *peer.Handshake) { hs.NodeID = "" }, refuse: true},
+		{name: "impostor", change: func(hs *peer.Handshake) { hs.NodeID = "0xF01813E4B85e178A83e29B8E7bF26BD830a25f32" }, refuse: true},
+	}
+
+	for _, tst := range tt {
+		f := func(t *testing.T) {
+			hs := local
+			hs.NodeID, hs.Host = peer.NodeID(privateKey.PublicKey), "host2"
+			tst.change(&hs)
+
+			hs, err := hs.Sign(privateKey)
+			if err != nil {
+				t.Fatalf("Test %s:\tShould be able to sign the handshake: %s", tst.name, err)
+			}
+
+			err = hs.Validate(local)
+			switch {
+			case tst.refuse && !errors.Is(err, peer.ErrHandshake):
+				t.Fatalf("Test %s:\tShould refuse the handshake, got: %v", tst.name, err)
+			case !tst.refuse && err != nil:
+				t.Fatalf("Test %s:\tShould accept the handshake: %v", tst.name, err)
+			}
+		}
+
+		t.Run(tst.name, f)
+	}
+}
+
+func Test_Scores(t *testing.T) {
+	ps := peer.NewPeerSetWithConfig(peer.Config{BanThreshold: -20, BanDuration: 50 * time.Millisecond})
+
+	bad := peer.New("host1")
+	good := peer.New("host2")
+	ps.Add(bad)
+	ps.Add(good)
+	ps.Identify(bad, "node1")
+	ps.Identify(good, "node2")
+
+	// The score belongs to the node, so a host that hasn't proved its node
+	// id can't be scored.
+	pending := peer.New("host3")
+	ps.Add(pending)
+	if score, banned := ps.Adjust(pending, -1000); banned || score != 0 {
+		t.Fatalf("Should not score a peer without a node id, got %d banned %v", score, banned)
+	}
+
+	if score, banned := ps.Adjust(good, peer.MaxScore+10); banned || score != peer.MaxScore {
+		t.Fatalf("Should cap the score at %d, got %d banned %v", peer.MaxScore, score, banned)
+	}
+
+	if _, banned := ps.Adjust(bad, peer.ScoreTimeout); banned {
+		t.Fatalf("Should not ban a peer above the threshold.")
+	}
+	if _, banned := ps.Adjust(bad, peer.ScoreTimeout); !banned {
+		t.Fatalf("Should ban a peer at the threshold.")
+	}
+
+	if ps.Exists(bad) || !ps.BannedNode("node1") {
+		t.Fatalf("Should remove the banned peer.")
+	}
+
+	// A banned node can't come back at the same or another host.
+	for _, pr := range []peer.Peer{bad, peer.New("host4")} {
+		ps.Add(pr)
+		if ps.Identify(pr, "node1") || ps.Exists(pr) {
+			t.Fatalf("Should not be able to add a banned node at %s.", pr.Host)
+		}
+	}
+
+	scores := ps.Scores()
+	if len(scores) != 3 || scores[0].BannedUntil == nil || scores[0].NodeID != "node1" {
+		t.Fatalf("Should get back the ban for the node: %+v", scores)
+	}
+	if scores[1].Score != peer.MaxScore || !scores[1].Known || scores[2].Host != pending.Host {
+		t.Fatalf("Should get back the scores for the known peers: %+v", scores)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	if ps.BannedNode("node1") || !ps.Add(bad) || !ps.Identify(bad, "node1") {
+		t.Fatalf("Should be able to add the peer once the ban expires.")
+	}
+	if score, _ := ps.Adjust(bad, 0); score != 0 {
+		t.Fatalf("Should start over with a zero score, got %d", score)
+	}
+}
+
+func Test_Identify(t *testing.T) {
+	ps := peer.NewPeerSet()
+
+	pr := peer.New("host1")
+	ps.Add(pr)
+	if _, exists := ps.Lookup("node1"); exists {
+		t.Fatalf("Should not know the node before it proves its node id.")
+	}
+
+	ps.Identify(pr, "node1")
+	if got, exists := ps.Lookup("node1"); !exists || got != pr {
+		t.Fatalf("Should bind the host to the node id, got %v %v", got, exists)
+	}
+	ps.Adjust(pr, peer.ScoreUsefulBlock)
+
+	// The node moved to another host and proved it there.
+	moved := peer.New("host2")
+	ps.Identify(moved, "node1")
+	if got, _ := ps.Lookup("node1"); got != moved || ps.Exists(pr) {
+		t.Fatalf("Should move the node to its new host, got %v", got)
+	}
+	if score, _ := ps.Adjust(moved, 0); score != peer.ScoreUsefulBlock {
+		t.Fatalf("Should keep the score of the node when it moves, got %d", score)
+	}
+
+	// Another node answers at the host now and takes it over.
+	ps.Identify(moved, "node2")
+	if _, exists := ps.Lookup("node1"); exists {
+		t.Fatalf("Should unbind the node that no longer answers at the host.")
+	}
+	if nodeID, _ := ps.Bound(moved); nodeID != "node2" {
+		t.Fatalf("Should bind the host to the node answering there, got %s", nodeID)
+	}
+
+	// A node that connected only claims its node id until this node makes
+	// a handshake to its host.
+	claimed := peer.New("host4")
+	ps.AddInbound(claimed, "node4")
+	if _, exists := ps.Lookup("node4"); exists {
+		t.Fatalf("Should not bind a claimed node id.")
+	}
+	if got, exists := ps.Claimed("node4"); !exists || got != claimed {
+		t.Fatalf("Should get back the host claiming the node id, got %v %v", got, exists)
+	}
+	ps.Identify(claimed, "node5")
+	if _, exists := ps.Claimed("node4"); exists {
+		t.Fatalf("Should drop the claim once the host proves its node id.")
+	}
+	if nodeID, _ := ps.Bound(claimed); nodeID != "node5" {
+		t.Fatalf("Should bind the host to the node id it proved, got %s", nodeID)
+	}
+
+	// A node can be identified without being in the set.
+	ps.Identify(peer.New("host3"), "node3")
+	if _, exists := ps.Lookup("node3"); exists || ps.Exists(peer.New("host3")) {
+		t.Fatalf("Should not add a node that isn't in the set.")
+	}
+}
+
+func Test_Remember(t *testing.T) {
+	ps := peer.NewPeerSet()
+
+	// Every node gets a different score so the least reliable are known.
+	for i := 0; i < peer.MaxRemembered+10; i++ {
+		pr := peer.New(fmt.Sprintf("host%d", i))
+		ps.Add(pr)
+		ps.Identify(pr, fmt.Sprintf("node%d", i))
+		ps.Adjust(pr, i%peer.MaxScore)
+		ps.Remove(pr)
+	}
+
+	scores := ps.Scores()
+	if len(scores) != peer.MaxRemembered {
+		t.Fatalf("Should only remember %d nodes outside the set, got %d", peer.MaxRemembered, len(scores))
+	}
+
+	for _, score := range scores {
+		if score.Score == 0 {
+			t.Fatalf("Should forget the least reliable nodes first, got %+v", score)
+		}
+	}
+}
+
+func Test_Slots(t *testing.T) {
+	ps := peer.NewPeerSetWithConfig(peer.Config{BanThreshold: -100, BanDuration: time.Hour, MaxInbound: 1, MaxOutbound: 2})
+
+	out1, out2, out3 := peer.New("out1"), peer.New("out2"), peer.New("out3")
+	if !ps.Add(out1) || !ps.Add(out2) {
+		t.Fatalf("Should be able to add peers while there are free outbound slots.")
+	}
+	if ps.Add(out3) {
+		t.Fatalf("Should not add a peer once the outbound slots are full.")
+	}
+	ps.Identify(out1, "out1")
+	ps.Identify(out2, "out2")
+
+	// The inbound slots are counted separately.
+	in1, in2 := peer.New("in1"), peer.New("in2")
+	if !ps.AddInbound(in1, "in1") || ps.AddInbound(in2, "in2") {
+		t.Fatalf("Should only add one inbound peer.")
+	}
+	ps.Identify(in1, "in1")
+
+	// Trusted peers always get a slot.
+	trusted := peer.New("trusted")
+	ps.Reserve(trusted)
+	if !ps.Exists(trusted) {
+		t.Fatalf("Should add a trusted peer when the slots are full.")
+	}
+	ps.Identify(trusted, "trusted")
+
+	if _, rotated := ps.Rotate(); rotated {
+		t.Fatalf("Should not rotate out peers that are performing.")
+	}
+
+	// An underperforming peer gives up its slot.
+	ps.Adjust(out2, peer.ScoreTimeout)
+	if pr, rotated := ps.Rotate(); !rotated || pr != out2 || ps.Exists(out2) {
+		t.Fatalf("Should rotate out the underperforming peer, got %v %v", pr, rotated)
+	}
+	if !ps.Add(out3) {
+		t.Fatalf("Should add a peer into the free slot.")
+	}
+
+	ps.Adjust(in1, peer.ScoreTimeout)
+	if !ps.AddInbound(in2, "in2") || ps.Exists(in1) {
+		t.Fatalf("Should replace an underperforming inbound peer.")
+	}
+
+	// Trusted peers are never removed or banned.
+	ps.Remove(trusted)
+	if _, banned := ps.Adjust(trusted, -1000); banned || !ps.Exists(trusted) {
+		t.Fatalf("Should keep the trusted peer.")
+	}
+}
+
+func Test_Store(t *testing.T) {
+	ps := peer.NewPeerSet()
+
+	stale := peer.New("host1")
+	fresh := peer.New("host2")
+	trusted := peer.New("host3")
+	banned := peer.New("host4")
+	removed := peer.New("host5")
+	for i, pr := range []peer.Peer{stale, fresh, trusted, banned, removed} {
+		ps.Add(pr)
+		ps.Identify(pr, fmt.Sprintf("node%d", i+1))
+	}
+	ps.Remove(removed)
+
+	ps.Seen(stale, 5)
+	time.Sleep(time.Millisecond)
+	ps.Seen(fresh, 3)
+	ps.Adjust(trusted, peer.ScoreUsefulBlock)
+	ps.Adjust(banned, peer.DefaultBanThreshold)
+
+	path := t.TempDir() + "/peers/peers.json"
+	if err := peer.Save(path, ps.Records()); err != nil {
+		t.Fatalf("Should be able to save the peers: %s", err)
+	}
+
+	records, err := peer.Load(path)
+	if err != nil {
+		t.Fatalf("Should be able to load the peers: %s", err)
+	}
+
+	restored := peer.NewPeerSet()
+	restored.Restore(records)
+
+	got := restored.Copy("")
+	exp := []peer.Peer{trusted, fresh, stale}
+	if len(got) != len(exp) {
+		t.Fatalf("Should restore %d peers, got %d: %v", len(exp), len(got), got)
+	}
+	for i := range exp {
+		if got[i] != exp[i] {
+			t.Fatalf("Should restore the peers in order of reliability: got %v, exp %v", got, exp)
+		}
+	}
+
+	if pr, exists := restored.Lookup("node3"); !exists || pr != trusted {
+		t.Fatalf("Should restore the node id of the peer, got %v %v", pr, exists)
+	}
+
+	if !restored.BannedNode("node4") {
+		t.Fatalf("Should restore the ban of the node.")
+	}
+	for _, rec := range records {
+		if rec.ID == "node5" {
+			t.Fatalf("Should not save a node outside the set: %+v", rec)
+		}
+	}
+
+	if records, err := peer.Load(t.TempDir() + "/missing.json"); err != nil || records != nil {
+		t.Fatalf("Should get no records for a missing file: %v %v", records, err)
+	}
+}
+
+func Test_SignRequest(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("Should be able to generate a node key: %s", err)
+	}
+
+	body := []byte(`{"nonce":1}`)
+	r := httptest.NewRequest(http.MethodPost, "/v1/node/tx/submit", nil)
+	if err := peer.SignRequest(r, body, privateKey); err != nil {
+		t.Fatalf("Should be able to sign the request: %s", err)
+	}
+
+	nodeID, err := peer.VerifyRequest(r, body, time.Now())
+	if err != nil {
+		t.Fatalf("Should be able to verify the request: %s", err)
+	}
+	if nodeID != peer.NodeID(privateKey.PublicKey) {
+		t.Fatalf("Should get back the node id of the signer: got %s", nodeID)
+	}
+
+	if _, err := peer.VerifyRequest(r, []byte(`{"nonce":2}`), time.Now()); !errors.Is(err, peer.ErrUnauthenticated) {
+		t.Fatalf("Should refuse a request with a changed body, got: %v", err)
+	}
+
+	if _, err := peer.VerifyRequest(r, body, time.Now().Add(2*peer.MaxClockSkew)); !errors.Is(err, peer.ErrUnauthenticated) {
+		t.Fatalf("Should refuse a request signed too long ago, got: %v", err)
+	}
+
+	r.Header.Set(peer.IDHeader, "0xF01813E4B85e178A83e29B8E7bF26BD830a25f32")
+	if _, err := peer.VerifyRequest(r, body, time.Now()); !errors.Is(err, peer.ErrUnauthenticated) {
+		t.Fatalf("Should refuse a request claiming another node id, got: %v", err)
+	}
+
+	r.Header.Del(peer.SignatureHeader)
+	if _, err := peer.VerifyRequest(r, body, time.Now()); !errors.Is(err, peer.ErrUnauthenticated) {
+		t.Fatalf("Should refuse an unsigned request, got: %v", err)
+	}
+}
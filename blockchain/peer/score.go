@@ -0,0 +1,53 @@
+package peer
+
+import "time"
+
+// CORE NOTE: Bitcoin Core gives each peer a misbehavior score and disconnects
+// and bans a peer once the score crosses a threshold. The Ardan blockchain
+// also rewards peers for useful data, so a peer that has a single timeout
+// after providing many blocks is not treated like a peer that has never
+// provided anything.
+
+// Set of amounts a peer's score is adjusted by for its behavior.
+const (
+	ScoreInvalidBlock = -50
+	ScoreInvalidTx    = -20
+	ScoreTimeout      = -10
+	ScoreUsefulBlock  = 5
+	ScoreUsefulTx     = 1
+)
+
+// MaxScore is the highest score a peer can build up. This limits how long a
+// peer that was useful in the past can misbehave before being banned.
+const MaxScore = 100
+
+// MaxRemembered is the most nodes outside the set whose score is
+// remembered. The least reliable nodes are forgotten first.
+const MaxRemembered = 256
+
+// Default ban policy used by NewPeerSet.
+const (
+	DefaultBanThreshold = -100
+	DefaultBanDuration  = time.Hour
+)
+
+// Config represents the ban policy and the peer limits for a peer set.
+// A limit of zero means there is no limit.
+type Config struct {
+	BanThreshold int           // A peer is banned when its score drops to this value.
+	BanDuration  time.Duration // How long a banned peer is refused.
+	MaxInbound   int           // Max number of peers that connected to this node.
+	MaxOutbound  int           // Max number of peers this node connected to.
+}
+
+// Score represents the score of a peer, if the peer is in the known peer
+// list and the slot it holds, and when its ban expires if the peer is banned.
+// A peer that hasn't proved its node id yet has no score.
+type Score struct {
+	Host        string     `json:"host,omitempty"`
+	NodeID      string     `json:"node_id,omitempty"`
+	Score       int        `json:"score"`
+	Known       bool       `json:"known"`
+	Slot        string     `json:"slot,omitempty"`
+	BannedUntil *time.Time `json:"banned_until,omitempty"`
+}
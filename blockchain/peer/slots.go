@@ -0,0 +1,172 @@
+package peer
+
+import (
+	"errors"
+	"sort"
+)
+
+// CORE NOTE: Bitcoin Core limits the number of peers it connects to and the
+// number of peers that can connect to it, so the resources a node uses stay
+// the same as the network grows. A few slots are set aside for peers the
+// operator trusts, so they can always connect. When the slots are full, a
+// peer that is underperforming gives up its slot to a new peer.
+
+// ErrPeerLimit is returned when a peer can't be added because there are no
+// free slots.
+var ErrPeerLimit = errors.New("no free peer slots")
+
+// slot represents how a peer came to be known.
+type slot int
+
+// Set of slots a peer can hold.
+const (
+	slotOutbound slot = iota
+	slotInbound
+	slotReserved
+)
+
+// String implements the Stringer interface.
+func (s slot) String() string {
+	switch s {
+	case slotInbound:
+		return "inbound"
+	case slotReserved:
+		return "reserved"
+	}
+
+	return "outbound"
+}
+
+// AddInbound adds a node that connected to this node into an inbound slot.
+// The node id claimed by the node isn't bound to the host until a handshake
+// this node makes to the host proves it. A host already in the set keeps its
+// slot and takes the claim. When the inbound slots are full, the node only
+// gets a slot if an underperforming node can be rotated out.
+func (ps *PeerSet) AddInbound(peer Peer, nodeID string) bool {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	if ps.isBanned(nodeID) {
+		return false
+	}
+
+	if c, exists := ps.pending[peer]; exists {
+		c.nodeID = nodeID
+		ps.pending[peer] = c
+		return true
+	}
+
+	if ps.exists(peer) || !ps.free(slotInbound) {
+		return false
+	}
+
+	ps.pending[peer] = claim{slot: slotInbound, nodeID: nodeID}
+
+	return true
+}
+
+// Reserve adds a trusted node into a reserved slot, moving the node there if
+// it already holds a slot. Reserved slots don't count against the limits and
+// the node is never rotated out or banned.
+func (ps *PeerSet) Reserve(peer Peer) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	if nodeID, exists := ps.hosts[peer.Host]; exists {
+		delete(ps.banned, nodeID)
+		m := ps.set[nodeID]
+		m.slot = slotReserved
+		ps.set[nodeID] = m
+		return
+	}
+
+	c := ps.pending[peer]
+	c.slot = slotReserved
+	ps.pending[peer] = c
+}
+
+// Rotate removes the least reliable underperforming node from the outbound
+// slots when they are full, which makes room for a new node to be found. It
+// returns the node that was removed.
+func (ps *PeerSet) Rotate() (Peer, bool) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	if !ps.full(slotOutbound) {
+		return Peer{}, false
+	}
+
+	return ps.evict(slotOutbound)
+}
+
+// =============================================================================
+
+// free reports if there is a slot of the specified kind for a new node,
+// rotating out an underperforming node when the slots are full. The caller
+// must hold the write lock.
+func (ps *PeerSet) free(s slot) bool {
+	if !ps.full(s) {
+		return true
+	}
+
+	_, evicted := ps.evict(s)
+	return evicted
+}
+
+// full reports if every slot of the specified kind is taken. The caller must
+// hold a lock.
+func (ps *PeerSet) full(s slot) bool {
+	limit := ps.maxOutbound
+	if s == slotInbound {
+		limit = ps.maxInbound
+	}
+
+	if limit <= 0 {
+		return false
+	}
+
+	var n int
+	for _, m := range ps.set {
+		if m.slot == s {
+			n++
+		}
+	}
+	for _, c := range ps.pending {
+		if c.slot == s {
+			n++
+		}
+	}
+
+	return n >= limit
+}
+
+// evict removes the least reliable node holding the specified kind of slot
+// if its score shows it's underperforming. Only a node that proved its node
+// id has a score, so a pending node is never evicted. The caller must hold
+// the write lock.
+func (ps *PeerSet) evict(s slot) (Peer, bool) {
+	var nodeIDs []string
+	for nodeID, m := range ps.set {
+		if m.slot == s {
+			nodeIDs = append(nodeIDs, nodeID)
+		}
+	}
+
+	if len(nodeIDs) == 0 {
+		return Peer{}, false
+	}
+
+	sort.Slice(nodeIDs, func(i, j int) bool {
+		return ps.moreReliable(ps.set[nodeIDs[i]].peer, ps.set[nodeIDs[j]].peer)
+	})
+
+	worst := nodeIDs[len(nodeIDs)-1]
+	if ps.info[worst].score >= 0 {
+		return Peer{}, false
+	}
+
+	pr := ps.set[worst].peer
+	ps.unbind(worst)
+
+	return pr, true
+}
@@ -0,0 +1,62 @@
+package peer
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Record represents what is known about a peer so it can be saved to disk
+// and restored when the node starts. A record for a banned node only has
+// the node id and when the ban expires.
+type Record struct {
+	Host        string     `json:"host"`
+	ID          string     `json:"id,omitempty"`
+	LastSeen    time.Time  `json:"last_seen"`
+	Height      uint64     `json:"height"`
+	Score       int        `json:"score"`
+	BannedUntil *time.Time `json:"banned_until,omitempty"`
+}
+
+// Load reads the peer records saved at the specified path. A missing file
+// is not an error since the node may be starting for the first time.
+func Load(path string) ([]Record, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var records []Record
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// Save writes the peer records to the specified path. The records are
+// written to a new file that is renamed so a crash never leaves a partially
+// written file behind.
+func Save(path string, records []Record) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
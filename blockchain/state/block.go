@@ -7,12 +7,20 @@ import (
 	"fmt"
 
 	"github.com/zacksfF/FullStack-Blockchain/blockchain/database"
+	"github.com/zacksfF/FullStack-Blockchain/events"
 )
 
 // ErrNoTransaction is returned when a block is required to be created
 // and there are not enough transaction.
 var ErrNoTransactions = errors.New("no transaction is mempool")
 
+// ErrInvalidBlock is returned when a block fails validation.
+var ErrInvalidBlock = errors.New("invalid block")
+
+// ErrMiningOff is returned when a block was mined while mining was turned
+// off, like for a reorganization that reset the chain.
+var ErrMiningOff = errors.New("mining is turned off")
+
 // MineNewBlock attempts to create a new block with a proper hash that can become
 // the next block in the chain.
 func (s *State) MineNewBlock(ctx context.Context) (database.Block, error) {
@@ -20,13 +28,22 @@ func (s *State) MineNewBlock(ctx context.Context) (database.Block, error) {
 
 	s.evHandler("state: MineNewBlock: MINING: check mempool count")
 
+	// Drop anything that can no longer be mined so an expired transaction
+	// doesn't make the new block invalid.
+	s.PruneMempool()
+
 	// Are there enough transactions in the pool.
 	if s.mempool.Count() == 0 {
 		return database.Block{}, ErrNoTransactions
 	}
 
-	// Pick the best transactions from the mempool.
-	trans := s.mempool.PickBest(s.genesis.TransPerBlock)
+	// Pick the best transactions from the mempool that fit into a block.
+	trans := database.FitTrans(s.mempool.PickBest(s.genesis.TransPerBlock))
+
+	s.publish(events.TopicMiningStarted, []database.AccountID{s.beneficiaryID}, MiningEvent{
+		BlockNumber: s.db.LatestBlock().Header.Number + 1,
+		Txs:         len(trans),
+	})
 
 	// If PoA is being used, drop the difficulty down to 1 to speed up
 	// the mining operation.
@@ -57,9 +74,10 @@ func (s *State) MineNewBlock(ctx context.Context) (database.Block, error) {
 	s.evHandler("state: MineNewBlock: MINING: validate and update database")
 
 	// Validate the block and then update the blockchain database.
-	if err := s.validateUpdateDatabase(block); err != nil {
+	if err := s.validateUpdateDatabase(block, true); err != nil {
 		return database.Block{}, err
 	}
+	s.seen.Add(block.Hash())
 
 	return block, nil
 }
@@ -71,9 +89,10 @@ func (s *State) ProcessProposedBlock(block database.Block) error {
 	defer s.evHandler("state: ValidateProposedBlock: completed: newBlk[%s]", block.Hash())
 
 	// Validate the block and then update the blockchain database.
-	if err := s.validateUpdateDatabase(block); err != nil {
+	if err := s.validateUpdateDatabase(block, false); err != nil {
 		return err
 	}
+	s.seen.Add(block.Hash())
 
 	// If the runMiningOperation function is being executed it needs to stop
 	// immediately.
@@ -82,15 +101,55 @@ func (s *State) ProcessProposedBlock(block database.Block) error {
 	return nil
 }
 
+// ReconstructBlock rebuilds a compact block received from a peer using the
+// transactions in the mempool. When transactions are missing, the indexes of
+// the missing transactions are returned so they can be requested.
+func (s *State) ReconstructBlock(cb database.CompactBlock) (database.Block, []int, error) {
+	block, missing, err := cb.Reconstruct(s.mempool.Values())
+	if err != nil {
+		return database.Block{}, nil, err
+	}
+
+	s.evHandler("state: ReconstructBlock: blk[%s]: trans[%d]: missing[%d]", cb.Hash, len(cb.ShortIDs), len(missing))
+
+	return block, missing, nil
+}
+
+// RelayBlock shares a block received from a peer with this node's peers.
+// It should only be called once the block has been accepted.
+func (s *State) RelayBlock(block database.Block) {
+	s.Worker.SignalShareBlock(block)
+}
+
+// IsMisbehavior reports if the error returned from processing a block or
+// transaction sent by a peer means the peer sent invalid data. Blocks that
+// are out of order or from a fork are expected when peers race to mine.
+func IsMisbehavior(err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, database.ErrChainForked), errors.Is(err, database.ErrBlockOutOfOrder):
+		return false
+	}
+
+	return errors.Is(err, ErrInvalidBlock) || errors.Is(err, ErrInvalidTx)
+}
+
 // =============================================================================
 
 // validateUpdateDatabase takes the block and validates the block against the
 // consensus rules. If the block passes, then the state of the node is updated
-// including adding the block to disk.
-func (s *State) validateUpdateDatabase(block database.Block) error {
+// including adding the block to disk. A block mined by this node is refused
+// when mining was turned off while it was being mined, since it may be built
+// on the chain a reorganization just reset.
+func (s *State) validateUpdateDatabase(block database.Block, mined bool) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if mined && !s.allowMining {
+		return ErrMiningOff
+	}
+
 	s.evHandler("state: validateUpdateDatabase: validate block")
 
 	// CORE NOTE: I could add logic to determine if this block was mined by this
@@ -99,7 +158,7 @@ func (s *State) validateUpdateDatabase(block database.Block) error {
 	// block with my own and attempt to have other peers accept my block instead.
 
 	if err := block.ValidateBlock(s.db.LatestBlock(), s.db.HashState(), s.evHandler); err != nil {
-		return err
+		return fmt.Errorf("%w: %w", ErrInvalidBlock, err)
 	}
 
 	s.evHandler("state: validateUpdateDatabase: write to disk")
@@ -113,7 +172,8 @@ func (s *State) validateUpdateDatabase(block database.Block) error {
 	s.evHandler("state: validateUpdateDatabase: update accounts and remove from mempool")
 
 	// Process the transactions and update the accounts.
-	for _, tx := range block.MerkleTree.Values() {
+	failed := make(map[int]error)
+	for i, tx := range block.MerkleTree.Values() {
 		s.evHandler("state: validateUpdateDatabase: tx[%s] update and remove", tx)
 
 		// Remove this transaction from the mempool.
@@ -122,6 +182,7 @@ func (s *State) validateUpdateDatabase(block database.Block) error {
 		// Apply the balance changes based on this transaction.
 		if err := s.db.ApplyTransaction(block, tx); err != nil {
 			s.evHandler("state: validateUpdateDatabase: WARNING : %s", err)
+			failed[i] = err
 			continue
 		}
 	}
@@ -133,6 +194,7 @@ func (s *State) validateUpdateDatabase(block database.Block) error {
 
 	// Send an event about this new block.
 	s.blockEvent(block)
+	s.newBlockEvent(block, failed)
 
 	return nil
 }
@@ -0,0 +1,241 @@
+package state
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/peer"
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/transport"
+	"github.com/zacksfF/FullStack-Blockchain/client"
+)
+
+// CORE NOTE: Every call to a peer is made with a client.Client that sends
+// its requests through peerDoer. Each attempt has its own deadline so a peer
+// that hangs can't hold up the caller, and an attempt that times out or
+// fails on the peer's side is tried again after a backoff that doubles each
+// time. The calls between nodes are safe to repeat since a node ignores a
+// block or transaction it already has. Calls made to many peers at once go
+// through callPeers, so a slow peer only delays itself.
+
+// Defaults for the calls made to peers.
+const (
+	DefaultPeerTimeout = 10 * time.Second
+	DefaultPeerBackoff = 250 * time.Millisecond
+)
+
+// ErrShutdown is returned when a call to a peer is waiting to be tried
+// again and the node shuts down.
+var ErrShutdown = errors.New("node is shutting down")
+
+// PeerErrors represents the errors returned by the peers of a call made to
+// many peers at once, keyed by peer.
+type PeerErrors map[peer.Peer]error
+
+// Error implements the error interface.
+func (pe PeerErrors) Error() string {
+	msgs := make([]string, 0, len(pe))
+	for pr, err := range pe {
+		msgs = append(msgs, fmt.Sprintf("%s: %s", pr.Host, err))
+	}
+	sort.Strings(msgs)
+
+	return strings.Join(msgs, "\n")
+}
+
+// Unwrap returns the errors of every peer so errors.Is and errors.As can
+// check them.
+func (pe PeerErrors) Unwrap() []error {
+	errs := make([]error, 0, len(pe))
+	for _, err := range pe {
+		errs = append(errs, err)
+	}
+
+	return errs
+}
+
+// =============================================================================
+
+// callPeers calls the function for every peer at the same time and waits
+// for all of them to finish. The errors are returned as PeerErrors.
+func (s *State) callPeers(peers []peer.Peer, call func(pr peer.Peer) error) error {
+	var mu sync.Mutex
+	errs := make(PeerErrors)
+
+	var wg sync.WaitGroup
+	wg.Add(len(peers))
+
+	for _, pr := range peers {
+		go func(pr peer.Peer) {
+			defer wg.Done()
+
+			if err := call(pr); err != nil {
+				mu.Lock()
+				errs[pr] = err
+				mu.Unlock()
+			}
+		}(pr)
+	}
+
+	wg.Wait()
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return errs
+}
+
+// peerClient returns a client for calling the private routes of the peer.
+func (s *State) peerClient(pr peer.Peer) *client.Client {
+	return client.New(client.Config{
+		URL:  "http://" + pr.Host,
+		Doer: peerDoer{state: s},
+	})
+}
+
+// peerDoer sends the requests made to peers. The requests are signed with
+// the node key so the peer knows who is calling. Attempts that fail for a
+// reason that can pass are retried with backoff.
+type peerDoer struct {
+	state *State
+}
+
+// Do implements the client.Doer interface.
+func (d peerDoer) Do(req *http.Request) (*http.Response, error) {
+	s := d.state
+
+	var data []byte
+	if req.Body != nil {
+		var err error
+		data, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	backoff := s.peerBackoff
+	for attempt := 0; ; attempt++ {
+		resp, err := s.sendOnce(req, data)
+		if attempt >= s.peerRetries || !retryable(resp, err) {
+			return resp, err
+		}
+
+		reason := fmt.Sprint(err)
+		if err == nil {
+			reason = resp.Status
+		}
+		s.evHandler("state: send: %s %s: retry[%d] in %v: %s", req.Method, req.URL, attempt+1, backoff, reason)
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-s.shut:
+			timer.Stop()
+			return nil, ErrShutdown
+		}
+		backoff *= 2
+	}
+}
+
+// sendOnce makes a single attempt to send the request to the node within
+// the peer timeout. The body of the response is read before the deadline
+// is released.
+func (s *State) sendOnce(req *http.Request, data []byte) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(req.Context(), s.peerTimeout)
+	defer cancel()
+
+	r, err := http.NewRequestWithContext(ctx, req.Method, req.URL.String(), bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	r.Header = req.Header.Clone()
+
+	if err := peer.SignRequest(r, data, s.nodeKey); err != nil {
+		return nil, err
+	}
+
+	status, body, err := s.do(ctx, r, data)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := http.Response{
+		Status:     fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode: status,
+		Header:     make(http.Header),
+		Body:       io.NopCloser(bytes.NewReader(body)),
+		Request:    req,
+	}
+
+	return &resp, nil
+}
+
+// do sends the request over the persistent connection to the peer. The
+// request is sent over HTTP when the peer can't be reached that way.
+func (s *State) do(ctx context.Context, req *http.Request, data []byte) (int, []byte, error) {
+	if s.transport != nil {
+		tr := transport.Request{
+			Method: req.Method,
+			Path:   req.URL.RequestURI(),
+			Header: req.Header,
+			Body:   data,
+		}
+
+		resp, err := s.transport.Do(ctx, req.URL.Host, tr)
+		if err == nil {
+			return resp.Status, resp.Body, nil
+		}
+
+		if !errors.Is(err, transport.ErrUnavailable) {
+			return 0, nil, err
+		}
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	// The response is held to the same limit as a message over the
+	// persistent connection.
+	body, err := io.ReadAll(io.LimitReader(resp.Body, transport.MaxMessageSize+1))
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if len(body) > transport.MaxMessageSize {
+		return 0, nil, fmt.Errorf("response larger than %d bytes", transport.MaxMessageSize)
+	}
+
+	return resp.StatusCode, body, nil
+}
+
+// retryable reports if the call can succeed when it's tried again. Calls
+// that timed out or failed on the peer's side are retried. A peer that
+// refused the call or has no free slot is not.
+func retryable(resp *http.Response, err error) bool {
+	if err == nil {
+		return resp.StatusCode >= http.StatusInternalServerError && resp.StatusCode != http.StatusServiceUnavailable
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
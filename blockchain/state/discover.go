@@ -0,0 +1,122 @@
+package state
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/dht"
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/peer"
+	"github.com/zacksfF/FullStack-Blockchain/client"
+)
+
+// Discover looks for new peers by walking the network from the routing
+// table. The peers found are spread over the buckets of the table, so they
+// come from every part of the network. A peer is only added once a handshake
+// proves it's the node of the contact. It returns the number of peers added
+// to the known peer list.
+func (s *State) Discover() (int, error) {
+	s.evHandler("state: Discover: started")
+	defer s.evHandler("state: Discover: completed")
+
+	if err := dht.Refresh(s.routes, s.NetFindNode); err != nil {
+		return 0, err
+	}
+
+	var added int
+	for _, c := range s.routes.Contacts() {
+		pr := peer.New(c.Host)
+		if pr.Match(s.host) {
+			continue
+		}
+
+		if !s.knownPeers.Add(pr) {
+			continue
+		}
+
+		// The contact came from another node, so the node at the host has
+		// to prove it's the node of the contact before it's used.
+		if err := s.handshakeContact(pr, c); err != nil {
+			s.evHandler("state: Discover: handshake: %s: ERROR: %s", c.Host, err)
+			s.knownPeers.Remove(pr)
+			continue
+		}
+
+		s.evHandler("state: Discover: add peer: node[%s] host[%s]", c.ID, c.Host)
+		s.peerAddedEvent(pr, c.ID, false)
+		added++
+	}
+
+	return added, nil
+}
+
+// FindNode returns the contacts this node knows closest to the target for
+// the peer with the specified node id. The peer is added to the routing
+// table since it just proved it's alive.
+func (s *State) FindNode(nodeID string, target string) ([]dht.Contact, error) {
+	id, err := dht.ParseID(target)
+	if err != nil {
+		return nil, err
+	}
+
+	if pr, exists := s.LookupNode(nodeID); exists {
+		s.routes.Add(dht.Contact{ID: nodeID, Host: pr.Host})
+	}
+
+	var contacts []dht.Contact
+	for _, c := range s.routes.Closest(id, dht.K+1) {
+		if !strings.EqualFold(c.ID, nodeID) && len(contacts) < dht.K {
+			contacts = append(contacts, c)
+		}
+	}
+
+	return contacts, nil
+}
+
+// NetFindNode asks the node at the contact for the contacts it knows closest
+// to the target. A node only answers peers it exchanged handshakes with, so
+// the handshake happens first when this node hasn't identified the contact
+// yet or the contact no longer knows this node.
+func (s *State) NetFindNode(c dht.Contact, target dht.ID) ([]dht.Contact, error) {
+	pr := peer.New(c.Host)
+
+	if known, exists := s.knownPeers.Lookup(c.ID); !exists || known != pr {
+		if err := s.handshakeContact(pr, c); err != nil {
+			return nil, err
+		}
+	}
+
+	pc := s.peerClient(pr)
+
+	contacts, err := pc.FindNode(context.Background(), target)
+	if client.StatusCode(err) == http.StatusForbidden {
+		if err := s.handshakeContact(pr, c); err != nil {
+			return nil, err
+		}
+		contacts, err = pc.FindNode(context.Background(), target)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return contacts, nil
+}
+
+// =============================================================================
+
+// handshakeContact exchanges handshakes with the node at the contact and
+// checks it's the node the contact says it is.
+func (s *State) handshakeContact(pr peer.Peer, c dht.Contact) error {
+	hs, err := s.NetHandshake(pr)
+	if err != nil {
+		return err
+	}
+
+	if !strings.EqualFold(hs.NodeID, c.ID) {
+		return fmt.Errorf("%s: node id doesn't match contact, got %s, exp %s", pr.Host, hs.NodeID, c.ID)
+	}
+
+	return nil
+}
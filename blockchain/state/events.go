@@ -0,0 +1,115 @@
+package state
+
+import (
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/database"
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/peer"
+	"github.com/zacksfF/FullStack-Blockchain/events"
+)
+
+// TxEvent is the data of the newPendingTx and txMined events. The block
+// fields are only set for a mined transaction and Reason is only set for a
+// mined transaction that failed.
+type TxEvent struct {
+	Hash        string             `json:"hash"`
+	From        database.AccountID `json:"from"`
+	To          database.AccountID `json:"to"`
+	Nonce       uint64             `json:"nonce"`
+	Value       uint64             `json:"value"`
+	Tip         uint64             `json:"tip"`
+	BlockNumber uint64             `json:"block_number,omitempty"`
+	BlockHash   string             `json:"block_hash,omitempty"`
+	Index       int                `json:"index,omitempty"`
+	Failed      bool               `json:"failed,omitempty"`
+	Reason      string             `json:"reason,omitempty"`
+}
+
+// ReorgEvent is the data of the reorg event. It holds the latest block the
+// node had before it dropped its chain to resync from its peers.
+type ReorgEvent struct {
+	BlockNumber uint64 `json:"block_number"`
+	BlockHash   string `json:"block_hash"`
+}
+
+// PeerEvent is the data of the peerAdded event. The node id is empty when
+// it isn't known yet.
+type PeerEvent struct {
+	Host    string `json:"host"`
+	NodeID  string `json:"node_id,omitempty"`
+	Inbound bool   `json:"inbound"`
+}
+
+// MiningEvent is the data of the miningStarted event.
+type MiningEvent struct {
+	BlockNumber uint64 `json:"block_number"`
+	Txs         int    `json:"txs"`
+}
+
+// =============================================================================
+
+// publish provides the typed event to the publisher when one is configured.
+func (s *State) publish(topic string, accounts []database.AccountID, data any) {
+	if s.publisher == nil {
+		return
+	}
+
+	ids := make([]string, len(accounts))
+	for i, account := range accounts {
+		ids[i] = string(account)
+	}
+
+	s.publisher(events.Event{
+		Topic:    topic,
+		Accounts: ids,
+		Data:     data,
+	})
+}
+
+// newBlockEvent publishes the block that was added to the chain along with
+// an event for every transaction in the block.
+func (s *State) newBlockEvent(block database.Block, failed map[int]error) {
+	if s.publisher == nil {
+		return
+	}
+
+	blockHash := block.Hash()
+	accounts := []database.AccountID{block.Header.BeneficiaryID}
+
+	for i, tx := range block.MerkleTree.Values() {
+		accounts = append(accounts, tx.FromID, tx.ToID)
+
+		ev := newTxEvent(tx)
+		ev.BlockNumber = block.Header.Number
+		ev.BlockHash = blockHash
+		ev.Index = i
+		if err, exists := failed[i]; exists {
+			ev.Failed = true
+			ev.Reason = err.Error()
+		}
+
+		s.publish(events.TopicTxMined, []database.AccountID{tx.FromID, tx.ToID}, ev)
+	}
+
+	s.publish(events.TopicNewBlock, accounts, database.NewBlockData(block))
+}
+
+// newPendingTxEvent publishes the transaction that was added to the mempool.
+func (s *State) newPendingTxEvent(tx database.BlockTx) {
+	s.publish(events.TopicNewPendingTx, []database.AccountID{tx.FromID, tx.ToID}, newTxEvent(tx))
+}
+
+// peerAddedEvent publishes the peer that was added to the known peer list.
+func (s *State) peerAddedEvent(pr peer.Peer, nodeID string, inbound bool) {
+	s.publish(events.TopicPeerAdded, nil, PeerEvent{Host: pr.Host, NodeID: nodeID, Inbound: inbound})
+}
+
+// newTxEvent constructs the event data for the transaction.
+func newTxEvent(tx database.BlockTx) TxEvent {
+	return TxEvent{
+		Hash:  tx.TxHash(),
+		From:  tx.FromID,
+		To:    tx.ToID,
+		Nonce: tx.Nonce,
+		Value: tx.Value,
+		Tip:   tx.Tip,
+	}
+}
@@ -0,0 +1,19 @@
+package state
+
+import "github.com/zacksfF/FullStack-Blockchain/blockchain/gossip"
+
+// WantedInventory returns the announced items this node has not seen yet so
+// the peer that announced them can send them.
+func (s *State) WantedInventory(inv []gossip.Inventory) []gossip.Inventory {
+	wanted := []gossip.Inventory{}
+	for _, item := range inv {
+		switch item.Kind {
+		case gossip.KindTx, gossip.KindBlock:
+			if !s.seen.Has(item.Hash) {
+				wanted = append(wanted, item)
+			}
+		}
+	}
+
+	return wanted
+}
@@ -1,96 +1,275 @@
 package state
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"errors"
 	"fmt"
-	"io"
 	"net/http"
+	"time"
 
 	"github.com/zacksfF/FullStack-Blockchain/blockchain/database"
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/dht"
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/download"
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/gossip"
 	"github.com/zacksfF/FullStack-Blockchain/blockchain/peer"
+	"github.com/zacksfF/FullStack-Blockchain/client"
 )
 
-const baseURL = "http://%s/v1/node"
+// ErrNoTransport is returned when the node isn't configured to keep
+// persistent connections with its peers.
+var ErrNoTransport = errors.New("persistent peer connections not supported")
 
-// NetSendBlockToPeers takes the new mined block and sends it to all know peers.
+// NetSendBlockToPeers announces the block to a random selection of the known
+// peers and sends the block to the peers that don't have it yet. The peers
+// are called at the same time and the errors are returned as PeerErrors.
 func (s *State) NetSendBlockToPeers(block database.Block) error {
 	s.evHandler("state: NetSendBlockToPeers: started")
 	defer s.evHandler("state: NetSendBlockToPeers: completed")
 
-	for _, peer := range s.KnownExternalPeers() {
-		s.evHandler("state: NetSendBlockToPeers: send: block[%s] to peer[%s]", block.Hash(), peer)
+	inv := []gossip.Inventory{{Kind: gossip.KindBlock, Hash: block.Hash()}}
 
-		url := fmt.Sprintf("%s/block/propose", fmt.Sprintf(baseURL, peer.Host))
-
-		var status struct {
-			Status string `json:"status"`
+	return s.callPeers(gossip.Fanout(s.KnownExternalPeers(), s.fanout), func(pr peer.Peer) error {
+		wanted, err := s.NetAnnounce(pr, inv)
+		if err != nil {
+			return err
 		}
-		if err := send(http.MethodPost, url, database.NewBlockData(block), &status); err != nil {
-			return fmt.Errorf("%s: %s", peer.Host, err)
+
+		if len(wanted) == 0 {
+			return nil
 		}
+
+		s.evHandler("state: NetSendBlockToPeers: send: block[%s] to peer[%s]", block.Hash(), pr)
+
+		return s.NetSendCompactBlock(pr, block)
+	})
+}
+
+// NetSendCompactBlock sends the block to the peer in compact form. The peer
+// rebuilds the block from its mempool and responds with the transactions it
+// is missing, which are sent along with the compact block a second time.
+func (s *State) NetSendCompactBlock(pr peer.Peer, block database.Block) error {
+	cb, err := database.NewCompactBlock(block)
+	if err != nil {
+		return err
+	}
+
+	pc := s.peerClient(pr)
+
+	missing, err := pc.ProposeCompactBlock(context.Background(), cb)
+	if err != nil {
+		return err
+	}
+
+	if len(missing) == 0 {
+		return nil
+	}
+
+	s.evHandler("state: NetSendCompactBlock: peer[%s]: block[%s]: missing[%d/%d]", pr, block.Hash(), len(missing), len(cb.ShortIDs))
+
+	cb, err = cb.Prefill(block, missing)
+	if err != nil {
+		return err
+	}
+
+	missing, err = pc.ProposeCompactBlock(context.Background(), cb)
+	if err != nil {
+		return err
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("peer unable to rebuild block, missing[%d]", len(missing))
 	}
 
 	return nil
 }
 
-// NetSendTxToPeers shares a new block transaction with the known peers.
-func (s *State) NetSendTxToPeers(tx database.BlockTx) {
+// NetSendTxToPeers announces the block transactions to a random selection of
+// the known peers and sends the transactions the peers don't have yet.
+func (s *State) NetSendTxToPeers(txs []database.BlockTx) {
 	s.evHandler("state: NetSendTxToPeers: started")
 	defer s.evHandler("state: NetSendTxToPeers: completed")
 
-	// CORE NOTE: Bitcoin does not send the full transaction immediately to save
-	// on bandwidth. A node will send the transaction's mempool key first so the
-	// receiving node can check if they already have the transaction or not. If
-	// the receiving node doesn't have it, then it will request the transaction
-	// based on the mempool key it received.
+	// CORE NOTE: Like Bitcoin, only the hashes of the transactions are sent
+	// at first. The peer responds with the hashes it doesn't have yet and
+	// only those transactions are sent, which saves on bandwidth.
 
-	// For now, the Ardan blockchain just sends the full transaction.
-	for _, peer := range s.KnownExternalPeers() {
-		s.evHandler("state: NetSendTxToPeers: send: tx[%s] to peer[%s]", tx, peer)
+	inv := make([]gossip.Inventory, len(txs))
+	byHash := make(map[string]database.BlockTx, len(txs))
+	for i, tx := range txs {
+		hash := tx.TxHash()
+		inv[i] = gossip.Inventory{Kind: gossip.KindTx, Hash: hash}
+		byHash[hash] = tx
+	}
 
-		url := fmt.Sprintf("%s/tx/submit", fmt.Sprintf(baseURL, peer.Host))
+	err := s.callPeers(gossip.Fanout(s.KnownExternalPeers(), s.fanout), func(pr peer.Peer) error {
+		wanted, err := s.NetAnnounce(pr, inv)
+		if err != nil {
+			return err
+		}
 
-		if err := send(http.MethodPost, url, tx, nil); err != nil {
-			s.evHandler("state: NetSendTxToPeers: WARNING: %s", err)
+		pc := s.peerClient(pr)
+
+		var errs []error
+		for _, w := range wanted {
+			tx, exists := byHash[w.Hash]
+			if !exists || w.Kind != gossip.KindTx {
+				continue
+			}
+
+			s.evHandler("state: NetSendTxToPeers: send: tx[%s] to peer[%s]", tx, pr)
+
+			if err := pc.SubmitNodeTransaction(context.Background(), tx); err != nil {
+				errs = append(errs, fmt.Errorf("tx[%s]: %w", tx, err))
+			}
 		}
+
+		return errors.Join(errs...)
+	})
+
+	if err != nil {
+		s.evHandler("state: NetSendTxToPeers: WARNING: %s", err)
 	}
 }
 
+// NetAnnounce sends the inventory to the specified peer and returns the
+// items the peer wants.
+func (s *State) NetAnnounce(pr peer.Peer, inv []gossip.Inventory) ([]gossip.Inventory, error) {
+	return s.peerClient(pr).Inventory(context.Background(), inv)
+}
+
 // NetSendNodeAvailableToPeers shares this node is available to
-// participate in the network with the known peers.
+// participate in the network with the known peers. Peers that refuse the
+// handshake, are running a different blockchain or have no free slots are
+// removed.
 func (s *State) NetSendNodeAvailableToPeers() {
 	s.evHandler("state: NetSendNodeAvailableToPeers: started")
 	defer s.evHandler("state: NetSendNodeAvailableToPeers: completed")
 
-	host := peer.Peer{Host: s.Host()}
+	s.callPeers(s.KnownExternalPeers(), func(pr peer.Peer) error {
+		s.evHandler("state: NetSendNodeAvailableToPeers: send: host[%s] to peer[%s]", s.Host(), pr)
 
-	for _, peer := range s.KnownExternalPeers() {
-		s.evHandler("state: NetSendNodeAvailableToPeers: send: host[%s] to peer[%s]", host, peer)
+		_, err := s.NetHandshake(pr)
+		if err != nil {
+			s.evHandler("state: NetSendNodeAvailableToPeers: WARNING: %s", err)
 
-		url := fmt.Sprintf("%s/peers", fmt.Sprintf(baseURL, peer.Host))
+			if errors.Is(err, peer.ErrHandshake) || errors.Is(err, peer.ErrPeerLimit) {
+				s.RemoveKnownPeer(pr)
+			}
+		}
 
-		if err := send(http.MethodPost, url, host, nil); err != nil {
-			s.evHandler("state: NetSendNodeAvailableToPeers: WARNING: %s", err)
+		return err
+	})
+}
+
+// NetHandshake exchanges handshakes with the specified peer. The peer's
+// handshake is validated against this node before it is returned, so an
+// error wrapping peer.ErrHandshake means the peer must not be used. The
+// node id proven by the peer is bound to its host since this node dialed
+// the host itself.
+func (s *State) NetHandshake(pr peer.Peer) (peer.Handshake, error) {
+	s.evHandler("state: NetHandshake: started: %s", pr)
+	defer s.evHandler("state: NetHandshake: completed: %s", pr)
+
+	// A peer that refuses the handshake responds with a conflict and a peer
+	// that doesn't know about handshakes or signed requests runs an older
+	// protocol. A peer without a free slot is unavailable.
+	hs, err := s.peerClient(pr).Handshake(context.Background(), s.handshake)
+	if err != nil {
+		switch client.StatusCode(err) {
+		case http.StatusConflict, http.StatusNotFound, http.StatusUnauthorized:
+			return peer.Handshake{}, fmt.Errorf("%s: %w: %s", pr.Host, peer.ErrHandshake, err)
+		case http.StatusServiceUnavailable:
+			return peer.Handshake{}, fmt.Errorf("%s: %w: %s", pr.Host, peer.ErrPeerLimit, err)
 		}
+		return peer.Handshake{}, fmt.Errorf("%s: %w", pr.Host, err)
 	}
+
+	if err := hs.Validate(s.handshake); err != nil {
+		return peer.Handshake{}, fmt.Errorf("%s: %w", pr.Host, err)
+	}
+
+	if !s.knownPeers.Identify(pr, hs.NodeID) {
+		return peer.Handshake{}, fmt.Errorf("%s: %w: node is banned", pr.Host, peer.ErrHandshake)
+	}
+	s.routes.Add(dht.Contact{ID: hs.NodeID, Host: pr.Host})
+
+	return hs, nil
 }
 
-// NetRequestPeerStatus looks for new nodes on the blockchain by asking
-// known nodes for their peer list. New nodes are added to the list.
+// confirmAttempts is the number of handshakes made to the host of a peer
+// to confirm the node id it claimed before leaving it to the worker.
+const confirmAttempts = 6
+
+// confirmClaim makes a handshake to the host of a peer that connected to
+// this node, which binds the host to the node id that answers there. The
+// peer may still be syncing and not answering yet, so the handshake is
+// retried with a growing delay. A peer that refuses the handshake is
+// removed.
+func (s *State) confirmClaim(pr peer.Peer) {
+	s.confirmMu.Lock()
+	defer s.confirmMu.Unlock()
+
+	select {
+	case <-s.shut:
+		return
+	default:
+	}
+
+	if _, exists := s.confirming[pr]; exists {
+		return
+	}
+	s.confirming[pr] = struct{}{}
+
+	s.confirmWG.Add(1)
+	go func() {
+		defer func() {
+			s.confirmMu.Lock()
+			delete(s.confirming, pr)
+			s.confirmMu.Unlock()
+			s.confirmWG.Done()
+		}()
+
+		delay := s.peerBackoff
+		for attempt := 0; attempt < confirmAttempts; attempt++ {
+			select {
+			case <-time.After(delay):
+			case <-s.shut:
+				return
+			}
+			delay *= 2
+
+			if !s.knownPeers.Exists(pr) || s.IsIdentifiedPeer(pr) {
+				return
+			}
+
+			_, err := s.NetHandshake(pr)
+			switch {
+			case err == nil:
+				return
+
+			case errors.Is(err, peer.ErrHandshake), errors.Is(err, peer.ErrPeerLimit):
+				s.evHandler("state: confirmClaim: %s: ERROR: %s", pr.Host, err)
+				s.RemoveKnownPeer(pr)
+				return
+			}
+		}
+	}()
+}
+
+// NetRequestPeerStatus asks the peer for the latest block it has, which also
+// confirms the peer is still available.
 func (s *State) NetRequestPeerStatus(pr peer.Peer) (peer.PeerStatus, error) {
 	s.evHandler("state: NetRequestPeerStatus: started: %s", pr)
 	defer s.evHandler("state: NetRequestPeerStatus: completed: %s", pr)
 
-	url := fmt.Sprintf("%s/status", fmt.Sprintf(baseURL, pr.Host))
-
-	var ps peer.PeerStatus
-	if err := send(http.MethodGet, url, nil, &ps); err != nil {
+	ps, err := s.peerClient(pr).Status(context.Background())
+	if err != nil {
 		return peer.PeerStatus{}, err
 	}
 
-	s.evHandler("state: NetRequestPeerStatus: peer-node[%s]: latest-blknum[%d]: peer-list[%s]", pr, ps.LatestBlockNumber, ps.KnownPeers)
+	s.evHandler("state: NetRequestPeerStatus: peer-node[%s]: latest-blknum[%d]", pr, ps.LatestBlockNumber)
+
+	s.knownPeers.Seen(pr, ps.LatestBlockNumber)
 
 	return ps, nil
 }
@@ -100,10 +279,8 @@ func (s *State) NetRequestPeerMempool(pr peer.Peer) ([]database.BlockTx, error)
 	s.evHandler("state: NetRequestPeerMempool: started: %s", pr)
 	defer s.evHandler("state: NetRequestPeerMempool: completed: %s", pr)
 
-	url := fmt.Sprintf("%s/tx/list", fmt.Sprintf(baseURL, pr.Host))
-
-	var mempool []database.BlockTx
-	if err := send(http.MethodGet, url, nil, &mempool); err != nil {
+	mempool, err := s.peerClient(pr).NodeMempool(context.Background())
+	if err != nil {
 		return nil, err
 	}
 
@@ -112,96 +289,53 @@ func (s *State) NetRequestPeerMempool(pr peer.Peer) ([]database.BlockTx, error)
 	return mempool, nil
 }
 
-// NetRequestPeerBlocks queries the specified node asking for blocks this node does
-// not have, then writes them to disk.
-func (s *State) NetRequestPeerBlocks(pr peer.Peer) error {
-	s.evHandler("state: NetRequestPeerBlocks: started: %s", pr)
+// NetRequestPeerBlocks asks the peer for the blocks in the range. The blocks
+// are not added to the chain.
+func (s *State) NetRequestPeerBlocks(pr peer.Peer, from uint64, to uint64) ([]database.Block, error) {
+	s.evHandler("state: NetRequestPeerBlocks: started: %s: blocks[%d-%d]", pr, from, to)
 	defer s.evHandler("state: NetRequestPeerBlocks: completed: %s", pr)
 
-	// CORE NOTE: Ideally you want to start by pulling just block headers and
-	// performing the cryptographic audit so you know your're not being attacked.
-	// After that you can start pulling the full block data for each block header
-	// if you are a full node and maybe only the last 1000 full blocks if you
-	// are a pruned node. That can be done in the background. Remember, you
-	// only need block headers to validate new blocks.
-
-	// Currently the Ardan blockchain is a full node only system and needs the
-	// transactions to have a complete account database. The cryptographic audit
-	// does take place as each full block is downloaded from peers.
-
-	from := s.LatestBlock().Header.Number + 1
-	url := fmt.Sprintf("%s/block/list/%d/latest", fmt.Sprintf(baseURL, pr.Host), from)
-
-	var blocksData []database.BlockData
-	if err := send(http.MethodGet, url, nil, &blocksData); err != nil {
-		return err
-	}
-
-	s.evHandler("state: NetRequestPeerBlocks: found blocks[%d]", len(blocksData))
+	// CORE NOTE: The headers for these blocks have already been validated, so
+	// a peer can't make this node download a chain that isn't valid. Only the
+	// full blocks provide the transactions needed to update the accounts,
+	// which is why this is a full node only system.
 
-	for _, blockData := range blocksData {
-		block, err := database.ToBlock(blockData)
+	// The peer only sends the blocks that fit into one message, so the rest
+	// of the range is asked for until the peer has no more.
+	var blocks []database.Block
+	for next := from; next <= to; {
+		blocksData, err := s.peerClient(pr).BlocksByNumber(context.Background(), next, to)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
-		if err := s.ProcessProposedBlock(block); err != nil {
-			return err
-		}
-	}
+		s.evHandler("state: NetRequestPeerBlocks: found blocks[%d]", len(blocksData))
 
-	return nil
-}
-
-// =============================================================================
-
-// send is a helper function to send an HTTP request to a node.
-func send(method string, url string, dataSend any, dataRecv any) error {
-	var req *http.Request
-
-	switch {
-	case dataSend != nil:
-		data, err := json.Marshal(dataSend)
-		if err != nil {
-			return err
-		}
-		req, err = http.NewRequest(method, url, bytes.NewReader(data))
-		if err != nil {
-			return err
+		if len(blocksData) == 0 {
+			break
 		}
 
-	default:
-		var err error
-		req, err = http.NewRequest(method, url, nil)
-		if err != nil {
-			return err
+		for _, blockData := range blocksData {
+			block, err := database.ToBlock(blockData)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %w", download.ErrInvalidBatch, err)
+			}
+			blocks = append(blocks, block)
 		}
-	}
 
-	var client http.Client
-	resp, err := client.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode == http.StatusNoContent {
-		return nil
+		next += uint64(len(blocksData))
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		msg, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return err
-		}
-		return errors.New(string(msg))
-	}
+	return blocks, nil
+}
 
-	if dataRecv != nil {
-		if err := json.NewDecoder(resp.Body).Decode(dataRecv); err != nil {
-			return err
-		}
+// AcceptStream upgrades the request from the specified peer into a
+// persistent connection that is used for the requests in both directions.
+// It blocks until the connection is closed.
+func (s *State) AcceptStream(w http.ResponseWriter, r *http.Request, pr peer.Peer) error {
+	if s.transport == nil {
+		return ErrNoTransport
 	}
 
-	return nil
+	return s.transport.Accept(w, r, pr.Host)
 }
@@ -1,6 +1,10 @@
 package state
 
-import "github.com/zacksfF/FullStack-Blockchain/blockchain/database"
+import (
+	"errors"
+
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/database"
+)
 
 // QueryLastest represents to query the latest block in the chain.
 const QueryLastest = ^uint64(0) >> 1
@@ -56,3 +60,59 @@ func (s *State) QueryBlocksByAccount(accountID database.AccountID) ([]database.B
 
 	return out, nil
 }
+
+// QueryBlockByNumber returns the block with the specified number. A number
+// past the latest block returns database.ErrBlockNotFound.
+func (s *State) QueryBlockByNumber(number uint64) (database.Block, error) {
+	if number == 0 || number > s.db.LatestBlock().Header.Number {
+		return database.Block{}, database.ErrBlockNotFound
+	}
+
+	return s.db.GetBlock(number)
+}
+
+// QueryBlockByHash returns the block with the specified hash using the hash
+// index of the storage.
+func (s *State) QueryBlockByHash(hash string) (database.Block, error) {
+	return s.db.GetBlockByHash(hash)
+}
+
+// MinedTx represents a transaction that was mined into a block along with
+// its position in the block and the outcome of applying it.
+type MinedTx struct {
+	Tx      database.BlockTx
+	Index   int
+	Block   database.Block
+	Receipt database.Receipt
+}
+
+// QueryMinedTx returns the mined transaction with the specified hash. The
+// receipt locates the block, so only transactions in the chain are found.
+func (s *State) QueryMinedTx(hash string) (MinedTx, error) {
+	r, err := s.db.Receipt(hash)
+	if err != nil {
+		if errors.Is(err, database.ErrReceiptNotFound) {
+			return MinedTx{}, ErrTxNotFound
+		}
+		return MinedTx{}, err
+	}
+
+	block, err := s.db.GetBlock(r.BlockNumber)
+	if err != nil {
+		return MinedTx{}, err
+	}
+
+	for i, tx := range block.MerkleTree.Values() {
+		if tx.TxHash() == hash {
+			mtx := MinedTx{
+				Tx:      tx,
+				Index:   i,
+				Block:   block,
+				Receipt: r,
+			}
+			return mtx, nil
+		}
+	}
+
+	return MinedTx{}, ErrTxNotFound
+}
@@ -1,5 +1,7 @@
 package state
 
+import "github.com/zacksfF/FullStack-Blockchain/events"
+
 // Reorganize corrects an identified fork. No mining is allowed to take place
 // while this process is running. New transactions can be placed into the mempool.
 func (s *State) Reorganize() error {
@@ -10,8 +12,14 @@ func (s *State) Reorganize() error {
 	s.allowMining = false
 
 	// Reset the state of the blockchain node.
+	latest := s.db.LatestBlock()
 	s.db.Reset()
 
+	s.publish(events.TopicReorg, nil, ReorgEvent{
+		BlockNumber: latest.Header.Number,
+		BlockHash:   latest.Hash(),
+	})
+
 	// Resync the state of the blockchain.
 	s.resyncWG.Add(1)
 	go func() {
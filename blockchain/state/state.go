@@ -1,12 +1,21 @@
 package state
 
 import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"net/http"
 	"sync"
+	"time"
 
 	"github.com/zacksfF/FullStack-Blockchain/blockchain/database"
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/dht"
 	"github.com/zacksfF/FullStack-Blockchain/blockchain/genesis"
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/gossip"
 	"github.com/zacksfF/FullStack-Blockchain/blockchain/mempool"
 	"github.com/zacksfF/FullStack-Blockchain/blockchain/peer"
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/transport"
+	"github.com/zacksfF/FullStack-Blockchain/events"
 )
 
 // The set of different consensus protocols that can be used.
@@ -19,6 +28,10 @@ const (
 // accur in the processing of presisting blocks.
 type EventHandler func(v string, args ...any)
 
+// Publisher defines a function that is called with the typed events
+// applications can subscribe to.
+type Publisher func(ev events.Event)
+
 // Worker interface represents the behavior required to be implement by any
 // package providing support for mining, peer updates, and transaction
 type Worker interface {
@@ -26,18 +39,35 @@ type Worker interface {
 	Sync()
 	SignalStartMining()
 	SignalShareTx(blockTx database.BlockTx)
+	SignalShareBlock(block database.Block)
 }
 
 // Config represents the configuration required to starts the blocckhain node.
 type Config struct {
-	BeneficiaryID  database.AccountID
-	Host           string
-	Storage        database.Storage
-	Genesis        genesis.Genesis
-	SelectStrategy string
-	KnownPeers     peer.PeerSet
-	EvHandler      EventHandler
-	Consensus      string
+	BeneficiaryID        database.AccountID
+	NodeKey              *ecdsa.PrivateKey
+	Host                 string
+	Storage              database.Storage
+	Genesis              genesis.Genesis
+	SelectStrategy       string
+	MempoolMaxSize       int
+	MempoolMaxPerAccount int
+	MempoolMinTip        uint64
+	MempoolTTL           time.Duration
+	MempoolJournal       string
+	MempoolReplaceBump   uint64 // Zero means mempool.DefaultReplaceBump
+	MempoolNoReplace     bool
+	KnownPeers           *peer.PeerSet
+	PeerStore            string
+	GossipFanout         int
+	Transport            *transport.Transport
+	Client               *http.Client
+	PeerTimeout          time.Duration
+	PeerRetries          int
+	PeerBackoff          time.Duration
+	EvHandler            EventHandler
+	Publisher            Publisher
+	Consensus            string
 }
 
 // State manages the Blockchain database
@@ -46,10 +76,30 @@ type State struct {
 	resyncWG    sync.WaitGroup
 	allowMining bool
 
+	shut       chan struct{}
+	confirmMu  sync.Mutex
+	confirming map[peer.Peer]struct{}
+	confirmWG  sync.WaitGroup
+
 	beneficiaryID database.AccountID
 	host          string
 	evHandler     EventHandler
+	publisher     Publisher
 	consensus     string
+	peerStore     string
+	nodeKey       *ecdsa.PrivateKey
+	handshake     peer.Handshake
+	fanout        int
+	seen          *gossip.Seen
+	transport     *transport.Transport
+	httpClient    *http.Client
+	peerTimeout   time.Duration
+	peerRetries   int
+	peerBackoff   time.Duration
+	routes        *dht.Table
+
+	syncMu       sync.Mutex
+	syncProgress SyncProgress
 
 	knownPeers *peer.PeerSet
 	storage    database.Storage
@@ -69,17 +119,102 @@ func New(cfg Config) (*State, error) {
 		}
 	}
 
+	// The node identity key is used to sign the requests sent to peers.
+	if cfg.NodeKey == nil {
+		return nil, errors.New("node key is required")
+	}
+
+	// The handshake never changes so it is signed once.
+	handshake, err := peer.Handshake{
+		ChainID:         cfg.Genesis.ChainID,
+		GenesisHash:     cfg.Genesis.Hash(),
+		ProtocolVersion: peer.ProtocolVersion,
+		NodeID:          peer.NodeID(cfg.NodeKey.PublicKey),
+		Host:            cfg.Host,
+	}.Sign(cfg.NodeKey)
+	if err != nil {
+		return nil, fmt.Errorf("signing handshake: %w", err)
+	}
+
 	//Access the storage for the blockchain
 	db, err := database.New(cfg.Genesis, cfg.Storage, ev)
 	if err != nil {
 		return nil, err
 	}
 
-	//Construct a mempool with the specified sort strategy
-	mempool, err := mempool.NewWithStrategy(cfg.SelectStrategy)
+	//Construct a mempool with the specified sort strategy and limits.
+	mempool, err := mempool.NewWithConfig(mempool.Config{
+		SelectStrategy: cfg.SelectStrategy,
+		MaxSize:        cfg.MempoolMaxSize,
+		MaxPerAccount:  cfg.MempoolMaxPerAccount,
+		MinTip:         cfg.MempoolMinTip,
+		TTL:            cfg.MempoolTTL,
+		JournalPath:    cfg.MempoolJournal,
+		ReplaceBump:    cfg.MempoolReplaceBump,
+		NoReplace:      cfg.MempoolNoReplace,
+		EvHandler:      ev,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Reload any transactions that were pending when the node went down.
+	// Transactions that have been mined since or are no longer valid are
+	// dropped from the pool.
+	restored, err := mempool.Replay(func(tx database.BlockTx) error {
+		if err := tx.Validate(cfg.Genesis.ChainID); err != nil {
+			return err
+		}
+
+		account, err := db.Query(tx.FromID)
+		if err == nil && tx.Nonce <= account.Nonce {
+			return fmt.Errorf("transaction already mined, nonce %d, account nonce %d", tx.Nonce, account.Nonce)
+		}
+
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
+	ev("state: New: mempool: restored transactions[%d]", restored)
+
+	// The routing table used to discover peers is keyed by the node id.
+	routes, err := dht.NewTable(handshake.NodeID)
+	if err != nil {
+		return nil, fmt.Errorf("constructing routing table: %w", err)
+	}
+
+	// Reload the peers this node knew about before it went down so it isn't
+	// isolated when the origin peers are unavailable.
+	if cfg.PeerStore != "" {
+		records, err := peer.Load(cfg.PeerStore)
+		if err != nil {
+			return nil, fmt.Errorf("loading peer store: %w", err)
+		}
+		cfg.KnownPeers.Restore(records)
+		for _, rec := range records {
+			if rec.ID != "" && rec.Host != "" {
+				routes.Add(dht.Contact{ID: rec.ID, Host: rec.Host})
+			}
+		}
+		ev("state: New: peers: restored peers[%d]", len(records))
+	}
+
+	// Every attempt to call a peer has a deadline and a failed attempt is
+	// retried after a backoff.
+	if cfg.PeerTimeout <= 0 {
+		cfg.PeerTimeout = DefaultPeerTimeout
+	}
+	if cfg.PeerBackoff <= 0 {
+		cfg.PeerBackoff = DefaultPeerBackoff
+	}
+
+	// Requests to peers that aren't sent over a persistent connection use
+	// this client, which can be replaced to change how peers are reached.
+	httpClient := cfg.Client
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: cfg.PeerTimeout}
+	}
 
 	// Create the State to provide support for managing the blockchain.
 	state := State{
@@ -87,10 +222,24 @@ func New(cfg Config) (*State, error) {
 		host:          cfg.Host,
 		storage:       cfg.Storage,
 		evHandler:     cfg.EvHandler,
+		publisher:     cfg.Publisher,
 		consensus:     cfg.Consensus,
+		peerStore:     cfg.PeerStore,
+		nodeKey:       cfg.NodeKey,
+		handshake:     handshake,
+		fanout:        cfg.GossipFanout,
+		seen:          gossip.NewSeen(gossip.DefaultSeenSize),
+		transport:     cfg.Transport,
+		httpClient:    httpClient,
+		peerTimeout:   cfg.PeerTimeout,
+		peerRetries:   cfg.PeerRetries,
+		peerBackoff:   cfg.PeerBackoff,
+		routes:        routes,
 		allowMining:   true,
+		shut:          make(chan struct{}),
+		confirming:    make(map[peer.Peer]struct{}),
 
-		knownPeers: &cfg.KnownPeers,
+		knownPeers: cfg.KnownPeers,
 		genesis:    cfg.Genesis,
 		mempool:    mempool,
 		db:         db,
@@ -106,14 +255,26 @@ func (s *State) Shutdown() error {
 	s.evHandler("state: shutdown: started")
 	defer s.evHandler("state: shutdown: completed")
 
-	// Make sure the database file is properly closed.
+	// Make sure the database and mempool files are properly closed and
+	// the peers are saved for the next start.
 	defer func() {
+		if err := s.SavePeers(); err != nil {
+			s.evHandler("state: shutdown: save peers: ERROR: %s", err)
+		}
+		s.mempool.Close()
 		s.db.Close()
 	}()
 
 	// Stop all blockchain writing activity.
 	s.Worker.Shutdown()
 
+	// Stop confirming the node ids claimed by peers. The channel is closed
+	// under the lock so no confirmation starts once the wait begins.
+	s.confirmMu.Lock()
+	close(s.shut)
+	s.confirmMu.Unlock()
+	s.confirmWG.Wait()
+
 	// Wait for any resync to finish.
 	s.resyncWG.Wait()
 
@@ -161,6 +322,10 @@ func (s *State) Mempool() []database.BlockTx {
 
 // UpsertMempool adds a new transaction to the mempool.
 func (s *State) UpsertMempool(tx database.BlockTx) error {
+	if err := s.checkExpiry(tx); err != nil {
+		return err
+	}
+
 	return s.mempool.Upsert(tx)
 }
 
@@ -171,8 +336,136 @@ func (s *State) Accounts() map[database.AccountID]database.Account {
 
 // AddKnownPeer provides the ability to add a new peer to
 // the known peer list.
-func (s *State) AddKnownPeer(peer peer.Peer) bool {
-	return s.knownPeers.Add(peer)
+func (s *State) AddKnownPeer(pr peer.Peer) bool {
+	if !s.knownPeers.Add(pr) {
+		return false
+	}
+	s.peerAddedEvent(pr, "", false)
+
+	return true
+}
+
+// NodeID returns the identity of this node.
+func (s *State) NodeID() string {
+	return s.handshake.NodeID
+}
+
+// Handshake returns the signed information this node shares with a peer so
+// they can decide if they are running the same blockchain.
+func (s *State) Handshake() peer.Handshake {
+	return s.handshake
+}
+
+// AcceptHandshake validates the handshake received from a peer and adds
+// the peer to the known peer list if it matches this node and there is a
+// free inbound slot. The node id in the handshake is only a claim until
+// this node makes a handshake to the host of the peer itself, so it's not
+// bound to the host and a claim never overwrites a host or node id that
+// is already bound. The handshake back to the host is made in the
+// background, since the peer may still be syncing and not answering yet.
+func (s *State) AcceptHandshake(hs peer.Handshake) error {
+	if err := hs.Validate(s.handshake); err != nil {
+		return err
+	}
+
+	if s.knownPeers.BannedNode(hs.NodeID) {
+		return fmt.Errorf("%w: node is banned", peer.ErrHandshake)
+	}
+
+	pr := peer.New(hs.Host)
+
+	nodeID, bound := s.knownPeers.Bound(pr)
+	switch {
+	case bound && nodeID != hs.NodeID:
+		return fmt.Errorf("%w: host is bound to another node", peer.ErrHandshake)
+
+	case bound:
+		return nil
+	}
+
+	if prev, exists := s.knownPeers.Lookup(hs.NodeID); exists {
+		return fmt.Errorf("%w: node is bound to host[%s]", peer.ErrHandshake, prev.Host)
+	}
+	if prev, exists := s.knownPeers.Claimed(hs.NodeID); exists && prev != pr {
+		return fmt.Errorf("%w: node is claimed by host[%s]", peer.ErrHandshake, prev.Host)
+	}
+
+	exists := s.knownPeers.Exists(pr)
+	if !s.knownPeers.AddInbound(pr, hs.NodeID) {
+		return fmt.Errorf("%w: inbound", peer.ErrPeerLimit)
+	}
+
+	if !exists {
+		s.evHandler("state: AcceptHandshake: add peer: node[%s] host[%s]", hs.NodeID, hs.Host)
+		s.peerAddedEvent(pr, hs.NodeID, true)
+	}
+
+	s.confirmClaim(pr)
+
+	return nil
+}
+
+// IsKnownPeer reports if the peer is in the known peer list.
+func (s *State) IsKnownPeer(peer peer.Peer) bool {
+	return s.knownPeers.Exists(peer)
+}
+
+// IsIdentifiedPeer reports if the node id of the peer was proved by a
+// handshake this node made to the host of the peer.
+func (s *State) IsIdentifiedPeer(pr peer.Peer) bool {
+	_, bound := s.knownPeers.Bound(pr)
+	return bound
+}
+
+// LookupNode returns the known peer bound to the specified node id.
+func (s *State) LookupNode(nodeID string) (peer.Peer, bool) {
+	return s.knownPeers.Lookup(nodeID)
+}
+
+// IsKnownNode reports if the node id belongs to a known peer. A node that
+// claimed the node id in its handshake is known for the requests it signs
+// before its host is proved.
+func (s *State) IsKnownNode(nodeID string) bool {
+	if _, exists := s.LookupNode(nodeID); exists {
+		return true
+	}
+
+	_, claimed := s.knownPeers.Claimed(nodeID)
+	return claimed
+}
+
+// ScorePeer adjusts the score of the peer based on its behavior. The peer
+// is banned if the score drops too low.
+func (s *State) ScorePeer(pr peer.Peer, delta int) {
+	if pr.Host == "" || pr.Match(s.host) {
+		return
+	}
+
+	score, banned := s.knownPeers.Adjust(pr, delta)
+	if banned {
+		s.evHandler("state: ScorePeer: banned: peer[%s]: score[%d]", pr, score)
+	}
+}
+
+// PeerScores returns the score for every peer this node has scored.
+func (s *State) PeerScores() []peer.Score {
+	return s.knownPeers.Scores()
+}
+
+// SavePeers writes what is known about the peers to the peer store so they
+// can be restored when the node starts.
+func (s *State) SavePeers() error {
+	if s.peerStore == "" {
+		return nil
+	}
+
+	return peer.Save(s.peerStore, s.knownPeers.Records())
+}
+
+// RotatePeers removes an underperforming peer when the outbound slots are
+// full, so a better peer can take its place.
+func (s *State) RotatePeers() (peer.Peer, bool) {
+	return s.knownPeers.Rotate()
 }
 
 // RemoveKnownPeer provides the ability to remove a peer from
@@ -2,16 +2,26 @@ package state_test
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/zacksfF/FullStack-Blockchain/blockchain/database"
 	"github.com/zacksfF/FullStack-Blockchain/blockchain/genesis"
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/gossip"
 	"github.com/zacksfF/FullStack-Blockchain/blockchain/peer"
 	"github.com/zacksfF/FullStack-Blockchain/blockchain/state"
 	"github.com/zacksfF/FullStack-Blockchain/blockchain/storage/memory"
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/transport"
 )
 
 const (
@@ -71,6 +81,490 @@ func Test_MineAndSyncBlock(t *testing.T) {
 	}
 }
 
+// Test_ExpiredTransaction validates a transaction that can't be mined into
+// the next block is refused by the mempool.
+func Test_ExpiredTransaction(t *testing.T) {
+	node1 := newNode(miner1PrivateKey, t)
+
+	tx := database.Tx{
+		ChainID:    chainID,
+		Nonce:      1,
+		FromID:     kennedyAccountID,
+		ToID:       edAccountID,
+		Value:      1,
+		ExpiryTime: uint64(time.Now().Add(-time.Minute).UnixMilli()),
+	}
+
+	signedTx := newSignedTx(tx, kennedyPrivateKey, t)
+	if err := node1.UpsertWalletTransaction(signedTx); !errors.Is(err, database.ErrTxExpired) {
+		t.Fatalf("Error upserting expired transaction: should have received ErrTxExpired, got %v", err)
+	}
+
+	if node1.MempoolLength() != 0 {
+		t.Fatalf("Error upserting expired transaction: mempool should be empty")
+	}
+}
+
+// Test_TxStatus follows transactions through their lifecycle from the
+// mempool into a block.
+func Test_TxStatus(t *testing.T) {
+	node1 := newNode(miner1PrivateKey, t)
+
+	tran := func(nonce uint64, to database.AccountID, value uint64, tip uint64) database.SignedTx {
+		tx := database.Tx{
+			ChainID: chainID,
+			Nonce:   nonce,
+			FromID:  kennedyAccountID,
+			ToID:    to,
+			Value:   value,
+			Tip:     tip,
+		}
+		return newSignedTx(tx, kennedyPrivateKey, t)
+	}
+
+	check := func(hash string, exp string) state.TxStatus {
+		status, err := node1.QueryTxStatus(hash)
+		if err != nil {
+			t.Fatalf("Error querying transaction status: %v", err)
+		}
+		if status.Status != exp {
+			t.Fatalf("Error querying transaction status: got %q, exp %q", status.Status, exp)
+		}
+		return status
+	}
+
+	if _, err := node1.QueryTxStatus("0x00"); !errors.Is(err, state.ErrTxNotFound) {
+		t.Fatalf("Error querying unknown transaction: should have received ErrTxNotFound, got %v", err)
+	}
+
+	sent := tran(1, edAccountID, 1, 0)
+	good := tran(1, edAccountID, 1, 10)
+	bad := tran(5, edAccountID, 1, 0)
+
+	for _, tx := range []database.SignedTx{sent, good, bad} {
+		if err := node1.UpsertWalletTransaction(tx); err != nil {
+			t.Fatalf("Error upserting wallet transaction: %v", err)
+		}
+	}
+
+	if status := check(good.TxHash(), state.TxPending); status.Position != 0 {
+		t.Fatalf("Error querying pending transaction: got position %d, exp 0", status.Position)
+	}
+	if status := check(sent.TxHash(), state.TxReplaced); status.ReplacedBy != good.TxHash() {
+		t.Fatalf("Error querying replaced transaction: got replaced by %s, exp %s", status.ReplacedBy, good.TxHash())
+	}
+
+	blk, err := node1.MineNewBlock(context.Background())
+	if err != nil {
+		t.Fatalf("Error mining new block: %v", err)
+	}
+
+	status := check(good.TxHash(), state.TxMined)
+	if status.BlockNumber != blk.Header.Number || status.Confirmations != 1 {
+		t.Fatalf("Error querying mined transaction: got block %d with %d confirmations", status.BlockNumber, status.Confirmations)
+	}
+
+	if status := check(bad.TxHash(), state.TxFailed); status.Reason == "" {
+		t.Fatalf("Error querying failed transaction: should have a reason")
+	}
+
+	if err := node1.UpsertWalletTransaction(tran(2, edAccountID, 1, 0)); err != nil {
+		t.Fatalf("Error upserting wallet transaction: %v", err)
+	}
+	if _, err := node1.MineNewBlock(context.Background()); err != nil {
+		t.Fatalf("Error mining new block: %v", err)
+	}
+
+	if status := check(good.TxHash(), state.TxMined); status.Confirmations != 2 {
+		t.Fatalf("Error querying mined transaction: got %d confirmations, exp 2", status.Confirmations)
+	}
+}
+
+// Test_WantedInventory validates a node only asks for the transactions and
+// blocks it has not seen.
+func Test_WantedInventory(t *testing.T) {
+	node1 := newNode(miner1PrivateKey, t)
+
+	tx := database.Tx{
+		ChainID: chainID,
+		Nonce:   1,
+		FromID:  kennedyAccountID,
+		ToID:    edAccountID,
+		Value:   1,
+	}
+	signedTx := newSignedTx(tx, kennedyPrivateKey, t)
+
+	inv := []gossip.Inventory{
+		{Kind: gossip.KindTx, Hash: signedTx.TxHash()},
+		{Kind: gossip.KindBlock, Hash: "0x01"},
+		{Kind: "unknown", Hash: "0x02"},
+	}
+
+	if wanted := node1.WantedInventory(inv); len(wanted) != 2 {
+		t.Fatalf("Error getting wanted inventory: should want the unseen transaction and block, got %v", wanted)
+	}
+
+	if err := node1.UpsertWalletTransaction(signedTx); err != nil {
+		t.Fatalf("Error upserting wallet transaction: %v", err)
+	}
+	block, err := node1.MineNewBlock(context.Background())
+	if err != nil {
+		t.Fatalf("Error mining new block: %v", err)
+	}
+	inv[1].Hash = block.Hash()
+
+	if wanted := node1.WantedInventory(inv); len(wanted) != 0 {
+		t.Fatalf("Error getting wanted inventory: should not want what was seen, got %v", wanted)
+	}
+}
+
+// Test_SeenAfterAccepted validates a transaction or block from a peer is
+// only marked as seen once it's accepted, so it can be received again.
+func Test_SeenAfterAccepted(t *testing.T) {
+	node1 := newNode(miner1PrivateKey, t)
+	node2 := newNode(miner2PrivateKey, t)
+
+	var blocks []database.Block
+	for nonce := uint64(1); nonce <= 2; nonce++ {
+		tx := database.Tx{
+			ChainID: chainID,
+			Nonce:   nonce,
+			FromID:  kennedyAccountID,
+			ToID:    edAccountID,
+			Value:   1,
+		}
+
+		if err := node1.UpsertWalletTransaction(newSignedTx(tx, kennedyPrivateKey, t)); err != nil {
+			t.Fatalf("Error upserting wallet transaction: %v", err)
+		}
+
+		if nonce == 1 {
+			bad := node1.Mempool()[0]
+			bad.Value = 100
+			if err := node2.UpsertNodeTransaction(bad); !errors.Is(err, state.ErrInvalidTx) {
+				t.Fatalf("Error upserting node transaction: should refuse a bad signature, got %v", err)
+			}
+			inv := []gossip.Inventory{{Kind: gossip.KindTx, Hash: bad.TxHash()}}
+			if wanted := node2.WantedInventory(inv); len(wanted) != 1 {
+				t.Fatal("Error getting wanted inventory: should still want a refused transaction")
+			}
+		}
+
+		blk, err := node1.MineNewBlock(context.Background())
+		if err != nil {
+			t.Fatalf("Error mining new block: %v", err)
+		}
+		blocks = append(blocks, blk)
+	}
+
+	// The second block arrives first and can't be added yet.
+	if err := node2.ProcessProposedBlock(blocks[1]); err == nil {
+		t.Fatal("Error proposing block: should refuse a block out of order")
+	}
+	inv := []gossip.Inventory{{Kind: gossip.KindBlock, Hash: blocks[1].Hash()}}
+	if wanted := node2.WantedInventory(inv); len(wanted) != 1 {
+		t.Fatal("Error getting wanted inventory: should still want a refused block")
+	}
+
+	for _, blk := range blocks {
+		if err := node2.ProcessProposedBlock(blk); err != nil {
+			t.Fatalf("Error proposing block: %v", err)
+		}
+	}
+	if wanted := node2.WantedInventory(inv); len(wanted) != 0 {
+		t.Fatal("Error getting wanted inventory: should not want an accepted block")
+	}
+}
+
+// Test_MiningOffDuringReorg validates a block mined while a reorganization
+// is resyncing the chain is refused instead of being added to the reset chain.
+func Test_MiningOffDuringReorg(t *testing.T) {
+	node := newNode(miner1PrivateKey, t)
+
+	resync := make(chan struct{})
+	node.Worker = syncWorker{done: resync}
+
+	tx := database.Tx{
+		ChainID: chainID,
+		Nonce:   1,
+		FromID:  kennedyAccountID,
+		ToID:    edAccountID,
+		Value:   1,
+	}
+
+	if err := node.UpsertWalletTransaction(newSignedTx(tx, kennedyPrivateKey, t)); err != nil {
+		t.Fatalf("Error upserting wallet transaction: %v", err)
+	}
+
+	if err := node.Reorganize(); err != nil {
+		t.Fatalf("Error reorganizing: %v", err)
+	}
+
+	if _, err := node.MineNewBlock(context.Background()); !errors.Is(err, state.ErrMiningOff) {
+		t.Fatalf("Error mining new block: should refuse a block mined during a reorganization, got %v", err)
+	}
+	if latest := node.LatestBlock(); latest.Header.Number != 0 {
+		t.Fatalf("Error getting latest block: should still be at genesis, got %d", latest.Header.Number)
+	}
+
+	close(resync)
+	for !node.IsMiningAllowed() {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if _, err := node.MineNewBlock(context.Background()); err != nil {
+		t.Fatalf("Error mining new block after the resync: %v", err)
+	}
+}
+
+// Test_RequestPeerHeaders validates the headers from a peer are checked as
+// they arrive and a peer sending invalid headers is not asked for more.
+func Test_RequestPeerHeaders(t *testing.T) {
+	node1 := newNode(miner1PrivateKey, t)
+	node2 := newNode(miner2PrivateKey, t)
+
+	for nonce := uint64(1); nonce <= 3; nonce++ {
+		tx := database.Tx{
+			ChainID: chainID,
+			Nonce:   nonce,
+			FromID:  kennedyAccountID,
+			ToID:    edAccountID,
+			Value:   1,
+		}
+
+		if err := node1.UpsertWalletTransaction(newSignedTx(tx, kennedyPrivateKey, t)); err != nil {
+			t.Fatalf("Error upserting wallet transaction: %v", err)
+		}
+		if _, err := node1.MineNewBlock(context.Background()); err != nil {
+			t.Fatalf("Error mining new block: %v", err)
+		}
+	}
+	headers := node1.QueryHeadersByNumber(1, 3)
+
+	// serve returns a peer that answers with the headers picked for the
+	// range and counts the requests.
+	serve := func(calls *atomic.Int32, pick func(from uint64, to uint64) []database.BlockHeader) peer.Peer {
+		return newPeer(t, func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+
+			var from, to uint64
+			fmt.Sscanf(r.URL.Path, "/v1/node/block/headers/%d/%d", &from, &to)
+			json.NewEncoder(w).Encode(pick(from, to))
+		})
+	}
+
+	var goodCalls, badCalls, floodCalls atomic.Int32
+
+	// One header at a time, so it takes a request per block.
+	good := serve(&goodCalls, func(from uint64, to uint64) []database.BlockHeader {
+		if from > 3 {
+			return nil
+		}
+		return headers[from-1 : from]
+	})
+	bad := serve(&badCalls, func(from uint64, to uint64) []database.BlockHeader {
+		h := headers[from-1]
+		if from == 2 {
+			h.TimeStamp++
+		}
+		return []database.BlockHeader{h}
+	})
+	flood := serve(&floodCalls, func(from uint64, to uint64) []database.BlockHeader {
+		return headers
+	})
+
+	got, err := node2.NetRequestPeerHeaders(good, node2.LatestBlock(), 10)
+	if err != nil || len(got) != 3 || goodCalls.Load() != 4 {
+		t.Fatalf("Error requesting peer headers: should get the 3 headers in 4 calls, got %d in %d: %v", len(got), goodCalls.Load(), err)
+	}
+
+	if _, err := node2.NetRequestPeerHeaders(bad, node2.LatestBlock(), 10); !errors.Is(err, database.ErrInvalidHeader) {
+		t.Fatalf("Error requesting peer headers: should get ErrInvalidHeader, got %v", err)
+	}
+	if badCalls.Load() != 2 {
+		t.Fatalf("Error requesting peer headers: should stop at the invalid chunk, got %d calls", badCalls.Load())
+	}
+
+	if _, err := node2.NetRequestPeerHeaders(flood, node2.LatestBlock(), 1); !errors.Is(err, database.ErrInvalidHeader) {
+		t.Fatalf("Error requesting peer headers: should refuse more headers than asked for, got %v", err)
+	}
+}
+
+// Test_Handshake validates a peer running a different blockchain or claiming
+// another node's identity is not added to the known peer list.
+func Test_Handshake(t *testing.T) {
+	node1 := newNode(miner1PrivateKey, t)
+
+	privateKey, err := crypto.HexToECDSA(miner2PrivateKey)
+	if err != nil {
+		t.Fatalf("Error constructing private key: %v", err)
+	}
+
+	otherKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("Error generating private key: %v", err)
+	}
+
+	sign := func(hs peer.Handshake, privateKey *ecdsa.PrivateKey) peer.Handshake {
+		hs, err := hs.Sign(privateKey)
+		if err != nil {
+			t.Fatalf("Error signing handshake: %v", err)
+		}
+		return hs
+	}
+
+	// answer returns a peer that answers a handshake with the handshake.
+	answer := func(hs func(host string) peer.Handshake) peer.Peer {
+		var host string
+		pr := newPeer(t, func(w http.ResponseWriter, r *http.Request) {
+			json.NewEncoder(w).Encode(hs(host))
+		})
+		host = pr.Host
+		return pr
+	}
+
+	hs := node1.Handshake()
+	hs.NodeID = string(miner2AccountID)
+
+	other := hs
+	other.NodeID = peer.NodeID(otherKey.PublicKey)
+
+	claim := func(hs peer.Handshake, host string) peer.Handshake {
+		hs.Host = host
+		return hs
+	}
+
+	miner2 := answer(func(host string) peer.Handshake { return sign(claim(hs, host), privateKey) })
+	liar := answer(func(host string) peer.Handshake { return sign(claim(other, host), otherKey) })
+
+	wrongChain := claim(hs, "localhost:9280")
+	wrongChain.ChainID++
+	if err := node1.AcceptHandshake(sign(wrongChain, privateKey)); !errors.Is(err, peer.ErrHandshake) {
+		t.Fatalf("Error accepting handshake: should have received ErrHandshake, got %v", err)
+	}
+	if node1.IsKnownPeer(peer.New(wrongChain.Host)) {
+		t.Fatalf("Error accepting handshake: refused peer should not be known")
+	}
+
+	impostor := claim(hs, miner2.Host)
+	impostor.NodeID = node1.NodeID()
+	if err := node1.AcceptHandshake(sign(impostor, privateKey)); !errors.Is(err, peer.ErrHandshake) {
+		t.Fatalf("Error accepting handshake: should refuse a node id signed by another key, got %v", err)
+	}
+
+	// The node id is only a claim until this node makes a handshake to the
+	// host, but the node is known for the requests it signs.
+	if err := node1.AcceptHandshake(sign(claim(hs, liar.Host), privateKey)); err != nil {
+		t.Fatalf("Error accepting handshake: %v", err)
+	}
+	if !node1.IsKnownPeer(liar) || !node1.IsKnownNode(hs.NodeID) {
+		t.Fatalf("Error accepting handshake: peer should be known")
+	}
+	if _, exists := node1.LookupNode(hs.NodeID); exists {
+		t.Fatalf("Error accepting handshake: claimed node id should not be bound")
+	}
+
+	if err := node1.AcceptHandshake(sign(claim(hs, miner2.Host), privateKey)); !errors.Is(err, peer.ErrHandshake) {
+		t.Fatalf("Error accepting handshake: should refuse a node id claimed by another host, got %v", err)
+	}
+
+	// The node answering at the host isn't the node that made the claim.
+	if _, err := node1.NetHandshake(liar); err != nil {
+		t.Fatalf("Error making handshake: %v", err)
+	}
+	if pr, _ := node1.LookupNode(other.NodeID); pr != liar {
+		t.Fatalf("Error making handshake: host should be bound to the node answering there, got %v", pr)
+	}
+	if node1.IsKnownNode(hs.NodeID) {
+		t.Fatalf("Error making handshake: claim should be dropped")
+	}
+
+	if err := node1.AcceptHandshake(sign(claim(hs, miner2.Host), privateKey)); err != nil {
+		t.Fatalf("Error accepting handshake: %v", err)
+	}
+	if _, err := node1.NetHandshake(miner2); err != nil {
+		t.Fatalf("Error making handshake: %v", err)
+	}
+
+	pr, exists := node1.LookupNode(hs.NodeID)
+	if !exists || pr != miner2 {
+		t.Fatalf("Error making handshake: node id should identify the peer, got %v %v", pr, exists)
+	}
+
+	// A claim never overwrites a binding that already exists.
+	if err := node1.AcceptHandshake(sign(claim(hs, "localhost:9380"), privateKey)); !errors.Is(err, peer.ErrHandshake) {
+		t.Fatalf("Error accepting handshake: should refuse to move a bound node, got %v", err)
+	}
+	if err := node1.AcceptHandshake(sign(claim(other, miner2.Host), otherKey)); !errors.Is(err, peer.ErrHandshake) {
+		t.Fatalf("Error accepting handshake: should refuse to take over a bound host, got %v", err)
+	}
+	if pr, _ := node1.LookupNode(hs.NodeID); pr != miner2 {
+		t.Fatalf("Error accepting handshake: binding should not change, got %v", pr)
+	}
+}
+
+// Test_CompactBlock validates a peer can rebuild a mined block from its
+// mempool and only needs the transactions it's missing.
+func Test_CompactBlock(t *testing.T) {
+	node1 := newNode(miner1PrivateKey, t)
+	node2 := newNode(miner2PrivateKey, t)
+
+	for nonce := uint64(1); nonce <= 2; nonce++ {
+		tx := database.Tx{
+			ChainID: chainID,
+			Nonce:   nonce,
+			FromID:  kennedyAccountID,
+			ToID:    edAccountID,
+			Value:   1,
+		}
+
+		if err := node1.UpsertWalletTransaction(newSignedTx(tx, kennedyPrivateKey, t)); err != nil {
+			t.Fatalf("Error upserting wallet transaction: %v", err)
+		}
+	}
+
+	// The second node only received the first transaction.
+	for _, tx := range node1.Mempool() {
+		if tx.Nonce == 1 {
+			if err := node2.UpsertNodeTransaction(tx); err != nil {
+				t.Fatalf("Error upserting node transaction: %v", err)
+			}
+		}
+	}
+
+	blk, err := node1.MineNewBlock(context.Background())
+	if err != nil {
+		t.Fatalf("Error mining new block: %v", err)
+	}
+
+	cb, err := database.NewCompactBlock(blk)
+	if err != nil {
+		t.Fatalf("Error constructing compact block: %v", err)
+	}
+
+	_, missing, err := node2.ReconstructBlock(cb)
+	if err != nil {
+		t.Fatalf("Error reconstructing block: %v", err)
+	}
+	if len(missing) != 1 || blk.MerkleTree.Values()[missing[0]].Nonce != 2 {
+		t.Fatalf("Error reconstructing block: should only be missing the second transaction, got %v", missing)
+	}
+
+	cb, err = cb.Prefill(blk, missing)
+	if err != nil {
+		t.Fatalf("Error prefilling compact block: %v", err)
+	}
+
+	block, missing, err := node2.ReconstructBlock(cb)
+	if err != nil || len(missing) != 0 {
+		t.Fatalf("Error reconstructing block: missing %v: %v", missing, err)
+	}
+
+	if err := node2.ProcessProposedBlock(block); err != nil {
+		t.Fatalf("Error proposing rebuilt block: %v", err)
+	}
+}
+
 // =============================================================================
 
 // Test_ProposeBlockValidation is an umbrella, holding different
@@ -167,6 +661,195 @@ func proposeBlockOneMissingBlock(blocks []database.Block) func(t *testing.T) {
 	return f
 }
 
+// Test_PeerCalls validates a block is sent to every peer at the same time,
+// a call that failed on the peer is retried and a peer that hangs only
+// delays itself.
+func Test_PeerCalls(t *testing.T) {
+	const timeout = 200 * time.Millisecond
+
+	var hungCalls, flakyCalls, refusedCalls atomic.Int32
+	var flakyAt atomic.Int64
+
+	hung := newPeer(t, func(w http.ResponseWriter, r *http.Request) {
+		hungCalls.Add(1)
+
+		// The request is cancelled once the body is read and the caller
+		// gives up.
+		io.Copy(io.Discard, r.Body)
+		<-r.Context().Done()
+	})
+
+	flaky := newPeer(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/block/compact") {
+			flakyAt.Store(time.Now().UnixNano())
+			json.NewEncoder(w).Encode(map[string]string{"status": "accepted"})
+			return
+		}
+
+		if flakyCalls.Add(1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		var inv []gossip.Inventory
+		json.NewDecoder(r.Body).Decode(&inv)
+		json.NewEncoder(w).Encode(inv)
+	})
+
+	refused := newPeer(t, func(w http.ResponseWriter, r *http.Request) {
+		refusedCalls.Add(1)
+		w.WriteHeader(http.StatusNotAcceptable)
+	})
+
+	privateKey, err := crypto.HexToECDSA(miner1PrivateKey)
+	if err != nil {
+		t.Fatalf("Error constructing private key: %v", err)
+	}
+
+	storage, err := memory.New()
+	if err != nil {
+		t.Fatalf("Error setting up memory storage: %v", err)
+	}
+
+	peerSet := peer.NewPeerSet()
+	for _, pr := range []peer.Peer{hung, flaky, refused} {
+		peerSet.Add(pr)
+	}
+
+	node, err := state.New(state.Config{
+		BeneficiaryID:  database.PublicKeyToAccountID(privateKey.PublicKey),
+		NodeKey:        privateKey,
+		Host:           "localhost:9080",
+		Genesis:        newGenesis(),
+		Storage:        storage,
+		SelectStrategy: "Tip",
+		KnownPeers:     peerSet,
+		PeerTimeout:    timeout,
+		PeerRetries:    2,
+		PeerBackoff:    10 * time.Millisecond,
+		EvHandler:      func(v string, args ...any) {},
+	})
+	if err != nil {
+		t.Fatalf("Error constructing node state: %v", err)
+	}
+	node.Worker = noopWorker{}
+
+	tx := database.Tx{
+		ChainID: chainID,
+		Nonce:   1,
+		FromID:  kennedyAccountID,
+		ToID:    edAccountID,
+		Value:   1,
+	}
+	if err := node.UpsertWalletTransaction(newSignedTx(tx, kennedyPrivateKey, t)); err != nil {
+		t.Fatalf("Error upserting wallet transaction: %v", err)
+	}
+
+	blk, err := node.MineNewBlock(context.Background())
+	if err != nil {
+		t.Fatalf("Error mining new block: %v", err)
+	}
+
+	start := time.Now()
+	err = node.NetSendBlockToPeers(blk)
+
+	var pe state.PeerErrors
+	if !errors.As(err, &pe) {
+		t.Fatalf("Error sending block: should have received PeerErrors, got %v", err)
+	}
+	if len(pe) != 2 || pe[hung] == nil || pe[refused] == nil {
+		t.Fatalf("Error sending block: should have failed for the hung and refused peers only, got %v", pe)
+	}
+	if !errors.Is(pe[hung], context.DeadlineExceeded) {
+		t.Fatalf("Error sending block: should have timed out the hung peer, got %v", pe[hung])
+	}
+
+	if got := hungCalls.Load(); got != 3 {
+		t.Fatalf("Error retrying the hung peer: got %d calls, exp 3", got)
+	}
+	if got := refusedCalls.Load(); got != 1 {
+		t.Fatalf("Error retrying the refused peer: got %d calls, exp 1", got)
+	}
+
+	at := flakyAt.Load()
+	if at == 0 {
+		t.Fatal("Error sending block: the flaky peer didn't receive the block after a retry")
+	}
+	if d := time.Unix(0, at).Sub(start); d >= timeout {
+		t.Fatalf("Error sending block: the flaky peer waited on the hung peer, got %v", d)
+	}
+}
+
+// Test_PeerCallLimits validates a call to a peer stops waiting to be tried
+// again when the node shuts down and a response that is too large is refused.
+func Test_PeerCallLimits(t *testing.T) {
+	var failedCalls atomic.Int32
+
+	failed := newPeer(t, func(w http.ResponseWriter, r *http.Request) {
+		failedCalls.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	large := newPeer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write(make([]byte, transport.MaxMessageSize+1))
+	})
+
+	privateKey, err := crypto.HexToECDSA(miner1PrivateKey)
+	if err != nil {
+		t.Fatalf("Error constructing private key: %v", err)
+	}
+
+	storage, err := memory.New()
+	if err != nil {
+		t.Fatalf("Error setting up memory storage: %v", err)
+	}
+
+	node, err := state.New(state.Config{
+		BeneficiaryID:  database.PublicKeyToAccountID(privateKey.PublicKey),
+		NodeKey:        privateKey,
+		Host:           "localhost:9080",
+		Genesis:        newGenesis(),
+		Storage:        storage,
+		SelectStrategy: "Tip",
+		KnownPeers:     peer.NewPeerSet(),
+		PeerRetries:    2,
+		PeerBackoff:    time.Hour,
+		EvHandler:      func(v string, args ...any) {},
+	})
+	if err != nil {
+		t.Fatalf("Error constructing node state: %v", err)
+	}
+	node.Worker = noopWorker{}
+
+	if _, err := node.NetAnnounce(large, nil); err == nil {
+		t.Fatal("Error announcing: should have refused the large response")
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := node.NetAnnounce(failed, nil)
+		done <- err
+	}()
+
+	for failedCalls.Load() == 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	node.Shutdown()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, state.ErrShutdown) {
+			t.Fatalf("Error announcing: should have stopped for the shutdown, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Error announcing: still waiting to try again after the shutdown")
+	}
+
+	if got := failedCalls.Load(); got != 1 {
+		t.Fatalf("Error retrying the failed peer: got %d calls, exp 1", got)
+	}
+}
+
 // =============================================================================
 
 // noopWorker implements the Worker interface which does nothing.
@@ -182,6 +865,17 @@ func (n noopWorker) SignalCancelMining() {}
 
 func (n noopWorker) SignalShareTx(blockTx database.BlockTx) {}
 
+func (n noopWorker) SignalShareBlock(block database.Block) {}
+
+// syncWorker implements the Worker interface with a Sync that runs until
+// the done channel is closed.
+type syncWorker struct {
+	noopWorker
+	done chan struct{}
+}
+
+func (w syncWorker) Sync() { <-w.done }
+
 // =============================================================================
 
 // newGenesis will create a new Genesis.
@@ -235,11 +929,12 @@ func newNode(hexKey string, t *testing.T) *state.State {
 
 	state, err := state.New(state.Config{
 		BeneficiaryID:  database.PublicKeyToAccountID(privateKey.PublicKey),
+		NodeKey:        privateKey,
 		Host:           "http://localhost:9080",
 		Genesis:        newGenesis(),
 		Storage:        storage,
 		SelectStrategy: "Tip",
-		KnownPeers:     *peer.NewPeerSet(),
+		KnownPeers:     peer.NewPeerSet(),
 		EvHandler:      func(v string, args ...any) {},
 	})
 	if err != nil {
@@ -249,3 +944,11 @@ func newNode(hexKey string, t *testing.T) *state.State {
 	state.Worker = noopWorker{}
 	return state
 }
+
+// newPeer starts a peer on localhost that answers with the handler.
+func newPeer(t *testing.T, handler http.HandlerFunc) peer.Peer {
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	return peer.New(srv.Listener.Addr().String())
+}
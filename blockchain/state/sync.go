@@ -0,0 +1,240 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/database"
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/download"
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/peer"
+)
+
+// MaxHeadersPerRequest is the max number of block headers returned for a
+// single request.
+const MaxHeadersPerRequest = 2000
+
+// MaxSyncHeaders is the max number of block headers held at once during a
+// sync. A longer chain is synced in rounds.
+const MaxSyncHeaders = 25 * MaxHeadersPerRequest
+
+// SyncProgress represents the progress of the current or last sync of the
+// chain with the peers.
+type SyncProgress struct {
+	Running bool   `json:"running"`
+	Peer    string `json:"best_peer"`
+	download.Progress
+}
+
+// SyncBlocks brings the chain up to date with the peers. The headers of the
+// best chain are retrieved and validated first, then the blocks are
+// downloaded from all the peers that have them at the same time. This is
+// repeated while the peers have more blocks than MaxSyncHeaders.
+func (s *State) SyncBlocks(sources []download.Source) error {
+	s.evHandler("state: SyncBlocks: started")
+	defer s.evHandler("state: SyncBlocks: completed")
+
+	for {
+		full, err := s.syncRound(sources)
+		if err != nil || !full {
+			return err
+		}
+	}
+}
+
+// SyncProgress returns the progress of the current or last sync.
+func (s *State) SyncProgress() SyncProgress {
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+
+	return s.syncProgress
+}
+
+// QueryHeadersByNumber returns the headers for the range of block numbers.
+// No more than MaxHeadersPerRequest headers are returned.
+func (s *State) QueryHeadersByNumber(from uint64, to uint64) []database.BlockHeader {
+	latest := s.LatestBlock().Header.Number
+	if from == 0 {
+		from = 1
+	}
+	if to > latest {
+		to = latest
+	}
+	if from > to {
+		return nil
+	}
+	if to-from >= MaxHeadersPerRequest {
+		to = from + MaxHeadersPerRequest - 1
+	}
+
+	blocks := s.QueryBlocksByNumber(from, to)
+
+	headers := make([]database.BlockHeader, len(blocks))
+	for i, block := range blocks {
+		headers[i] = block.Header
+	}
+
+	return headers
+}
+
+// NetRequestPeerHeaders asks the peer for the headers of the blocks that
+// extend the previous block, up to the block number to. The headers are
+// requested in chunks until the peer has no more, and each chunk is
+// validated as it arrives. No more than MaxSyncHeaders headers are held.
+func (s *State) NetRequestPeerHeaders(pr peer.Peer, prev database.Block, to uint64) ([]database.BlockHeader, error) {
+	to = min(to, prev.Header.Number+MaxSyncHeaders)
+
+	s.evHandler("state: NetRequestPeerHeaders: started: %s: blocks[%d-%d]", pr, prev.Header.Number+1, to)
+	defer s.evHandler("state: NetRequestPeerHeaders: completed: %s", pr)
+
+	var headers []database.BlockHeader
+	for from := prev.Header.Number + 1; from <= to; {
+		chunk, err := s.peerClient(pr).BlockHeadersByNumber(context.Background(), from, to)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(chunk) == 0 {
+			break
+		}
+
+		if n := uint64(len(chunk)); n > to-from+1 || n > MaxHeadersPerRequest {
+			return nil, fmt.Errorf("%w: got %d headers for blocks[%d-%d]", database.ErrInvalidHeader, n, from, to)
+		}
+
+		// A peer on a different fork fails on the first chunk, so a failure
+		// later on means the peer sent invalid headers.
+		if err := database.ValidateHeaders(prev, chunk); err != nil {
+			if len(headers) > 0 && errors.Is(err, database.ErrBlockOutOfOrder) {
+				return nil, fmt.Errorf("%w: %w", database.ErrInvalidHeader, err)
+			}
+			return nil, err
+		}
+
+		headers = append(headers, chunk...)
+		prev = database.Block{Header: chunk[len(chunk)-1]}
+		from = prev.Header.Number + 1
+	}
+
+	return headers, nil
+}
+
+// =============================================================================
+
+// syncRound downloads the blocks of the best chain the peers have, up to
+// MaxSyncHeaders blocks. It reports if that many were found, so there may
+// be more.
+func (s *State) syncRound(sources []download.Source) (bool, error) {
+	latest := s.LatestBlock()
+
+	pr, headers := s.bestHeaders(latest, sources)
+	if len(headers) == 0 {
+		return false, nil
+	}
+
+	s.evHandler("state: SyncBlocks: best chain: peer[%s]: blocks[%d-%d]", pr, headers[0].Number, headers[len(headers)-1].Number)
+
+	s.setSyncProgress(SyncProgress{Running: true, Peer: pr.Host})
+
+	cfg := download.Config{
+		Sources: sources,
+		Headers: headers,
+		Backoff: s.peerBackoff,
+		Fetch:   s.NetRequestPeerBlocks,
+		Apply:   s.applySyncedBlock,
+		Failed: func(pr peer.Peer, err error) {
+			s.evHandler("state: SyncBlocks: peer[%s]: WARNING: %s", pr, err)
+
+			delta := peer.ScoreTimeout
+			if errors.Is(err, download.ErrInvalidBatch) {
+				delta = peer.ScoreInvalidBlock
+			}
+			s.ScorePeer(pr, delta)
+		},
+		Progress: func(p download.Progress) {
+			s.setSyncProgress(SyncProgress{Running: true, Peer: pr.Host, Progress: p})
+			s.evHandler("viewer: sync: progress: blocks[%d/%d]: batches[%d/%d]: retries[%d]", p.Applied, p.To-p.From+1, p.BatchesDone, p.Batches, p.Retries)
+		},
+	}
+
+	p, err := download.Run(cfg)
+	s.setSyncProgress(SyncProgress{Peer: pr.Host, Progress: p})
+
+	full := len(headers) == MaxSyncHeaders && s.LatestBlock().Header.Number > latest.Header.Number
+	return full, err
+}
+
+// bestHeaders returns the longest valid chain of headers that extends the
+// latest block and the peer it came from. Peers claiming the most blocks
+// are asked first and peers that can't do better than the chain already
+// found are skipped.
+func (s *State) bestHeaders(latest database.Block, sources []download.Source) (peer.Peer, []database.BlockHeader) {
+	candidates := make([]download.Source, 0, len(sources))
+	for _, src := range sources {
+		if src.Height > latest.Header.Number {
+			candidates = append(candidates, src)
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Height > candidates[j].Height
+	})
+
+	var best peer.Peer
+	var bestHeaders []database.BlockHeader
+
+	for _, src := range candidates {
+		if src.Height <= latest.Header.Number+uint64(len(bestHeaders)) {
+			break
+		}
+
+		// A peer on a different fork isn't misbehaving, but it can't be
+		// synced from until the fork is resolved.
+		headers, err := s.NetRequestPeerHeaders(src.Peer, latest, src.Height)
+		if err != nil {
+			s.evHandler("state: SyncBlocks: headers: peer[%s]: WARNING: %s", src.Peer, err)
+			switch {
+			case errors.Is(err, database.ErrInvalidHeader):
+				s.ScorePeer(src.Peer, peer.ScoreInvalidBlock)
+			case !errors.Is(err, database.ErrBlockOutOfOrder):
+				s.ScorePeer(src.Peer, peer.ScoreTimeout)
+			}
+			continue
+		}
+
+		if len(headers) > len(bestHeaders) {
+			best, bestHeaders = src.Peer, headers
+		}
+	}
+
+	return best, bestHeaders
+}
+
+// applySyncedBlock adds a block downloaded during a sync to the chain. A
+// block received through gossip while the sync was running may already be
+// in the chain.
+func (s *State) applySyncedBlock(pr peer.Peer, block database.Block) error {
+	if block.Header.Number <= s.LatestBlock().Header.Number {
+		return nil
+	}
+
+	if err := s.ProcessProposedBlock(block); err != nil {
+		if IsMisbehavior(err) {
+			s.ScorePeer(pr, peer.ScoreInvalidBlock)
+		}
+		return err
+	}
+
+	s.ScorePeer(pr, peer.ScoreUsefulBlock)
+
+	return nil
+}
+
+// setSyncProgress records the progress of the sync.
+func (s *State) setSyncProgress(p SyncProgress) {
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+
+	s.syncProgress = p
+}
@@ -1,6 +1,44 @@
 package state
 
-import "github.com/zacksfF/FullStack-Blockchain/blockchain/database"
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/database"
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/mempool"
+)
+
+// Set of errors returned when working with transactions.
+var (
+	ErrTxNotFound = errors.New("transaction not found")
+	ErrInvalidTx  = errors.New("invalid transaction")
+)
+
+// Set of statuses a transaction can have during its lifecycle.
+const (
+	TxPending  = "pending"
+	TxMined    = "mined"
+	TxFailed   = "failed"
+	TxReplaced = "replaced"
+	TxExpired  = "expired"
+	TxEvicted  = "evicted"
+)
+
+// TxStatus represents what the node knows about a transaction. Position is
+// only set for pending transactions, the block fields for mined and failed
+// transactions and ReplacedBy for replaced transactions.
+type TxStatus struct {
+	Hash          string
+	Status        string
+	Position      int
+	BlockNumber   uint64
+	BlockHash     string
+	Confirmations uint64
+	ReplacedBy    string
+	Reason        string
+}
 
 // UpsertWalletTransaction accepts a transaction from a wallet for inclusion.
 func (s *State) UpsertWalletTransaction(signedTx database.SignedTx) error {
@@ -18,9 +56,15 @@ func (s *State) UpsertWalletTransaction(signedTx database.SignedTx) error {
 
 	const oneUnitOfGas = 1
 	tx := database.NewBlockTx(signedTx, s.genesis.GasPrice, oneUnitOfGas)
+	if err := s.checkExpiry(tx); err != nil {
+		return err
+	}
+
 	if err := s.mempool.Upsert(tx); err != nil {
 		return err
 	}
+	s.seen.Add(tx.TxHash())
+	s.newPendingTxEvent(tx)
 
 	s.Worker.SignalShareTx(tx)
 	s.Worker.SignalStartMining()
@@ -28,12 +72,18 @@ func (s *State) UpsertWalletTransaction(signedTx database.SignedTx) error {
 	return nil
 }
 
-// UpsertNodeTransaction accepts a transaction from a node for inclusion.
+// UpsertNodeTransaction accepts a transaction from a node for inclusion. A
+// transaction this node has not seen before is relayed to its peers.
 func (s *State) UpsertNodeTransaction(tx database.BlockTx) error {
 
 	// Check the signed transaction has a proper signature, the from matches the
-	// signature, and the from and to fields are properly formatted.
+	// signature, and the from and to fields are properly formatted. A node
+	// should never share a transaction that fails these checks.
 	if err := tx.Validate(s.genesis.ChainID); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidTx, err)
+	}
+
+	if err := s.checkExpiry(tx); err != nil {
 		return err
 	}
 
@@ -41,7 +91,112 @@ func (s *State) UpsertNodeTransaction(tx database.BlockTx) error {
 		return err
 	}
 
+	// Only a transaction that was accepted is marked as seen, so one that
+	// was refused can still be requested and accepted later.
+	if s.seen.Add(tx.TxHash()) {
+		s.newPendingTxEvent(tx)
+		s.Worker.SignalShareTx(tx)
+	}
 	s.Worker.SignalStartMining()
 
 	return nil
 }
+
+// PruneMempool removes the transactions that have expired or have been
+// sitting in the mempool longer than the configured TTL. An event is sent
+// for each transaction that is dropped.
+func (s *State) PruneMempool() int {
+	nextNumber := s.db.LatestBlock().Header.Number + 1
+
+	dropped := s.mempool.Prune(nextNumber, time.Now())
+	for _, d := range dropped {
+		s.evHandler("state: PruneMempool: dropped: tx[%s]: reason[%s]", d.Tx, d.Reason)
+		s.droppedTxEvent(d)
+	}
+
+	return len(dropped)
+}
+
+// QueryTxStatus reports the status of the transaction with the specified
+// hash using the receipts for mined transactions and the mempool for the
+// transactions that have not been mined.
+func (s *State) QueryTxStatus(hash string) (TxStatus, error) {
+	r, err := s.db.Receipt(hash)
+	if err != nil && !errors.Is(err, database.ErrReceiptNotFound) {
+		return TxStatus{}, err
+	}
+
+	if err == nil {
+		status := TxStatus{
+			Hash:          hash,
+			Status:        TxMined,
+			BlockNumber:   r.BlockNumber,
+			BlockHash:     r.BlockHash,
+			Confirmations: s.db.LatestBlock().Header.Number - r.BlockNumber + 1,
+		}
+
+		if r.Failed {
+			status.Status = TxFailed
+			status.Reason = r.Error
+		}
+
+		return status, nil
+	}
+
+	if pos, exists := s.mempool.Position(hash); exists {
+		return TxStatus{Hash: hash, Status: TxPending, Position: pos}, nil
+	}
+
+	if d, exists := s.mempool.Dropped(hash); exists {
+		status := TxStatus{Hash: hash, Reason: d.Reason}
+
+		switch d.Reason {
+		case mempool.DropReplaced:
+			status.Status = TxReplaced
+			status.ReplacedBy = d.ReplacedBy
+		case mempool.DropEvicted:
+			status.Status = TxEvicted
+		default:
+			status.Status = TxExpired
+		}
+
+		return status, nil
+	}
+
+	return TxStatus{}, ErrTxNotFound
+}
+
+// =============================================================================
+
+// checkExpiry validates the transaction can still be mined into the next block.
+func (s *State) checkExpiry(tx database.BlockTx) error {
+	nextNumber := s.db.LatestBlock().Header.Number + 1
+	now := uint64(time.Now().UTC().UnixMilli())
+
+	if tx.IsExpired(nextNumber, now) {
+		return fmt.Errorf("%w: tx[%s]", database.ErrTxExpired, tx)
+	}
+
+	return nil
+}
+
+// droppedTxEvent provides a specific event about a transaction that was
+// dropped from the mempool without being mined.
+func (s *State) droppedTxEvent(d mempool.Dropped) {
+	ev := struct {
+		From   database.AccountID `json:"from"`
+		Nonce  uint64             `json:"nonce"`
+		Reason string             `json:"reason"`
+	}{
+		From:   d.Tx.FromID,
+		Nonce:  d.Tx.Nonce,
+		Reason: d.Reason,
+	}
+
+	data, err := json.Marshal(ev)
+	if err != nil {
+		data = []byte(fmt.Sprintf("{error: %q}", err.Error()))
+	}
+
+	s.evHandler("viewer: tx: dropped: %s", string(data))
+}
@@ -8,10 +8,19 @@ import (
 	"os"
 	"path"
 	"strconv"
+	"strings"
 
 	"github.com/zacksfF/FullStack-Blockchain/blockchain/database"
 )
 
+// hashDir is the directory under the database path that holds the hash
+// index. Each file is named by a block hash and holds the block number.
+const hashDir = "hash"
+
+// receiptDir is the directory under the database path that holds the
+// receipts. Each file is named by a transaction hash.
+const receiptDir = "receipt"
+
 // Disk represents the serialization implementation for reading and storing
 // blocks in their own separate files on disk. This implements the database.Storage
 // interface.
@@ -19,13 +28,21 @@ type Disk struct {
 	dbPath string
 }
 
-// New constructs an Disk value for use.
+// New constructs an Disk value for use. The blocks missing from the hash
+// index are indexed when it's opened, which covers a chain written before
+// the index existed and a crash between writing a block and indexing it.
 func New(dbPath string) (*Disk, error) {
 	if err := os.MkdirAll(dbPath, 0755); err != nil {
 		return nil, err
 	}
 
-	return &Disk{dbPath: dbPath}, nil
+	d := Disk{dbPath: dbPath}
+
+	if err := d.reconcileIndex(); err != nil {
+		return nil, fmt.Errorf("reconciling hash index: %w", err)
+	}
+
+	return &d, nil
 }
 
 // Close in this implementation has nothing to do since a new file is
@@ -56,7 +73,7 @@ func (d *Disk) Write(blockData database.BlockData) error {
 		return err
 	}
 
-	return nil
+	return d.index(blockData)
 }
 
 // GetBlock searches the blockchain on disk to locate and return the
@@ -80,6 +97,71 @@ func (d *Disk) GetBlock(num uint64) (database.BlockData, error) {
 	return blockData, nil
 }
 
+// GetBlockByHash locates and returns the contents of the specified block
+// by hash using the hash index.
+func (d *Disk) GetBlockByHash(hash string) (database.BlockData, error) {
+	if !isHash(hash) {
+		return database.BlockData{}, database.ErrBlockNotFound
+	}
+
+	data, err := os.ReadFile(path.Join(d.dbPath, hashDir, hash))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return database.BlockData{}, database.ErrBlockNotFound
+		}
+		return database.BlockData{}, err
+	}
+
+	num, err := strconv.ParseUint(string(data), 10, 64)
+	if err != nil {
+		return database.BlockData{}, fmt.Errorf("reading hash index: %w", err)
+	}
+
+	return d.GetBlock(num)
+}
+
+// WriteReceipt stores the receipt in a file named by the hash of its
+// transaction.
+func (d *Disk) WriteReceipt(receipt database.Receipt) error {
+	if !isHash(receipt.TxHash) {
+		return fmt.Errorf("invalid transaction hash %q", receipt.TxHash)
+	}
+
+	data, err := json.Marshal(receipt)
+	if err != nil {
+		return err
+	}
+
+	dir := path.Join(d.dbPath, receiptDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path.Join(dir, receipt.TxHash), data, 0600)
+}
+
+// GetReceipt returns the receipt for the specified transaction.
+func (d *Disk) GetReceipt(txHash string) (database.Receipt, error) {
+	if !isHash(txHash) {
+		return database.Receipt{}, database.ErrReceiptNotFound
+	}
+
+	data, err := os.ReadFile(path.Join(d.dbPath, receiptDir, txHash))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return database.Receipt{}, database.ErrReceiptNotFound
+		}
+		return database.Receipt{}, err
+	}
+
+	var receipt database.Receipt
+	if err := json.Unmarshal(data, &receipt); err != nil {
+		return database.Receipt{}, fmt.Errorf("reading receipt: %w", err)
+	}
+
+	return receipt, nil
+}
+
 // ForEach returns an iterator to walk through all the blocks
 // starting with block number 1.
 func (d *Disk) ForEach() database.Iterator {
@@ -92,7 +174,7 @@ func (d *Disk) Reset() error {
 		return err
 	}
 
-	return os.MkdirAll(d.dbPath, 0755)
+	return os.MkdirAll(path.Join(d.dbPath, hashDir), 0755)
 }
 
 // getPath forms the path to the specified block.
@@ -101,6 +183,92 @@ func (d *Disk) getPath(blockNum uint64) string {
 	return path.Join(d.dbPath, fmt.Sprintf("%s.json", name))
 }
 
+// index adds the block to the hash index.
+func (d *Disk) index(blockData database.BlockData) error {
+	dir := path.Join(d.dbPath, hashDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	num := strconv.FormatUint(blockData.Header.Number, 10)
+	return os.WriteFile(path.Join(dir, blockData.Hash), []byte(num), 0600)
+}
+
+// reconcileIndex adds the blocks on disk that are missing from the hash
+// index. Blocks are indexed in order as they are written, so it walks back
+// from the latest block until it finds one that is already indexed.
+func (d *Disk) reconcileIndex() error {
+	if err := os.MkdirAll(path.Join(d.dbPath, hashDir), 0755); err != nil {
+		return err
+	}
+
+	latest, err := d.latestNumber()
+	if err != nil {
+		return err
+	}
+
+	for num := latest; num > 0; num-- {
+		blockData, err := d.GetBlock(num)
+		if err != nil {
+			return err
+		}
+
+		data, err := os.ReadFile(path.Join(d.dbPath, hashDir, blockData.Hash))
+		if err == nil && string(data) == strconv.FormatUint(num, 10) {
+			return nil
+		}
+
+		if err := d.index(blockData); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// latestNumber returns the number of the latest block on disk, or zero
+// when there are no blocks.
+func (d *Disk) latestNumber() (uint64, error) {
+	entries, err := os.ReadDir(d.dbPath)
+	if err != nil {
+		return 0, err
+	}
+
+	var latest uint64
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok || entry.IsDir() {
+			continue
+		}
+
+		num, err := strconv.ParseUint(name, 10, 64)
+		if err != nil {
+			continue
+		}
+		latest = max(latest, num)
+	}
+
+	return latest, nil
+}
+
+// isHash reports if the value is formatted like a block or transaction hash,
+// so it's safe to use as a file name.
+func isHash(hash string) bool {
+	const hashLength = 66
+
+	if len(hash) != hashLength || hash[:2] != "0x" {
+		return false
+	}
+
+	for _, c := range hash[2:] {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+
+	return true
+}
+
 // =============================================================================
 
 // diskIterator represents the iteration implementation for walking
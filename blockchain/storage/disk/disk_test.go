@@ -0,0 +1,63 @@
+package disk_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/database"
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/storage/disk"
+)
+
+func Test_ReconcileIndex(t *testing.T) {
+	dbPath := t.TempDir()
+
+	d, err := disk.New(dbPath)
+	if err != nil {
+		t.Fatalf("Should be able to open the storage: %s", err)
+	}
+
+	var blocks []database.BlockData
+	for num := uint64(1); num <= 3; num++ {
+		blockData := database.BlockData{
+			Hash:   fmt.Sprintf("0x%064x", num),
+			Header: database.BlockHeader{Number: num},
+		}
+		if err := d.Write(blockData); err != nil {
+			t.Fatalf("Should be able to write block %d: %s", num, err)
+		}
+		blocks = append(blocks, blockData)
+	}
+
+	// The node stopped after writing the last block but before indexing it.
+	if err := os.Remove(filepath.Join(dbPath, "hash", blocks[2].Hash)); err != nil {
+		t.Fatalf("Should be able to remove the index entry: %s", err)
+	}
+
+	d, err = disk.New(dbPath)
+	if err != nil {
+		t.Fatalf("Should be able to reopen the storage: %s", err)
+	}
+
+	for _, want := range blocks {
+		got, err := d.GetBlockByHash(want.Hash)
+		if err != nil || got.Header.Number != want.Header.Number {
+			t.Fatalf("Should find block %d by hash after reopening, got %d: %v", want.Header.Number, got.Header.Number, err)
+		}
+	}
+
+	// A chain written before the index existed is indexed in full.
+	if err := os.RemoveAll(filepath.Join(dbPath, "hash")); err != nil {
+		t.Fatalf("Should be able to remove the index: %s", err)
+	}
+
+	d, err = disk.New(dbPath)
+	if err != nil {
+		t.Fatalf("Should be able to reopen the storage: %s", err)
+	}
+
+	if _, err := d.GetBlockByHash(blocks[0].Hash); err != nil {
+		t.Fatalf("Should find the first block by hash: %s", err)
+	}
+}
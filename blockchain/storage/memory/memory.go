@@ -11,13 +11,20 @@ import (
 // blocks in memory using a slice. This implements the database.Storage
 // interface.
 type Memory struct {
-	mu     sync.RWMutex
-	blocks []database.BlockData
+	mu       sync.RWMutex
+	blocks   []database.BlockData
+	hashes   map[string]uint64
+	receipts map[string]database.Receipt
 }
 
 // New constructs an Memory value for use.
 func New() (*Memory, error) {
-	return &Memory{}, nil
+	m := Memory{
+		hashes:   make(map[string]uint64),
+		receipts: make(map[string]database.Receipt),
+	}
+
+	return &m, nil
 }
 
 // Close in this implementation has nothing to do since everything
@@ -37,6 +44,7 @@ func (m *Memory) Write(blockData database.BlockData) error {
 	}
 
 	m.blocks = append(m.blocks, blockData)
+	m.hashes[blockData.Hash] = blockData.Header.Number
 
 	return nil
 }
@@ -47,12 +55,49 @@ func (m *Memory) GetBlock(num uint64) (database.BlockData, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
+	// Block 1 is stored at the front of the slice.
 	l := uint64(len(m.blocks))
-	if l == 0 || num >= l {
+	if num == 0 || num > l {
 		return database.BlockData{}, errors.New("block does not exist")
 	}
 
-	return m.blocks[num], nil
+	return m.blocks[num-1], nil
+}
+
+// GetBlockByHash locates and returns the contents of the specified block
+// by hash.
+func (m *Memory) GetBlockByHash(hash string) (database.BlockData, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	num, exists := m.hashes[hash]
+	if !exists {
+		return database.BlockData{}, database.ErrBlockNotFound
+	}
+
+	return m.blocks[num-1], nil
+}
+
+// WriteReceipt stores the receipt by the hash of its transaction.
+func (m *Memory) WriteReceipt(receipt database.Receipt) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.receipts[receipt.TxHash] = receipt
+	return nil
+}
+
+// GetReceipt returns the receipt for the specified transaction.
+func (m *Memory) GetReceipt(txHash string) (database.Receipt, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	receipt, exists := m.receipts[txHash]
+	if !exists {
+		return database.Receipt{}, database.ErrReceiptNotFound
+	}
+
+	return receipt, nil
 }
 
 // ForEach returns an iterator to walk through all the blocks
@@ -67,6 +112,8 @@ func (m *Memory) Reset() error {
 	defer m.mu.Unlock()
 
 	m.blocks = []database.BlockData{}
+	m.hashes = make(map[string]uint64)
+	m.receipts = make(map[string]database.Receipt)
 	return nil
 }
 
@@ -87,13 +134,12 @@ func (mi *memoryIterator) Next() (database.BlockData, error) {
 		return database.BlockData{}, errors.New("end of chain")
 	}
 
+	mi.current++
 	blockData, err := mi.storage.GetBlock(mi.current)
 	if err != nil {
 		mi.eoc = true
 	}
 
-	mi.current++
-
 	return blockData, err
 }
 
@@ -0,0 +1,283 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/database"
+)
+
+// Set of values used to keep an idle connection alive.
+const (
+	pingInterval = 30 * time.Second
+	pongWait     = 2 * pingInterval
+	writeWait    = 10 * time.Second
+)
+
+// MaxMessageSize is the largest message accepted from a peer. It's the
+// largest valid block with room for the frame around it. Responses that
+// carry several blocks or transactions are cut down by the peer to fit.
+const MaxMessageSize = database.MaxBlockSize + 64<<10
+
+// Conn represents a persistent connection to a peer. Both sides can send
+// requests over the same connection and the responses are matched back to
+// the requests, so many requests can be in flight at the same time.
+type Conn struct {
+	ws          *websocket.Conn
+	handler     http.Handler
+	out         chan []byte
+	inFlight    chan struct{}
+	done        chan struct{}
+	closeOnce   sync.Once
+	mu          sync.Mutex
+	nextID      uint64
+	pending     map[uint64]chan frame
+	ctx         context.Context
+	cancel      context.CancelFunc
+	wg          sync.WaitGroup
+	remoteAddr  string
+	queueWait   time.Duration
+	closeReason error
+}
+
+// newConn constructs a connection over the websocket and starts reading
+// and writing messages. Requests received from the peer are served by the
+// handler.
+func newConn(ws *websocket.Conn, handler http.Handler, cfg Config) *Conn {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	c := Conn{
+		ws:         ws,
+		handler:    handler,
+		out:        make(chan []byte, cfg.QueueSize),
+		inFlight:   make(chan struct{}, cfg.MaxInFlight),
+		done:       make(chan struct{}),
+		pending:    make(map[uint64]chan frame),
+		ctx:        ctx,
+		cancel:     cancel,
+		remoteAddr: ws.RemoteAddr().String(),
+		queueWait:  cfg.Timeout,
+	}
+
+	ws.SetReadLimit(MaxMessageSize)
+	ws.SetReadDeadline(time.Now().Add(pongWait))
+	ws.SetPongHandler(func(string) error {
+		return ws.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	c.wg.Add(2)
+	go c.readLoop()
+	go c.writeLoop()
+
+	return &c
+}
+
+// Do sends the request to the peer and waits for the response. The call
+// blocks while the outbound queue is full, which slows down the callers
+// when the peer can't keep up.
+func (c *Conn) Do(ctx context.Context, req Request) (Response, error) {
+	ch := make(chan frame, 1)
+
+	c.mu.Lock()
+	c.nextID++
+	id := c.nextID
+	c.pending[id] = ch
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	f := frame{
+		ID:     id,
+		Method: req.Method,
+		Path:   req.Path,
+		Header: flatten(req.Header),
+		Body:   req.Body,
+	}
+
+	if err := c.enqueue(ctx, f); err != nil {
+		return Response{}, err
+	}
+
+	select {
+	case f := <-ch:
+		return Response{Status: f.Status, Body: f.Body}, nil
+	case <-ctx.Done():
+		return Response{}, ctx.Err()
+	case <-c.done:
+		return Response{}, fmt.Errorf("%w: %w", ErrUnavailable, c.closeReason)
+	}
+}
+
+// Done returns a channel that is closed when the connection is closed.
+func (c *Conn) Done() <-chan struct{} {
+	return c.done
+}
+
+// Close closes the connection and fails the requests still waiting for
+// a response.
+func (c *Conn) Close() error {
+	c.shutdown(ErrClosed)
+	c.wg.Wait()
+	return nil
+}
+
+// =============================================================================
+
+// shutdown closes the connection once, recording why it was closed.
+func (c *Conn) shutdown(reason error) {
+	c.closeOnce.Do(func() {
+		c.closeReason = reason
+		c.cancel()
+		close(c.done)
+		c.ws.Close()
+	})
+}
+
+// enqueue places the frame on the outbound queue.
+func (c *Conn) enqueue(ctx context.Context, f frame) error {
+	msg, err := f.encode()
+	if err != nil {
+		return err
+	}
+
+	select {
+	case c.out <- msg:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-c.done:
+		return fmt.Errorf("%w: %w", ErrUnavailable, c.closeReason)
+	}
+}
+
+// writeLoop writes the queued messages to the peer and keeps the
+// connection alive.
+func (c *Conn) writeLoop() {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case msg := <-c.out:
+			c.ws.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.ws.WriteMessage(websocket.BinaryMessage, msg); err != nil {
+				c.shutdown(err)
+				return
+			}
+
+		case <-ticker.C:
+			if err := c.ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
+				c.shutdown(err)
+				return
+			}
+
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// readLoop reads messages from the peer. Responses are handed to the
+// waiting request and requests are served by the handler. Once the max
+// number of requests are being served, reading stops until one completes
+// so the peer has to slow down.
+func (c *Conn) readLoop() {
+	defer c.wg.Done()
+
+	for {
+		_, msg, err := c.ws.ReadMessage()
+		if err != nil {
+			c.shutdown(err)
+			return
+		}
+
+		f, err := decode(msg)
+		if err != nil {
+			c.shutdown(err)
+			return
+		}
+
+		// The request is removed before its reply is handed over, so a
+		// repeated reply is dropped and can't block reading once the
+		// buffer of the request is full.
+		if f.Reply {
+			c.mu.Lock()
+			ch, exists := c.pending[f.ID]
+			delete(c.pending, f.ID)
+			c.mu.Unlock()
+
+			if exists {
+				ch <- f
+			}
+			continue
+		}
+
+		select {
+		case c.inFlight <- struct{}{}:
+		case <-c.done:
+			return
+		}
+
+		go func(f frame) {
+			defer func() { <-c.inFlight }()
+			c.serve(f)
+		}(f)
+	}
+}
+
+// serve runs the request received from the peer through the handler and
+// sends back the response.
+func (c *Conn) serve(f frame) {
+	reply := frame{ID: f.ID, Reply: true}
+
+	r, err := http.NewRequestWithContext(c.ctx, f.Method, f.Path, io.NopCloser(bytes.NewReader(f.Body)))
+	switch {
+	case err != nil:
+		reply.Status = http.StatusBadRequest
+
+	default:
+		for k, v := range f.Header {
+			r.Header.Set(k, v)
+		}
+		r.RemoteAddr = c.remoteAddr
+
+		rw := newResponseWriter()
+		c.handler.ServeHTTP(rw, r)
+
+		reply.Status = rw.status
+		if reply.Status == 0 {
+			reply.Status = http.StatusOK
+		}
+		reply.Body = rw.body.Bytes()
+	}
+
+	ctx, cancel := context.WithTimeout(c.ctx, c.queueWait)
+	defer cancel()
+
+	c.enqueue(ctx, reply)
+}
+
+// flatten keeps the first value of each header.
+func flatten(header http.Header) map[string]string {
+	if len(header) == 0 {
+		return nil
+	}
+
+	m := make(map[string]string, len(header))
+	for k := range header {
+		m[k] = header.Get(k)
+	}
+	return m
+}
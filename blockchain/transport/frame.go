@@ -0,0 +1,94 @@
+package transport
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// frame represents a request or response sent over a peer connection. The
+// id matches a response to the request it answers.
+type frame struct {
+	ID     uint64            `json:"id"`
+	Reply  bool              `json:"reply,omitempty"`
+	Method string            `json:"method,omitempty"`
+	Path   string            `json:"path,omitempty"`
+	Header map[string]string `json:"header,omitempty"`
+	Status int               `json:"status,omitempty"`
+	Body   []byte            `json:"-"`
+}
+
+// encode converts the frame into a message. The message starts with the
+// length of the frame header, followed by the header in JSON and the body.
+func (f frame) encode() ([]byte, error) {
+	hdr, err := json.Marshal(f)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := make([]byte, 4+len(hdr)+len(f.Body))
+	binary.BigEndian.PutUint32(msg, uint32(len(hdr)))
+	copy(msg[4:], hdr)
+	copy(msg[4+len(hdr):], f.Body)
+
+	return msg, nil
+}
+
+// decode converts a message back into a frame.
+func decode(msg []byte) (frame, error) {
+	if len(msg) < 4 {
+		return frame{}, errors.New("message too short")
+	}
+
+	n := binary.BigEndian.Uint32(msg)
+	if uint64(n) > uint64(len(msg)-4) {
+		return frame{}, errors.New("invalid header length")
+	}
+
+	var f frame
+	if err := json.Unmarshal(msg[4:4+n], &f); err != nil {
+		return frame{}, err
+	}
+	f.Body = msg[4+n:]
+
+	return f, nil
+}
+
+// =============================================================================
+
+// responseWriter captures the response of a handler serving a request that
+// was received over a peer connection.
+type responseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+// newResponseWriter constructs a response writer for a single request.
+func newResponseWriter() *responseWriter {
+	return &responseWriter{
+		header: make(http.Header),
+	}
+}
+
+// Header implements the http.ResponseWriter interface.
+func (rw *responseWriter) Header() http.Header {
+	return rw.header
+}
+
+// WriteHeader implements the http.ResponseWriter interface.
+func (rw *responseWriter) WriteHeader(status int) {
+	if rw.status == 0 {
+		rw.status = status
+	}
+}
+
+// Write implements the http.ResponseWriter interface.
+func (rw *responseWriter) Write(data []byte) (int, error) {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	return rw.body.Write(data)
+}
@@ -0,0 +1,338 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Package transport provides persistent connections between peers. Requests
+// for blocks, transactions and status are multiplexed over a single
+// websocket per peer instead of opening a new HTTP request for each call.
+// Callers fall back to HTTP when a peer can't be reached this way.
+
+// StreamPath is the route a node accepts peer connections on.
+const StreamPath = "/v1/node/stream"
+
+// Set of default values used when the config leaves them unset.
+const (
+	DefaultQueueSize   = 64
+	DefaultMaxInFlight = 16
+	DefaultMaxInbound  = 64
+	DefaultTimeout     = 10 * time.Second
+	DefaultRetryAfter  = time.Minute
+)
+
+// Set of errors returned by the transport.
+var (
+	ErrUnavailable  = errors.New("peer connection unavailable")
+	ErrClosed       = errors.New("transport closed")
+	ErrTooManyConns = errors.New("too many inbound connections")
+)
+
+// Request represents a request sent to a peer.
+type Request struct {
+	Method string
+	Path   string
+	Header http.Header
+	Body   []byte
+}
+
+// Response represents the response received from a peer.
+type Response struct {
+	Status int
+	Body   []byte
+}
+
+// Config represents the settings for the transport. Sign is called to
+// authenticate the request that opens a connection to a peer. MaxInbound
+// caps the connections accepted from peers.
+type Config struct {
+	QueueSize   int
+	MaxInFlight int
+	MaxInbound  int
+	Timeout     time.Duration
+	RetryAfter  time.Duration
+	Sign        func(r *http.Request) error
+	EvHandler   func(v string, args ...any)
+}
+
+// Transport maintains a persistent connection with each peer. Connections
+// are opened when a request is first sent to a peer or accepted when a peer
+// connects. A peer that can't be connected to isn't retried until the retry
+// period has passed.
+type Transport struct {
+	cfg      Config
+	dialer   websocket.Dialer
+	upgrader websocket.Upgrader
+
+	mu      sync.Mutex
+	handler http.Handler
+	conns   map[string]*Conn
+	dialing map[string]chan struct{}
+	inbound map[*Conn]struct{}
+	failed  map[string]time.Time
+	closed  bool
+
+	// upgrading counts the accepted requests that are being upgraded, so
+	// they are held against the max number of inbound connections.
+	upgrading int
+}
+
+// New constructs a transport with the specified config.
+func New(cfg Config) *Transport {
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = DefaultQueueSize
+	}
+	if cfg.MaxInFlight <= 0 {
+		cfg.MaxInFlight = DefaultMaxInFlight
+	}
+	if cfg.MaxInbound <= 0 {
+		cfg.MaxInbound = DefaultMaxInbound
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	if cfg.RetryAfter <= 0 {
+		cfg.RetryAfter = DefaultRetryAfter
+	}
+	if cfg.EvHandler == nil {
+		cfg.EvHandler = func(v string, args ...any) {}
+	}
+
+	return &Transport{
+		cfg:      cfg,
+		dialer:   websocket.Dialer{HandshakeTimeout: cfg.Timeout},
+		upgrader: websocket.Upgrader{},
+		conns:    make(map[string]*Conn),
+		dialing:  make(map[string]chan struct{}),
+		inbound:  make(map[*Conn]struct{}),
+		failed:   make(map[string]time.Time),
+	}
+}
+
+// SetHandler sets the handler that serves the requests received from peers.
+// This is the same handler that serves the node's private HTTP routes.
+func (t *Transport) SetHandler(handler http.Handler) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.handler = handler
+}
+
+// Do sends the request to the peer at the specified host. An error wrapping
+// ErrUnavailable means the request was not sent and can be sent over HTTP.
+func (t *Transport) Do(ctx context.Context, host string, req Request) (Response, error) {
+	conn, err := t.conn(ctx, host)
+	if err != nil {
+		return Response{}, err
+	}
+
+	return conn.Do(ctx, req)
+}
+
+// Accept upgrades the request from the peer at the specified host into a
+// persistent connection. It blocks until the connection is closed. When the
+// host isn't known yet, the connection only serves the peer's requests.
+// ErrTooManyConns is returned without upgrading the request when the max
+// number of inbound connections is open.
+func (t *Transport) Accept(w http.ResponseWriter, r *http.Request, host string) error {
+	t.mu.Lock()
+	if len(t.inbound)+t.upgrading >= t.cfg.MaxInbound {
+		t.mu.Unlock()
+		return ErrTooManyConns
+	}
+	t.upgrading++
+	t.mu.Unlock()
+
+	ws, err := t.upgrader.Upgrade(w, r, nil)
+
+	t.mu.Lock()
+	t.upgrading--
+	if err != nil {
+		t.mu.Unlock()
+		return err
+	}
+	if t.closed {
+		t.mu.Unlock()
+		ws.Close()
+		return ErrClosed
+	}
+
+	conn := newConn(ws, http.HandlerFunc(t.serve), t.cfg)
+	t.inbound[conn] = struct{}{}
+
+	// When both nodes connect to each other at the same time, the existing
+	// connection keeps being used for outbound requests.
+	if _, exists := t.conns[host]; !exists && host != "" {
+		t.conns[host] = conn
+		delete(t.failed, host)
+	}
+	t.mu.Unlock()
+
+	t.cfg.EvHandler("transport: Accept: connected: peer[%s]", host)
+
+	<-conn.Done()
+	t.forget(host, conn)
+	conn.Close()
+
+	t.mu.Lock()
+	delete(t.inbound, conn)
+	t.mu.Unlock()
+
+	t.cfg.EvHandler("transport: Accept: disconnected: peer[%s]: %s", host, conn.closeReason)
+
+	return nil
+}
+
+// Close closes every connection.
+func (t *Transport) Close() {
+	t.mu.Lock()
+	t.closed = true
+	conns := make([]*Conn, 0, len(t.conns)+len(t.inbound))
+	for _, conn := range t.conns {
+		conns = append(conns, conn)
+	}
+	for conn := range t.inbound {
+		conns = append(conns, conn)
+	}
+	t.conns = make(map[string]*Conn)
+	t.mu.Unlock()
+
+	for _, conn := range conns {
+		conn.Close()
+	}
+}
+
+// =============================================================================
+
+// conn returns the connection to the peer, connecting to the peer if there
+// isn't a connection yet. Only one connection is opened to a peer at a time.
+func (t *Transport) conn(ctx context.Context, host string) (*Conn, error) {
+	for {
+		t.mu.Lock()
+
+		if t.closed {
+			t.mu.Unlock()
+			return nil, fmt.Errorf("%w: %w", ErrUnavailable, ErrClosed)
+		}
+
+		if conn, exists := t.conns[host]; exists {
+			t.mu.Unlock()
+			return conn, nil
+		}
+
+		if at, exists := t.failed[host]; exists && time.Since(at) < t.cfg.RetryAfter {
+			t.mu.Unlock()
+			return nil, fmt.Errorf("%w: waiting to retry", ErrUnavailable)
+		}
+
+		// Wait for another caller that is already connecting to this peer.
+		if ch, exists := t.dialing[host]; exists {
+			t.mu.Unlock()
+
+			select {
+			case <-ch:
+				continue
+			case <-ctx.Done():
+				return nil, fmt.Errorf("%w: %w", ErrUnavailable, ctx.Err())
+			}
+		}
+
+		ch := make(chan struct{})
+		t.dialing[host] = ch
+		t.mu.Unlock()
+
+		conn, err := t.dial(ctx, host)
+
+		t.mu.Lock()
+		delete(t.dialing, host)
+		close(ch)
+
+		switch {
+		case err != nil:
+			t.failed[host] = time.Now()
+		case t.closed:
+			err = ErrClosed
+		default:
+			t.conns[host] = conn
+		}
+		t.mu.Unlock()
+
+		if err != nil {
+			if conn != nil {
+				conn.Close()
+			}
+			return nil, fmt.Errorf("%w: %w", ErrUnavailable, err)
+		}
+
+		go func() {
+			<-conn.Done()
+			t.forget(host, conn)
+			t.cfg.EvHandler("transport: disconnected: peer[%s]: %s", host, conn.closeReason)
+		}()
+
+		t.cfg.EvHandler("transport: connected: peer[%s]", host)
+
+		return conn, nil
+	}
+}
+
+// dial opens a connection to the peer.
+func (t *Transport) dial(ctx context.Context, host string) (*Conn, error) {
+	url := fmt.Sprintf("ws://%s%s", host, StreamPath)
+
+	// The request that opens the connection is signed like any other
+	// request so the peer knows who is connecting.
+	header := make(http.Header)
+	if t.cfg.Sign != nil {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		if err := t.cfg.Sign(req); err != nil {
+			return nil, err
+		}
+		header = req.Header
+	}
+
+	ws, resp, err := t.dialer.DialContext(ctx, url, header)
+	if err != nil {
+		if resp != nil {
+			return nil, fmt.Errorf("%w: %s", err, resp.Status)
+		}
+		return nil, err
+	}
+
+	return newConn(ws, http.HandlerFunc(t.serve), t.cfg), nil
+}
+
+// serve passes the request received from a peer to the current handler so
+// connections opened before the handler is set can still serve requests.
+func (t *Transport) serve(w http.ResponseWriter, r *http.Request) {
+	t.mu.Lock()
+	handler := t.handler
+	t.mu.Unlock()
+
+	if handler == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// forget removes the connection for the host if it is still the current one.
+func (t *Transport) forget(host string, conn *Conn) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.conns[host] == conn {
+		delete(t.conns, host)
+	}
+}
@@ -0,0 +1,262 @@
+package transport_test
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/transport"
+)
+
+// newNode starts a server that accepts peer connections and serves requests
+// with the specified handler, both over HTTP and the peer connection.
+func newNode(t *testing.T, tr *transport.Transport, handler http.Handler) string {
+	tr.SetHandler(handler)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(transport.StreamPath, func(w http.ResponseWriter, r *http.Request) {
+		tr.Accept(w, r, r.Header.Get("X-Test-Host"))
+	})
+	mux.Handle("/", handler)
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	t.Cleanup(tr.Close)
+
+	return strings.TrimPrefix(srv.URL, "http://")
+}
+
+func Test_Do(t *testing.T) {
+	echo := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, "%s %s %s %s", r.Method, r.URL.Path, r.Header.Get("X-Test"), body)
+	})
+
+	server := transport.New(transport.Config{})
+	host := newNode(t, server, echo)
+
+	client := transport.New(transport.Config{})
+	t.Cleanup(client.Close)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			req := transport.Request{
+				Method: http.MethodPost,
+				Path:   fmt.Sprintf("/v1/node/%d", i),
+				Header: http.Header{"X-Test": []string{"header"}},
+				Body:   []byte(fmt.Sprintf("body%d", i)),
+			}
+
+			resp, err := client.Do(context.Background(), host, req)
+			if err != nil {
+				t.Errorf("Should be able to send request %d: %s", i, err)
+				return
+			}
+
+			exp := fmt.Sprintf("POST /v1/node/%d header body%d", i, i)
+			if resp.Status != http.StatusCreated || string(resp.Body) != exp {
+				t.Errorf("Should get back the response for request %d: got %d %q", i, resp.Status, resp.Body)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func Test_Bidirectional(t *testing.T) {
+	server := transport.New(transport.Config{})
+	host := newNode(t, server, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	// The client connects to the server, which registers the connection for
+	// the client's host so it can send requests back over it.
+	dialer := transport.New(transport.Config{
+		Sign: func(r *http.Request) error {
+			r.Header.Set("X-Test-Host", "client")
+			return nil
+		},
+	})
+	dialer.SetHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "from client")
+	}))
+	t.Cleanup(dialer.Close)
+
+	if _, err := dialer.Do(context.Background(), host, transport.Request{Method: http.MethodGet, Path: "/"}); err != nil {
+		t.Fatalf("Should be able to send a request to the server: %s", err)
+	}
+
+	resp, err := server.Do(context.Background(), "client", transport.Request{Method: http.MethodGet, Path: "/"})
+	if err != nil {
+		t.Fatalf("Should be able to send a request back over the connection: %s", err)
+	}
+	if string(resp.Body) != "from client" {
+		t.Fatalf("Should get back the response from the client: got %q", resp.Body)
+	}
+}
+
+func Test_Backpressure(t *testing.T) {
+	var running, most atomic.Int32
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := running.Add(1)
+		defer running.Add(-1)
+
+		for {
+			m := most.Load()
+			if n <= m || most.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	})
+
+	const maxInFlight = 2
+
+	server := transport.New(transport.Config{MaxInFlight: maxInFlight})
+	host := newNode(t, server, slow)
+
+	client := transport.New(transport.Config{QueueSize: 1})
+	t.Cleanup(client.Close)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := client.Do(context.Background(), host, transport.Request{Method: http.MethodGet, Path: "/"}); err != nil {
+				t.Errorf("Should be able to send the request: %s", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if m := most.Load(); m > maxInFlight {
+		t.Fatalf("Should serve at most %d requests at the same time, got %d", maxInFlight, m)
+	}
+}
+
+func Test_Unavailable(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	t.Cleanup(srv.Close)
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	client := transport.New(transport.Config{})
+	t.Cleanup(client.Close)
+
+	for i := 0; i < 2; i++ {
+		_, err := client.Do(context.Background(), host, transport.Request{Method: http.MethodGet, Path: "/"})
+		if !errors.Is(err, transport.ErrUnavailable) {
+			t.Fatalf("Should report the peer unavailable so HTTP can be used, got: %v", err)
+		}
+	}
+}
+
+func Test_RepeatedReply(t *testing.T) {
+	// The peer answers the first request several times over.
+	var upgrader websocket.Upgrader
+	mux := http.NewServeMux()
+	mux.HandleFunc(transport.StreamPath, func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer ws.Close()
+
+		for n := 0; ; n++ {
+			_, msg, err := ws.ReadMessage()
+			if err != nil {
+				return
+			}
+
+			var req struct{ ID uint64 }
+			if err := json.Unmarshal(msg[4:4+binary.BigEndian.Uint32(msg)], &req); err != nil {
+				return
+			}
+
+			hdr := fmt.Sprintf(`{"id":%d,"reply":true,"status":200}`, req.ID)
+			reply := binary.BigEndian.AppendUint32(nil, uint32(len(hdr)))
+			reply = append(reply, hdr...)
+
+			times := 1
+			if n == 0 {
+				times = 3
+			}
+			for range times {
+				if err := ws.WriteMessage(websocket.BinaryMessage, reply); err != nil {
+					return
+				}
+			}
+		}
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	client := transport.New(transport.Config{})
+	t.Cleanup(client.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Do(ctx, host, transport.Request{Method: http.MethodGet, Path: "/"})
+		if err != nil || resp.Status != http.StatusOK {
+			t.Fatalf("Should get the reply to request %d despite the repeated reply: %v", i, err)
+		}
+	}
+}
+
+func Test_MaxInbound(t *testing.T) {
+	server := transport.New(transport.Config{MaxInbound: 1})
+	server.SetHandler(http.NotFoundHandler())
+	t.Cleanup(server.Close)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := server.Accept(w, r, ""); errors.Is(err, transport.ErrTooManyConns) {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	first, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Should accept the first connection: %s", err)
+	}
+
+	_, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	if err == nil || resp == nil || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("Should refuse a connection over the max, got: %v", err)
+	}
+
+	// Once the first connection is closed there is room again.
+	first.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		ws, _, err := websocket.DefaultDialer.Dial(url, nil)
+		if err == nil {
+			ws.Close()
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Should accept a connection once there is room: %s", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
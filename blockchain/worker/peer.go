@@ -5,10 +5,11 @@ import "github.com/zacksfF/FullStack-Blockchain/blockchain/peer"
 // CORE NOTE: The p2p network is managed by this goroutine. There is
 // a single node that is considered the origin node. The defaults in
 // main.go represent the origin node. That node must be running first.
-// All new peer nodes connect to the origin node to identify all other
-// peers on the network. The topology is all nodes having a connection
-// to all other nodes. If a node does not respond to a network call,
-// they are removed from the peer list until the next peer operation.
+// All new peer nodes connect to the origin node and then discover other
+// peers by walking a Kademlia style routing table keyed by node id, so
+// no node has to share its full list of peers. If a node does not
+// respond to a network call, they are removed from the peer list until
+// the next peer operation.
 
 // peerOperations handles finding new peers.
 func (w *Worker) peerOperations() {
@@ -40,45 +41,56 @@ func (w *Worker) runPeersOperation() {
 	w.evHandler("worker: runPeersOperation: started")
 	defer w.evHandler("worker: runPeersOperation: completed")
 
-	for _, peer := range w.state.KnownExternalPeers() {
+	for _, pr := range w.state.KnownExternalPeers() {
+
+		// A peer whose node id hasn't been proved by a handshake to its
+		// host, like a peer that connected to this node, is handshaked
+		// first so its host can be bound to its node id. A peer that can't
+		// be handshaked has no score yet, so it's simply removed.
+		if !w.state.IsIdentifiedPeer(pr) {
+			if _, err := w.state.NetHandshake(pr); err != nil {
+				w.evHandler("worker: runPeersOperation: handshake: %s: ERROR: %s", pr.Host, err)
+				w.state.RemoveKnownPeer(pr)
+				continue
+			}
+		}
 
 		// Retrieve the status of this peer.
-		peerStatus, err := w.state.NetRequestPeerStatus(peer)
-		if err != nil {
-			w.evHandler("worker: runPeersOperation: requestPeerStatus: %s: ERROR: %s", peer.Host, err)
-
-			// Since this peer is unavailable, remove them from the list.
-			w.state.RemoveKnownPeer(peer)
+		if _, err := w.state.NetRequestPeerStatus(pr); err != nil {
+			w.evHandler("worker: runPeersOperation: requestPeerStatus: %s: ERROR: %s", pr.Host, err)
 
-			continue
+			// Since this peer is unavailable, remove them from the list. The
+			// score is remembered so a peer that keeps failing gets banned.
+			w.state.ScorePeer(pr, peer.ScoreTimeout)
+			w.state.RemoveKnownPeer(pr)
 		}
+	}
 
-		// Add peers from this nodes peer list that we are missing.
-		w.addNewPeers(peerStatus.KnownPeers)
+	// Make room for a better peer when the slots are full.
+	if pr, rotated := w.state.RotatePeers(); rotated {
+		w.evHandler("worker: runPeersOperation: rotated out peer: %s", pr.Host)
 	}
 
+	// Look for peers this node doesn't know about yet.
+	w.discoverPeers()
+
 	// Share with peers this node is available to participate in the network.
 	w.state.NetSendNodeAvailableToPeers()
-}
 
-// addNewPeers takes the list of known peers and makes sure they are included
-// in the nodes list of know peers.
-func (w *Worker) addNewPeers(knownPeers []peer.Peer) error {
-	w.evHandler("worker: runPeerUpdatesOperation: addNewPeers: started")
-	defer w.evHandler("worker: runPeerUpdatesOperation: addNewPeers: completed")
-
-	for _, peer := range knownPeers {
-
-		// Don't add this running node to the known peer list.
-		if peer.Match(w.state.Host()) {
-			continue
-		}
+	// Save what was learned about the peers in case the node goes down.
+	if err := w.state.SavePeers(); err != nil {
+		w.evHandler("worker: runPeersOperation: savePeers: ERROR: %s", err)
+	}
+}
 
-		// Only log when the peer is new.
-		if w.state.AddKnownPeer(peer) {
-			w.evHandler("worker: runPeerUpdatesOperation: addNewPeers: add peer nodes: adding peer-node %s", peer.Host)
-		}
+// discoverPeers walks the network to find new peers, which fills the known
+// peer list with peers from every part of the network.
+func (w *Worker) discoverPeers() {
+	added, err := w.state.Discover()
+	if err != nil {
+		w.evHandler("worker: discoverPeers: ERROR: %s", err)
+		return
 	}
 
-	return nil
+	w.evHandler("worker: discoverPeers: added peers[%d]", added)
 }
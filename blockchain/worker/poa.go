@@ -12,6 +12,7 @@ import (
 )
 
 // cycleDuration sets the mining operation to happen every 12 seconds
+// unless the worker is configured with a different cycle.
 const secondsPerCycle = 12
 const cycleDuration = secondsPerCycle * time.Second
 
@@ -20,10 +21,10 @@ func (w *Worker) poaOperations() {
 	w.evHandler("worker: poaOperations: G started")
 	defer w.evHandler("worker: poaOperations: G completed")
 
-	ticker := time.NewTicker(cycleDuration)
+	ticker := time.NewTicker(w.cycle)
 
-	// Start this on a secondsPerCycle mark: ex. MM.00, MM.12, MM.24, MM.36.
-	resetTicker(ticker, secondsPerCycle*time.Second)
+	// Start this on a cycle mark: ex. MM.00, MM.12, MM.24, MM.36.
+	resetTicker(ticker, w.cycle, w.cycle)
 
 	for {
 		select {
@@ -37,7 +38,7 @@ func (w *Worker) poaOperations() {
 		}
 
 		// Reset the ticker for the next cycle.
-		resetTicker(ticker, 0)
+		resetTicker(ticker, w.cycle, 0)
 	}
 }
 
@@ -115,6 +116,8 @@ func (w *Worker) runPoaOperation() {
 			switch {
 			case errors.Is(err, state.ErrNoTransactions):
 				w.evHandler("worker: runMiningOperation: MINING: WARNING: no transactions in mempool")
+			case errors.Is(err, state.ErrMiningOff):
+				w.evHandler("worker: runMiningOperation: MINING: turned off")
 			case ctx.Err() != nil:
 				w.evHandler("worker: runMiningOperation: MINING: CANCEL: complete")
 			default:
@@ -163,8 +166,8 @@ func (w *Worker) selection() string {
 // =============================================================================
 
 // resetTicker makes sure the next tick happens on the described cadence.
-func resetTicker(ticker *time.Ticker, waitOnSecond time.Duration) {
-	nextTick := time.Now().Add(cycleDuration).Round(waitOnSecond)
+func resetTicker(ticker *time.Ticker, cycle time.Duration, waitOnSecond time.Duration) {
+	nextTick := time.Now().Add(cycle).Round(waitOnSecond)
 	diff := time.Until(nextTick)
 	ticker.Reset(diff)
 }
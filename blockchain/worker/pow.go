@@ -102,6 +102,8 @@ func (w *Worker) runPowOperation() {
 			switch {
 			case errors.Is(err, state.ErrNoTransactions):
 				w.evHandler("worker: runMiningOperation: MINING: WARNING: no transactions in mempool")
+			case errors.Is(err, state.ErrMiningOff):
+				w.evHandler("worker: runMiningOperation: MINING: turned off")
 			case ctx.Err() != nil:
 				w.evHandler("worker: runMiningOperation: MINING: CANCEL: complete")
 			default:
@@ -0,0 +1,39 @@
+package worker
+
+import "time"
+
+// pruneInterval represents the interval of dropping expired and stale
+// transactions from the mempool.
+const pruneInterval = time.Minute
+
+// pruneOperations handles removing transactions from the mempool that
+// can no longer be mined.
+func (w *Worker) pruneOperations() {
+	w.evHandler("worker: pruneOperations: G started")
+	defer w.evHandler("worker: pruneOperations: G completed")
+
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if !w.isShutdown() {
+				w.runPruneOperation()
+			}
+		case <-w.shut:
+			w.evHandler("worker: pruneOperations: received shut signal")
+			return
+		}
+	}
+}
+
+// runPruneOperation drops the expired and stale transactions.
+func (w *Worker) runPruneOperation() {
+	w.evHandler("worker: runPruneOperation: started")
+	defer w.evHandler("worker: runPruneOperation: completed")
+
+	if dropped := w.state.PruneMempool(); dropped > 0 {
+		w.evHandler("worker: runPruneOperation: dropped transactions[%d]", dropped)
+	}
+}
@@ -0,0 +1,27 @@
+package worker
+
+// maxBlockShareRequests represents the max number of pending block network
+// share requests that can be outstanding before share requests are dropped.
+// A dropped block still reaches the peers through the other nodes relaying
+// it or when the peers sync.
+const maxBlockShareRequests = 10
+
+// shareBlockOperations handles relaying blocks received from peers.
+func (w *Worker) shareBlockOperations() {
+	w.evHandler("worker: shareBlockOperations: G started")
+	defer w.evHandler("worker: shareBlockOperations: G completed")
+
+	for {
+		select {
+		case block := <-w.blockSharing:
+			if !w.isShutdown() {
+				if err := w.state.NetSendBlockToPeers(block); err != nil {
+					w.evHandler("worker: shareBlockOperations: WARNING: %s", err)
+				}
+			}
+		case <-w.shut:
+			w.evHandler("worker: shareBlockOperations: received shut signal")
+			return
+		}
+	}
+}
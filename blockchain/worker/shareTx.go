@@ -1,5 +1,7 @@
 package worker
 
+import "github.com/zacksfF/FullStack-Blockchain/blockchain/database"
+
 // maxTxShareRequests represents the max number of pending tx network share
 // requests that can be outstanding before share requests are dropped. To keep
 // this simple, a buffered channel of this arbitrary number is being used. If
@@ -7,7 +9,8 @@ package worker
 // will not be accepted.
 const maxTxShareRequests = 100
 
-// shareTxOperations handles sharing new block transactions.
+// shareTxOperations handles sharing new block transactions. The transactions
+// waiting to be shared are announced to the peers together.
 func (w *Worker) shareTxOperations() {
 	w.evHandler("worker: shareTxOperations: G started")
 	defer w.evHandler("worker: shareTxOperations: G completed")
@@ -16,7 +19,7 @@ func (w *Worker) shareTxOperations() {
 		select {
 		case tx := <-w.txSharing:
 			if !w.isShutdown() {
-				w.state.NetSendTxToPeers(tx)
+				w.state.NetSendTxToPeers(w.pendingTxs(tx))
 			}
 		case <-w.shut:
 			w.evHandler("worker: shareTxOperations: received shut signal")
@@ -24,3 +27,17 @@ func (w *Worker) shareTxOperations() {
 		}
 	}
 }
+
+// pendingTxs returns the specified transaction along with any other
+// transactions already waiting to be shared.
+func (w *Worker) pendingTxs(tx database.BlockTx) []database.BlockTx {
+	txs := []database.BlockTx{tx}
+	for {
+		select {
+		case tx := <-w.txSharing:
+			txs = append(txs, tx)
+		default:
+			return txs
+		}
+	}
+}
@@ -1,39 +1,56 @@
 package worker
 
+import (
+	"errors"
+
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/download"
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/peer"
+)
+
 // Sync updates the peer list, mempool and blocks.
 func (w *Worker) Sync() {
 	w.evHandler("worker: sync: started")
 	defer w.evHandler("worker: sync: completed")
 
-	for _, peer := range w.state.KnownExternalPeers() {
+	var sources []download.Source
+	for _, pr := range w.state.KnownExternalPeers() {
+
+		// Make sure this peer is running the same blockchain before
+		// anything is taken from it.
+		if _, err := w.state.NetHandshake(pr); err != nil {
+			w.evHandler("worker: sync: handshake: %s: ERROR: %s", pr.Host, err)
+			if errors.Is(err, peer.ErrHandshake) || errors.Is(err, peer.ErrPeerLimit) {
+				w.state.RemoveKnownPeer(pr)
+			}
+			continue
+		}
 
 		// Retrieve the status of this peer.
-		peerStatus, err := w.state.NetRequestPeerStatus(peer)
+		peerStatus, err := w.state.NetRequestPeerStatus(pr)
 		if err != nil {
-			w.evHandler("worker: sync: queryPeerStatus: %s: ERROR: %s", peer.Host, err)
+			w.evHandler("worker: sync: queryPeerStatus: %s: ERROR: %s", pr.Host, err)
+			w.state.ScorePeer(pr, peer.ScoreTimeout)
+		} else {
+			sources = append(sources, download.Source{Peer: pr, Height: peerStatus.LatestBlockNumber})
 		}
 
-		// Add new peers to this nodes list.
-		w.addNewPeers(peerStatus.KnownPeers)
-
 		// Retrieve the mempool from the peer.
-		pool, err := w.state.NetRequestPeerMempool(peer)
+		pool, err := w.state.NetRequestPeerMempool(pr)
 		if err != nil {
-			w.evHandler("worker: sync: retrievePeerMempool: %s: ERROR: %s", peer.Host, err)
+			w.evHandler("worker: sync: retrievePeerMempool: %s: ERROR: %s", pr.Host, err)
 		}
 		for _, tx := range pool {
-			w.evHandler("worker: sync: retrievePeerMempool: %s: Add Tx: %s", peer.Host, tx.SignatureString()[:16])
+			w.evHandler("worker: sync: retrievePeerMempool: %s: Add Tx: %s", pr.Host, tx.SignatureString()[:16])
 			w.state.UpsertMempool(tx)
 		}
+	}
 
-		// If this peer has blocks we don't have, we need to add them.
-		if peerStatus.LatestBlockNumber > w.state.LatestBlock().Header.Number {
-			w.evHandler("worker: sync: retrievePeerBlocks: %s: latestBlockNumber[%d]", peer.Host, peerStatus.LatestBlockNumber)
+	// Look for more peers through the peers that were just confirmed.
+	w.discoverPeers()
 
-			if err := w.state.NetRequestPeerBlocks(peer); err != nil {
-				w.evHandler("worker: sync: retrievePeerBlocks: %s: ERROR %s", peer.Host, err)
-			}
-		}
+	// Download the blocks we don't have from the peers that have them.
+	if err := w.state.SyncBlocks(sources); err != nil {
+		w.evHandler("worker: sync: syncBlocks: ERROR %s", err)
 	}
 
 	// Share with peers this node is available to participate in the network.
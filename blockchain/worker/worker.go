@@ -12,29 +12,57 @@ import (
 // and updating the blockchain on disk with missing blocks.
 const peerUpdateInterval = time.Second * 10
 
+// Config represents the settings for the worker. Any interval not set uses
+// its default.
+type Config struct {
+	PeerUpdateInterval time.Duration
+	CycleDuration      time.Duration
+	EvHandler          state.EventHandler
+}
+
 // Worker manages the POW workflows for the blockchain.
 type Worker struct {
 	state        *state.State
 	wg           sync.WaitGroup
-	ticker       time.Ticker
+	ticker       *time.Ticker
+	cycle        time.Duration
 	shut         chan struct{}
 	startMining  chan bool
 	cancelMining chan bool
 	txSharing    chan database.BlockTx
+	blockSharing chan database.Block
 	evHandler    state.EventHandler
 }
 
 // Run creates a worker, registers the worker with the state package, and
 // starts up all the background processes.
 func Run(st *state.State, evHandler state.EventHandler) {
+	RunWithConfig(st, Config{EvHandler: evHandler})
+}
+
+// RunWithConfig creates a worker with the specified intervals, registers the
+// worker with the state package, and starts up all the background processes.
+func RunWithConfig(st *state.State, cfg Config) {
+	if cfg.PeerUpdateInterval <= 0 {
+		cfg.PeerUpdateInterval = peerUpdateInterval
+	}
+	if cfg.CycleDuration <= 0 {
+		cfg.CycleDuration = cycleDuration
+	}
+	if cfg.EvHandler == nil {
+		cfg.EvHandler = func(v string, args ...any) {}
+	}
+
 	w := Worker{
 		state:        st,
-		ticker:       *time.NewTicker(peerUpdateInterval),
+		ticker:       time.NewTicker(cfg.PeerUpdateInterval),
+		cycle:        cfg.CycleDuration,
 		shut:         make(chan struct{}),
 		startMining:  make(chan bool, 1),
 		cancelMining: make(chan bool, 1),
 		txSharing:    make(chan database.BlockTx, maxTxShareRequests),
-		evHandler:    evHandler,
+		blockSharing: make(chan database.Block, maxBlockShareRequests),
+		evHandler:    cfg.EvHandler,
 	}
 
 	// Register this worker with the state package.
@@ -53,6 +81,8 @@ func Run(st *state.State, evHandler state.EventHandler) {
 	operations := []func(){
 		w.peerOperations,
 		w.shareTxOperations,
+		w.shareBlockOperations,
+		w.pruneOperations,
 		consensusOperation,
 	}
 
@@ -145,6 +175,17 @@ func (w *Worker) SignalShareTx(blockTx database.BlockTx) {
 	}
 }
 
+// SignalShareBlock signals a share block operation. If
+// maxBlockShareRequests signals exist in the channel, we won't send these.
+func (w *Worker) SignalShareBlock(block database.Block) {
+	select {
+	case w.blockSharing <- block:
+		w.evHandler("worker: SignalShareBlock: share block signaled")
+	default:
+		w.evHandler("worker: SignalShareBlock: queue full, block won't be shared.")
+	}
+}
+
 // =============================================================================
 
 // isShutdown is used to test if a shutdown has been signaled.
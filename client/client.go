@@ -0,0 +1,156 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/zacksfF/FullStack-Blockchain/core/web/errs"
+)
+
+// Package client provides typed access to the public and private APIs of a
+// node. The wallet uses it to talk to a node and nodes use it to talk to
+// their peers.
+
+// Doer sends a request and returns the response. A http.Client is a Doer.
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Config represents the settings for a client. The URL is the scheme and
+// host of the node, like http://localhost:8080. When Doer is nil, the
+// requests are sent with http.DefaultClient.
+type Config struct {
+	URL  string
+	Doer Doer
+}
+
+// Client provides the methods for calling the routes of a node. The public
+// and private routes are served on different hosts, so a client is only
+// able to call the routes of the host in its URL.
+type Client struct {
+	url  string
+	doer Doer
+}
+
+// New constructs a client for the node at the specified URL.
+func New(cfg Config) *Client {
+	if cfg.Doer == nil {
+		cfg.Doer = http.DefaultClient
+	}
+
+	return &Client{
+		url:  strings.TrimSuffix(cfg.URL, "/"),
+		doer: cfg.Doer,
+	}
+}
+
+// URL returns the URL of the node.
+func (c *Client) URL() string {
+	return c.url
+}
+
+// =============================================================================
+
+// Error represents an error response from the node.
+type Error struct {
+	StatusCode int
+	Message    string
+	Fields     map[string]string
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+
+	return fmt.Sprintf("%d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// StatusCode returns the status code of the error response from the node.
+// Zero is returned when the error isn't an error response.
+func StatusCode(err error) int {
+	var e *Error
+	if !errors.As(err, &e) {
+		return 0
+	}
+
+	return e.StatusCode
+}
+
+// =============================================================================
+
+// send is a helper function to send a request to the node. The data to send
+// is marshaled as JSON and the response is decoded into the data to receive.
+// A response with no content leaves the data to receive as it is.
+func (c *Client) send(ctx context.Context, method string, path string, dataSend any, dataRecv any) error {
+	var body io.Reader
+	if dataSend != nil {
+		data, err := json.Marshal(dataSend)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.url+path, body)
+	if err != nil {
+		return err
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.doer.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNoContent:
+		return nil
+	default:
+		return decodeError(resp)
+	}
+
+	if dataRecv != nil {
+		if err := json.NewDecoder(resp.Body).Decode(dataRecv); err != nil {
+			return fmt.Errorf("decoding response: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// decodeError reads the error response from the node. A body that isn't an
+// errs.Response is used as the message.
+func decodeError(resp *http.Response) error {
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	e := Error{
+		StatusCode: resp.StatusCode,
+	}
+
+	var er errs.Response
+	switch {
+	case json.Unmarshal(data, &er) == nil && er.Error != "":
+		e.Message = er.Error
+		e.Fields = er.Fields
+	default:
+		e.Message = strings.TrimSpace(string(data))
+	}
+
+	return &e
+}
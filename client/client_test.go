@@ -0,0 +1,497 @@
+package client_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/zacksfF/FullStack-Blockchain/app/services/nodes/handlers/routers"
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/database"
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/genesis"
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/peer"
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/state"
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/storage/memory"
+	"github.com/zacksfF/FullStack-Blockchain/client"
+	"github.com/zacksfF/FullStack-Blockchain/events"
+	"github.com/zacksfF/FullStack-Blockchain/nameservices"
+	"go.uber.org/zap"
+)
+
+const (
+	minerPrivateKey   = "8dc79feefd3b86e2f9991def0e5ccd9a5128e104682407b308594bc1032ac7f0"
+	kennedyPrivateKey = "9f332e3700d8fc2446eaf6d15034cf96e0c2745e40353deef032a5dbf1dfed93"
+
+	kennedyAccountID = database.AccountID("0xF01813E4B85e178A83e29B8E7bF26BD830a25f32")
+	edAccountID      = database.AccountID("0xa988b1866EaBF72B4c53b592c97aAD8e4b9bDCC0")
+
+	chainID = 1
+)
+
+func Test_Public(t *testing.T) {
+	st, public, _ := newNode(t)
+	ctx := context.Background()
+
+	gen, err := public.Genesis(ctx)
+	if err != nil {
+		t.Fatalf("Should be able to get the genesis: %s", err)
+	}
+	if gen.ChainID != chainID {
+		t.Fatalf("Should get the genesis of the node, got chain %d, exp %d", gen.ChainID, chainID)
+	}
+
+	signedTx := newSignedTx(t, 1)
+	if err := public.SubmitTransaction(ctx, signedTx); err != nil {
+		t.Fatalf("Should be able to submit the transaction: %s", err)
+	}
+
+	status, err := public.TxStatus(ctx, signedTx.TxHash())
+	if err != nil {
+		t.Fatalf("Should be able to get the transaction status: %s", err)
+	}
+	if status.Status != state.TxPending || status.Position == nil {
+		t.Fatalf("Should get the pending status, got %s", status.Status)
+	}
+
+	if _, err := st.MineNewBlock(ctx); err != nil {
+		t.Fatalf("Should be able to mine a block: %s", err)
+	}
+
+	accounts, err := public.Account(ctx, kennedyAccountID)
+	if err != nil {
+		t.Fatalf("Should be able to get the account: %s", err)
+	}
+	if accounts.LatestBlock != st.LatestBlock().Hash() {
+		t.Fatalf("Should get the latest block, got %q, exp %q", accounts.LatestBlock, st.LatestBlock().Hash())
+	}
+	if len(accounts.Accounts) != 1 || accounts.Accounts[0].Nonce != 1 {
+		t.Fatalf("Should get the account, got %+v", accounts.Accounts)
+	}
+
+	blocks, err := public.BlocksByAccount(ctx, edAccountID)
+	if err != nil {
+		t.Fatalf("Should be able to get the blocks: %s", err)
+	}
+	if len(blocks) != 1 || len(blocks[0].Transactions) != 1 {
+		t.Fatalf("Should get the block with the transaction, got %d blocks", len(blocks))
+	}
+}
+
+func Test_Explorer(t *testing.T) {
+	st, public, _ := newNode(t)
+	ctx := context.Background()
+
+	// Mine three blocks with a transaction in each.
+	signedTx := newSignedTx(t, 1)
+	for nonce := uint64(1); nonce <= 3; nonce++ {
+		tx := signedTx
+		if nonce > 1 {
+			tx = newSignedTx(t, nonce)
+		}
+		if err := st.UpsertWalletTransaction(tx); err != nil {
+			t.Fatalf("Should be able to submit the transaction: %s", err)
+		}
+		if _, err := st.MineNewBlock(ctx); err != nil {
+			t.Fatalf("Should be able to mine a block: %s", err)
+		}
+	}
+
+	// Walk the chain two blocks at a time by following the cursor.
+	var numbers []uint64
+	from := uint64(1)
+	for {
+		page, err := public.BlocksPage(ctx, from, 2)
+		if err != nil {
+			t.Fatalf("Should be able to get the page from %d: %s", from, err)
+		}
+		for _, block := range page.Blocks {
+			numbers = append(numbers, block.Number)
+		}
+		if page.Next == "" {
+			break
+		}
+		if from, err = strconv.ParseUint(page.Next, 10, 64); err != nil {
+			t.Fatalf("Should get a block number for the next page, got %q", page.Next)
+		}
+	}
+	if fmt.Sprint(numbers) != "[1 2 3]" {
+		t.Fatalf("Should get every block once in order, got %v", numbers)
+	}
+
+	block, err := public.Block(ctx, 1)
+	if err != nil {
+		t.Fatalf("Should be able to get the block by number: %s", err)
+	}
+	if block.TxCount != 1 || block.TxHashes[0] != signedTx.TxHash() {
+		t.Fatalf("Should get the transaction in the block, got %v", block.TxHashes)
+	}
+
+	byHash, err := public.BlockByHash(ctx, block.Hash)
+	if err != nil {
+		t.Fatalf("Should be able to get the block by hash: %s", err)
+	}
+	if byHash.Number != 1 {
+		t.Fatalf("Should get the block with the hash, got block %d", byHash.Number)
+	}
+
+	tx, err := public.Tx(ctx, signedTx.TxHash())
+	if err != nil {
+		t.Fatalf("Should be able to get the transaction: %s", err)
+	}
+	if tx.Status != state.TxMined || tx.BlockHash != block.Hash || tx.Index != 0 {
+		t.Fatalf("Should get the block of the transaction, got %+v", tx)
+	}
+	if tx.To != edAccountID || tx.Value != 1 {
+		t.Fatalf("Should get the transaction, got to %s value %d", tx.To, tx.Value)
+	}
+
+	if _, err := public.Block(ctx, 4); client.StatusCode(err) != http.StatusNotFound {
+		t.Fatalf("Should get not found for a block past the latest, got %v", err)
+	}
+	if _, err := public.BlockByHash(ctx, "0x01"); client.StatusCode(err) != http.StatusNotFound {
+		t.Fatalf("Should get not found for an unknown hash, got %v", err)
+	}
+	if _, err := public.BlocksPage(ctx, 1, 1000); client.StatusCode(err) != http.StatusBadRequest {
+		t.Fatalf("Should get bad request for a limit over the max, got %v", err)
+	}
+
+	page, err := public.BlocksPage(ctx, 10, 2)
+	if err != nil {
+		t.Fatalf("Should be able to get a page past the latest: %s", err)
+	}
+	if len(page.Blocks) != 0 || page.Next != "" {
+		t.Fatalf("Should get an empty last page, got %+v", page)
+	}
+}
+
+func Test_Errors(t *testing.T) {
+	_, public, private := newNode(t)
+	ctx := context.Background()
+
+	_, err := public.TxStatus(ctx, "0x01")
+	if client.StatusCode(err) != http.StatusNotFound {
+		t.Fatalf("Should get not found for an unknown transaction, got %v", err)
+	}
+
+	// The node refuses a transaction for another chain and the reason is
+	// decoded from the error response.
+	signedTx := newSignedTx(t, 1)
+	signedTx.ChainID = chainID + 1
+
+	err = public.SubmitTransaction(ctx, signedTx)
+	if client.StatusCode(err) != http.StatusBadRequest {
+		t.Fatalf("Should get bad request for a refused transaction, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "invalid chain id") {
+		t.Fatalf("Should get the reason the transaction was refused, got %q", err)
+	}
+
+	// The routes for peers need a signed request from a known node.
+	_, err = private.Status(ctx)
+	if client.StatusCode(err) != http.StatusUnauthorized {
+		t.Fatalf("Should get unauthorized for an unsigned request, got %v", err)
+	}
+
+	// The sync progress and webhook status are only for the operator, who
+	// signs with the key of the node.
+	_, err = private.SyncProgress(ctx)
+	if client.StatusCode(err) != http.StatusUnauthorized {
+		t.Fatalf("Should get unauthorized for an unsigned sync progress, got %v", err)
+	}
+
+	_, err = private.Webhooks(ctx)
+	if client.StatusCode(err) != http.StatusUnauthorized {
+		t.Fatalf("Should get unauthorized for unsigned webhooks, got %v", err)
+	}
+
+	privateKey, err := crypto.HexToECDSA(minerPrivateKey)
+	if err != nil {
+		t.Fatalf("Should be able to construct the private key: %s", err)
+	}
+	operator := client.New(client.Config{URL: private.URL(), Doer: signer{privateKey: privateKey}})
+
+	if _, err := operator.SyncProgress(ctx); err != nil {
+		t.Fatalf("Should be able to get the sync progress: %s", err)
+	}
+
+	hooks, err := operator.Webhooks(ctx)
+	if err != nil || len(hooks) != 0 {
+		t.Fatalf("Should get no webhooks for a node without any, got %v: %v", hooks, err)
+	}
+
+	_, err = operator.WebhookDeliveries(ctx, "backoffice")
+	if client.StatusCode(err) != http.StatusNotFound {
+		t.Fatalf("Should get not found for an unknown webhook, got %v", err)
+	}
+}
+
+func Test_Events(t *testing.T) {
+	st, public, _ := newNode(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	evts, err := public.Events(ctx)
+	if err != nil {
+		t.Fatalf("Should be able to subscribe to the events: %s", err)
+	}
+
+	// The node registers the subscription after the websocket is open, so
+	// it's given a moment before the block is mined.
+	if err := st.UpsertWalletTransaction(newSignedTx(t, 1)); err != nil {
+		t.Fatalf("Should be able to submit the transaction: %s", err)
+	}
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		st.MineNewBlock(ctx)
+	}()
+
+	for msg := range evts {
+		if strings.HasPrefix(msg, "viewer: block: ") {
+			cancel()
+			for range evts {
+			}
+			return
+		}
+	}
+
+	t.Fatal("Should get the event for the new block")
+}
+
+func Test_Subscribe(t *testing.T) {
+	st, public, _ := newNode(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := public.Subscribe(ctx, events.Filter{Topics: []string{"newThing"}}); client.StatusCode(err) != http.StatusBadRequest {
+		t.Fatalf("Should get bad request for an unknown topic, got %v", err)
+	}
+
+	filter := events.Filter{
+		Topics:   []string{events.TopicNewPendingTx, events.TopicTxMined},
+		Accounts: []string{strings.ToLower(string(edAccountID))},
+	}
+	evts, err := public.Subscribe(ctx, filter)
+	if err != nil {
+		t.Fatalf("Should be able to subscribe to the events: %s", err)
+	}
+
+	// The node registers the subscription after the websocket is open, so
+	// it's given a moment before the transaction is submitted.
+	signedTx := newSignedTx(t, 1)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		st.UpsertWalletTransaction(signedTx)
+		st.MineNewBlock(ctx)
+	}()
+
+	var topics []string
+	for ev := range evts {
+		var tx state.TxEvent
+		if err := json.Unmarshal(ev.Data, &tx); err != nil {
+			t.Fatalf("Should be able to decode the event data: %s", err)
+		}
+		if tx.Hash != signedTx.TxHash() {
+			t.Fatalf("Should get the event for the transaction, got %s", tx.Hash)
+		}
+
+		topics = append(topics, ev.Topic)
+		if ev.Topic == events.TopicTxMined {
+			if tx.BlockNumber != 1 {
+				t.Fatalf("Should get the block of the transaction, got %d", tx.BlockNumber)
+			}
+			break
+		}
+	}
+
+	if fmt.Sprint(topics) != "[newPendingTx txMined]" {
+		t.Fatalf("Should only get the events for the topics, got %v", topics)
+	}
+}
+
+func Test_Resume(t *testing.T) {
+	st, public, _ := newNode(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// The events are published while nobody is subscribed.
+	if err := st.UpsertWalletTransaction(newSignedTx(t, 1)); err != nil {
+		t.Fatalf("Should be able to submit the transaction: %s", err)
+	}
+	if _, err := st.MineNewBlock(ctx); err != nil {
+		t.Fatalf("Should be able to mine a block: %s", err)
+	}
+
+	// A stream from before a restart starts over with a reset marker.
+	evts, err := public.Resume(ctx, events.Filter{}, "old", 1000)
+	if err != nil {
+		t.Fatalf("Should be able to resume an old stream: %s", err)
+	}
+	reset := <-evts
+	if reset.Topic != events.TopicReset || reset.Stream == "" || reset.Stream == "old" {
+		t.Fatalf("Should get a reset marker with the new stream, got %+v", reset)
+	}
+	if ev := <-evts; ev.Stream != reset.Stream || ev.Seq != 1 {
+		t.Fatalf("Should get the new stream from the start, got %+v", ev)
+	}
+
+	if _, err := public.Resume(ctx, events.Filter{}, reset.Stream, 1000); client.StatusCode(err) != http.StatusBadRequest {
+		t.Fatalf("Should get bad request for a sequence past the latest event, got %v", err)
+	}
+
+	evts, err = public.Resume(ctx, events.Filter{}, reset.Stream, 1)
+	if err != nil {
+		t.Fatalf("Should be able to resume the events: %s", err)
+	}
+
+	// Everything after the pending transaction is replayed in order.
+	exp := []string{events.TopicMiningStarted, events.TopicTxMined, events.TopicNewBlock}
+	for i, topic := range exp {
+		ev, open := <-evts
+		if !open {
+			t.Fatalf("Should get the event %d before the stream ends", i)
+		}
+		if ev.Seq != uint64(i+2) || ev.Topic != topic {
+			t.Fatalf("Should get %s with seq %d, got %s with seq %d", topic, i+2, ev.Topic, ev.Seq)
+		}
+	}
+}
+
+// =============================================================================
+
+// newNode starts the public and private routes of a node with funded
+// accounts and returns a client for each.
+func newNode(t *testing.T) (*state.State, *client.Client, *client.Client) {
+	privateKey, err := crypto.HexToECDSA(minerPrivateKey)
+	if err != nil {
+		t.Fatalf("Should be able to construct the private key: %s", err)
+	}
+
+	storage, err := memory.New()
+	if err != nil {
+		t.Fatalf("Should be able to construct the storage: %s", err)
+	}
+
+	evts := events.New()
+	t.Cleanup(evts.Shutdown)
+
+	st, err := state.New(state.Config{
+		BeneficiaryID: database.PublicKeyToAccountID(privateKey.PublicKey),
+		NodeKey:       privateKey,
+		Host:          "localhost:9080",
+		Storage:       storage,
+		Genesis: genesis.Genesis{
+			Date:          time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			ChainID:       chainID,
+			TransPerBlock: 10,
+			Difficulty:    1,
+			MiningReward:  700,
+			GasPrice:      15,
+			Balances: map[string]uint64{
+				string(kennedyAccountID): 1_000_000,
+			},
+		},
+		SelectStrategy: "Tip",
+		KnownPeers:     peer.NewPeerSet(),
+		EvHandler: func(v string, args ...any) {
+			if strings.HasPrefix(v, "viewer:") {
+				evts.Send(fmt.Sprintf(v, args...))
+			}
+		},
+		Publisher: evts.Publish,
+	})
+	if err != nil {
+		t.Fatalf("Should be able to construct the state: %s", err)
+	}
+	st.Worker = noopWorker{}
+
+	ns, err := nameservices.New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Should be able to construct the name service: %s", err)
+	}
+
+	cfg := routers.MuxConfig{
+		Shutdown: make(chan os.Signal, 1),
+		Log:      zap.NewNop().Sugar(),
+		State:    st,
+		NS:       ns,
+		Evts:     evts,
+	}
+
+	public := httptest.NewServer(routers.PublicMux(cfg))
+	t.Cleanup(public.Close)
+
+	private := httptest.NewServer(routers.PrivateMux(cfg))
+	t.Cleanup(private.Close)
+
+	return st, client.New(client.Config{URL: public.URL}), client.New(client.Config{URL: private.URL})
+}
+
+// signer sends the requests signed with the node key.
+type signer struct {
+	privateKey *ecdsa.PrivateKey
+}
+
+// Do implements the client.Doer interface.
+func (s signer) Do(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	if err := peer.SignRequest(req, body, s.privateKey); err != nil {
+		return nil, err
+	}
+
+	return http.DefaultClient.Do(req)
+}
+
+// newSignedTx signs a transaction from kennedy with the nonce.
+func newSignedTx(t *testing.T, nonce uint64) database.SignedTx {
+	privateKey, err := crypto.HexToECDSA(kennedyPrivateKey)
+	if err != nil {
+		t.Fatalf("Should be able to construct the private key: %s", err)
+	}
+
+	tx := database.Tx{
+		ChainID: chainID,
+		Nonce:   nonce,
+		FromID:  kennedyAccountID,
+		ToID:    edAccountID,
+		Value:   1,
+	}
+
+	signedTx, err := tx.Sign(privateKey)
+	if err != nil {
+		t.Fatalf("Should be able to sign the transaction: %s", err)
+	}
+
+	return signedTx
+}
+
+// noopWorker implements the Worker interface which does nothing.
+type noopWorker struct{}
+
+func (n noopWorker) Shutdown()                              {}
+func (n noopWorker) Sync()                                  {}
+func (n noopWorker) SignalStartMining()                     {}
+func (n noopWorker) SignalCancelMining()                    {}
+func (n noopWorker) SignalShareTx(blockTx database.BlockTx) {}
+func (n noopWorker) SignalShareBlock(block database.Block)  {}
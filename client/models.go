@@ -0,0 +1,126 @@
+package client
+
+import (
+	"encoding/json"
+
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/database"
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/download"
+)
+
+// Account represents the balance of an account on the node.
+type Account struct {
+	Account database.AccountID `json:"account"`
+	Name    string             `json:"name"`
+	Balance uint64             `json:"balance"`
+	Nonce   uint64             `json:"nonce"`
+}
+
+// Accounts represents the accounts on the node along with the latest block
+// the balances are for.
+type Accounts struct {
+	LatestBlock string    `json:"latest_block"`
+	Uncommitted int       `json:"uncommitted"`
+	Accounts    []Account `json:"accounts"`
+}
+
+// Tx represents a transaction as it's shown by the node. The proof is only
+// provided for transactions in a block.
+type Tx struct {
+	FromAccount database.AccountID `json:"from"`
+	FromName    string             `json:"from_name"`
+	To          database.AccountID `json:"to"`
+	ToName      string             `json:"to_name"`
+	ChainID     uint16             `json:"chain_id"`
+	Nonce       uint64             `json:"nonce"`
+	Value       uint64             `json:"value"`
+	Tip         uint64             `json:"tip"`
+	Data        []byte             `json:"data"`
+	ExpiryBlock uint64             `json:"expiry_block,omitempty"`
+	ExpiryTime  uint64             `json:"expiry_time,omitempty"`
+	TimeStamp   uint64             `json:"timestamp"`
+	GasPrice    uint64             `json:"gas_price"`
+	GasUnits    uint64             `json:"gas_units"`
+	Sig         string             `json:"sig"`
+	Proof       []string           `json:"proof"`
+	ProofOrder  []int64            `json:"proof_order"`
+}
+
+// Block represents a block as it's shown by the node.
+type Block struct {
+	Number        uint64             `json:"number"`
+	PrevBlockHash string             `json:"prev_block_hash"`
+	TimeStamp     uint64             `json:"timestamp"`
+	BeneficiaryID database.AccountID `json:"beneficiary"`
+	Difficulty    uint16             `json:"difficulty"`
+	MiningReward  uint64             `json:"mining_reward"`
+	StateRoot     string             `json:"state_root"`
+	TransRoot     string             `json:"trans_root"`
+	Nonce         uint64             `json:"nonce"`
+	Transactions  []Tx               `json:"txs"`
+}
+
+// TxStatus represents what the node knows about a transaction. The position
+// is only provided for a pending transaction.
+type TxStatus struct {
+	Hash          string `json:"hash"`
+	Status        string `json:"status"`
+	Position      *int   `json:"position,omitempty"`
+	BlockNumber   uint64 `json:"block_number,omitempty"`
+	BlockHash     string `json:"block_hash,omitempty"`
+	Confirmations uint64 `json:"confirmations,omitempty"`
+	ReplacedBy    string `json:"replaced_by,omitempty"`
+	Reason        string `json:"reason,omitempty"`
+}
+
+// SyncProgress represents the progress of the current or last sync of the
+// chain on the node.
+type SyncProgress struct {
+	Running bool   `json:"running"`
+	Peer    string `json:"best_peer"`
+	download.Progress
+}
+
+// BlockInfo represents a block as it's shown by the explorer routes, which
+// list the hashes of the transactions instead of the transactions.
+type BlockInfo struct {
+	Number        uint64             `json:"number"`
+	Hash          string             `json:"hash"`
+	PrevBlockHash string             `json:"prev_block_hash"`
+	TimeStamp     uint64             `json:"timestamp"`
+	BeneficiaryID database.AccountID `json:"beneficiary"`
+	Difficulty    uint16             `json:"difficulty"`
+	MiningReward  uint64             `json:"mining_reward"`
+	StateRoot     string             `json:"state_root"`
+	TransRoot     string             `json:"trans_root"`
+	Nonce         uint64             `json:"nonce"`
+	TxCount       int                `json:"tx_count"`
+	TxHashes      []string           `json:"tx_hashes"`
+}
+
+// BlockPage represents a page of blocks. Next is the block number to start
+// the following page from and is empty on the last page.
+type BlockPage struct {
+	Blocks []BlockInfo `json:"blocks"`
+	Next   string      `json:"next,omitempty"`
+}
+
+// TxInfo represents a mined transaction along with the block it's in.
+type TxInfo struct {
+	Hash        string `json:"hash"`
+	Status      string `json:"status"`
+	Reason      string `json:"reason,omitempty"`
+	BlockNumber uint64 `json:"block_number"`
+	BlockHash   string `json:"block_hash"`
+	Index       int    `json:"index"`
+	Tx
+}
+
+// Event represents a typed event from the node. The form of Data depends on
+// the topic of the event. Stream and Seq are used to resume after the event.
+type Event struct {
+	Stream   string          `json:"stream"`
+	Seq      uint64          `json:"seq"`
+	Topic    string          `json:"topic"`
+	Accounts []string        `json:"accounts"`
+	Data     json.RawMessage `json:"data"`
+}
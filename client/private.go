@@ -0,0 +1,172 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/database"
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/dht"
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/gossip"
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/peer"
+	"github.com/zacksfF/FullStack-Blockchain/webhook"
+)
+
+// The routes under /v1/node are only called by known peers and must be
+// signed with the node key of the caller, which is up to the Doer. The
+// sync progress and webhook status can be read by anyone. The
+// stream route is left to the transport package, which owns the persistent
+// connections.
+
+// Handshake sends the handshake of this node and returns the handshake of
+// the node being called.
+func (c *Client) Handshake(ctx context.Context, hs peer.Handshake) (peer.Handshake, error) {
+	var resp peer.Handshake
+	if err := c.send(ctx, http.MethodPost, "/v1/node/handshake", hs, &resp); err != nil {
+		return peer.Handshake{}, err
+	}
+
+	return resp, nil
+}
+
+// PeerScores returns the score and ban status of the peers of the node.
+func (c *Client) PeerScores(ctx context.Context) ([]peer.Score, error) {
+	var scores []peer.Score
+	if err := c.send(ctx, http.MethodGet, "/v1/node/peers/scores", nil, &scores); err != nil {
+		return nil, err
+	}
+
+	return scores, nil
+}
+
+// SyncProgress returns the progress of the current or last sync of the
+// chain on the node. The request must be signed with the key of the node or
+// a known peer.
+func (c *Client) SyncProgress(ctx context.Context) (SyncProgress, error) {
+	var progress SyncProgress
+	if err := c.send(ctx, http.MethodGet, "/v1/node/sync/progress", nil, &progress); err != nil {
+		return SyncProgress{}, err
+	}
+
+	return progress, nil
+}
+
+// Webhooks returns the delivery status of every webhook of the node. Like
+// the deliveries, the request must be signed with the key of the node or a
+// known peer.
+func (c *Client) Webhooks(ctx context.Context) ([]webhook.HookStatus, error) {
+	var statuses []webhook.HookStatus
+	if err := c.send(ctx, http.MethodGet, "/v1/node/webhooks", nil, &statuses); err != nil {
+		return nil, err
+	}
+
+	return statuses, nil
+}
+
+// WebhookDeliveries returns the pending deliveries of the webhook followed
+// by its most recent finished deliveries. A node without the webhook
+// responds with http.StatusNotFound.
+func (c *Client) WebhookDeliveries(ctx context.Context, id string) ([]webhook.Delivery, error) {
+	var deliveries []webhook.Delivery
+	if err := c.send(ctx, http.MethodGet, fmt.Sprintf("/v1/node/webhooks/%s/deliveries", id), nil, &deliveries); err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+// Status returns the latest block of the node.
+func (c *Client) Status(ctx context.Context) (peer.PeerStatus, error) {
+	var status peer.PeerStatus
+	if err := c.send(ctx, http.MethodGet, "/v1/node/status", nil, &status); err != nil {
+		return peer.PeerStatus{}, err
+	}
+
+	return status, nil
+}
+
+// Inventory announces the inventory to the node and returns the items the
+// node wants.
+func (c *Client) Inventory(ctx context.Context, inv []gossip.Inventory) ([]gossip.Inventory, error) {
+	var wanted []gossip.Inventory
+	if err := c.send(ctx, http.MethodPost, "/v1/node/inv", inv, &wanted); err != nil {
+		return nil, err
+	}
+
+	return wanted, nil
+}
+
+// FindNode returns the contacts the node knows closest to the target.
+func (c *Client) FindNode(ctx context.Context, target dht.ID) ([]dht.Contact, error) {
+	req := dht.FindRequest{
+		Target: target.String(),
+	}
+
+	var contacts []dht.Contact
+	if err := c.send(ctx, http.MethodPost, "/v1/node/dht/find", req, &contacts); err != nil {
+		return nil, err
+	}
+
+	return contacts, nil
+}
+
+// BlocksByNumber returns the blocks in the range. No blocks are returned
+// when the node doesn't have the blocks. The node only returns the leading
+// blocks that fit into one message, so the rest are asked for again.
+func (c *Client) BlocksByNumber(ctx context.Context, from uint64, to uint64) ([]database.BlockData, error) {
+	var blocks []database.BlockData
+	if err := c.send(ctx, http.MethodGet, fmt.Sprintf("/v1/node/block/list/%d/%d", from, to), nil, &blocks); err != nil {
+		return nil, err
+	}
+
+	return blocks, nil
+}
+
+// BlockHeadersByNumber returns the headers of the blocks in the range. The
+// node returns at most a fixed number of headers for a single call.
+func (c *Client) BlockHeadersByNumber(ctx context.Context, from uint64, to uint64) ([]database.BlockHeader, error) {
+	var headers []database.BlockHeader
+	if err := c.send(ctx, http.MethodGet, fmt.Sprintf("/v1/node/block/headers/%d/%d", from, to), nil, &headers); err != nil {
+		return nil, err
+	}
+
+	return headers, nil
+}
+
+// ProposeBlock sends the block to the node to be validated and added to
+// the chain.
+func (c *Client) ProposeBlock(ctx context.Context, block database.Block) error {
+	return c.send(ctx, http.MethodPost, "/v1/node/block/propose", database.NewBlockData(block), nil)
+}
+
+// ProposeCompactBlock sends the block in compact form to the node. The node
+// returns the indexes of the transactions it's missing to rebuild the block,
+// which is empty when the block was accepted.
+func (c *Client) ProposeCompactBlock(ctx context.Context, cb database.CompactBlock) ([]int, error) {
+	var resp struct {
+		Status  string `json:"status"`
+		Missing []int  `json:"missing"`
+	}
+	if err := c.send(ctx, http.MethodPost, "/v1/node/block/compact", cb, &resp); err != nil {
+		return nil, err
+	}
+
+	return resp.Missing, nil
+}
+
+// SubmitNodeTransaction adds the block transaction to the mempool of the
+// node.
+func (c *Client) SubmitNodeTransaction(ctx context.Context, tx database.BlockTx) error {
+	return c.send(ctx, http.MethodPost, "/v1/node/tx/submit", tx, nil)
+}
+
+// NodeMempool returns the transactions in the mempool of the node. The node
+// only returns its best transactions that fit into one message.
+func (c *Client) NodeMempool(ctx context.Context) ([]database.BlockTx, error) {
+	var txs []database.BlockTx
+	if err := c.send(ctx, http.MethodGet, "/v1/node/tx/list", nil, &txs); err != nil {
+		return nil, err
+	}
+
+	return txs, nil
+}
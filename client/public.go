@@ -0,0 +1,265 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/websocket"
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/database"
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/genesis"
+	"github.com/zacksfF/FullStack-Blockchain/events"
+)
+
+// Genesis returns the genesis information of the node.
+func (c *Client) Genesis(ctx context.Context) (genesis.Genesis, error) {
+	var gen genesis.Genesis
+	if err := c.send(ctx, http.MethodGet, "/v1/genesis/list", nil, &gen); err != nil {
+		return genesis.Genesis{}, err
+	}
+
+	return gen, nil
+}
+
+// Accounts returns the balances of every account on the node.
+func (c *Client) Accounts(ctx context.Context) (Accounts, error) {
+	var accounts Accounts
+	if err := c.send(ctx, http.MethodGet, "/v1/accounts/list", nil, &accounts); err != nil {
+		return Accounts{}, err
+	}
+
+	return accounts, nil
+}
+
+// Account returns the balance of the specified account.
+func (c *Client) Account(ctx context.Context, accountID database.AccountID) (Accounts, error) {
+	var accounts Accounts
+	if err := c.send(ctx, http.MethodGet, fmt.Sprintf("/v1/accounts/list/%s", accountID), nil, &accounts); err != nil {
+		return Accounts{}, err
+	}
+
+	return accounts, nil
+}
+
+// Blocks returns every block on the node.
+func (c *Client) Blocks(ctx context.Context) ([]Block, error) {
+	var blocks []Block
+	if err := c.send(ctx, http.MethodGet, "/v1/blocks/list", nil, &blocks); err != nil {
+		return nil, err
+	}
+
+	return blocks, nil
+}
+
+// BlocksByAccount returns the blocks with transactions from or to the
+// specified account.
+func (c *Client) BlocksByAccount(ctx context.Context, accountID database.AccountID) ([]Block, error) {
+	var blocks []Block
+	if err := c.send(ctx, http.MethodGet, fmt.Sprintf("/v1/blocks/list/%s", accountID), nil, &blocks); err != nil {
+		return nil, err
+	}
+
+	return blocks, nil
+}
+
+// BlocksPage returns up to limit blocks starting with the block number
+// from. The Next field of the page is used as from for the following page.
+func (c *Client) BlocksPage(ctx context.Context, from uint64, limit int) (BlockPage, error) {
+	var page BlockPage
+	if err := c.send(ctx, http.MethodGet, fmt.Sprintf("/v1/blocks?from=%d&limit=%d", from, limit), nil, &page); err != nil {
+		return BlockPage{}, err
+	}
+
+	return page, nil
+}
+
+// Block returns the block with the specified number. A node that doesn't
+// have the block responds with http.StatusNotFound.
+func (c *Client) Block(ctx context.Context, number uint64) (BlockInfo, error) {
+	var block BlockInfo
+	if err := c.send(ctx, http.MethodGet, fmt.Sprintf("/v1/blocks/%d", number), nil, &block); err != nil {
+		return BlockInfo{}, err
+	}
+
+	return block, nil
+}
+
+// BlockByHash returns the block with the specified hash. A node that doesn't
+// have the block responds with http.StatusNotFound.
+func (c *Client) BlockByHash(ctx context.Context, hash string) (BlockInfo, error) {
+	var block BlockInfo
+	if err := c.send(ctx, http.MethodGet, fmt.Sprintf("/v1/blocks/hash/%s", hash), nil, &block); err != nil {
+		return BlockInfo{}, err
+	}
+
+	return block, nil
+}
+
+// Mempool returns the uncommitted transactions on the node.
+func (c *Client) Mempool(ctx context.Context) ([]Tx, error) {
+	var txs []Tx
+	if err := c.send(ctx, http.MethodGet, "/v1/tx/uncommitted/list", nil, &txs); err != nil {
+		return nil, err
+	}
+
+	return txs, nil
+}
+
+// MempoolByAccount returns the uncommitted transactions from or to the
+// specified account.
+func (c *Client) MempoolByAccount(ctx context.Context, accountID database.AccountID) ([]Tx, error) {
+	var txs []Tx
+	if err := c.send(ctx, http.MethodGet, fmt.Sprintf("/v1/tx/uncommitted/list/%s", accountID), nil, &txs); err != nil {
+		return nil, err
+	}
+
+	return txs, nil
+}
+
+// SubmitTransaction adds the signed transaction to the mempool of the node.
+func (c *Client) SubmitTransaction(ctx context.Context, signedTx database.SignedTx) error {
+	return c.send(ctx, http.MethodPost, "/v1/tx/submit", signedTx, nil)
+}
+
+// TxStatus returns what the node knows about the transaction with the
+// specified hash. A node that doesn't know about the transaction responds
+// with http.StatusNotFound.
+func (c *Client) TxStatus(ctx context.Context, hash string) (TxStatus, error) {
+	var status TxStatus
+	if err := c.send(ctx, http.MethodGet, fmt.Sprintf("/v1/tx/status/%s", hash), nil, &status); err != nil {
+		return TxStatus{}, err
+	}
+
+	return status, nil
+}
+
+// Tx returns the mined transaction with the specified hash. A transaction
+// that isn't in a block yet responds with http.StatusNotFound.
+func (c *Client) Tx(ctx context.Context, hash string) (TxInfo, error) {
+	var info TxInfo
+	if err := c.send(ctx, http.MethodGet, fmt.Sprintf("/v1/tx/%s", hash), nil, &info); err != nil {
+		return TxInfo{}, err
+	}
+
+	return info, nil
+}
+
+// Events subscribes to the events of the node over a websocket. The channel
+// is closed when the context is cancelled or the websocket is closed.
+func (c *Client) Events(ctx context.Context) (<-chan string, error) {
+	ws, err := c.dial(ctx, "/v1/events")
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan string)
+
+	go func() {
+		defer close(ch)
+
+		for {
+			_, msg, err := ws.ReadMessage()
+			if err != nil {
+				return
+			}
+
+			select {
+			case ch <- string(msg):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+// Subscribe subscribes to the typed events of the node that match the
+// filter. The channel is closed when the context is cancelled or the
+// websocket is closed.
+func (c *Client) Subscribe(ctx context.Context, filter events.Filter) (<-chan Event, error) {
+	return c.subscribe(ctx, filter, "", nil)
+}
+
+// Resume is like Subscribe but first provides the events that match the
+// filter after the sequence number since in the stream. Events the node no
+// longer keeps are replaced by an event with the events.TopicGap topic. A
+// subscriber can resume again from one before the start of a gap to get
+// events it dropped for being too slow, while the node still keeps them.
+// When the node restarted since, the events start with an event with the
+// events.TopicReset topic, followed by the events of the new stream.
+func (c *Client) Resume(ctx context.Context, filter events.Filter, stream string, since uint64) (<-chan Event, error) {
+	return c.subscribe(ctx, filter, stream, &since)
+}
+
+// subscribe opens the stream of typed events, starting after the sequence
+// number since in the stream when it's provided.
+func (c *Client) subscribe(ctx context.Context, filter events.Filter, stream string, since *uint64) (<-chan Event, error) {
+	query := make(url.Values)
+	if len(filter.Topics) > 0 {
+		query.Set("topics", strings.Join(filter.Topics, ","))
+	}
+	if len(filter.Accounts) > 0 {
+		query.Set("accounts", strings.Join(filter.Accounts, ","))
+	}
+	if since != nil {
+		query.Set("stream", stream)
+		query.Set("since", strconv.FormatUint(*since, 10))
+	}
+
+	path := "/v1/events/stream"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	ws, err := c.dial(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan Event)
+
+	go func() {
+		defer close(ch)
+
+		for {
+			var ev Event
+			if err := ws.ReadJSON(&ev); err != nil {
+				return
+			}
+
+			select {
+			case ch <- ev:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+// dial opens a websocket to the path on the node. The websocket is closed
+// when the context is cancelled.
+func (c *Client) dial(ctx context.Context, path string) (*websocket.Conn, error) {
+	wsURL := "ws" + strings.TrimPrefix(c.url, "http") + path
+
+	ws, resp, err := websocket.DefaultDialer.DialContext(ctx, wsURL, nil)
+	if err != nil {
+		if resp != nil {
+			defer resp.Body.Close()
+			return nil, decodeError(resp)
+		}
+		return nil, err
+	}
+
+	go func() {
+		<-ctx.Done()
+		ws.Close()
+	}()
+
+	return ws, nil
+}
@@ -0,0 +1,72 @@
+package miidd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/database"
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/peer"
+	"github.com/zacksfF/FullStack-Blockchain/core/web/errs"
+	"github.com/zacksfF/FullStack-Blockchain/web2"
+)
+
+// maxBodySize is the largest body accepted from a node. It's the largest
+// valid block with room for the rest of the request.
+const maxBodySize = database.MaxBlockSize + 64<<10
+
+// nodeKey is how the node id of the caller is stored/retrieved.
+type nodeKey struct{}
+
+// GetNodeID returns the node id of the peer that signed the request.
+func GetNodeID(ctx context.Context) string {
+	nodeID, _ := ctx.Value(nodeKey{}).(string)
+	return nodeID
+}
+
+// Node verifies the request was signed by the node it claims to come from.
+// If known is provided, the node must also be a known peer.
+func Node(known func(nodeID string) bool) web2.Middleware {
+
+	// This is the actual middleware function to be executed.
+	m := func(handler web2.Handler) web2.Handler {
+
+		// Create the handler that will be attached in the middleware chain.
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+
+			// The body is part of the signature so it needs to be read and
+			// then replaced for the handler.
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+			if err != nil {
+				var maxErr *http.MaxBytesError
+				if errors.As(err, &maxErr) {
+					return errs.NewTrusted(fmt.Errorf("payload over %d bytes", maxErr.Limit), http.StatusRequestEntityTooLarge)
+				}
+				return fmt.Errorf("reading body: %w", err)
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			nodeID, err := peer.VerifyRequest(r, body, time.Now())
+			if err != nil {
+				return errs.NewTrusted(err, http.StatusUnauthorized)
+			}
+
+			if known != nil && !known(nodeID) {
+				return errs.NewTrusted(errors.New("node is not a known peer"), http.StatusForbidden)
+			}
+
+			ctx = context.WithValue(ctx, nodeKey{}, nodeID)
+
+			// Call the next handler.
+			return handler(ctx, w, r)
+		}
+
+		return h
+	}
+
+	return m
+}
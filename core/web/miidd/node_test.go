@@ -0,0 +1,143 @@
+package miidd_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/database"
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/peer"
+	"github.com/zacksfF/FullStack-Blockchain/core/web/errs"
+	"github.com/zacksfF/FullStack-Blockchain/core/web/miidd"
+)
+
+func Test_Node(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("Should be able to generate a key: %s", err)
+	}
+	nodeID := peer.NodeID(privateKey.PublicKey)
+
+	known := func(id string) bool { return id == nodeID }
+
+	tests := []struct {
+		name   string
+		known  func(id string) bool
+		req    func(t *testing.T) *http.Request
+		status int
+	}{
+		{
+			name:  "signed",
+			known: known,
+			req: func(t *testing.T) *http.Request {
+				return newRequest(t, privateKey, []byte(`{"ok":true}`))
+			},
+		},
+		{
+			name:  "badsignature",
+			known: known,
+			req: func(t *testing.T) *http.Request {
+				r := newRequest(t, privateKey, []byte(`{"ok":true}`))
+				sig := r.Header.Get(peer.SignatureHeader)
+				r.Header.Set(peer.SignatureHeader, sig[:len(sig)-4]+"0000")
+				return r
+			},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:  "expired",
+			known: known,
+			req: func(t *testing.T) *http.Request {
+				r := newRequest(t, privateKey, []byte(`{"ok":true}`))
+				old := time.Now().Add(-2 * peer.MaxClockSkew).Unix()
+				r.Header.Set(peer.TimeHeader, strconv.FormatInt(old, 10))
+				return r
+			},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:  "tamperedbody",
+			known: known,
+			req: func(t *testing.T) *http.Request {
+				r := newRequest(t, privateKey, []byte(`{"ok":true}`))
+				r.Body = io.NopCloser(strings.NewReader(`{"ok":false}`))
+				return r
+			},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:  "unknown",
+			known: func(id string) bool { return false },
+			req: func(t *testing.T) *http.Request {
+				return newRequest(t, privateKey, []byte(`{"ok":true}`))
+			},
+			status: http.StatusForbidden,
+		},
+		{
+			name:  "toolarge",
+			known: known,
+			req: func(t *testing.T) *http.Request {
+				return newRequest(t, privateKey, make([]byte, database.MaxBlockSize+128<<10))
+			},
+			status: http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for _, tst := range tests {
+		f := func(t *testing.T) {
+			var called bool
+			handler := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+				called = true
+
+				if id := miidd.GetNodeID(ctx); id != nodeID {
+					t.Fatalf("Should have the node id of the signer, got %q", id)
+				}
+				if body, err := io.ReadAll(r.Body); err != nil || string(body) != `{"ok":true}` {
+					t.Fatalf("Should be able to read the body again, got %q: %v", body, err)
+				}
+				return nil
+			}
+
+			err := miidd.Node(tst.known)(handler)(context.Background(), httptest.NewRecorder(), tst.req(t))
+
+			if tst.status == 0 {
+				if err != nil || !called {
+					t.Fatalf("Should call the handler for a signed request: %v", err)
+				}
+				return
+			}
+
+			if called {
+				t.Fatal("Should not call the handler.")
+			}
+			if trusted := errs.GetTrusted(err); trusted == nil || trusted.Status != tst.status {
+				t.Fatalf("Should fail with status %d, got: %v", tst.status, err)
+			}
+			if tst.name == "expired" && !strings.Contains(err.Error(), "skew") {
+				t.Fatalf("Should refuse the time of the request, got: %v", err)
+			}
+		}
+
+		t.Run(tst.name, f)
+	}
+}
+
+// =============================================================================
+
+// newRequest constructs a request with the body signed by the key.
+func newRequest(t *testing.T, privateKey *ecdsa.PrivateKey, body []byte) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/v1/node/inv", bytes.NewReader(body))
+	if err := peer.SignRequest(r, body, privateKey); err != nil {
+		t.Fatalf("Should be able to sign the request: %s", err)
+	}
+
+	return r
+}
@@ -0,0 +1,127 @@
+package events
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// The set of topics a typed event can be published under.
+const (
+	TopicNewBlock      = "newBlock"
+	TopicNewPendingTx  = "newPendingTx"
+	TopicTxMined       = "txMined"
+	TopicReorg         = "reorg"
+	TopicPeerAdded     = "peerAdded"
+	TopicMiningStarted = "miningStarted"
+)
+
+// TopicGap is the topic of the marker a subscriber gets in place of events
+// it missed. TopicReset is the topic of the marker a subscriber gets when it
+// resumes a stream that is gone. They can't be subscribed to since every
+// subscriber gets them.
+const (
+	TopicGap   = "gap"
+	TopicReset = "reset"
+)
+
+// Topics lists every topic a typed event can be published under.
+var Topics = []string{
+	TopicNewBlock,
+	TopicNewPendingTx,
+	TopicTxMined,
+	TopicReorg,
+	TopicPeerAdded,
+	TopicMiningStarted,
+}
+
+// Event represents something that happened on the node. Seq is assigned
+// when the event is published and increases by one with every event, so a
+// subscriber can resume after the last event it saw. The sequence starts
+// over when the node restarts, so Stream identifies the run of the node the
+// sequence number belongs to. Accounts lists the accounts the event is
+// about so subscribers can filter on them, and Data holds the details,
+// which depend on the topic.
+type Event struct {
+	Stream   string   `json:"stream"`
+	Seq      uint64   `json:"seq"`
+	Topic    string   `json:"topic"`
+	Accounts []string `json:"accounts,omitempty"`
+	Data     any      `json:"data"`
+}
+
+// Gap is the data of a gap marker. It holds the range of sequence numbers
+// that may include events the subscriber missed. The marker has the
+// sequence number of the end of the range, so resuming after it continues
+// past the gap.
+type Gap struct {
+	From uint64 `json:"from"`
+	To   uint64 `json:"to"`
+}
+
+// newGap constructs the marker for the range of sequence numbers.
+func newGap(stream string, from uint64, to uint64) Event {
+	return Event{
+		Stream: stream,
+		Seq:    to,
+		Topic:  TopicGap,
+		Data:   Gap{From: from, To: to},
+	}
+}
+
+// Reset is the data of a reset marker. It holds the stream the subscriber
+// tried to resume. The marker has the new stream and sequence number zero,
+// and the events that follow it start from the beginning of the new stream.
+type Reset struct {
+	Stream string `json:"stream"`
+}
+
+// newReset constructs the marker for resuming the old stream.
+func newReset(stream string, old string) Event {
+	return Event{
+		Stream: stream,
+		Topic:  TopicReset,
+		Data:   Reset{Stream: old},
+	}
+}
+
+// Filter represents the events a subscriber wants to receive. An empty list
+// of topics or accounts matches every topic or account.
+type Filter struct {
+	Topics   []string
+	Accounts []string
+}
+
+// Validate checks the filter only names known topics.
+func (f Filter) Validate() error {
+	for _, topic := range f.Topics {
+		if !slices.Contains(Topics, topic) {
+			return fmt.Errorf("unknown topic %q", topic)
+		}
+	}
+
+	return nil
+}
+
+// Match reports if the event is one the subscriber wants. An event that
+// isn't about any account doesn't match a filter on accounts. Accounts are
+// compared without regard to case so checksum and lowercase forms match.
+func (f Filter) Match(ev Event) bool {
+	if len(f.Topics) > 0 && !slices.Contains(f.Topics, ev.Topic) {
+		return false
+	}
+
+	if len(f.Accounts) == 0 {
+		return true
+	}
+
+	for _, want := range f.Accounts {
+		for _, account := range ev.Accounts {
+			if strings.EqualFold(want, account) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -1,82 +1,345 @@
 package events
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"sync"
+	"sync/atomic"
 )
 
+// Since a message will be dropped if the websocket receiver is not ready to
+// receive, this arbitrary buffer should give the receiver enough time to not
+// lose a message. Websocket send could take long.
+const messageBuffer = 100
+
+// DefaultReplaySize is the number of typed events kept so a subscriber can
+// resume after a disconnect.
+const DefaultReplaySize = 1024
+
+// Config represents the configuration for the events. Sink is called with
+// every typed event once it has its sequence number, in order, from its own
+// goroutine so a slow sink doesn't hold up Publish. Unlike a subscriber
+// it's never skipped, so Publish waits once ReplaySize events are waiting
+// for the sink.
+type Config struct {
+	ReplaySize int // Number of typed events kept for resuming. Zero uses DefaultReplaySize.
+	Sink       func(ev Event)
+}
+
+// subscriber represents a registered receiver of either the raw messages or
+// the typed events that match its filter. The gap fields hold the range of
+// sequence numbers dropped since the last event was delivered.
+type subscriber struct {
+	raw     chan string
+	typed   chan Event
+	filter  Filter
+	dropped atomic.Uint64
+	gapFrom uint64
+	gapTo   uint64
+}
+
 // Events maintains a mapping if unique id and channels so goroutines
-// can register and receive events.
+// can register and receive events. The latest typed events are kept in a
+// ring buffer indexed by their sequence number. The stream is a random id
+// for this run of the node so subscribers can tell a sequence number from
+// before a restart. Publish holds pubMu while it hands an event to the sink,
+// so the events reach the sink in order without holding mu when the sink is
+// full.
 type Events struct {
-	m  map[string]chan string
-	mu sync.RWMutex
+	m        map[string]*subscriber
+	mu       sync.RWMutex
+	pubMu    sync.Mutex
+	stream   string
+	seq      uint64
+	ring     []Event
+	sink     chan Event
+	sinkDone chan struct{}
 }
 
 // New Constructs an events for registering and receiving and receiving events
 func New() *Events {
-	return &Events{
-		m: make(map[string]chan string),
+	return NewWithConfig(Config{})
+}
+
+// NewWithConfig constructs an events that keeps the number of typed events
+// in the configuration for subscribers that resume.
+func NewWithConfig(cfg Config) *Events {
+	if cfg.ReplaySize <= 0 {
+		cfg.ReplaySize = DefaultReplaySize
+	}
+
+	evt := Events{
+		m:      make(map[string]*subscriber),
+		stream: newStreamID(),
+		ring:   make([]Event, cfg.ReplaySize),
 	}
+
+	if cfg.Sink != nil {
+		sink := make(chan Event, cfg.ReplaySize)
+		done := make(chan struct{})
+
+		go func() {
+			defer close(done)
+			for ev := range sink {
+				cfg.Sink(ev)
+			}
+		}()
+
+		evt.sink = sink
+		evt.sinkDone = done
+	}
+
+	return &evt
+}
+
+// Stream returns the id of the stream the sequence numbers belong to.
+func (evt *Events) Stream() string {
+	return evt.stream
 }
 
 // Acquire takes a unique id and returns a channel that can be used
-// to receive events.
+// to receive the raw messages provided to Send.
 func (evt *Events) Acquire(id string) chan string {
 	evt.mu.Lock()
 	defer evt.mu.Unlock()
 
-	ch, exists := evt.m[id]
-	if exists {
-		return ch
+	if sub, exists := evt.m[id]; exists {
+		return sub.raw
+	}
+
+	sub := subscriber{
+		raw: make(chan string, messageBuffer),
+	}
+	evt.m[id] = &sub
+
+	return sub.raw
+}
+
+// Subscribe takes a unique id and returns a channel that can be used to
+// receive the typed events provided to Publish that match the filter.
+func (evt *Events) Subscribe(id string, filter Filter) chan Event {
+	evt.mu.Lock()
+	defer evt.mu.Unlock()
+
+	if sub, exists := evt.m[id]; exists {
+		return sub.typed
+	}
+
+	sub := subscriber{
+		typed:  make(chan Event, messageBuffer),
+		filter: filter,
+	}
+	evt.m[id] = &sub
+
+	return sub.typed
+}
+
+// Resume is like Subscribe but also returns the events after the sequence
+// number since in the stream that match the filter. The events are returned
+// in order and the channel provides the events that follow, so nothing is
+// missed or seen twice. When some of those events are no longer kept, the
+// events returned start with a gap marker for them. When the stream isn't
+// the current one, like after a restart, the events returned start with a
+// reset marker and continue from the beginning of the current stream.
+func (evt *Events) Resume(id string, filter Filter, stream string, since uint64) (chan Event, []Event, error) {
+	evt.mu.Lock()
+	defer evt.mu.Unlock()
+
+	var backlog []Event
+	if stream != evt.stream {
+		backlog = append(backlog, newReset(evt.stream, stream))
+		since = 0
+	}
+
+	if since > evt.seq {
+		return nil, nil, fmt.Errorf("sequence %d is past the latest event %d", since, evt.seq)
+	}
+
+	if _, exists := evt.m[id]; exists {
+		return nil, nil, fmt.Errorf("id %q already exists", id)
+	}
+
+	size := uint64(len(evt.ring))
+
+	oldest := uint64(1)
+	if evt.seq > size {
+		oldest = evt.seq - size + 1
+	}
+
+	if since+1 < oldest {
+		backlog = append(backlog, newGap(evt.stream, since+1, oldest-1))
+		since = oldest - 1
+	}
+
+	for seq := since + 1; seq <= evt.seq; seq++ {
+		if ev := evt.ring[seq%size]; filter.Match(ev) {
+			backlog = append(backlog, ev)
+		}
 	}
 
-	// Since a message will be dropped if the websocket receiver is
-	// not ready to receive, this arbitrary buffer should give the receiver
-	// enough time to not lose a message. Websocket send could take long.
-	const messageBuffer = 100
+	sub := subscriber{
+		typed:  make(chan Event, messageBuffer),
+		filter: filter,
+	}
+	evt.m[id] = &sub
 
-	evt.m[id] = make(chan string, messageBuffer)
-	return evt.m[id]
+	return sub.typed, backlog, nil
 }
 
 // Release closes and removes the channel that was provided by
-// the call to Acquire.
+// the call to Acquire or Subscribe.
 func (evt *Events) Release(id string) error {
 	evt.mu.Lock()
 	defer evt.mu.Unlock()
 
-	ch, exists := evt.m[id]
+	sub, exists := evt.m[id]
 	if !exists {
 		return fmt.Errorf("id %q does not exist", id)
 	}
 
 	delete(evt.m, id)
-	close(ch)
+	sub.close()
 	return nil
 }
 
-// Send signals a message to ever registered channel. Send will not block
-// waiting for a receiver on any given channel.
+// Dropped returns the number of messages or events that were dropped for
+// the subscriber because its channel was full.
+func (evt *Events) Dropped(id string) uint64 {
+	evt.mu.RLock()
+	defer evt.mu.RUnlock()
+
+	sub, exists := evt.m[id]
+	if !exists {
+		return 0
+	}
+
+	return sub.dropped.Load()
+}
+
+// Send signals a message to ever channel registered with Acquire. Send will
+// not block waiting for a receiver on any given channel. A message that
+// can't be delivered is counted as dropped for that subscriber.
 func (evt *Events) Send(s string) {
 	evt.mu.RLock()
 	defer evt.mu.RUnlock()
 
-	for _, ch := range evt.m {
+	for _, sub := range evt.m {
+		if sub.raw == nil {
+			continue
+		}
+
 		select {
-		case ch <- s:
+		case sub.raw <- s:
 		default:
+			sub.dropped.Add(1)
+		}
+	}
+}
+
+// Publish assigns the event the next sequence number, keeps it for
+// subscribers that resume, hands it to the sink and signals it to every
+// channel registered with Subscribe whose filter matches the event. Like
+// Send, Publish will not block on a subscriber. An event that can't be
+// delivered is counted as dropped and the subscriber gets a gap marker for
+// it once there is room again.
+func (evt *Events) Publish(ev Event) {
+	evt.pubMu.Lock()
+	defer evt.pubMu.Unlock()
+
+	evt.mu.Lock()
+	evt.seq++
+	ev.Stream = evt.stream
+	ev.Seq = evt.seq
+	evt.ring[ev.Seq%uint64(len(evt.ring))] = ev
+
+	for _, sub := range evt.m {
+		if sub.typed == nil {
+			continue
+		}
+
+		if sub.gapTo != 0 && !sub.deliver(newGap(evt.stream, sub.gapFrom, sub.gapTo)) {
+			if sub.filter.Match(ev) {
+				sub.miss(ev.Seq)
+			}
+			continue
+		}
+		sub.gapFrom, sub.gapTo = 0, 0
+
+		if sub.filter.Match(ev) && !sub.deliver(ev) {
+			sub.miss(ev.Seq)
 		}
 	}
+
+	sink := evt.sink
+	evt.mu.Unlock()
+
+	// The sink can be full, so the event is handed to it without holding
+	// the lock that Subscribe, Release and Send need.
+	if sink != nil {
+		sink <- ev
+	}
 }
 
 // Shutdown closes and removes all channels that were provided by
-// the call to Acquire.
+// the call to Acquire or Subscribe. It waits for the sink to be handed
+// every event that was published.
 func (evt *Events) Shutdown() {
-	evt.mu.RLock()
-	defer evt.mu.RUnlock()
+	evt.mu.Lock()
 
-	for id, ch := range evt.m {
+	for id, sub := range evt.m {
 		delete(evt.m, id)
-		close(ch)
+		sub.close()
+	}
+
+	sink := evt.sink
+	evt.sink = nil
+	evt.mu.Unlock()
+
+	// Wait for an event being handed to the sink before closing it.
+	if sink != nil {
+		evt.pubMu.Lock()
+		close(sink)
+		evt.pubMu.Unlock()
+		<-evt.sinkDone
+	}
+}
+
+// newStreamID returns a random id for the stream.
+func newStreamID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
+
+// deliver sends the event to the subscriber without blocking and reports
+// if it was sent.
+func (sub *subscriber) deliver(ev Event) bool {
+	select {
+	case sub.typed <- ev:
+		return true
+	default:
+		return false
+	}
+}
+
+// miss records the event with the sequence number as dropped.
+func (sub *subscriber) miss(seq uint64) {
+	sub.dropped.Add(1)
+
+	if sub.gapFrom == 0 {
+		sub.gapFrom = seq
+	}
+	sub.gapTo = seq
+}
+
+// close closes the channel of the subscriber.
+func (sub *subscriber) close() {
+	if sub.raw != nil {
+		close(sub.raw)
+	}
+	if sub.typed != nil {
+		close(sub.typed)
 	}
 }
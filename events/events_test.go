@@ -0,0 +1,246 @@
+package events_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zacksfF/FullStack-Blockchain/events"
+)
+
+func Test_Filter(t *testing.T) {
+	type table struct {
+		name   string
+		filter events.Filter
+		ev     events.Event
+		match  bool
+	}
+
+	ev := events.Event{
+		Topic:    events.TopicTxMined,
+		Accounts: []string{"0xF01813E4B85e178A83e29B8E7bF26BD830a25f32"},
+	}
+
+	tt := []table{
+		{name: "empty", filter: events.Filter{}, ev: ev, match: true},
+		{name: "topic", filter: events.Filter{Topics: []string{events.TopicTxMined}}, ev: ev, match: true},
+		{name: "other topic", filter: events.Filter{Topics: []string{events.TopicNewBlock}}, ev: ev, match: false},
+		{name: "account case", filter: events.Filter{Accounts: []string{"0xf01813e4b85e178a83e29b8e7bf26bd830a25f32"}}, ev: ev, match: true},
+		{name: "other account", filter: events.Filter{Accounts: []string{"0xa988b1866EaBF72B4c53b592c97aAD8e4b9bDCC0"}}, ev: ev, match: false},
+		{name: "no accounts", filter: events.Filter{Accounts: []string{"0xF01813E4B85e178A83e29B8E7bF26BD830a25f32"}}, ev: events.Event{Topic: events.TopicReorg}, match: false},
+	}
+
+	for _, tst := range tt {
+		t.Run(tst.name, func(t *testing.T) {
+			if got := tst.filter.Match(tst.ev); got != tst.match {
+				t.Fatalf("Should get match %t, got %t", tst.match, got)
+			}
+		})
+	}
+
+	if err := (events.Filter{Topics: []string{"newThing"}}).Validate(); err == nil {
+		t.Fatal("Should not be able to use an unknown topic")
+	}
+}
+
+func Test_Dropped(t *testing.T) {
+	evts := events.New()
+	defer evts.Shutdown()
+
+	typed := evts.Subscribe("typed", events.Filter{Topics: []string{events.TopicNewBlock}})
+	raw := evts.Acquire("raw")
+
+	// The raw subscriber doesn't get typed events and the typed subscriber
+	// doesn't get the events outside its filter.
+	const buffer = 100
+	for range buffer + 5 {
+		evts.Publish(events.Event{Topic: events.TopicNewBlock})
+		evts.Publish(events.Event{Topic: events.TopicTxMined})
+	}
+
+	if len(typed) != buffer {
+		t.Fatalf("Should fill the buffer of the subscriber, got %d", len(typed))
+	}
+	if got := evts.Dropped("typed"); got != 5 {
+		t.Fatalf("Should count the events that didn't fit, got %d, exp 5", got)
+	}
+	if len(raw) != 0 || evts.Dropped("raw") != 0 {
+		t.Fatalf("Should not send typed events to a raw subscriber, got %d", len(raw))
+	}
+
+	evts.Send("viewer: message")
+	if len(raw) != 1 {
+		t.Fatalf("Should send the message to the raw subscriber, got %d", len(raw))
+	}
+
+	if err := evts.Release("typed"); err != nil {
+		t.Fatalf("Should be able to release the subscriber: %s", err)
+	}
+	if _, open := <-typed; !open {
+		t.Fatal("Should still be able to read the buffered events")
+	}
+}
+
+func Test_Shutdown(t *testing.T) {
+	evts := events.NewWithConfig(events.Config{Sink: func(ev events.Event) {}})
+
+	for i := range 100 {
+		evts.Subscribe(fmt.Sprintf("sub%d", i), events.Filter{})
+	}
+
+	// Shutdown removes the subscribers, so calls at the same time must not
+	// close a channel twice.
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			evts.Shutdown()
+		}()
+	}
+	wg.Wait()
+}
+
+func Test_Resume(t *testing.T) {
+	evts := events.NewWithConfig(events.Config{ReplaySize: 4})
+	defer evts.Shutdown()
+
+	for range 6 {
+		evts.Publish(events.Event{Topic: events.TopicNewBlock})
+	}
+
+	// The first two events are no longer kept so they are replaced by a gap.
+	_, backlog, err := evts.Resume("old", events.Filter{}, evts.Stream(), 0)
+	if err != nil {
+		t.Fatalf("Should be able to resume: %s", err)
+	}
+	if len(backlog) != 5 || backlog[0].Topic != events.TopicGap {
+		t.Fatalf("Should get a gap and the kept events, got %+v", backlog)
+	}
+	if gap := backlog[0].Data.(events.Gap); gap.From != 1 || gap.To != 2 {
+		t.Fatalf("Should get the missing range, got %+v", gap)
+	}
+	for i, ev := range backlog[1:] {
+		if ev.Seq != uint64(i+3) {
+			t.Fatalf("Should get the events in order, got seq %d at %d", ev.Seq, i)
+		}
+	}
+
+	ch, backlog, err := evts.Resume("new", events.Filter{}, evts.Stream(), 4)
+	if err != nil {
+		t.Fatalf("Should be able to resume: %s", err)
+	}
+	if len(backlog) != 2 || backlog[0].Seq != 5 || backlog[1].Seq != 6 {
+		t.Fatalf("Should get the events after since, got %+v", backlog)
+	}
+
+	evts.Publish(events.Event{Topic: events.TopicNewBlock})
+	if ev := <-ch; ev.Seq != 7 {
+		t.Fatalf("Should get the events that follow on the channel, got seq %d", ev.Seq)
+	}
+
+	if _, _, err := evts.Resume("future", events.Filter{}, evts.Stream(), 8); err == nil {
+		t.Fatal("Should not be able to resume past the latest event")
+	}
+
+	// A stream from before a restart gets a reset marker and the kept
+	// events of the current stream, whatever the sequence number.
+	_, backlog, err = evts.Resume("restarted", events.Filter{}, "old", 100)
+	if err != nil {
+		t.Fatalf("Should be able to resume an old stream: %s", err)
+	}
+	if len(backlog) != 6 || backlog[0].Topic != events.TopicReset || backlog[1].Topic != events.TopicGap {
+		t.Fatalf("Should get a reset, a gap and the kept events, got %+v", backlog)
+	}
+	if reset := backlog[0].Data.(events.Reset); reset.Stream != "old" || backlog[0].Stream != evts.Stream() {
+		t.Fatalf("Should get the old and new streams, got %+v", backlog[0])
+	}
+	if backlog[5].Stream != evts.Stream() || backlog[5].Seq != 7 {
+		t.Fatalf("Should get the events of the current stream, got %+v", backlog[5])
+	}
+}
+
+func Test_Gap(t *testing.T) {
+	evts := events.New()
+	defer evts.Shutdown()
+
+	ch := evts.Subscribe("slow", events.Filter{})
+
+	// Overflow the buffer of the subscriber then make room for more.
+	const buffer = 100
+	for range buffer + 5 {
+		evts.Publish(events.Event{Topic: events.TopicNewBlock})
+	}
+	for range buffer {
+		<-ch
+	}
+
+	evts.Publish(events.Event{Topic: events.TopicNewBlock})
+
+	ev := <-ch
+	if ev.Topic != events.TopicGap {
+		t.Fatalf("Should get a gap for the dropped events, got %s", ev.Topic)
+	}
+	if gap := ev.Data.(events.Gap); gap.From != buffer+1 || gap.To != buffer+5 {
+		t.Fatalf("Should get the range that was dropped, got %+v", gap)
+	}
+
+	if ev := <-ch; ev.Seq != buffer+6 {
+		t.Fatalf("Should get the next event after the gap, got seq %d", ev.Seq)
+	}
+}
+
+func Test_Sink(t *testing.T) {
+	var seqs []uint64
+	release := make(chan struct{})
+	evts := events.NewWithConfig(events.Config{
+		ReplaySize: 4,
+		Sink: func(ev events.Event) {
+			<-release
+			seqs = append(seqs, ev.Seq)
+		},
+	})
+
+	// The sink gets every event even when no subscriber wants it, and a
+	// sink that is behind doesn't hold up Publish.
+	evts.Subscribe("none", events.Filter{Topics: []string{events.TopicReorg}})
+	for range 3 {
+		evts.Publish(events.Event{Topic: events.TopicNewBlock})
+	}
+
+	// Once the sink is full Publish waits, but subscribers can still come
+	// and go.
+	published := make(chan struct{})
+	go func() {
+		defer close(published)
+		for range 3 {
+			evts.Publish(events.Event{Topic: events.TopicNewBlock})
+		}
+	}()
+
+	// Give the publisher time to fill the sink.
+	time.Sleep(100 * time.Millisecond)
+
+	subscribed := make(chan struct{})
+	go func() {
+		defer close(subscribed)
+		evts.Subscribe("late", events.Filter{})
+		evts.Release("late")
+	}()
+
+	select {
+	case <-subscribed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Should be able to subscribe while the sink is full.")
+	}
+
+	// Shutdown waits for the sink to get every event.
+	close(release)
+	<-published
+	evts.Shutdown()
+
+	if len(seqs) != 6 || seqs[0] != 1 || seqs[5] != 6 {
+		t.Fatalf("Should give the sink every event in order, got %v", seqs)
+	}
+}
@@ -0,0 +1,217 @@
+package webhook
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// The set of operations that can be recorded in the journal.
+const (
+	opQueue  = "queue"
+	opUpdate = "update"
+)
+
+// minCompactRecords is the minimum number of records that need to be written
+// before the journal is compacted back down to the pending deliveries.
+const minCompactRecords = 1000
+
+// record represents a single change made to a delivery. The payload is only
+// recorded when the delivery is queued since it never changes.
+type record struct {
+	Op       string          `json:"op"`
+	Delivery Delivery        `json:"delivery"`
+	Payload  json.RawMessage `json:"payload,omitempty"`
+}
+
+// journal maintains an append only file of the changes made to deliveries.
+type journal struct {
+	path    string
+	file    *os.File
+	records int
+}
+
+// openJournal opens the journal at the specified path for appending,
+// creating the file and any missing folders.
+func openJournal(path string) (*journal, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	return &journal{path: path, file: f}, nil
+}
+
+// write appends the record to the end of the journal.
+func (j *journal) write(rec record) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	if _, err := j.file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	if err := j.file.Sync(); err != nil {
+		return err
+	}
+	j.records++
+
+	return nil
+}
+
+// load reads the journal and applies each record in order, returning the
+// deliveries that were still pending when the journal was last written as
+// records that queue them. A partial record at the end of the file from a
+// crash is ignored, any other record that can't be read fails the load.
+func (j *journal) load() ([]record, error) {
+	f, err := os.Open(j.path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	pending := make(map[string]record)
+	var order []string
+
+	// A record that can't be read is only an error once another record
+	// follows it, otherwise it was the last write before a crash.
+	var partial error
+	var line int
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if partial != nil {
+			return nil, fmt.Errorf("corrupt journal record on line %d: %w", line, partial)
+		}
+		line++
+
+		var rec record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			partial = err
+			continue
+		}
+
+		switch rec.Op {
+		case opQueue:
+			if _, exists := pending[rec.Delivery.ID]; !exists {
+				order = append(order, rec.Delivery.ID)
+			}
+			pending[rec.Delivery.ID] = rec
+
+		case opUpdate:
+			queued, exists := pending[rec.Delivery.ID]
+			if !exists {
+				continue
+			}
+			if rec.Delivery.Status != StatusPending {
+				delete(pending, rec.Delivery.ID)
+				continue
+			}
+			queued.Delivery = rec.Delivery
+			pending[rec.Delivery.ID] = queued
+
+		default:
+			return nil, fmt.Errorf("unknown journal operation %q", rec.Op)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	records := make([]record, 0, len(pending))
+	for _, id := range order {
+		if rec, exists := pending[id]; exists {
+			records = append(records, rec)
+			delete(pending, id)
+		}
+	}
+
+	return records, nil
+}
+
+// rotate replaces the journal with a new file that only holds the specified
+// records. The new file is written next to the old one and renamed so a
+// crash never leaves a partially written journal behind.
+func (j *journal) rotate(records []record) error {
+	tmp := j.path + ".tmp"
+
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(f)
+	for _, rec := range records {
+		data, err := json.Marshal(rec)
+		if err != nil {
+			f.Close()
+			return err
+		}
+		if _, err := w.Write(append(data, '\n')); err != nil {
+			f.Close()
+			return err
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp, j.path); err != nil {
+		return err
+	}
+	if err := syncDir(filepath.Dir(j.path)); err != nil {
+		return err
+	}
+
+	// The old file handle points to the replaced file.
+	j.file.Close()
+
+	file, err := os.OpenFile(j.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	j.file = file
+	j.records = len(records)
+
+	return nil
+}
+
+// close closes the journal file.
+func (j *journal) close() error {
+	return j.file.Close()
+}
+
+// syncDir flushes the folder to disk so a file renamed into it survives a
+// crash.
+func syncDir(path string) error {
+	d, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return d.Sync()
+}
@@ -0,0 +1,638 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/zacksfF/FullStack-Blockchain/events"
+)
+
+// Package webhook delivers the typed events of a node to services that
+// can't hold a websocket open. Each hook names a URL and a filter, and the
+// events that match are sent to the URL as signed JSON posts. Deliveries are
+// journaled so they survive a restart, and a failed delivery is retried with
+// exponential backoff until it succeeds or runs out of attempts.
+
+// Set of HTTP headers sent with every delivery so the receiver can tell
+// which hook it's for and ignore a delivery it already processed.
+const (
+	HookHeader     = "X-Webhook-ID"
+	DeliveryHeader = "X-Webhook-Delivery"
+)
+
+// Set of statuses a delivery can have.
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	StatusFailed    = "failed"
+)
+
+// Set of default values used when the config leaves them unset.
+const (
+	DefaultTimeout     = 10 * time.Second
+	DefaultMaxAttempts = 10
+	DefaultBackoff     = time.Second
+	DefaultMaxBackoff  = 10 * time.Minute
+)
+
+// historySize is the number of finished deliveries remembered per hook so
+// their status can still be reported.
+const historySize = 100
+
+// ErrHookNotFound is returned when there is no hook with the id.
+var ErrHookNotFound = errors.New("webhook not found")
+
+// Hook represents a URL that is sent the events that match the topics and
+// accounts. An empty list of topics or accounts matches every topic or
+// account.
+type Hook struct {
+	ID       string   `json:"id"`
+	URL      string   `json:"url"`
+	Topics   []string `json:"topics,omitempty"`
+	Accounts []string `json:"accounts,omitempty"`
+}
+
+// filter returns the filter for the events of the hook.
+func (h Hook) filter() events.Filter {
+	return events.Filter{Topics: h.Topics, Accounts: h.Accounts}
+}
+
+// Config represents the settings for the webhooks. Sign is called to sign
+// every delivery with the body that is sent. The journal is not kept when
+// JournalPath is empty, so deliveries are lost on a restart.
+type Config struct {
+	Hooks       []Hook
+	JournalPath string
+	Client      *http.Client
+	Timeout     time.Duration // How long a single attempt can take.
+	MaxAttempts int           // Attempts before a delivery is marked as failed.
+	Backoff     time.Duration // Wait before the first retry, doubled after each retry.
+	MaxBackoff  time.Duration // Longest wait between retries.
+	Sign        func(r *http.Request, body []byte) error
+	EvHandler   func(v string, args ...any)
+}
+
+// Delivery represents an event being sent to a hook. NextAttempt is only
+// set for a pending delivery and Finished once it's delivered or failed.
+type Delivery struct {
+	ID          string     `json:"id"`
+	Hook        string     `json:"hook"`
+	Seq         uint64     `json:"seq"`
+	Topic       string     `json:"topic"`
+	Status      string     `json:"status"`
+	Attempts    int        `json:"attempts"`
+	StatusCode  int        `json:"status_code,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+	Created     time.Time  `json:"created"`
+	NextAttempt *time.Time `json:"next_attempt,omitempty"`
+	Finished    *time.Time `json:"finished,omitempty"`
+}
+
+// HookStatus represents the deliveries of a hook since the node started,
+// along with the deliveries still pending from before.
+type HookStatus struct {
+	ID            string     `json:"id"`
+	Topics        []string   `json:"topics,omitempty"`
+	Accounts      []string   `json:"accounts,omitempty"`
+	Pending       int        `json:"pending"`
+	Delivered     int        `json:"delivered"`
+	Failed        int        `json:"failed"`
+	LastError     string     `json:"last_error,omitempty"`
+	LastDelivered *time.Time `json:"last_delivered,omitempty"`
+}
+
+// hook maintains the state of a single hook.
+type hook struct {
+	Hook
+	wake    chan struct{}
+	pending []*Delivery
+	payload map[string][]byte
+	history []Delivery
+	status  HookStatus
+}
+
+// Webhooks maintains the set of hooks and sends them the events they want.
+type Webhooks struct {
+	mu          sync.Mutex
+	wg          sync.WaitGroup
+	ctx         context.Context
+	cancel      context.CancelFunc
+	hooks       map[string]*hook
+	order       []string
+	journal     *journal
+	client      *http.Client
+	timeout     time.Duration
+	maxAttempts int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+	sign        func(r *http.Request, body []byte) error
+	evHandler   func(v string, args ...any)
+}
+
+// New constructs the webhooks, restores the deliveries that were pending
+// when the node went down and starts delivering them. Deliveries for a hook
+// that is no longer configured are dropped.
+func New(cfg Config) (*Webhooks, error) {
+	ev := func(v string, args ...any) {
+		if cfg.EvHandler != nil {
+			cfg.EvHandler(v, args...)
+		}
+	}
+
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = DefaultMaxAttempts
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = DefaultBackoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = DefaultMaxBackoff
+	}
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	w := Webhooks{
+		ctx:         ctx,
+		cancel:      cancel,
+		hooks:       make(map[string]*hook),
+		client:      cfg.Client,
+		timeout:     cfg.Timeout,
+		maxAttempts: cfg.MaxAttempts,
+		backoff:     cfg.Backoff,
+		maxBackoff:  cfg.MaxBackoff,
+		sign:        cfg.Sign,
+		evHandler:   ev,
+	}
+
+	for _, h := range cfg.Hooks {
+		if err := validate(h); err != nil {
+			cancel()
+			return nil, fmt.Errorf("webhook %q: %w", h.ID, err)
+		}
+		if _, exists := w.hooks[h.ID]; exists {
+			cancel()
+			return nil, fmt.Errorf("webhook %q: duplicate id", h.ID)
+		}
+
+		w.hooks[h.ID] = &hook{
+			Hook:    h,
+			wake:    make(chan struct{}, 1),
+			payload: make(map[string][]byte),
+			status:  HookStatus{ID: h.ID, Topics: h.Topics, Accounts: h.Accounts},
+		}
+		w.order = append(w.order, h.ID)
+	}
+
+	if cfg.JournalPath != "" {
+		if err := w.restore(cfg.JournalPath); err != nil {
+			cancel()
+			return nil, fmt.Errorf("restoring webhook journal: %w", err)
+		}
+	}
+
+	for _, id := range w.order {
+		w.wg.Add(1)
+		go w.run(w.hooks[id])
+	}
+
+	return &w, nil
+}
+
+// Shutdown stops the deliveries and closes the journal. Pending deliveries
+// are sent the next time the webhooks are constructed from the journal.
+func (w *Webhooks) Shutdown() {
+	w.cancel()
+	w.wg.Wait()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.journal != nil {
+		w.journal.close()
+	}
+}
+
+// Publish queues a delivery of the event for every hook that wants it. The
+// delivery is journaled before Publish returns.
+func (w *Webhooks) Publish(ev events.Event) {
+	if len(w.hooks) == 0 {
+		return
+	}
+
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		w.evHandler("webhook: publish: seq[%d]: ERROR: %s", ev.Seq, err)
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := time.Now().UTC()
+
+	for _, id := range w.order {
+		h := w.hooks[id]
+		if !h.filter().Match(ev) {
+			continue
+		}
+
+		deliveryID, err := newDeliveryID()
+		if err != nil {
+			w.evHandler("webhook: publish: hook[%s]: ERROR: %s", id, err)
+			continue
+		}
+
+		d := Delivery{
+			ID:          deliveryID,
+			Hook:        id,
+			Seq:         ev.Seq,
+			Topic:       ev.Topic,
+			Status:      StatusPending,
+			Created:     now,
+			NextAttempt: &now,
+		}
+
+		w.write(record{Op: opQueue, Delivery: d, Payload: payload})
+		w.queue(h, d, payload)
+
+		select {
+		case h.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Status returns the status of every hook in the order they are configured.
+func (w *Webhooks) Status() []HookStatus {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	statuses := make([]HookStatus, len(w.order))
+	for i, id := range w.order {
+		h := w.hooks[id]
+		statuses[i] = h.status
+		statuses[i].Pending = len(h.pending)
+	}
+
+	return statuses
+}
+
+// Deliveries returns the pending deliveries for the hook followed by the
+// most recent finished deliveries, newest first.
+func (w *Webhooks) Deliveries(id string) ([]Delivery, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	h, exists := w.hooks[id]
+	if !exists {
+		return nil, ErrHookNotFound
+	}
+
+	deliveries := make([]Delivery, 0, len(h.pending)+len(h.history))
+	for _, d := range h.pending {
+		deliveries = append(deliveries, *d)
+	}
+	for i := len(h.history) - 1; i >= 0; i-- {
+		deliveries = append(deliveries, h.history[i])
+	}
+
+	return deliveries, nil
+}
+
+// =============================================================================
+
+// run delivers the pending deliveries for the hook as they come due until
+// the webhooks are shut down.
+func (w *Webhooks) run(h *hook) {
+	defer w.wg.Done()
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		d, payload, wait := w.next(h)
+		if d != nil && wait <= 0 {
+			w.attempt(h, *d, payload)
+
+			// An attempt cut short by a shutdown leaves the delivery due.
+			if w.ctx.Err() != nil {
+				return
+			}
+			continue
+		}
+
+		// Nothing is due, so wait until the next retry or a new delivery.
+		if d == nil {
+			wait = time.Hour
+		}
+		timer.Reset(wait)
+
+		select {
+		case <-w.ctx.Done():
+			return
+		case <-h.wake:
+		case <-timer.C:
+		}
+	}
+}
+
+// next returns the pending delivery for the hook that is due first and how
+// long until it's due.
+func (w *Webhooks) next(h *hook) (*Delivery, []byte, time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var due *Delivery
+	for _, d := range h.pending {
+		if due == nil || d.NextAttempt.Before(*due.NextAttempt) {
+			due = d
+		}
+	}
+
+	if due == nil {
+		return nil, nil, 0
+	}
+
+	return due, h.payload[due.ID], time.Until(*due.NextAttempt)
+}
+
+// attempt sends the delivery to the hook once and records the outcome.
+func (w *Webhooks) attempt(h *hook, d Delivery, payload []byte) {
+	code, err := w.send(h.Hook, d, payload)
+
+	// An attempt cut short by a shutdown doesn't count.
+	if w.ctx.Err() != nil {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	idx := slices.IndexFunc(h.pending, func(p *Delivery) bool { return p.ID == d.ID })
+	if idx < 0 {
+		return
+	}
+	pd := h.pending[idx]
+
+	now := time.Now().UTC()
+	pd.Attempts++
+	pd.StatusCode = code
+
+	switch {
+	case err == nil:
+		pd.Status = StatusDelivered
+		pd.LastError = ""
+		pd.NextAttempt = nil
+		pd.Finished = &now
+		h.status.LastDelivered = &now
+		w.evHandler("webhook: deliver: hook[%s]: delivery[%s]: seq[%d]: delivered", h.ID, pd.ID, pd.Seq)
+
+	case pd.Attempts >= w.maxAttempts:
+		pd.Status = StatusFailed
+		pd.LastError = err.Error()
+		pd.NextAttempt = nil
+		pd.Finished = &now
+		h.status.LastError = err.Error()
+		w.evHandler("webhook: deliver: hook[%s]: delivery[%s]: seq[%d]: failed: %s", h.ID, pd.ID, pd.Seq, err)
+
+	default:
+		pd.LastError = err.Error()
+		next := now.Add(w.retryAfter(pd.Attempts))
+		pd.NextAttempt = &next
+		h.status.LastError = err.Error()
+		w.evHandler("webhook: deliver: hook[%s]: delivery[%s]: retry[%d] in %v: %s", h.ID, pd.ID, pd.Attempts, next.Sub(now), err)
+	}
+
+	w.write(record{Op: opUpdate, Delivery: *pd})
+
+	if pd.Status != StatusPending {
+		w.finish(h, idx)
+	}
+}
+
+// send posts the payload to the URL of the hook and returns the status code
+// of the response. Any status other than 2xx is an error.
+func (w *Webhooks) send(h Hook, d Delivery, payload []byte) (int, error) {
+	ctx, cancel := context.WithTimeout(w.ctx, w.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HookHeader, h.ID)
+	req.Header.Set(DeliveryHeader, d.ID)
+
+	if w.sign != nil {
+		if err := w.sign(req, payload); err != nil {
+			return 0, fmt.Errorf("signing delivery: %w", err)
+		}
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	// Drain the body so the connection can be reused.
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// retryAfter returns how long to wait before the next attempt after the
+// specified number of attempts.
+func (w *Webhooks) retryAfter(attempts int) time.Duration {
+	wait := w.backoff
+	for range attempts - 1 {
+		wait *= 2
+		if wait >= w.maxBackoff {
+			return w.maxBackoff
+		}
+	}
+
+	return wait
+}
+
+// queue adds the delivery to the pending deliveries of the hook. The caller
+// must hold the lock.
+func (w *Webhooks) queue(h *hook, d Delivery, payload []byte) {
+	h.pending = append(h.pending, &d)
+	h.payload[d.ID] = payload
+}
+
+// finish moves the pending delivery at the index into the history of the
+// hook. The caller must hold the lock.
+func (w *Webhooks) finish(h *hook, idx int) {
+	d := h.pending[idx]
+
+	h.pending = slices.Delete(h.pending, idx, idx+1)
+	delete(h.payload, d.ID)
+
+	switch d.Status {
+	case StatusDelivered:
+		h.status.Delivered++
+	case StatusFailed:
+		h.status.Failed++
+	}
+
+	h.history = append(h.history, *d)
+	if len(h.history) > historySize {
+		h.history = h.history[1:]
+	}
+}
+
+// write appends the record to the journal and compacts the journal once it
+// mostly holds finished deliveries. The caller must hold the lock.
+func (w *Webhooks) write(rec record) {
+	if w.journal == nil {
+		return
+	}
+
+	if err := w.journal.write(rec); err != nil {
+		w.evHandler("webhook: journal: WARNING: %s", err)
+		return
+	}
+
+	if w.journal.records > max(minCompactRecords, 2*w.pendingCount()) {
+		if err := w.journal.rotate(w.pendingRecords()); err != nil {
+			w.evHandler("webhook: journal: compact: WARNING: %s", err)
+		}
+	}
+}
+
+// restore opens the journal, reloads the pending deliveries for the hooks
+// that are still configured and rewrites the journal to only hold those.
+func (w *Webhooks) restore(path string) error {
+	j, err := openJournal(path)
+	if err != nil {
+		return err
+	}
+
+	records, err := j.load()
+	if err != nil {
+		j.close()
+		return err
+	}
+
+	var restored int
+	for _, rec := range records {
+		h, exists := w.hooks[rec.Delivery.Hook]
+		if !exists {
+			continue
+		}
+
+		// Deliveries are retried right away after a restart.
+		now := time.Now().UTC()
+		rec.Delivery.NextAttempt = &now
+		w.queue(h, rec.Delivery, rec.Payload)
+		restored++
+	}
+
+	w.journal = j
+	if err := j.rotate(w.pendingRecords()); err != nil {
+		return err
+	}
+
+	w.evHandler("webhook: New: journal: restored deliveries[%d]", restored)
+
+	return nil
+}
+
+// pendingCount returns the number of pending deliveries for every hook. The
+// caller must hold the lock.
+func (w *Webhooks) pendingCount() int {
+	var n int
+	for _, h := range w.hooks {
+		n += len(h.pending)
+	}
+
+	return n
+}
+
+// pendingRecords returns the records that queue the pending deliveries for
+// every hook. The caller must hold the lock.
+func (w *Webhooks) pendingRecords() []record {
+	var records []record
+	for _, id := range w.order {
+		h := w.hooks[id]
+		for _, d := range h.pending {
+			records = append(records, record{Op: opQueue, Delivery: *d, Payload: h.payload[d.ID]})
+		}
+	}
+
+	return records
+}
+
+// validate checks the hook has an id, an absolute http or https URL and
+// only filters on known topics.
+func validate(h Hook) error {
+	if h.ID == "" {
+		return errors.New("id is required")
+	}
+
+	u, err := url.Parse(h.URL)
+	if err != nil {
+		return fmt.Errorf("parsing url: %w", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url %q must be an absolute http or https url", h.URL)
+	}
+
+	return h.filter().Validate()
+}
+
+// newDeliveryID returns a random id for a delivery.
+func newDeliveryID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hexutil.Encode(b), nil
+}
+
+// =============================================================================
+
+// Load reads the hooks saved at the specified path. A missing file is not
+// an error since a node doesn't need any hooks.
+func Load(path string) ([]Hook, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var hooks []Hook
+	if err := json.Unmarshal(data, &hooks); err != nil {
+		return nil, err
+	}
+
+	return hooks, nil
+}
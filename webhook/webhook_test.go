@@ -0,0 +1,265 @@
+package webhook_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/zacksfF/FullStack-Blockchain/blockchain/peer"
+	"github.com/zacksfF/FullStack-Blockchain/events"
+	"github.com/zacksfF/FullStack-Blockchain/webhook"
+)
+
+const accountID = "0xF01813E4B85e178A83e29B8E7bF26BD830a25f32"
+
+func Test_Deliver(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("Should be able to generate a key: %s", err)
+	}
+
+	// The receiver fails the first two attempts.
+	var mu sync.Mutex
+	var received []events.Event
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		nodeID, err := peer.VerifyRequest(r, body, time.Now())
+		if err != nil || nodeID != peer.NodeID(privateKey.PublicKey) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Header.Get(webhook.HookHeader) != "backoffice" || r.Header.Get(webhook.DeliveryHeader) == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if calls.Add(1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		var ev events.Event
+		json.Unmarshal(body, &ev)
+
+		mu.Lock()
+		received = append(received, ev)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	hooks, err := webhook.New(webhook.Config{
+		Hooks: []webhook.Hook{
+			{ID: "backoffice", URL: srv.URL, Topics: []string{events.TopicTxMined}, Accounts: []string{accountID}},
+		},
+		Backoff: 10 * time.Millisecond,
+		Sign: func(r *http.Request, body []byte) error {
+			return peer.SignRequest(r, body, privateKey)
+		},
+	})
+	if err != nil {
+		t.Fatalf("Should be able to construct the webhooks: %s", err)
+	}
+	defer hooks.Shutdown()
+
+	hooks.Publish(events.Event{Seq: 1, Topic: events.TopicNewBlock, Accounts: []string{accountID}})
+	hooks.Publish(events.Event{Seq: 2, Topic: events.TopicTxMined, Accounts: []string{accountID}})
+
+	waitFor(t, func() bool { return hooks.Status()[0].Delivered == 1 })
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(received) != 1 || received[0].Seq != 2 {
+		t.Fatalf("Should only deliver the event that matches the hook, got %+v", received)
+	}
+
+	deliveries, err := hooks.Deliveries("backoffice")
+	if err != nil {
+		t.Fatalf("Should be able to get the deliveries: %s", err)
+	}
+	if len(deliveries) != 1 || deliveries[0].Attempts != 3 || deliveries[0].Status != webhook.StatusDelivered {
+		t.Fatalf("Should get the delivery after three attempts, got %+v", deliveries)
+	}
+
+	if _, err := hooks.Deliveries("unknown"); err == nil {
+		t.Fatal("Should not be able to get the deliveries of an unknown hook")
+	}
+}
+
+func Test_Failed(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	hooks, err := webhook.New(webhook.Config{
+		Hooks:       []webhook.Hook{{ID: "backoffice", URL: srv.URL}},
+		MaxAttempts: 2,
+		Backoff:     10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Should be able to construct the webhooks: %s", err)
+	}
+	defer hooks.Shutdown()
+
+	hooks.Publish(events.Event{Seq: 1, Topic: events.TopicNewBlock})
+
+	waitFor(t, func() bool { return hooks.Status()[0].Failed == 1 })
+
+	status := hooks.Status()[0]
+	if status.Pending != 0 || status.LastError != "status 500" {
+		t.Fatalf("Should report the failure, got %+v", status)
+	}
+
+	deliveries, _ := hooks.Deliveries("backoffice")
+	if len(deliveries) != 1 || deliveries[0].Attempts != 2 || deliveries[0].StatusCode != http.StatusInternalServerError {
+		t.Fatalf("Should stop after the max attempts, got %+v", deliveries)
+	}
+}
+
+func Test_Restart(t *testing.T) {
+	var up atomic.Bool
+	var seqs []uint64
+	var mu sync.Mutex
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !up.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		var ev events.Event
+		json.NewDecoder(r.Body).Decode(&ev)
+
+		mu.Lock()
+		seqs = append(seqs, ev.Seq)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	cfg := webhook.Config{
+		Hooks:       []webhook.Hook{{ID: "backoffice", URL: srv.URL}},
+		JournalPath: filepath.Join(t.TempDir(), "webhooks.journal"),
+		Backoff:     time.Hour,
+	}
+
+	hooks, err := webhook.New(cfg)
+	if err != nil {
+		t.Fatalf("Should be able to construct the webhooks: %s", err)
+	}
+
+	hooks.Publish(events.Event{Seq: 7, Topic: events.TopicNewBlock})
+	waitFor(t, func() bool {
+		deliveries, _ := hooks.Deliveries("backoffice")
+		return len(deliveries) == 1 && deliveries[0].Attempts == 1
+	})
+	hooks.Shutdown()
+
+	// The delivery is waiting an hour to be retried, which is cut short by
+	// the restart.
+	up.Store(true)
+
+	hooks, err = webhook.New(cfg)
+	if err != nil {
+		t.Fatalf("Should be able to reconstruct the webhooks: %s", err)
+	}
+	defer hooks.Shutdown()
+
+	waitFor(t, func() bool { return hooks.Status()[0].Delivered == 1 })
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(seqs) != 1 || seqs[0] != 7 {
+		t.Fatalf("Should deliver the event from before the restart, got %v", seqs)
+	}
+
+	deliveries, _ := hooks.Deliveries("backoffice")
+	if deliveries[0].Attempts != 2 {
+		t.Fatalf("Should keep the attempts from before the restart, got %d", deliveries[0].Attempts)
+	}
+}
+
+func Test_JournalCorrupt(t *testing.T) {
+	cfg := webhook.Config{
+		Hooks:       []webhook.Hook{{ID: "backoffice", URL: "http://localhost:0"}},
+		JournalPath: filepath.Join(t.TempDir(), "webhooks.journal"),
+		Backoff:     time.Hour,
+	}
+
+	write := func(tail string) {
+		hooks, err := webhook.New(cfg)
+		if err != nil {
+			t.Fatalf("Should be able to construct the webhooks: %s", err)
+		}
+		hooks.Publish(events.Event{Seq: 1, Topic: events.TopicNewBlock})
+		hooks.Shutdown()
+
+		f, err := os.OpenFile(cfg.JournalPath, os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			t.Fatalf("Should be able to open the journal: %s", err)
+		}
+		f.WriteString(tail)
+		f.Close()
+	}
+
+	// A record cut short by a crash is the last thing in the journal.
+	write(`{"op":"update","delivery":{`)
+	hooks, err := webhook.New(cfg)
+	if err != nil {
+		t.Fatalf("Should ignore a partial record at the end of the journal: %s", err)
+	}
+	hooks.Shutdown()
+
+	// A record that can't be read with records after it is corruption.
+	write(`{"op":"update","delivery":{` + "\n" + `{"op":"queue","delivery":{"id":"1","hook":"backoffice"}}` + "\n")
+	if _, err := webhook.New(cfg); err == nil {
+		t.Fatal("Should fail on a corrupt record in the middle of the journal.")
+	}
+}
+
+func Test_Config(t *testing.T) {
+	bad := [][]webhook.Hook{
+		{{ID: "", URL: "http://localhost"}},
+		{{ID: "hook", URL: "localhost:8080"}},
+		{{ID: "hook", URL: "http://localhost", Topics: []string{"newThing"}}},
+		{{ID: "hook", URL: "http://localhost"}, {ID: "hook", URL: "http://localhost"}},
+	}
+
+	for _, hooks := range bad {
+		if _, err := webhook.New(webhook.Config{Hooks: hooks}); err == nil {
+			t.Fatalf("Should not be able to construct the webhooks with %+v", hooks)
+		}
+	}
+
+	hooks, err := webhook.Load(filepath.Join(t.TempDir(), "missing.json"))
+	if err != nil || hooks != nil {
+		t.Fatalf("Should get no hooks for a missing file, got %v: %v", hooks, err)
+	}
+}
+
+// =============================================================================
+
+// waitFor waits for the condition to be true.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("Should meet the condition before the deadline")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}